@@ -3,402 +3,251 @@ package main
 // apiserver runs an http server and handles incoming requests
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"mariners/team"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"mariners/apitoken"
+	"mariners/db"
 	"mariners/game"
+	"mariners/mpevent"
 	"mariners/player"
+	"mariners/queue"
+	"mariners/role"
+	"mariners/scoring"
+	"mariners/sms"
+	"mariners/team"
+	"mariners/tee"
 	"mariners/weather"
 
 	"github.com/gorilla/mux"
 )
 
-func RespondWithPlayer(w http.ResponseWriter, p player.Player) error {
-	j, err := json.MarshalIndent(p, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(j)
-	fmt.Fprintf(w, "\n")
-
-	return nil
-}
-
-func RespondWithWeather(w http.ResponseWriter, wt weather.Weather) error {
-	j, err := json.MarshalIndent(wt, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(j)
-	fmt.Fprintf(w, "\n")
-
-	return nil
-}
-
-func RespondWithPlayers(w http.ResponseWriter, p []player.Player) error {
-	j, err := json.MarshalIndent(p, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(j)
-	fmt.Fprintf(w, "\n")
-
-	return nil
-}
-
-func RespondWithGame(w http.ResponseWriter, g game.Game) error {
-	j, err := json.MarshalIndent(g, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(j)
-	fmt.Fprintf(w, "\n")
-
-	return nil
-}
-
-func RespondWithTeam(w http.ResponseWriter, t team.Team) error {
-	j, err := json.MarshalIndent(t, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(j)
-	fmt.Fprintf(w, "\n")
-
-	return nil
+// operation is one route of the API.  The router and the OpenAPI spec are
+// both built from operations, so they can't disagree about what the API
+// takes and returns.
+type operation struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+	Tag     string
+	Summary string
+	// Permission is what the caller needs to make the request, from both
+	// their token and their roles, or empty if any token will do.  Owner, if
+	// set, also lets callers without the role make it on their own things.
+	Permission string
+	Owner      ownerFunc
+	// Request is the type the body is decoded into, nil for no body.
+	Request interface{}
+	Query   []parameter
+	// Status is the status of a successful response, whose body is a
+	// Response, or empty if Response is nil.
+	Status   int
+	Response interface{}
+	// Errors are the error statuses the operation can return on top of the
+	// ones every operation can.
+	Errors []int
 }
 
-func RespondWithGames(w http.ResponseWriter, g []game.Game) error {
-	j, err := json.MarshalIndent(g, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(j)
-	fmt.Fprintf(w, "\n")
-
-	return nil
+// operations are the routes of the API.  Paths are singular, as they always
+// have been, so existing scripts like schema/api/add_players.sh keep working.
+var operations = []operation{
+	{"POST", "/player", AddPlayerHandler, "player", "Add a player", role.PlayersWrite, nil, playerRequest{}, nil, http.StatusCreated, player.Player{}, nil},
+	{"GET", "/player", GetPlayersHandler, "player", "List the players", "", nil, nil, nil, http.StatusOK, player.Players{}, nil},
+	{"GET", "/player/{id}", GetPlayerHandler, "player", "Get a player", "", nil, nil, nil, http.StatusOK, player.Player{}, nil},
+	{"PUT", "/player/{id}", UpdatePlayerHandler, "player", "Update a player", role.PlayersWrite, self, playerRequest{}, nil, http.StatusOK, player.Player{}, nil},
+	{"DELETE", "/player/{id}", DeletePlayerHandler, "player", "Delete a player", role.PlayersWrite, nil, nil, nil, http.StatusNoContent, nil, nil},
+	{"GET", "/player/{id}/preferences", GetPreferencesHandler, "player", "Get a player's message preferences", "", nil, nil, nil, http.StatusOK, player.Preferences{}, nil},
+	{"PUT", "/player/{id}/preferences", UpdatePreferencesHandler, "player", "Update a player's message preferences", role.PlayersWrite, self, preferencesRequest{}, nil, http.StatusOK, player.Preferences{}, nil},
+	{"GET", "/player/{id}/roles", GetPlayerRolesHandler, "player", "List a player's roles", "", nil, nil, nil, http.StatusOK, []roleBody{}, nil},
+	{"PUT", "/player/{id}/roles/{rid}", AddPlayerRoleHandler, "player", "Give a player a role", role.PlayersWrite, nil, nil, nil, http.StatusOK, []roleBody{}, nil},
+	{"DELETE", "/player/{id}/roles/{rid}", DeletePlayerRoleHandler, "player", "Take a role from a player", role.PlayersWrite, nil, nil, nil, http.StatusNoContent, nil, nil},
+
+	{"GET", "/token", GetTokensHandler, "token", "List the caller's API tokens", "", nil, nil, nil, http.StatusOK, apitoken.Tokens{}, nil},
+	{"POST", "/token", AddTokenHandler, "token", "Issue the caller a new API token", "", nil, tokenRequest{}, nil, http.StatusCreated, issuedToken{}, []int{http.StatusForbidden}},
+	{"DELETE", "/token/{id}", DeleteTokenHandler, "token", "Revoke an API token", "", nil, nil, nil, http.StatusNoContent, nil, nil},
+
+	{"POST", "/role", AddRoleHandler, "role", "Add a role", role.RolesManage, nil, roleRequest{}, nil, http.StatusCreated, roleBody{}, []int{http.StatusConflict}},
+	{"GET", "/role", GetRolesHandler, "role", "List the roles", "", nil, nil, nil, http.StatusOK, []roleBody{}, nil},
+	{"GET", "/role/{id}", GetRoleHandler, "role", "Get a role", "", nil, nil, nil, http.StatusOK, roleBody{}, nil},
+	{"PUT", "/role/{id}", UpdateRoleHandler, "role", "Rename a role", role.RolesManage, nil, roleRequest{}, nil, http.StatusOK, roleBody{}, []int{http.StatusConflict}},
+	{"DELETE", "/role/{id}", DeleteRoleHandler, "role", "Delete a role", role.RolesManage, nil, nil, nil, http.StatusNoContent, nil, []int{http.StatusConflict}},
+	{"PUT", "/role/{id}/permissions", UpdateRolePermissionsHandler, "role", "Change what a role allows", role.RolesManage, nil, permissionsRequest{}, nil, http.StatusOK, roleBody{}, []int{http.StatusConflict}},
+	{"GET", "/permission", GetPermissionsHandler, "role", "List the permissions a role can grant", "", nil, nil, nil, http.StatusOK, []role.Permission{}, nil},
+
+	{"POST", "/event", AddEventHandler, "event", "Add an event", role.EventsManage, anyPlayer, eventRequest{}, nil, http.StatusCreated, mpevent.Event{}, []int{http.StatusConflict}},
+	{"GET", "/event", GetEventsHandler, "event", "List the events", "", nil, nil, nil, http.StatusOK, mpevent.Events{}, nil},
+	{"GET", "/event/{id}", GetEventHandler, "event", "Get an event", "", nil, nil, nil, http.StatusOK, mpevent.Event{}, nil},
+	{"PUT", "/event/{id}", UpdateEventHandler, "event", "Update an event", role.EventsManage, eventOwner, eventRequest{}, nil, http.StatusOK, mpevent.Event{}, nil},
+	{"DELETE", "/event/{id}", DeleteEventHandler, "event", "Delete an event", role.EventsManage, eventOwner, nil, nil, http.StatusNoContent, nil, nil},
+	{"GET", "/event/{id}/members", GetMembersHandler, "event", "List an event's members", "", nil, nil, nil, http.StatusOK, mpevent.EventMembers{}, nil},
+	{"POST", "/event/{id}/members", AddMemberHandler, "event", "Add a member to an event", role.EventsManage, eventOwner, memberRequest{}, nil, http.StatusCreated, mpevent.EventMember{}, []int{http.StatusConflict}},
+	{"PUT", "/event/{id}/members/{pid}", UpdateMemberHandler, "event", "Update an event member", role.EventsManage, eventOwner, memberRequest{}, nil, http.StatusOK, mpevent.EventMember{}, nil},
+	{"DELETE", "/event/{id}/members/{pid}", DeleteMemberHandler, "event", "Remove a member from an event", role.EventsManage, eventOwnerOrSelf, nil, nil, http.StatusNoContent, nil, nil},
+	{"GET", "/event/{id}/messages", GetEventMessagesHandler, "event", "List the messages sent to an event", "", nil, nil, nil, http.StatusOK, mpevent.EventMessages{}, nil},
+	{"POST", "/event/{id}/messages", AddEventMessageHandler, "event", "Text an event's members", role.EventsManage, eventOwner, eventMessageRequest{}, nil, http.StatusCreated, mpevent.EventMessage{}, nil},
+
+	{"POST", "/message", AddMessageHandler, "message", "Text the league or the tournament players", role.MessagesSend, nil, messageRequest{}, nil, http.StatusCreated, queue.Blast{}, nil},
+	{"GET", "/message", GetMessagesHandler, "message", "List the most recent messages", "", nil, nil, []parameter{limitParameter}, http.StatusOK, queue.Blasts{}, nil},
+	{"GET", "/message/{id}", GetMessageHandler, "message", "Get a message and where it's got to", "", nil, nil, nil, http.StatusOK, queue.Blast{}, nil},
+
+	{"POST", "/tee", AddTeeHandler, "tee", "Add a ninth tee", role.GamesManage, nil, teeRequest{}, nil, http.StatusCreated, tee.Tee{}, []int{http.StatusConflict}},
+	{"GET", "/tee", GetTeesHandler, "tee", "List the ninth tees", "", nil, nil, nil, http.StatusOK, tee.Tees{}, nil},
+	{"GET", "/tee/{id}", GetTeeHandler, "tee", "Get a ninth tee", "", nil, nil, nil, http.StatusOK, tee.Tee{}, nil},
+	{"PUT", "/tee/{id}", UpdateTeeHandler, "tee", "Rename a ninth tee", role.GamesManage, nil, teeRequest{}, nil, http.StatusOK, tee.Tee{}, []int{http.StatusConflict}},
+	{"DELETE", "/tee/{id}", DeleteTeeHandler, "tee", "Delete a ninth tee", role.GamesManage, nil, nil, nil, http.StatusNoContent, nil, []int{http.StatusConflict}},
+
+	{"POST", "/game", AddGameHandler, "game", "Add today's game", role.GamesManage, nil, gameRequest{}, nil, http.StatusCreated, game.Game{}, []int{http.StatusConflict}},
+	{"GET", "/game", GetGamesHandler, "game", "List the games", "", nil, nil, nil, http.StatusOK, game.Games{}, nil},
+	{"GET", "/game/bydate/{date}", GetGameByDateHandler, "game", "Get the game played on a day", "", nil, nil, nil, http.StatusOK, game.Game{}, nil},
+	{"GET", "/game/{id}", GetGameHandler, "game", "Get a game", "", nil, nil, nil, http.StatusOK, game.Game{}, nil},
+	{"PUT", "/game/{id}", UpdateGameHandler, "game", "Update a game", role.GamesManage, nil, gameRequest{}, nil, http.StatusOK, game.Game{}, nil},
+	{"DELETE", "/game/{id}", DeleteGameHandler, "game", "Delete a game", role.GamesManage, nil, nil, nil, http.StatusNoContent, nil, []int{http.StatusConflict}},
+	{"POST", "/game/{id}/cancel", CancelGameHandler, "game", "Cancel or postpone a game", role.GamesManage, nil, cancelRequest{}, nil, http.StatusOK, game.Game{}, []int{http.StatusConflict}},
+	{"GET", "/game/{id}/checkins", GetCheckinsHandler, "game", "List a game's check-ins", "", nil, nil, nil, http.StatusOK, game.Checkins{}, nil},
+	{"POST", "/game/{id}/checkins", AddCheckinHandler, "game", "Check a player in", role.GamesManage, selfInBody, checkinRequest{}, nil, http.StatusCreated, game.Checkin{}, []int{http.StatusConflict}},
+	{"DELETE", "/game/{id}/checkins/{pid}", DeleteCheckinHandler, "game", "Check a player out", role.GamesManage, selfPid, nil, []parameter{lateParameter}, http.StatusNoContent, nil, []int{http.StatusConflict}},
+	{"GET", "/game/{id}/mystery", GetMysteryHandler, "game", "Get the mystery hole result", "", nil, nil, nil, http.StatusOK, scoring.MysteryResult{}, nil},
+	{"POST", "/game/{id}/mystery", DrawMysteryHandler, "game", "Draw the mystery hole", role.GamesManage, nil, nil, nil, http.StatusCreated, game.Mystery{}, []int{http.StatusConflict}},
+
+	{"GET", "/game/{id}/teams", GetTeamsHandler, "team", "List a game's teams", "", nil, nil, nil, http.StatusOK, []drawnTeam{}, nil},
+	{"POST", "/game/{id}/teams", AddTeamHandler, "team", "Add an empty team to a game", role.GamesManage, nil, nil, nil, http.StatusCreated, drawnTeam{}, []int{http.StatusConflict}},
+	{"DELETE", "/game/{id}/teams", DeleteTeamsHandler, "team", "Delete a game's teams", role.GamesManage, nil, nil, nil, http.StatusNoContent, nil, []int{http.StatusConflict}},
+	{"POST", "/game/{id}/teams/draw", DrawTeamsHandler, "team", "Draw teams from the players checked in", role.GamesManage, nil, drawRequest{}, nil, http.StatusCreated, []drawnTeam{}, []int{http.StatusConflict}},
+	{"PUT", "/game/{id}/teams/lock", LockTeamsHandler, "team", "Lock the teams and text them out", role.GamesManage, nil, nil, nil, http.StatusOK, []drawnTeam{}, []int{http.StatusConflict}},
+	{"GET", "/team/{id}", GetTeamHandler, "team", "Get a team", "", nil, nil, nil, http.StatusOK, drawnTeam{}, nil},
+	{"POST", "/team/{id}/members", AddTeamMemberHandler, "team", "Add a player or a ghost to a team", role.GamesManage, nil, teamMemberRequest{}, nil, http.StatusCreated, team.TeamMember{}, []int{http.StatusConflict}},
+
+	{"GET", "/game/{id}/scores", GetScoresHandler, "score", "List a game's scores", "", nil, nil, nil, http.StatusOK, scoring.Scores{}, nil},
+	{"POST", "/game/{id}/scores", AddScoreHandler, "score", "Add a player's card", role.GamesManage, selfInBody, scoreRequest{}, nil, http.StatusCreated, scoring.Score{}, []int{http.StatusConflict}},
+	{"GET", "/game/{id}/scores/{pid}", GetScoreHandler, "score", "Get a player's card", "", nil, nil, nil, http.StatusOK, scoring.Score{}, nil},
+	{"PUT", "/game/{id}/scores/{pid}", UpdateScoreHandler, "score", "Update a player's card", role.GamesManage, selfPid, scoreRequest{}, nil, http.StatusOK, scoring.Score{}, nil},
+	{"DELETE", "/game/{id}/scores/{pid}", DeleteScoreHandler, "score", "Delete a player's card", role.GamesManage, selfPid, nil, nil, http.StatusNoContent, nil, nil},
+	{"GET", "/averages", GetAveragesHandler, "score", "List the players' averages", "", nil, nil, nil, http.StatusOK, scoring.MPAverages{}, nil},
+	{"GET", "/averages/weather", GetWeatherStatsHandler, "score", "Set scores against the weather they were played in", "", nil, nil, nil, http.StatusOK, scoring.WeatherStats{}, nil},
+
+	{"GET", "/game/{id}/weather/revisions", GetWeatherRevisionsHandler, "weather", "List every fetch of a game's forecast", "", nil, nil, nil, http.StatusOK, weather.Revisions{}, nil},
+	{"POST", "/game/{id}/weather", RefreshGameWeatherHandler, "weather", "Fetch a game's forecast again", role.GamesManage, nil, nil, nil, http.StatusOK, weather.WeatherHours{}, nil},
+	{"GET", "/game/{id}/weather/observed", GetGameObservedHandler, "weather", "List the weather observed during a game", "", nil, nil, nil, http.StatusOK, weather.WeatherHours{}, nil},
+	{"POST", "/game/{id}/weather/observed", RecordGameWeatherHandler, "weather", "Record the weather observed during a game", role.GamesManage, nil, nil, nil, http.StatusOK, weather.WeatherHours{}, []int{http.StatusConflict}},
+	{"GET", "/weather/bydate/{date}", GetWeatherByDateHandler, "weather", "Get the forecast for a day", "", nil, nil, nil, http.StatusOK, weather.WeatherHours{}, nil},
+	{"GET", "/weather/{id}", GetWeatherHandler, "weather", "Get one hour of a forecast", "", nil, nil, nil, http.StatusOK, weather.Weather{}, nil},
 }
 
-func AddPlayerHandler(w http.ResponseWriter, r *http.Request) {
-	p := player.Player{}
+// routes builds the API from operations.  Every request needs an API token,
+// and the operation's permission, and is checked against the spec before
+// it reaches the handler.
+func routes() *mux.Router {
+	r := mux.NewRouter()
+	r.Use(authenticate)
 
-	err := json.NewDecoder(r.Body).Decode(&p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	for _, op := range operations {
+		r.Handle(op.Path, authorize(op, api.handler(op))).Methods(op.Method).Name(op.key())
 	}
 
-	err = player.AddPlayer(&p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, r, notFoundf("no route for %s", r.URL.Path))
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusMethodNotAllowed, errorBody{Error: fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path)})
+	})
 
-	err = RespondWithPlayer(w, p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	return r
 }
 
-func UpdatePlayerHandler(w http.ResponseWriter, r *http.Request) {
-	strid := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(strid)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	p := player.Player{}
-
-	err = json.NewDecoder(r.Body).Decode(&p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	err = player.UpdatePlayer(int64(id), &p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = RespondWithPlayer(w, p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+// key names the operation by method and path, as in "GET /player/{id}".
+func (op operation) key() string {
+	return op.Method + " " + op.Path
 }
 
-func DeletePlayerHandler(w http.ResponseWriter, r *http.Request) {
-	strid := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(strid)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	err = player.DeletePlayer(int64(id))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Player %d deleted.\n", id)
-}
-
-func GetPlayerHandler(w http.ResponseWriter, r *http.Request) {
-	strid := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(strid)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	p := player.Player{}
-
-	err = player.GetPlayer(int64(id), &p)
-	switch {
-	case err == sql.ErrNoRows:
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	case err != nil:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	default:
-		err = RespondWithPlayer(w, p)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
 	}
+	return fallback
 }
 
-func GetPlayersHandler(w http.ResponseWriter, r *http.Request) {
-	p, err := player.GetPlayers()
-	switch {
-	case err == sql.ErrNoRows:
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	case err != nil:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	default:
-		err = RespondWithPlayers(w, p)
+func main() {
+	spec := flag.Bool("spec", false, "write the OpenAPI spec to stdout and exit")
+	issue := flag.Int64("issue", 0, "issue an API token to the player with `id` and exit")
+	name := flag.String("name", "", "the `name` of the token -issue makes")
+	scopes := flag.String("scopes", "", "the comma separated `scopes` of the token -issue makes")
+	flag.Parse()
+
+	if *spec {
+		y, err := api.yaml(operations)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("Could not generate the spec: %s", err)
 		}
-	}
-}
-
-func AddWeatherHandler(w http.ResponseWriter, r *http.Request) {
-	wt := weather.Weather{}
-
-	err := wt.AddWeather()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		os.Stdout.Write(y)
+		os.Exit(0)
 	}
 
-	err = RespondWithWeather(w, wt)
+	var err error
+	db.Con, err = db.DBConnection()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Fatalf("Could not connect to database: %s", err)
 	}
-}
+	defer db.Con.Close()
 
-func GetWeatherHandler(w http.ResponseWriter, r *http.Request) {
-	strid := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(strid)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	wt := weather.Weather{}
-	err = wt.GetWeather(int64(id))
-	switch {
-	case err == sql.ErrNoRows:
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	case err != nil:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	default:
-		err = RespondWithWeather(w, wt)
+	if getEnv("MPMIGRATE", "true") == "true" {
+		err = db.Migrate(db.Con, db.Dialect, -1)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("Could not migrate database: %s", err)
 		}
 	}
-}
 
-func GetWeatherByDateHandler(w http.ResponseWriter, r *http.Request) {
-	sdate := mux.Vars(r)["date"]
-
-	wt := weather.Weather{}
-	err := wt.GetWeatherByDate(sdate)
-	switch {
-	case err == sql.ErrNoRows:
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	case err != nil:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	default:
-		err = RespondWithWeather(w, wt)
+	// The first token has to come from somewhere other than the API.
+	if *issue != 0 {
+		err = issueToken(context.Background(), *issue, *name, *scopes)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("Could not issue a token: %s", err)
 		}
+		os.Exit(0)
 	}
-}
 
-func GetGameByDateHandler(w http.ResponseWriter, r *http.Request) {
-	sdate := mux.Vars(r)["date"]
-
-	g := game.Game{}
-	err := g.GetGameByDate(sdate)
-	switch {
-	case err == sql.ErrNoRows:
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	case err != nil:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	default:
-		err = RespondWithGame(w, g)
+	if v := getEnv("MPTEAMSIZE", ""); v != "" {
+		teamSize, err = strconv.Atoi(v)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("MPTEAMSIZE: %s", err)
 		}
 	}
-}
-
-func AddGameHandler(w http.ResponseWriter, r *http.Request) {
-	g := game.Game{}
-
-	err := json.NewDecoder(r.Body).Decode(&g)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	err = g.AddGame()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = RespondWithGame(w, g)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
-
-func GetGameHandler(w http.ResponseWriter, r *http.Request) {
-	strid := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(strid)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	g := game.Game{}
-
-	err = g.GetGame(int64(id))
-	switch {
-	case err == sql.ErrNoRows:
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	case err != nil:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	default:
-		err = RespondWithGame(w, g)
+	if v := getEnv("MPPAR", ""); v != "" {
+		par, err = scoring.ParseHoles(v)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("MPPAR: %s", err)
 		}
 	}
-}
 
-func AddTeamHandler(w http.ResponseWriter, r *http.Request) {
-	strgid := mux.Vars(r)["id"]
-	gid, err := strconv.Atoi(strgid)
+	// Texts are queued here and sent by the ui's worker.
+	err = sms.Configure()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		log.Fatalf("Could not configure messaging: %s", err)
 	}
 
-	t := team.Team{}
-	err = team.AddTeam(int64(gid), &t)
+	err = weather.Configure()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		log.Printf("Could not configure the weather provider: %s", err)
 	}
-	err = RespondWithTeam(w, t)
+
+	err = game.Configure()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Fatalf("Could not configure games: %s", err)
 	}
-}
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	}
-	return fallback
-}
+	// Responses are only checked against the spec when asked, since it
+	// means holding each one until it's complete.
+	validateResponses = getEnv("MPAPIVALIDATE", "") == "responses"
 
-func main() {
 	listenport := getEnv("listenport", "8080")
 
-	r := mux.NewRouter()
-
-	r.HandleFunc("/player", AddPlayerHandler).Methods("POST")
-	r.HandleFunc("/player", GetPlayersHandler).Methods("GET")
-	r.HandleFunc("/player/{id}", GetPlayerHandler).Methods("GET")
-	r.HandleFunc("/player/{id}", UpdatePlayerHandler).Methods("PUT")
-	r.HandleFunc("/player/{id}", DeletePlayerHandler).Methods("DELETE")
-
-	r.HandleFunc("/game", AddGameHandler).Methods("POST")
-	r.HandleFunc("/game/{id}", GetGameHandler).Methods("GET")
-	r.HandleFunc("/game/bydate/{date}", GetGameByDateHandler).Methods("GET")
-
-	r.HandleFunc("/weather", AddWeatherHandler).Methods("POST")
-	r.HandleFunc("/weather/{id}", GetWeatherHandler).Methods("GET")
-	r.HandleFunc("/weather/bydate/{date}", GetWeatherByDateHandler).Methods("GET")
-
-	r.HandleFunc("/team/{gameid}", AddTeamHandler).Methods("POST")
-
+	r := routes()
 	http.Handle("/", r)
 
 	srv := &http.Server{
@@ -410,3 +259,27 @@ func main() {
 
 	log.Fatal(srv.ListenAndServe())
 }
+
+// issueToken issues player id a token and prints its secret.
+func issueToken(ctx context.Context, id int64, name, scopes string) error {
+	p := player.Player{}
+	err := p.GetPlayerByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	ss := make([]string, 0)
+	for _, s := range strings.Split(scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			ss = append(ss, s)
+		}
+	}
+
+	t, secret, err := apitoken.Issue(ctx, p, name, ss)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Token %d for %s with scopes %q:\n%s\n", t.ID, p.PreferredName, t.Scopes, secret)
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"mariners/apitoken"
+	"mariners/player"
+	"mariners/role"
+)
+
+// caller is who a request comes from: the token it carried and its player.
+type caller struct {
+	Token  apitoken.Token
+	Player player.Player
+}
+
+type callerKey struct{}
+
+// getCaller returns the caller authenticate found for r.
+func getCaller(r *http.Request) caller {
+	c, _ := r.Context().Value(callerKey{}).(caller)
+
+	return c
+}
+
+// authenticate resolves the caller from the request's bearer token, and
+// refuses requests without a good one.  It runs on every route.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := r.Header.Get("Authorization")
+		if !strings.HasPrefix(h, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mplinksters"`)
+			respondError(w, r, unauthorizedf("an API token is required"))
+			return
+		}
+
+		t, p, err := apitoken.Authenticate(r.Context(), strings.TrimSpace(strings.TrimPrefix(h, "Bearer ")))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mplinksters", error="invalid_token"`)
+			respondError(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), callerKey{}, caller{Token: t, Player: p})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// can reports whether the caller's token and roles both allow perm.
+func (c caller) can(perm string) bool {
+	return c.Token.Has(perm) && c.Player.Can(perm)
+}
+
+// checkAssign refuses to give or take role id unless the caller can manage
+// players and has every permission the role grants, so no one can hand out
+// more than they have themselves.
+func (c caller) checkAssign(id int64, m role.Matrix) error {
+	if !c.can(role.PlayersWrite) {
+		return forbiddenf("changing roles needs the %s permission", role.PlayersWrite)
+	}
+	for _, perm := range m[id] {
+		if !c.can(perm) {
+			return forbiddenf("role %d grants %s, which the caller doesn't have", id, perm)
+		}
+	}
+
+	return nil
+}
+
+// ownerFunc reports whether the request is on something the caller owns, so
+// they can make it without the operation's permission coming from a role.
+type ownerFunc func(r *http.Request, c caller) bool
+
+// self owns the player named by path variable id.
+func self(r *http.Request, c caller) bool {
+	id, err := pathID(r, "id")
+
+	return err == nil && id == c.Player.ID
+}
+
+// selfPid owns the player named by path variable pid, like their own card.
+func selfPid(r *http.Request, c caller) bool {
+	pid, err := pathID(r, "pid")
+
+	return err == nil && pid == c.Player.ID
+}
+
+// eventOwner owns the event named by path variable id.
+func eventOwner(r *http.Request, c caller) bool {
+	e, err := getEvent(r)
+
+	return err == nil && e.Owner.ID == c.Player.ID
+}
+
+// eventOwnerOrSelf is the event's owner, or the member leaving it.
+func eventOwnerOrSelf(r *http.Request, c caller) bool {
+	return selfPid(r, c) || eventOwner(r, c)
+}
+
+// anyPlayer lets anyone add an event, as long as it's theirs.
+// AddEventHandler checks the owner.
+func anyPlayer(r *http.Request, c caller) bool {
+	return true
+}
+
+// selfInBody lets anyone check themselves in or add their own card, which
+// name the player in the body.  AddCheckinHandler and AddScoreHandler check
+// the player.
+func selfInBody(r *http.Request, c caller) bool {
+	return true
+}
+
+// authorize refuses a request unless the caller's token carries op's
+// permission, and either their roles grant it or op.Owner says the request
+// is on their own things.
+func authorize(op operation, next http.Handler) http.Handler {
+	if op.Permission == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := getCaller(r)
+		if !c.Token.Has(op.Permission) {
+			respondError(w, r, forbiddenf("%s needs a token with %s", op.key(), op.Permission))
+			return
+		}
+		if !c.Player.Can(op.Permission) && (op.Owner == nil || !op.Owner(r, c)) {
+			respondError(w, r, forbiddenf("%s needs the %s permission", op.key(), op.Permission))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenRequest is the body of a token POST.  The new token is the caller's,
+// and can't carry a scope the caller's own token doesn't.
+type tokenRequest struct {
+	Name   string   `json:"name" api:"required,minLength=1,maxLength=45"`
+	Scopes []string `json:"scopes" api:"enum=players:write|roles:manage|events:manage|messages:send|games:manage|site:admin"`
+}
+
+func (tr *tokenRequest) validate() error {
+	if strings.TrimSpace(tr.Name) == "" {
+		return invalidf("name is required")
+	}
+
+	return nil
+}
+
+// issuedToken is a new token with its secret, which is never shown again.
+type issuedToken struct {
+	apitoken.Token
+	Secret string `json:"secret"`
+}
+
+// getToken loads the token named by path variable id, as long as it's the
+// caller's or the caller can manage players.
+func getToken(r *http.Request) (apitoken.Token, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return apitoken.Token{}, err
+	}
+
+	t, err := apitoken.GetToken(r.Context(), id)
+	if err != nil {
+		return t, err
+	}
+
+	c := getCaller(r)
+	if t.PlayerID != c.Player.ID && !c.can(role.PlayersWrite) {
+		return t, notFoundf("no token %d", id)
+	}
+
+	return t, nil
+}
+
+func GetTokensHandler(w http.ResponseWriter, r *http.Request) {
+	ts, err := apitoken.GetTokens(r.Context(), getCaller(r).Player.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, ts)
+}
+
+func AddTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tr := tokenRequest{}
+	err := decode(r, &tr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	c := getCaller(r)
+	for _, s := range tr.Scopes {
+		if !c.Token.Has(s) {
+			respondError(w, r, forbiddenf("a token can't be issued with %s by one without it", s))
+			return
+		}
+	}
+
+	t, secret, err := apitoken.Issue(r.Context(), c.Player, tr.Name, tr.Scopes)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, issuedToken{Token: t, Secret: secret})
+}
+
+func DeleteTokenHandler(w http.ResponseWriter, r *http.Request) {
+	t, err := getToken(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = apitoken.Revoke(r.Context(), t.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
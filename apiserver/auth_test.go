@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"mariners/apitoken"
+	"mariners/db/dbtest"
+	"mariners/player"
+	"mariners/role"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+var allScopes = []string{role.PlayersWrite, role.RolesManage, role.EventsManage, role.MessagesSend, role.GamesManage, role.SiteAdmin}
+
+// authorized reports whether authorize let c make op's request r.
+func authorized(op operation, r *http.Request, c caller) bool {
+	w := httptest.NewRecorder()
+	r = r.WithContext(context.WithValue(r.Context(), callerKey{}, c))
+	authorize(op, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})).ServeHTTP(w, r)
+
+	return w.Code == http.StatusTeapot
+}
+
+func TestAuthorizeRoles(t *testing.T) {
+	ctx := context.Background()
+	dbtest.Open(t)
+	role.SetStore(nil)
+
+	m, err := role.GetMatrix(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := role.GetRoles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"Administrator":  allScopes,
+		"User":           nil,
+		"Game Manager":   {role.GamesManage},
+		"Communications": {role.MessagesSend},
+		"Tournament":     nil,
+	}
+
+	for id, name := range rs {
+		perms, ok := want[name]
+		if !ok {
+			continue
+		}
+		c := caller{Token: apitoken.Token{Scopes: allScopes}, Player: player.Player{ID: 2, Permissions: m[id]}}
+		for _, p := range role.Permissions {
+			allowed := false
+			for _, wp := range perms {
+				allowed = allowed || wp == p.Name
+			}
+			op := operation{Method: "GET", Path: "/test", Permission: p.Name}
+			if got := authorized(op, httptest.NewRequest("GET", "/test", nil), c); got != allowed {
+				t.Errorf("%s with %s: %t, want %t", name, p.Name, got, allowed)
+			}
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	admin := player.Player{ID: 1, Permissions: allScopes}
+	user := player.Player{ID: 2}
+	card := func(pid int64) *http.Request {
+		r := httptest.NewRequest("DELETE", "/game/1/scores/"+strconv.FormatInt(pid, 10), nil)
+		return mux.SetURLVars(r, map[string]string{"id": "1", "pid": strconv.FormatInt(pid, 10)})
+	}
+	open := operation{Method: "GET", Path: "/game"}
+	managed := operation{Method: "DELETE", Path: "/game/{id}/scores/{pid}", Permission: role.GamesManage}
+	owned := managed
+	owned.Owner = selfPid
+
+	tests := []struct {
+		name   string
+		op     operation
+		r      *http.Request
+		scopes []string
+		p      player.Player
+		want   bool
+	}{
+		{"open to any token", open, card(2), nil, user, true},
+		{"role and scope", managed, card(2), allScopes, admin, true},
+		{"role without the scope", managed, card(2), []string{role.PlayersWrite}, admin, false},
+		{"scope without the role", managed, card(2), allScopes, user, false},
+		{"own card", owned, card(2), allScopes, user, true},
+		{"own card without the scope", owned, card(2), nil, user, false},
+		{"another's card", owned, card(3), allScopes, user, false},
+		{"another's card with the role", owned, card(3), allScopes, admin, true},
+	}
+	for _, tt := range tests {
+		c := caller{Token: apitoken.Token{Scopes: tt.scopes}, Player: tt.p}
+		if got := authorized(tt.op, tt.r, c); got != tt.want {
+			t.Errorf("%s: %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
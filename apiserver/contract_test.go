@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mariners/apitoken"
+	"mariners/db"
+	"mariners/game"
+	"mariners/player"
+	"mariners/role"
+	"mariners/sms"
+	"mariners/tee"
+	"mariners/weather"
+
+	"github.com/gorilla/mux"
+)
+
+// contractCall is one request of the contract run and the status it should
+// get.  {day} in the path is replaced with the day of the seeded game.  Calls
+// are made with the administrator's token unless Token names another of
+// contractTokens, or is "player N" for a token of player N's, issued the first
+// time it's used with every scope they can hold.
+type contractCall struct {
+	Method string
+	Path   string
+	Body   string
+	Status int
+	Token  string
+}
+
+// contractTokens are the secrets of the tokens seedContract issues, by name.
+// "none" sends no token and "bogus" one that doesn't exist.
+var contractTokens = map[string]string{
+	"bogus": "mpat_bogus",
+}
+
+// contractCalls work through every operation on a database holding only an
+// administrator with two tokens, one with every scope and one with none, a
+// tee, a game played yesterday, so check-in is closed and the mystery hole can
+// be drawn, and an hour of its forecast.  Forecasts come from the captured ones
+// built into the weather package.
+var contractCalls = []contractCall{
+	{Method: "GET", Path: "/player", Status: 401, Token: "none"},
+	{Method: "GET", Path: "/player", Status: 401, Token: "bogus"},
+	{Method: "GET", Path: "/player", Status: 200, Token: "reader"},
+	{Method: "POST", Path: "/tee", Body: `{"name":"Green"}`, Status: 403, Token: "reader"},
+	{Method: "POST", Path: "/token", Body: `{"name":"escalate","scopes":["players:write"]}`, Status: 403, Token: "reader"},
+	{"GET", "/token", ``, 200, ""},
+	{"POST", "/token", `{"name":"scores","scopes":["games:manage"]}`, 201, ""},
+	{"POST", "/token", `{"name":"pigeons","scopes":["pigeons:fly"]}`, 422, ""},
+	{"DELETE", "/token/3", ``, 204, ""},
+	{"DELETE", "/token/3", ``, 404, ""},
+
+	{"POST", "/player", `{"name":"Ann A","preferred_name":"Ann","phone":"4155550100","role_ids":[2]}`, 201, ""},
+	{"POST", "/player", `{"name":"Bob B","preferred_name":"Bob","phone":"4155550101","role_ids":[2,5]}`, 201, ""},
+	{"POST", "/player", `{"name":"Cal C","preferred_name":"Cal"}`, 201, ""},
+	{"POST", "/player", `{"name":"Dee D"}`, 422, ""},
+	{"POST", "/player", `{"name":"Dee D","preferred_name":"Dee","nickname":"D"}`, 400, ""},
+	{"POST", "/player", `{"name":"Dee D","preferred_name":"Dee","phone":"not a phone","role_ids":[2]}`, 422, ""},
+	{"GET", "/player", ``, 200, ""},
+	{"GET", "/player/1", ``, 200, ""},
+	{"GET", "/player/99", ``, 404, ""},
+	{"GET", "/player/ann", ``, 400, ""},
+	{"PUT", "/player/3", `{"name":"Bob B","preferred_name":"Bobby","phone":"4155550101"}`, 200, ""},
+	{"GET", "/player/2/preferences", ``, 200, ""},
+	{"PUT", "/player/2/preferences", `{"channel":"sms","league":true,"tournament":true,"events":true,"game":true,"quiet_start":21,"quiet_end":7}`, 200, ""},
+	{"PUT", "/player/2/preferences", `{"channel":"pigeon","league":true,"tournament":true,"events":true,"game":true,"quiet_start":21,"quiet_end":7}`, 422, ""},
+	{"GET", "/player/2/roles", ``, 200, ""},
+	{"PUT", "/player/2", `{"name":"Ann A","preferred_name":"Annie","phone":"4155550100"}`, 200, "player 2"},
+	{"PUT", "/player/2", `{"name":"Ann A","preferred_name":"Annie","phone":"4155550100","role_ids":[1,2]}`, 403, "player 2"},
+	{"PUT", "/player/3", `{"name":"Bob B","preferred_name":"Bobber","phone":"4155550101"}`, 403, "player 2"},
+	{"PUT", "/player/2/preferences", `{"channel":"sms","league":true,"tournament":false,"events":true,"game":true,"quiet_start":21,"quiet_end":7}`, 200, "player 2"},
+	{"PUT", "/player/3/roles/1", ``, 403, "player 2"},
+
+	{"POST", "/role", `{"name":"Volunteer"}`, 201, ""},
+	{"POST", "/role", `{"name":"User"}`, 409, ""},
+	{"GET", "/role", ``, 200, ""},
+	{"GET", "/role/6", ``, 200, ""},
+	{"PUT", "/role/6", `{"name":"Helper"}`, 200, ""},
+	{"PUT", "/role/1", `{"name":"Boss"}`, 409, ""},
+	{"GET", "/permission", ``, 200, ""},
+	{"PUT", "/role/6/permissions", `{"permissions":["events:manage","games:manage"]}`, 200, ""},
+	{"PUT", "/role/6/permissions", `{"permissions":["pigeons:fly"]}`, 422, ""},
+	{"PUT", "/role/6/permissions", `{"permissions":["roles:manage"]}`, 403, "player 2"},
+	{"PUT", "/role/1/permissions", `{"permissions":["players:write"]}`, 409, ""},
+	{"PUT", "/player/4/roles/6", ``, 200, ""},
+	{"DELETE", "/player/4/roles/6", ``, 204, ""},
+	{"DELETE", "/player/4/roles/6", ``, 404, ""},
+	{"DELETE", "/role/6", ``, 204, ""},
+	{"DELETE", "/role/1", ``, 409, ""},
+
+	{"POST", "/tee", `{"name":"White"}`, 201, ""},
+	{"POST", "/tee", `{"name":"White"}`, 409, ""},
+	{"GET", "/tee", ``, 200, ""},
+	{"GET", "/tee/2", ``, 200, ""},
+	{"PUT", "/tee/2", `{"name":"Red"}`, 200, ""},
+	{"DELETE", "/tee/2", ``, 204, ""},
+	{"DELETE", "/tee/1", ``, 409, ""},
+
+	{"POST", "/event", `{"name":"Gala","date":"2030-12-01T18:00:00-08:00","owner_id":1,"paid_event":true,"cost":40}`, 201, ""},
+	{"POST", "/event", `{"name":"Gala","owner_id":1}`, 409, ""},
+	{"POST", "/event", `{"name":"Picnic","owner_id":1,"cost":-1}`, 422, ""},
+	{"GET", "/event", ``, 200, ""},
+	{"GET", "/event/1", ``, 200, ""},
+	{"PUT", "/event/1", `{"date":"2030-12-02T18:00:00-08:00","owner_id":1,"paid_event":true,"cost":45}`, 200, ""},
+	{"POST", "/event/1/members", `{"player_id":2}`, 201, ""},
+	{"POST", "/event/1/members", `{"player_id":2}`, 409, ""},
+	{"GET", "/event/1/members", ``, 200, ""},
+	{"PUT", "/event/1/members/2", `{"paid":true}`, 200, ""},
+	{"DELETE", "/event/1/members/2", ``, 204, ""},
+	{"DELETE", "/event/1/members/2", ``, 404, ""},
+	{"POST", "/event/1/messages", `{"message":"See you there"}`, 201, ""},
+	{"GET", "/event/1/messages", ``, 200, ""},
+	{"DELETE", "/event/1", ``, 204, ""},
+	{"POST", "/event", `{"name":"Outing","owner_id":1}`, 403, "player 2"},
+	{"POST", "/event", `{"name":"Outing","owner_id":2}`, 201, "player 2"},
+	{"PUT", "/event/2", `{"description":"Bring a lunch","owner_id":2}`, 200, "player 2"},
+	{"PUT", "/event/2", `{"description":"Bring two","owner_id":3}`, 403, "player 3"},
+	{"POST", "/event/2/members", `{"player_id":3}`, 201, "player 2"},
+	{"DELETE", "/event/2/members/3", ``, 204, "player 3"},
+
+	{"POST", "/message", `{"category":"league","message":"Rain today"}`, 201, ""},
+	{"POST", "/message", `{"sender_id":2,"category":"league","message":"Not me"}`, 400, ""},
+	{"POST", "/message", `{"category":"pigeon","message":"Coo"}`, 422, ""},
+	{"GET", "/message?limit=5", ``, 200, ""},
+	{"GET", "/message?limit=0", ``, 422, ""},
+	{"GET", "/message/1", ``, 200, ""},
+
+	{"GET", "/game", ``, 200, ""},
+	{"GET", "/game/1", ``, 200, ""},
+	{"GET", "/game/bydate/{day}", ``, 200, ""},
+	{"GET", "/game/bydate/yesterday", ``, 400, ""},
+	{"PUT", "/game/1", `{"tee_id":1,"is_match":true}`, 200, ""},
+	{"POST", "/game/1/checkins", `{"player_id":2}`, 409, ""},
+	{"POST", "/game/1/checkins", `{"player_id":2}`, 409, "player 2"},
+	{"POST", "/game/1/checkins", `{"player_id":2,"late":true}`, 403, "player 2"},
+	{"POST", "/game/1/checkins", `{"player_id":3,"late":true}`, 403, "player 2"},
+	{"POST", "/game/1/checkins", `{"player_id":2,"late":true}`, 201, ""},
+	{"POST", "/game/1/checkins", `{"player_id":3,"late":true}`, 201, ""},
+	{"POST", "/game/1/checkins", `{"player_id":3,"late":true}`, 409, ""},
+	{"GET", "/game/1/checkins", ``, 200, ""},
+	{"DELETE", "/game/1/checkins/3", ``, 409, ""},
+	{"DELETE", "/game/1/checkins/3?late=true", ``, 403, "player 3"},
+	{"DELETE", "/game/1/checkins/3?late=true", ``, 204, ""},
+	{"POST", "/game/1/checkins", `{"player_id":3,"late":true}`, 201, ""},
+	{"GET", "/game/1/mystery", ``, 404, ""},
+	{"POST", "/game/1/mystery", ``, 201, ""},
+	{"POST", "/game/1/mystery", ``, 409, ""},
+
+	{"GET", "/game/1/teams", ``, 200, ""},
+	{"POST", "/game/1/teams/draw", `{"size":5}`, 422, ""},
+	{"POST", "/game/1/teams/draw", `{"size":2}`, 201, ""},
+	{"GET", "/team/1", ``, 200, ""},
+	{"POST", "/game/1/teams", ``, 201, ""},
+	{"POST", "/team/2/members", `{"ghost":true}`, 201, ""},
+	{"POST", "/team/2/members", `{"player_id":2}`, 409, ""},
+
+	{"POST", "/game/1/scores", `{"idplayer":2,"idteam":1,"first":4,"second":4,"third":4,"fourth":4,"fifth":4,"sixth":4,"seventh":4,"eighth":4,"ninth":4}`, 201, ""},
+	{"POST", "/game/1/scores", `{"idplayer":2,"idteam":1,"first":4,"second":4,"third":4,"fourth":4,"fifth":4,"sixth":4,"seventh":4,"eighth":4,"ninth":4}`, 409, ""},
+	{"POST", "/game/1/scores", `{"idplayer":3,"idteam":1,"first":4,"second":4,"third":4,"fourth":4,"fifth":4,"sixth":4,"seventh":4,"eighth":4,"ninth":4}`, 403, "player 2"},
+	{"POST", "/game/1/scores", `{"idplayer":3,"idteam":1,"first":40,"second":4,"third":4,"fourth":4,"fifth":4,"sixth":4,"seventh":4,"eighth":4,"ninth":4}`, 422, "player 3"},
+	{"POST", "/game/1/scores", `{"idplayer":4,"idteam":1,"first":4,"second":4,"third":4,"fourth":4,"fifth":4,"sixth":4,"seventh":4,"eighth":4,"ninth":4}`, 422, ""},
+	{"GET", "/game/1/scores", ``, 200, ""},
+	{"GET", "/game/1/scores/2", ``, 200, ""},
+	{"PUT", "/game/1/scores/2", `{"idteam":1,"first":5,"second":4,"third":4,"fourth":4,"fifth":4,"sixth":4,"seventh":4,"eighth":4,"ninth":4}`, 200, ""},
+	{"GET", "/game/1/mystery", ``, 200, ""},
+	{"GET", "/averages", ``, 200, ""},
+	{"POST", "/game/1/weather/observed", ``, 200, ""},
+	{"GET", "/game/1/weather/observed", ``, 200, ""},
+	{"GET", "/game/99/weather/observed", ``, 404, ""},
+	{"GET", "/averages/weather", ``, 200, ""},
+	{"DELETE", "/game/1/teams", ``, 409, ""},
+	{"DELETE", "/game/1", ``, 409, ""},
+	{"PUT", "/game/1/teams/lock", ``, 200, ""},
+	{"PUT", "/game/1/teams/lock", ``, 409, ""},
+	{"DELETE", "/game/1/scores/2", ``, 204, ""},
+	{"DELETE", "/game/1/scores/2", ``, 404, ""},
+
+	{"GET", "/weather/1", ``, 200, ""},
+	{"GET", "/weather/99", ``, 404, ""},
+	{"GET", "/weather/bydate/{day}", ``, 200, ""},
+	{"POST", "/game/1/weather", ``, 200, ""},
+	{"POST", "/game/99/weather", ``, 404, ""},
+	{"GET", "/game/1/weather/revisions", ``, 200, ""},
+	{"GET", "/game/99/weather/revisions", ``, 404, ""},
+	{"POST", "/game", `{"tee_id":1,"tee_time":"2020-01-01T12:00:00-08:00"}`, 422, ""},
+	{"POST", "/game", `{"tee_id":1}`, 201, ""},
+	{"POST", "/game", `{"tee_id":1}`, 409, ""},
+	{"POST", "/game/2/checkins", `{"player_id":2,"late":true}`, 201, ""},
+	{"POST", "/game/2/cancel", `{"reschedule_to":"2020-01-01T12:00:00-08:00"}`, 422, ""},
+	{"POST", "/game/2/cancel", `{"reschedule_to":"2030-12-07T13:00:00-08:00"}`, 200, ""},
+	{"POST", "/game/2/cancel", `{}`, 409, ""},
+	{"POST", "/game/2/weather/observed", ``, 409, ""},
+	{"POST", "/game/99/cancel", `{}`, 404, ""},
+	{"POST", "/game/2/checkins", `{"player_id":1,"late":true}`, 409, ""},
+	{"GET", "/game/3/checkins", ``, 200, ""},
+
+	{"DELETE", "/player/4", ``, 204, ""},
+}
+
+// specFile is the generated spec, which has to match the routes.
+const specFile = "../schema/api/swagger.yaml"
+
+// TestContract makes sure specFile is the spec the API generates, then runs
+// contractCalls against the API with every response checked against the
+// spec.  It fails on a call that gets the wrong status, on a response that
+// doesn't match, and on an operation no call reaches.
+func TestContract(t *testing.T) {
+	want, err := os.ReadFile(specFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := api.yaml(operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("%s is out of date, regenerate it with -spec", specFile)
+	}
+
+	day, err := seedContract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Con.Close()
+
+	validateResponses = true
+	defer func() { validateResponses = false }()
+	r := routes()
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	reached := make(map[string]bool)
+	for _, c := range contractCalls {
+		path := strings.ReplaceAll(c.Path, "{day}", day)
+		req, err := http.NewRequest(c.Method, srv.URL+path, strings.NewReader(c.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Token == "" {
+			c.Token = "admin"
+		}
+		if c.Token != "none" {
+			secret, err := contractToken(c.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+
+		var m mux.RouteMatch
+		if r.Match(req, &m) && m.Route != nil {
+			reached[m.Route.GetName()] = true
+		}
+
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != c.Status {
+			t.Errorf("%s %s: got %d, want %d\n%s", c.Method, path, res.StatusCode, c.Status, body)
+		}
+	}
+
+	for _, op := range operations {
+		if !reached[op.key()] {
+			t.Errorf("%s: no contract call", op.key())
+		}
+	}
+}
+
+// contractToken returns the secret of the token called name, issuing it first
+// if it's a "player N" token that hasn't been used yet.
+func contractToken(name string) (string, error) {
+	ctx := context.Background()
+	if secret, ok := contractTokens[name]; ok {
+		return secret, nil
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(name, "player "), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("no contract token %q", name)
+	}
+	p := player.Player{}
+	err = p.GetPlayerByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	scopes := make([]string, 0)
+	for _, perm := range role.Permissions {
+		if apitoken.Allowed(p, perm.Name) {
+			scopes = append(scopes, perm.Name)
+		}
+	}
+	_, contractTokens[name], err = apitoken.Issue(ctx, p, name, scopes)
+
+	return contractTokens[name], err
+}
+
+// seedContract sets up the in-memory database contractCalls expect and
+// returns the day of its game.
+func seedContract() (string, error) {
+	var err error
+	db.Con, err = db.OpenMemory()
+	if err != nil {
+		return "", err
+	}
+	sms.SetMessenger(sms.NewFakeMessenger(""))
+	weather.SetProvider(weather.NewFixtureProvider(""))
+
+	admin := player.Player{Name: "Ada Admin", PreferredName: "Ada", Phone: "4155550199", Roles: role.Roles{1: "Administrator"}}
+	err = player.AddPlayer(context.Background(), &admin)
+	if err != nil {
+		return "", err
+	}
+	err = admin.GetPlayerByID(context.Background(), admin.ID)
+	if err != nil {
+		return "", err
+	}
+	scopes := make([]string, 0)
+	for _, perm := range role.Permissions {
+		scopes = append(scopes, perm.Name)
+	}
+	_, contractTokens["admin"], err = apitoken.Issue(context.Background(), admin, "admin", scopes)
+	if err != nil {
+		return "", err
+	}
+	_, contractTokens["reader"], err = apitoken.Issue(context.Background(), admin, "reader", nil)
+	if err != nil {
+		return "", err
+	}
+
+	t := tee.Tee{Name: "Blue"}
+	err = t.AddTee(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancelfunc := db.Context()
+	defer cancelfunc()
+
+	g := game.Game{Tee: t}
+	g.Date, _ = db.Day(time.Now().AddDate(0, 0, -1))
+	g.TeeTime = g.Date.Add(12 * time.Hour)
+	err = game.NewSQLGameStore(db.Con).AddGame(ctx, &g)
+	if err != nil {
+		return "", err
+	}
+
+	w := weather.Weather{GameID: g.ID, Date: g.TeeTime, Temperature: 61, WeatherText: "Partly cloudy"}
+	err = weather.NewSQLWeatherStore(db.Con).AddWeather(ctx, &w)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Day(), nil
+}
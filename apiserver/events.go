@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"mariners/mpevent"
+	"mariners/player"
+	"mariners/role"
+)
+
+// eventRequest is the body of an event POST or PUT.  An event's name is fixed
+// once it's created, since its SNS topic is named after it, so Name is only
+// read on a POST.  Date is RFC 3339 and may be left out for an event with no
+// date yet.
+type eventRequest struct {
+	Name        string    `json:"name"`
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	OwnerID     int64     `json:"owner_id" api:"required,min=1"`
+	PaidEvent   bool      `json:"paid_event"`
+	Cost        float64   `json:"cost" api:"min=0"`
+	InviteOnly  bool      `json:"invite_only"`
+}
+
+func (er *eventRequest) validate() error {
+	if !er.PaidEvent && er.Cost != 0 {
+		return invalidf("only a paid_event has a cost")
+	}
+
+	return nil
+}
+
+// apply copies the request, apart from the name, onto e.
+func (er *eventRequest) apply(ctx context.Context, e *mpevent.Event) error {
+	err := e.Owner.GetPlayerByID(ctx, er.OwnerID)
+	if err != nil {
+		return invalidf("no player with id %d", er.OwnerID)
+	}
+
+	e.Date = er.Date.UTC()
+	e.Description = er.Description
+	e.PaidEvent = er.PaidEvent
+	e.Cost = er.Cost
+	e.InviteOnly = er.InviteOnly
+
+	return nil
+}
+
+// memberRequest is the body of an event member POST or PUT.  PlayerID is
+// only read on a POST.
+type memberRequest struct {
+	PlayerID int64 `json:"player_id"`
+	Paid     bool  `json:"paid"`
+}
+
+func (mr *memberRequest) validate() error {
+	return nil
+}
+
+// eventMessageRequest is the body of an event message POST, which is sent
+// from the caller.
+type eventMessageRequest struct {
+	Message string `json:"message" api:"required,minLength=1"`
+}
+
+func (mr *eventMessageRequest) validate() error {
+	if strings.TrimSpace(mr.Message) == "" {
+		return invalidf("message is required")
+	}
+
+	return nil
+}
+
+// getEvent loads the event named by path variable id.
+func getEvent(r *http.Request) (mpevent.Event, error) {
+	e := mpevent.Event{}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return e, err
+	}
+
+	err = e.GetEventByID(r.Context(), id)
+
+	return e, err
+}
+
+// getMember finds the member named by path variable pid in e.
+func getMember(r *http.Request, e mpevent.Event) (mpevent.EventMember, error) {
+	pid, err := pathID(r, "pid")
+	if err != nil {
+		return mpevent.EventMember{}, err
+	}
+
+	for _, m := range e.Members {
+		if m.Player.ID == pid {
+			return m, nil
+		}
+	}
+
+	return mpevent.EventMember{}, notFoundf("player %d is not a member of event %d", pid, e.ID)
+}
+
+func GetEventsHandler(w http.ResponseWriter, r *http.Request) {
+	es, err := mpevent.GetEvents(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, es)
+}
+
+func AddEventHandler(w http.ResponseWriter, r *http.Request) {
+	er := eventRequest{}
+	err := decode(r, &er)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(er.Name) == "" {
+		respondError(w, r, invalidf("name is required"))
+		return
+	}
+	c := getCaller(r)
+	if er.OwnerID != c.Player.ID && !c.can(role.EventsManage) {
+		respondError(w, r, forbiddenf("only someone with %s can add an event for someone else", role.EventsManage))
+		return
+	}
+
+	x := mpevent.Event{}
+	if x.GetEventByName(r.Context(), er.Name) == nil {
+		respondError(w, r, conflictf("there is already an event called %s", er.Name))
+		return
+	}
+
+	e := mpevent.Event{Name: er.Name}
+	err = er.apply(r.Context(), &e)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = e.CreateEvent(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = e.GetEventByID(r.Context(), e.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, e)
+}
+
+func GetEventHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, e)
+}
+
+func UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	er := eventRequest{}
+	err = decode(r, &er)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if er.Name != "" && er.Name != e.Name {
+		respondError(w, r, invalidf("an event can't be renamed"))
+		return
+	}
+
+	err = er.apply(r.Context(), &e)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = e.UpdateEvent(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, e)
+}
+
+func DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = e.DeleteEvent(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+func GetMembersHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	ms := e.Members
+	if ms == nil {
+		ms = make(mpevent.EventMembers, 0)
+	}
+
+	respond(w, http.StatusOK, ms)
+}
+
+func AddMemberHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	mr := memberRequest{}
+	err = decode(r, &mr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	p := player.Player{}
+	err = p.GetPlayerByID(r.Context(), mr.PlayerID)
+	if err != nil {
+		respondError(w, r, invalidf("no player with id %d", mr.PlayerID))
+		return
+	}
+	if e.HasMember(p) {
+		respondError(w, r, conflictf("%s is already a member of %s", p.PreferredName, e.Name))
+		return
+	}
+
+	err = e.AddMember(r.Context(), p.ID, mr.Paid)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, mpevent.EventMember{Player: p, Paid: mr.Paid})
+}
+
+func UpdateMemberHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m, err := getMember(r, e)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	mr := memberRequest{}
+	err = decode(r, &mr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = e.UpdateMember(r.Context(), m.Player.ID, mr.Paid)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m.Paid = mr.Paid
+
+	respond(w, http.StatusOK, m)
+}
+
+func DeleteMemberHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m, err := getMember(r, e)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = e.DeleteMember(r.Context(), m.Player.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+func GetEventMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	ms := e.Messages
+	if ms == nil {
+		ms = make(mpevent.EventMessages, 0)
+	}
+
+	respond(w, http.StatusOK, ms)
+}
+
+func AddEventMessageHandler(w http.ResponseWriter, r *http.Request) {
+	e, err := getEvent(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	mr := eventMessageRequest{}
+	err = decode(r, &mr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = e.SendEventMessage(r.Context(), mr.Message, getCaller(r).Player.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, e.Messages[len(e.Messages)-1])
+}
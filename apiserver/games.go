@@ -0,0 +1,733 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mariners/db"
+	"mariners/game"
+	"mariners/player"
+	"mariners/queue"
+	"mariners/role"
+	"mariners/scoring"
+	"mariners/team"
+	"mariners/tee"
+	"mariners/weather"
+
+	"github.com/gorilla/mux"
+)
+
+// teamSize is the size of a drawn team when a draw doesn't ask for one, and
+// the largest a draw can ask for, since scoring can't score bigger teams.
+var teamSize = scoring.DefaultRules.TeamSize
+
+// gameRequest is the body of a game POST or PUT.  A game is always for the
+// day it's added on, so the date can't be set.  TeeTime is RFC 3339 and on
+// the day of the game; left out, a new game tees off at the usual time and
+// an existing one keeps its own.
+type gameRequest struct {
+	TeeID   int64     `json:"tee_id" api:"required,min=1"`
+	IsMatch bool      `json:"is_match"`
+	TeeTime time.Time `json:"tee_time"`
+}
+
+func (gr *gameRequest) validate() error {
+	return nil
+}
+
+// apply copies the request onto g.
+func (gr *gameRequest) apply(ctx context.Context, g *game.Game) error {
+	t := tee.Tee{}
+	err := t.GetTeeByID(ctx, gr.TeeID)
+	if err != nil {
+		return invalidf("no tee with id %d", gr.TeeID)
+	}
+	g.Tee = t
+	g.IsMatch = gr.IsMatch
+	if !gr.TeeTime.IsZero() {
+		g.TeeTime = gr.TeeTime.UTC()
+	}
+
+	return nil
+}
+
+// checkinRequest is the body of a check-in POST.  Late checks the player in
+// after check-in has closed, as a Game Manager can.
+type checkinRequest struct {
+	PlayerID int64 `json:"player_id" api:"required,min=1"`
+	Late     bool  `json:"late"`
+}
+
+func (cr *checkinRequest) validate() error {
+	return nil
+}
+
+// cancelRequest is the body of a game cancel.  RescheduleTo is RFC 3339 and
+// on a later day: the game moves to that day, teeing off then.  Left out, the
+// game is just called off.
+type cancelRequest struct {
+	RescheduleTo time.Time `json:"reschedule_to"`
+}
+
+func (cr *cancelRequest) validate() error {
+	return nil
+}
+
+// drawRequest is the body of a team draw.  Seeded evens the teams out using
+// each player's last 20 average.
+type drawRequest struct {
+	Size   int  `json:"size" api:"min=0"`
+	Seeded bool `json:"seeded"`
+}
+
+func (dr *drawRequest) validate() error {
+	return nil
+}
+
+// teamMemberRequest is the body of a team member POST.  A ghost has no
+// player.
+type teamMemberRequest struct {
+	PlayerID int64 `json:"player_id" api:"min=0"`
+	Ghost    bool  `json:"ghost"`
+}
+
+func (mr *teamMemberRequest) validate() error {
+	if mr.Ghost && mr.PlayerID != 0 {
+		return invalidf("a ghost has no player_id")
+	}
+	if !mr.Ghost && mr.PlayerID == 0 {
+		return invalidf("player_id is required")
+	}
+
+	return nil
+}
+
+// drawnTeam is a team as the API shows it, with its members.
+type drawnTeam struct {
+	team.Team
+	Members team.TeamMembers `json:"members"`
+}
+
+// getGame loads the game named by path variable id, with its tee, weather and
+// check-ins.
+func getGame(r *http.Request) (game.Game, error) {
+	g := game.Game{}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return g, err
+	}
+
+	err = g.GetGameByID(r.Context(), id)
+	if err != nil {
+		return g, err
+	}
+
+	err = g.Tee.GetTeeByID(r.Context(), g.Tee.ID)
+	if err != nil {
+		return g, err
+	}
+
+	err = g.GetWeather(r.Context())
+	if err != nil {
+		return g, err
+	}
+
+	err = g.GetCheckins(r.Context())
+
+	return g, err
+}
+
+// getTeams loads the teams drawn for game gid with their members.
+func getTeams(ctx context.Context, gid int64) ([]drawnTeam, error) {
+	tbs := make([]drawnTeam, 0)
+
+	ts, err := team.GetTeamsByGameID(ctx, gid)
+	if err != nil {
+		return tbs, err
+	}
+
+	for _, t := range ts {
+		ms, err := team.GetTeamMembers(ctx, t.ID)
+		if err != nil {
+			return tbs, err
+		}
+		tbs = append(tbs, drawnTeam{Team: t, Members: ms})
+	}
+
+	return tbs, nil
+}
+
+// textCheckins queues msg from player sid to every player checked in to g.
+func textCheckins(ctx context.Context, sid int64, label string, msg string, g game.Game) error {
+	ps := make(player.Players, 0)
+	for _, ci := range g.Checkins {
+		p := player.Player{}
+		err := p.GetPlayerByID(ctx, ci.PlayerID)
+		if err != nil {
+			return err
+		}
+		ps = append(ps, p)
+	}
+
+	_, err := queue.Enqueue(ctx, sid, player.CategoryGame, label, msg, ps)
+
+	return err
+}
+
+func GetGamesHandler(w http.ResponseWriter, r *http.Request) {
+	gs, err := game.GetGames(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, gs)
+}
+
+// AddGameHandler adds today's game, fetching its forecast.
+func AddGameHandler(w http.ResponseWriter, r *http.Request) {
+	gr := gameRequest{}
+	err := decode(r, &gr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	_, err = game.GetGameByDate(r.Context(), time.Now())
+	switch {
+	case err == nil:
+		respondError(w, r, conflictf("there is already a game today"))
+		return
+	case !errors.Is(err, sql.ErrNoRows):
+		respondError(w, r, err)
+		return
+	}
+
+	g := game.Game{}
+	err = gr.apply(r.Context(), &g)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = g.AddGame(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, g)
+}
+
+func GetGameHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, g)
+}
+
+func GetGameByDateHandler(w http.ResponseWriter, r *http.Request) {
+	t, err := db.ParseLocal("2006-01-02", mux.Vars(r)["date"])
+	if err != nil {
+		respondError(w, r, fmt.Errorf("%w: date must be YYYY-MM-DD", errBadRequest))
+		return
+	}
+
+	g, err := game.GetGameByDate(r.Context(), t)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, g)
+}
+
+func UpdateGameHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	gr := gameRequest{}
+	err = decode(r, &gr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	tee := g.TeeTime
+	err = gr.apply(r.Context(), &g)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = g.UpdateGame(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// A new tee time moves the hours the forecast is for.
+	if !g.TeeTime.Equal(tee) {
+		err = g.RefreshWeather(r.Context(), weather.ReasonRequested)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	respond(w, http.StatusOK, g)
+}
+
+func DeleteGameHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = g.DeleteGame(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+// CancelGameHandler calls the game off, moving it and its check-ins to
+// another day if asked, and texts the players it affects.
+func CancelGameHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	cr := cancelRequest{}
+	err = decode(r, &cr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = g.Cancel(r.Context(), getCaller(r).Player.ID, cr.RescheduleTo)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, g)
+}
+
+func GetCheckinsHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	cs := g.Checkins
+	if cs == nil {
+		cs = make(game.Checkins, 0)
+	}
+
+	respond(w, http.StatusOK, cs)
+}
+
+func AddCheckinHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	cr := checkinRequest{}
+	err = decode(r, &cr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	c := getCaller(r)
+	if cr.PlayerID != c.Player.ID && !c.can(role.GamesManage) {
+		respondError(w, r, forbiddenf("only someone with %s can check in someone else", role.GamesManage))
+		return
+	}
+	if cr.Late && !c.can(role.GamesManage) {
+		respondError(w, r, forbiddenf("only someone with %s can check in after check-in has closed", role.GamesManage))
+		return
+	}
+
+	p := player.Player{}
+	err = p.GetPlayerByID(r.Context(), cr.PlayerID)
+	if err != nil {
+		respondError(w, r, invalidf("no player with id %d", cr.PlayerID))
+		return
+	}
+
+	err = g.AddCheckin(r.Context(), p, cr.Late)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, g.Checkins[len(g.Checkins)-1])
+}
+
+// DeleteCheckinHandler checks a player out of the game.  Passing late=true
+// does so after check-in has closed.
+func DeleteCheckinHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	p, err := getPlayer(r, "pid")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	late := false
+	if v := r.URL.Query().Get("late"); v != "" {
+		late, err = strconv.ParseBool(v)
+		if err != nil {
+			respondError(w, r, fmt.Errorf("%w: late must be true or false", errBadRequest))
+			return
+		}
+	}
+	if late && !getCaller(r).can(role.GamesManage) {
+		respondError(w, r, forbiddenf("only someone with %s can check out after check-in has closed", role.GamesManage))
+		return
+	}
+
+	err = g.RemoveCheckin(r.Context(), p, late)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+func GetMysteryHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if g.Mystery.Hole == 0 {
+		respondError(w, r, notFoundf("the mystery hole hasn't been drawn for game %d", g.ID))
+		return
+	}
+
+	mr, err := scoring.GetMysteryResult(r.Context(), g.ID, g.Mystery.Hole)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, mr)
+}
+
+// DrawMysteryHandler draws the mystery hole once play has closed and texts it
+// to the players checked in.  The draw stands even if the text can't be
+// queued.
+func DrawMysteryHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = g.DrawMystery(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	msg := fmt.Sprintf("The mystery hole for %s is hole %d!", g.Day(), g.Mystery.Hole)
+	err = textCheckins(r.Context(), getCaller(r).Player.ID, "Mystery Hole", msg, g)
+	if err != nil {
+		log.Printf("DrawMysteryHandler: %s", err)
+	}
+
+	respond(w, http.StatusCreated, g.Mystery)
+}
+
+func GetTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	tbs, err := getTeams(r.Context(), g.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, tbs)
+}
+
+// AddTeamHandler adds an empty team to the game, for building teams by hand.
+func AddTeamHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if g.TeamsLocked {
+		respondError(w, r, game.ErrTeamsLocked)
+		return
+	}
+
+	t := team.Team{}
+	err = team.AddTeam(r.Context(), g.ID, &t)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	t.GameID = g.ID
+
+	respond(w, http.StatusCreated, drawnTeam{Team: t, Members: make(team.TeamMembers, 0)})
+}
+
+// DrawTeamsHandler replaces the game's teams with a draw of the players
+// checked in.
+func DrawTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if g.TeamsLocked {
+		respondError(w, r, game.ErrTeamsLocked)
+		return
+	}
+
+	dr := drawRequest{}
+	err = decode(r, &dr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if dr.Size == 0 {
+		dr.Size = teamSize
+	}
+	if dr.Size > teamSize {
+		respondError(w, r, invalidf("teams of %d: teams are at most %d", dr.Size, teamSize))
+		return
+	}
+
+	pids := make([]int64, 0, len(g.Checkins))
+	for _, ci := range g.Checkins {
+		pids = append(pids, ci.PlayerID)
+	}
+	if len(pids) == 0 {
+		respondError(w, r, conflictf("nobody is checked in to game %d", g.ID))
+		return
+	}
+
+	var seeds map[int64]float64
+	if dr.Seeded {
+		as, err := scoring.GetAverages(r.Context())
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		seeds = make(map[int64]float64)
+		for _, a := range as {
+			seeds[a.Player.ID] = a.Last20
+		}
+	}
+
+	draw, err := team.Draw(pids, dr.Size, seeds)
+	if err != nil {
+		respondError(w, r, invalidf("%s", err))
+		return
+	}
+
+	_, err = team.SaveDraw(r.Context(), g.ID, draw)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	tbs, err := getTeams(r.Context(), g.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, tbs)
+}
+
+// LockTeamsHandler stops the teams being redrawn and texts them to the
+// players checked in.
+func LockTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	tbs, err := getTeams(r.Context(), g.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if len(tbs) == 0 {
+		respondError(w, r, conflictf("no teams have been drawn for game %d", g.ID))
+		return
+	}
+
+	err = g.LockTeams(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	msg := fmt.Sprintf("Teams for %s:", g.Day())
+	for i, tb := range tbs {
+		names := make([]string, 0, len(tb.Members))
+		for _, m := range tb.Members {
+			if m.Ghost {
+				names = append(names, "Ghost")
+				continue
+			}
+			p := player.Player{}
+			err = p.GetPlayerByID(r.Context(), m.PlayerID)
+			if err != nil {
+				respondError(w, r, err)
+				return
+			}
+			names = append(names, p.PreferredName)
+		}
+		msg += fmt.Sprintf("\n%d: %s", i+1, strings.Join(names, ", "))
+	}
+	err = textCheckins(r.Context(), getCaller(r).Player.ID, "Teams", msg, g)
+	if err != nil {
+		log.Printf("LockTeamsHandler: %s", err)
+	}
+
+	respond(w, http.StatusOK, tbs)
+}
+
+func DeleteTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if g.TeamsLocked {
+		respondError(w, r, game.ErrTeamsLocked)
+		return
+	}
+
+	err = team.DeleteTeams(r.Context(), g.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+// getTeam loads the team named by path variable id with its members.
+func getTeam(r *http.Request) (drawnTeam, error) {
+	tb := drawnTeam{}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return tb, err
+	}
+
+	err = team.GetTeam(r.Context(), id, &tb.Team)
+	if err != nil {
+		return tb, err
+	}
+
+	tb.Members, err = team.GetTeamMembers(r.Context(), tb.ID)
+
+	return tb, err
+}
+
+func GetTeamHandler(w http.ResponseWriter, r *http.Request) {
+	tb, err := getTeam(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, tb)
+}
+
+func AddTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	tb, err := getTeam(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	mr := teamMemberRequest{}
+	err = decode(r, &mr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	g := game.Game{}
+	err = g.GetGameByID(r.Context(), tb.GameID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if g.TeamsLocked {
+		respondError(w, r, game.ErrTeamsLocked)
+		return
+	}
+
+	if !mr.Ghost {
+		p := player.Player{}
+		err = p.GetPlayerByID(r.Context(), mr.PlayerID)
+		if err != nil {
+			respondError(w, r, invalidf("no player with id %d", mr.PlayerID))
+			return
+		}
+
+		x := team.Team{}
+		err = team.GetTeamByPlayer(r.Context(), tb.GameID, p.ID, &x)
+		switch {
+		case err == nil:
+			respondError(w, r, conflictf("%s is already on team %d", p.PreferredName, x.ID))
+			return
+		case !errors.Is(err, sql.ErrNoRows):
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	m := team.TeamMember{TeamID: tb.ID, PlayerID: mr.PlayerID, Ghost: mr.Ghost}
+	err = team.AddTeamMember(r.Context(), &m)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, m)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mariners/player"
+	"mariners/queue"
+)
+
+// messageRequest is the body of a message POST, which texts the league, or
+// just the players in the Tournament role, as the message page does.  It's
+// sent from the caller.
+type messageRequest struct {
+	Category string `json:"category" api:"required,enum=league|tournament"`
+	Message  string `json:"message" api:"required,minLength=1"`
+}
+
+func (mr *messageRequest) validate() error {
+	if strings.TrimSpace(mr.Message) == "" {
+		return invalidf("message is required")
+	}
+
+	return nil
+}
+
+func GetMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondError(w, r, fmt.Errorf("%w: limit must be a positive number", errBadRequest))
+			return
+		}
+		limit = n
+	}
+
+	bs, err := queue.GetBlasts(r.Context(), limit)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, bs)
+}
+
+func GetMessageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	b := queue.Blast{}
+	err = b.GetBlastByID(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, b)
+}
+
+func AddMessageHandler(w http.ResponseWriter, r *http.Request) {
+	mr := messageRequest{}
+	err := decode(r, &mr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	sender := getCaller(r).Player
+
+	all, err := player.GetPlayers(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	label := "League"
+	ps := make(player.Players, 0)
+	for _, p := range all {
+		if !p.HasRole("User") {
+			continue
+		}
+		if mr.Category == player.CategoryTournament && !p.HasRole("Tournament") {
+			continue
+		}
+		ps = append(ps, p)
+	}
+	if mr.Category == player.CategoryTournament {
+		label = "Tournament"
+	}
+
+	msg := fmt.Sprintf("Message from %s: %s", sender.PreferredName, mr.Message)
+	b, err := queue.Enqueue(r.Context(), sender.ID, mr.Category, label, msg, ps)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, b)
+}
@@ -0,0 +1,865 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// The OpenAPI spec is generated from operations and the types their bodies
+// are read into and written from, following the rules encoding/json uses, so
+// it describes what the handlers really do.  schema/api/swagger.yaml is the
+// generated copy; run apiserver with -spec to regenerate it, and its contract
+// test makes sure it and the handlers still agree.
+//
+// Request types can narrow their fields with an api tag: required, min=,
+// max=, minLength=, maxLength= and enum= with the values split by |.  They
+// refuse fields they don't have, as decode does.
+
+// maxBody is the largest request body read.
+const maxBody = 1 << 20
+
+const refPrefix = "#/components/schemas/"
+
+// schema is the subset of an OpenAPI 3.0 schema object the API uses.
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+}
+
+// parameter is a path or query parameter.
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+var (
+	one            = 1.0
+	limitParameter = parameter{Name: "limit", In: "query", Description: "how many to list, 10 if not given", Schema: &schema{Type: "integer", Minimum: &one}}
+	lateParameter  = parameter{Name: "late", In: "query", Description: "check out after check-in has closed", Schema: &schema{Type: "boolean"}}
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	requestType = reflect.TypeOf((*request)(nil)).Elem()
+	pathParams  = regexp.MustCompile(`{([a-z]+)}`)
+)
+
+// apiSpec is the generated spec, with the component schemas it's built from.
+type apiSpec struct {
+	schemas map[string]*schema
+	types   map[string]reflect.Type
+}
+
+// api is the spec for operations.
+var api = newSpec(operations)
+
+func newSpec(ops []operation) *apiSpec {
+	sp := &apiSpec{schemas: make(map[string]*schema), types: make(map[string]reflect.Type)}
+
+	sp.schemaFor(reflect.TypeOf(errorBody{}))
+	for _, op := range ops {
+		if op.Request != nil {
+			sp.schemaFor(reflect.TypeOf(op.Request))
+		}
+		if op.Response != nil {
+			sp.schemaFor(reflect.TypeOf(op.Response))
+		}
+	}
+
+	return sp
+}
+
+// schemaName is the component name of struct type t: its name, capitalized,
+// without a Body suffix.
+func schemaName(t reflect.Type) string {
+	n := strings.TrimSuffix(t.Name(), "Body")
+	r, size := utf8.DecodeRuneInString(n)
+
+	return string(unicode.ToUpper(r)) + n[size:]
+}
+
+// schemaFor returns the schema of values of type t, adding a component for
+// each struct type it uses.
+func (sp *apiSpec) schemaFor(t reflect.Type) *schema {
+	if t == timeType {
+		return &schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &schema{Type: "integer"}
+	case reflect.Int64:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Array:
+		n := t.Len()
+		return &schema{Type: "array", Items: sp.schemaFor(t.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Slice:
+		return &schema{Type: "array", Nullable: true, Items: sp.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", Nullable: true, AdditionalProperties: sp.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return sp.component(t)
+	}
+
+	panic(fmt.Sprintf("apiserver: no schema for %s", t))
+}
+
+// component adds struct type t to the components and returns a reference to
+// it.
+func (sp *apiSpec) component(t reflect.Type) *schema {
+	name := schemaName(t)
+	ref := &schema{Ref: refPrefix + name}
+
+	if have, ok := sp.types[name]; ok {
+		if have != t {
+			panic(fmt.Sprintf("apiserver: %s and %s are both schema %s", have, t, name))
+		}
+		return ref
+	}
+	sp.types[name] = t
+
+	strict := reflect.PtrTo(t).Implements(requestType)
+	s := &schema{Type: "object", Properties: make(map[string]*schema)}
+	if strict {
+		s.AdditionalProperties = false
+	}
+	sp.fields(t, s, strict)
+	sort.Strings(s.Required)
+	sp.schemas[name] = s
+
+	return ref
+}
+
+// fields adds the fields of struct type t to s as encoding/json would write
+// them.  Every field of a response is required, since it's always written;
+// request fields are only required when their api tag says so.
+func (sp *apiSpec) fields(t reflect.Type, s *schema, strict bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			sp.fields(f.Type, s, strict)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := sp.schemaFor(f.Type)
+		required := !strict
+		for _, o := range opts[1:] {
+			if o == "omitempty" {
+				required = false
+			}
+		}
+		if nt, ok := f.Tag.Lookup("api"); ok {
+			required = narrow(fs, nt) || required
+		}
+
+		s.Properties[name] = fs
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// narrow applies api tag to s, and reports whether it makes the field
+// required.
+func narrow(s *schema, tag string) bool {
+	required := false
+
+	for _, part := range strings.Split(tag, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if kv[0] == "required" {
+			required = true
+			continue
+		}
+		if len(kv) != 2 {
+			panic(fmt.Sprintf("apiserver: bad api tag %q", tag))
+		}
+
+		// The values of a list are those of its items.
+		if kv[0] == "enum" {
+			if s.Items != nil {
+				s.Items.Enum = strings.Split(kv[1], "|")
+			} else {
+				s.Enum = strings.Split(kv[1], "|")
+			}
+			continue
+		}
+
+		n, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			panic(fmt.Sprintf("apiserver: bad api tag %q: %s", tag, err))
+		}
+		switch kv[0] {
+		case "min":
+			s.Minimum = &n
+		case "max":
+			s.Maximum = &n
+		case "minLength":
+			l := int(n)
+			s.MinLength = &l
+		case "maxLength":
+			l := int(n)
+			s.MaxLength = &l
+		default:
+			panic(fmt.Sprintf("apiserver: bad api tag %q", tag))
+		}
+	}
+
+	return required
+}
+
+// parameters are the path parameters in op's path followed by its query
+// parameters.  Dates are YYYY-MM-DD and everything else in a path is an ID.
+func (sp *apiSpec) parameters(op operation) []parameter {
+	ps := make([]parameter, 0)
+
+	for _, m := range pathParams.FindAllStringSubmatch(op.Path, -1) {
+		p := parameter{Name: m[1], In: "path", Required: true, Schema: &schema{Type: "integer", Format: "int64"}}
+		if m[1] == "date" {
+			p.Description = "a day in the league's timezone"
+			p.Schema = &schema{Type: "string", Format: "date"}
+		}
+		ps = append(ps, p)
+	}
+
+	return append(ps, op.Query...)
+}
+
+// errorStatuses are the error statuses op can return: 400, 401 and 500 for
+// any operation, 403 for one that needs a permission, 404 for one on a particular
+// thing, 422 for one that takes input, and any more it lists.
+func (sp *apiSpec) errorStatuses(op operation) []int {
+	ss := []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError}
+	if op.Permission != "" {
+		ss = append(ss, http.StatusForbidden)
+	}
+	if strings.Contains(op.Path, "{") {
+		ss = append(ss, http.StatusNotFound)
+	}
+	if op.Request != nil || len(op.Query) > 0 {
+		ss = append(ss, http.StatusUnprocessableEntity)
+	}
+	for _, s := range op.Errors {
+		found := false
+		for _, have := range ss {
+			found = found || have == s
+		}
+		if !found {
+			ss = append(ss, s)
+		}
+	}
+	sort.Ints(ss)
+
+	return ss
+}
+
+// operationID is the name of op's handler without Handler, as in getPlayers.
+func operationID(op operation) string {
+	name := runtime.FuncForPC(reflect.ValueOf(op.Handler).Pointer()).Name()
+	name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "Handler")
+	r, size := utf8.DecodeRuneInString(name)
+
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+func jsonContent(s *schema) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": s},
+	}
+}
+
+// document is the OpenAPI document for ops.
+func (sp *apiSpec) document(ops []operation) map[string]interface{} {
+	paths := make(map[string]interface{})
+	tags := make([]interface{}, 0)
+	seen := make(map[string]bool)
+
+	for _, op := range ops {
+		if !seen[op.Tag] {
+			seen[op.Tag] = true
+			tags = append(tags, map[string]interface{}{"name": op.Tag})
+		}
+
+		o := map[string]interface{}{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+		}
+		switch {
+		case op.Permission != "" && op.Owner != nil:
+			o["description"] = fmt.Sprintf("Needs the %s permission, from the caller's roles and as a scope of their token.  Without the role, the scope is enough for the caller's own things.", op.Permission)
+		case op.Permission != "":
+			o["description"] = fmt.Sprintf("Needs the %s permission, from the caller's roles and as a scope of their token.", op.Permission)
+		}
+		if ps := sp.parameters(op); len(ps) > 0 {
+			o["parameters"] = ps
+		}
+		if op.Request != nil {
+			o["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(sp.schemaFor(reflect.TypeOf(op.Request))),
+			}
+		}
+
+		rs := make(map[string]interface{})
+		ok := map[string]interface{}{"description": http.StatusText(op.Status)}
+		if op.Response != nil {
+			ok["content"] = jsonContent(sp.schemaFor(reflect.TypeOf(op.Response)))
+		}
+		rs[strconv.Itoa(op.Status)] = ok
+		for _, s := range sp.errorStatuses(op) {
+			rs[strconv.Itoa(s)] = map[string]interface{}{
+				"description": http.StatusText(s),
+				"content":     jsonContent(&schema{Ref: refPrefix + "Error"}),
+			}
+		}
+		o["responses"] = rs
+
+		item, _ := paths[op.Path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = o
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Mariners Point Golf Club",
+			"description": "This is the mplinksters golf club server.",
+			"version":     "1.0.0",
+			"contact":     map[string]interface{}{"email": "ennead.tbc@gmail.com"},
+			"license": map[string]interface{}{
+				"name": "Apache 2.0",
+				"url":  "http://www.apache.org/licenses/LICENSE-2.0.html",
+			},
+		},
+		"servers":  []interface{}{map[string]interface{}{"url": "http://localhost:8080"}},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+		"tags":     tags,
+		"paths":    paths,
+		"components": map[string]interface{}{
+			"schemas": sp.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "A personal access token, issued with apiserver -issue or POST /token.",
+				},
+			},
+		},
+	}
+}
+
+// yaml returns the document for ops as YAML.
+func (sp *apiSpec) yaml(ops []operation) ([]byte, error) {
+	j, err := json.Marshal(sp.document(ops))
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+	err = dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeYAML(&buf, v, 0, "")
+
+	return buf.Bytes(), nil
+}
+
+// keyOrder is the order fields of the spec's own objects are written in.
+// Anything else, and the keys of maps like paths and properties, is sorted.
+var keyOrder = []string{
+	"openapi", "info", "servers", "security", "tags", "paths", "components",
+	"schemas", "securitySchemes",
+	"title", "summary", "description", "version", "contact", "license", "name", "url",
+	"get", "post", "put", "delete",
+	"operationId", "parameters", "requestBody", "responses",
+	"in", "required", "content", "schema",
+	"$ref", "type", "format", "nullable", "enum", "minimum", "maximum",
+	"minLength", "maxLength", "minItems", "maxItems", "items",
+	"additionalProperties", "properties",
+}
+
+// sortedKeys are the maps whose keys are names rather than fields.
+var sortedKeys = map[string]bool{"paths": true, "schemas": true, "properties": true, "responses": true, "content": true}
+
+func keyRank(k string) int {
+	for i, o := range keyOrder {
+		if o == k {
+			return i
+		}
+	}
+
+	return len(keyOrder)
+}
+
+var plainKey = regexp.MustCompile(`^[A-Za-z_$/][A-Za-z0-9_$/{}.\-]*$`)
+
+func yamlKey(k string) string {
+	if plainKey.MatchString(k) {
+		return k
+	}
+
+	return strconv.Quote(k)
+}
+
+// writeYAML writes v, decoded from JSON, as block YAML indented by indent.
+// parent is the key v is under.
+func writeYAML(buf *bytes.Buffer, v interface{}, indent int, parent string) {
+	pad := strings.Repeat(" ", indent)
+
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(tv))
+		for k := range tv {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if !sortedKeys[parent] {
+				ri, rj := keyRank(keys[i]), keyRank(keys[j])
+				if ri != rj {
+					return ri < rj
+				}
+			}
+			return keys[i] < keys[j]
+		})
+		for i, k := range keys {
+			if i > 0 || buf.Len() == 0 || buf.Bytes()[buf.Len()-1] == '\n' {
+				buf.WriteString(pad)
+			}
+			buf.WriteString(yamlKey(k) + ":")
+			writeValue(buf, tv[k], indent, k)
+		}
+	case []interface{}:
+		for _, item := range tv {
+			buf.WriteString(pad + "- ")
+			if m, ok := item.(map[string]interface{}); ok && len(m) > 0 {
+				writeYAML(buf, m, indent+2, parent)
+				continue
+			}
+			writeScalar(buf, item)
+			buf.WriteString("\n")
+		}
+	}
+}
+
+// writeValue writes the value of key k, after its colon.
+func writeValue(buf *bytes.Buffer, v interface{}, indent int, k string) {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		if len(tv) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, tv, indent+2, k)
+	case []interface{}:
+		if len(tv) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, tv, indent, k)
+	default:
+		buf.WriteString(" ")
+		writeScalar(buf, v)
+		buf.WriteString("\n")
+	}
+}
+
+func writeScalar(buf *bytes.Buffer, v interface{}) {
+	switch tv := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case string:
+		buf.WriteString(strconv.Quote(tv))
+	case json.Number:
+		buf.WriteString(tv.String())
+	case bool:
+		buf.WriteString(strconv.FormatBool(tv))
+	case map[string]interface{}:
+		buf.WriteString("{}")
+	case []interface{}:
+		buf.WriteString("[]")
+	}
+}
+
+// handler wraps op's handler so that requests that don't match the spec are
+// refused before they reach it, and, when validateResponses is set, so that a
+// response that doesn't match is logged and replaced with a 500.
+func (sp *apiSpec) handler(op operation) http.Handler {
+	ps := sp.parameters(op)
+	var body *schema
+	if op.Request != nil {
+		body = sp.schemaFor(reflect.TypeOf(op.Request))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := sp.checkRequest(r, ps, body)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+
+		if !validateResponses {
+			op.Handler(w, r)
+			return
+		}
+
+		bw := &bufferedWriter{header: make(http.Header), status: http.StatusOK}
+		op.Handler(bw, r)
+		err = sp.checkResponse(op, bw.status, bw.body.Bytes())
+		if err != nil {
+			err = fmt.Errorf("%d response does not match the spec: %s", bw.status, reason(err))
+			respondError(w, r, err)
+			return
+		}
+		bw.flush(w)
+	})
+}
+
+// reason is what a check error says, without the status it would get were
+// it about a request.
+func reason(err error) string {
+	msg := err.Error()
+	for _, e := range []error{errBadRequest, errInvalid} {
+		if errors.Is(err, e) {
+			return strings.TrimPrefix(msg, e.Error()+": ")
+		}
+	}
+
+	return msg
+}
+
+// validateResponses turns on checking responses against the spec.
+var validateResponses = false
+
+// checkRequest checks r's parameters and body against the spec.  A body that
+// matches is put back for the handler to decode.
+func (sp *apiSpec) checkRequest(r *http.Request, ps []parameter, body *schema) error {
+	vars := mux.Vars(r)
+	q := r.URL.Query()
+	for _, p := range ps {
+		raw, ok := vars[p.Name], p.In == "path"
+		if p.In == "query" {
+			raw, ok = q.Get(p.Name), q.Has(p.Name)
+		}
+		if !ok {
+			continue
+		}
+		err := sp.checkParameter(p, raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	if body == nil {
+		return nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		return fmt.Errorf("%w: %s", errBadRequest, err)
+	}
+	if len(b) > maxBody {
+		return fmt.Errorf("%w: body is over %d bytes", errBadRequest, maxBody)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+
+	v, err := decodeValue(b)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errBadRequest, err)
+	}
+
+	return sp.check(body, v, "body")
+}
+
+// checkParameter checks the raw value of parameter p.
+func (sp *apiSpec) checkParameter(p parameter, raw string) error {
+	var v interface{} = raw
+
+	switch p.Schema.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return fmt.Errorf("%w: %s must be a whole number", errBadRequest, p.Name)
+		}
+		v = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%w: %s must be true or false", errBadRequest, p.Name)
+		}
+		v = b
+	case "string":
+		if p.Schema.Format == "date" {
+			if _, err := time.Parse("2006-01-02", raw); err != nil {
+				return fmt.Errorf("%w: %s must be YYYY-MM-DD", errBadRequest, p.Name)
+			}
+		}
+	}
+
+	return sp.check(p.Schema, v, p.Name)
+}
+
+// checkResponse checks that status is one op can return and that body is the
+// one the spec gives for it.
+func (sp *apiSpec) checkResponse(op operation, status int, body []byte) error {
+	var s *schema
+
+	switch {
+	case status == op.Status:
+		if op.Response == nil {
+			if len(body) != 0 {
+				return fmt.Errorf("expected an empty body")
+			}
+			return nil
+		}
+		s = sp.schemaFor(reflect.TypeOf(op.Response))
+	default:
+		for _, e := range sp.errorStatuses(op) {
+			if e == status {
+				s = &schema{Ref: refPrefix + "Error"}
+			}
+		}
+		if s == nil {
+			return fmt.Errorf("status %d is not in the spec", status)
+		}
+	}
+
+	v, err := decodeValue(body)
+	if err != nil {
+		return err
+	}
+
+	return sp.check(s, v, "body")
+}
+
+// decodeValue decodes a JSON document, keeping numbers as written.
+func decodeValue(b []byte) (interface{}, error) {
+	var v interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("more than one JSON value")
+	}
+
+	return v, nil
+}
+
+// check checks v, decoded with decodeValue, against s.  at is where v is in
+// the request, for errors.  Values of the wrong shape are bad requests and
+// values outside the schema's limits are invalid ones.
+func (sp *apiSpec) check(s *schema, v interface{}, at string) error {
+	if s.Ref != "" {
+		return sp.check(sp.schemas[strings.TrimPrefix(s.Ref, refPrefix)], v, at)
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%w: %s can't be null", errBadRequest, at)
+	}
+
+	switch s.Type {
+	case "object":
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: %s must be an object", errBadRequest, at)
+		}
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ps, ok := s.Properties[k]
+			if !ok {
+				ps, ok = s.AdditionalProperties.(*schema)
+			}
+			if !ok {
+				if s.AdditionalProperties == false {
+					return fmt.Errorf("%w: %s has unknown field %q", errBadRequest, at, k)
+				}
+				continue
+			}
+			err := sp.check(ps, o[k], at+"."+k)
+			if err != nil {
+				return err
+			}
+		}
+		for _, k := range s.Required {
+			if _, ok := o[k]; !ok {
+				return invalidf("%s.%s is required", at, k)
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%w: %s must be an array", errBadRequest, at)
+		}
+		if s.MinItems != nil && len(a) < *s.MinItems {
+			return invalidf("%s must have at least %d items", at, *s.MinItems)
+		}
+		if s.MaxItems != nil && len(a) > *s.MaxItems {
+			return invalidf("%s must have at most %d items", at, *s.MaxItems)
+		}
+		for i, item := range a {
+			err := sp.check(s.Items, item, fmt.Sprintf("%s[%d]", at, i))
+			if err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%w: %s must be a string", errBadRequest, at)
+		}
+		return checkString(s, str, at)
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%w: %s must be a number", errBadRequest, at)
+		}
+		if s.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return fmt.Errorf("%w: %s must be a whole number", errBadRequest, at)
+			}
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("%w: %s must be a number", errBadRequest, at)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return invalidf("%s must be at least %v", at, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return invalidf("%s must be at most %v", at, *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%w: %s must be true or false", errBadRequest, at)
+		}
+	}
+
+	return nil
+}
+
+func checkString(s *schema, str string, at string) error {
+	n := utf8.RuneCountInString(str)
+	if s.MinLength != nil && n < *s.MinLength {
+		return invalidf("%s must be at least %d characters", at, *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		return invalidf("%s must be at most %d characters", at, *s.MaxLength)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			found = found || e == str
+		}
+		if !found {
+			return invalidf("%s must be one of %q", at, s.Enum)
+		}
+	}
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return invalidf("%s must be an RFC 3339 time", at)
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", str); err != nil {
+			return invalidf("%s must be YYYY-MM-DD", at)
+		}
+	}
+
+	return nil
+}
+
+// bufferedWriter holds a response until it has been checked.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	return bw.body.Write(b)
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	bw.status = status
+}
+
+func (bw *bufferedWriter) flush(w http.ResponseWriter) {
+	for k, v := range bw.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(bw.status)
+	w.Write(bw.body.Bytes())
+}
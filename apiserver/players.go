@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"mariners/player"
+	"mariners/role"
+)
+
+// playerRequest is the body of a player POST or PUT.  RoleIDs replaces the
+// player's roles when it's given, and is left alone on a PUT when it isn't.
+// Only a caller who could give or take each changed role can change them.
+type playerRequest struct {
+	Name           string  `json:"name" api:"required,minLength=1"`
+	PreferredName  string  `json:"preferred_name" api:"required,minLength=1"`
+	Phone          string  `json:"phone"`
+	Email          string  `json:"email"`
+	GhinNumber     string  `json:"ghin_number"`
+	TextPreference string  `json:"text_preference" api:"enum=|uk-text-small|uk-text-default|uk-text-large"`
+	RoleIDs        []int64 `json:"role_ids"`
+}
+
+func (pr *playerRequest) validate() error {
+	if strings.TrimSpace(pr.Name) == "" {
+		return invalidf("name is required")
+	}
+	if strings.TrimSpace(pr.PreferredName) == "" {
+		return invalidf("preferred_name is required")
+	}
+
+	return nil
+}
+
+// apply copies the request onto p for caller c.
+func (pr *playerRequest) apply(ctx context.Context, p *player.Player, c caller) error {
+	p.Name = pr.Name
+	p.PreferredName = pr.PreferredName
+	p.Phone = pr.Phone
+	p.Email = pr.Email
+	p.GhinNumber = pr.GhinNumber
+	p.TextPreference = pr.TextPreference
+
+	if pr.RoleIDs == nil {
+		if p.Roles == nil {
+			p.Roles = make(role.Roles)
+		}
+		return nil
+	}
+
+	rs, err := role.GetRoles(ctx)
+	if err != nil {
+		return err
+	}
+	m, err := role.GetMatrix(ctx)
+	if err != nil {
+		return err
+	}
+	roles := make(role.Roles)
+	for _, id := range pr.RoleIDs {
+		name, ok := rs[id]
+		if !ok {
+			return invalidf("no role with id %d", id)
+		}
+		roles[id] = name
+	}
+	for id := range rs {
+		_, had := p.Roles[id]
+		_, has := roles[id]
+		if had != has {
+			err = c.checkAssign(id, m)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	p.Roles = roles
+
+	return nil
+}
+
+// roleBody is a role as the API shows it, with the permissions it grants.
+type roleBody struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+func newRoleBody(id int64, name string, m role.Matrix) roleBody {
+	b := roleBody{ID: id, Name: name, Permissions: m[id]}
+	if b.Permissions == nil {
+		b.Permissions = make([]string, 0)
+	}
+
+	return b
+}
+
+// roleBodies lists rs by ID.
+func roleBodies(rs role.Roles, m role.Matrix) []roleBody {
+	bs := make([]roleBody, 0, len(rs))
+	for id, name := range rs {
+		bs = append(bs, newRoleBody(id, name, m))
+	}
+	sort.Slice(bs, func(i, j int) bool {
+		return bs[i].ID < bs[j].ID
+	})
+
+	return bs
+}
+
+// getPlayer loads the player named by path variable name.
+func getPlayer(r *http.Request, name string) (player.Player, error) {
+	p := player.Player{}
+
+	id, err := pathID(r, name)
+	if err != nil {
+		return p, err
+	}
+
+	err = p.GetPlayerByID(r.Context(), id)
+
+	return p, err
+}
+
+func GetPlayersHandler(w http.ResponseWriter, r *http.Request) {
+	ps, err := player.GetPlayers(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, ps)
+}
+
+func AddPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	pr := playerRequest{}
+	err := decode(r, &pr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	p := player.Player{Preferences: player.DefaultPreferences}
+	err = pr.apply(r.Context(), &p, getCaller(r))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = player.AddPlayer(r.Context(), &p)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, p)
+}
+
+func GetPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := getPlayer(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, p)
+}
+
+func UpdatePlayerHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := getPlayer(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	pr := playerRequest{}
+	err = decode(r, &pr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = pr.apply(r.Context(), &p, getCaller(r))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = p.UpdatePlayer(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, p)
+}
+
+func DeletePlayerHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := getPlayer(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = p.DeletePlayer(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+// preferencesRequest is the body of a preferences PUT.
+type preferencesRequest player.Preferences
+
+// validate leaves the checks to UpdatePreferences, which makes the same ones
+// for the web pages.
+func (pr *preferencesRequest) validate() error {
+	return nil
+}
+
+func GetPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := getPlayer(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, p.Preferences)
+}
+
+func UpdatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := getPlayer(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	pr := preferencesRequest{}
+	err = decode(r, &pr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	p.Preferences = player.Preferences(pr)
+	err = p.UpdatePreferences(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, p.Preferences)
+}
+
+func GetPlayerRolesHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := getPlayer(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	m, err := role.GetMatrix(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, roleBodies(p.Roles, m))
+}
+
+// AddPlayerRoleHandler gives the player a role.  Roles are changed through
+// UpdatePlayer so that gaining or losing User subscribes or unsubscribes them
+// from league texts, as it does on the web pages.  The caller needs every
+// permission the role grants.
+func AddPlayerRoleHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := getPlayer(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	rid, err := pathID(r, "rid")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rs, err := role.GetRoleByID(r.Context(), rid)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m, err := role.GetMatrix(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	err = getCaller(r).checkAssign(rid, m)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, ok := p.Roles[rid]; !ok {
+		p.Roles[rid] = rs[rid]
+		err = p.UpdatePlayer(r.Context())
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	respond(w, http.StatusOK, roleBodies(p.Roles, m))
+}
+
+func DeletePlayerRoleHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := getPlayer(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	rid, err := pathID(r, "rid")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, ok := p.Roles[rid]; !ok {
+		respondError(w, r, notFoundf("player %d does not have role %d", p.ID, rid))
+		return
+	}
+	m, err := role.GetMatrix(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	err = getCaller(r).checkAssign(rid, m)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	delete(p.Roles, rid)
+	err = p.UpdatePlayer(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"mariners/apitoken"
+	"mariners/game"
+	"mariners/player"
+	"mariners/role"
+	"mariners/scoring"
+	"mariners/team"
+	"mariners/tee"
+
+	"github.com/gorilla/mux"
+)
+
+// errorBody is the body of every error response.
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// Errors the handlers return for the request itself, as opposed to the ones
+// the domain packages return.  errorStatus maps both to a status.
+var (
+	errBadRequest   = errors.New("bad request")
+	errUnauthorized = errors.New("unauthorized")
+	errForbidden    = errors.New("forbidden")
+	errInvalid      = errors.New("invalid request")
+	errNotFound     = errors.New("not found")
+	errConflict     = errors.New("conflict")
+)
+
+// request is a typed request body, which checks itself once decoded.
+type request interface {
+	validate() error
+}
+
+func invalidf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errInvalid, fmt.Sprintf(format, args...))
+}
+
+func unauthorizedf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errUnauthorized, fmt.Sprintf(format, args...))
+}
+
+func forbiddenf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errForbidden, fmt.Sprintf(format, args...))
+}
+
+func notFoundf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errNotFound, fmt.Sprintf(format, args...))
+}
+
+func conflictf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errConflict, fmt.Sprintf(format, args...))
+}
+
+// errorStatus is the status an error is reported with: 400 for requests that
+// can't be read, 401 without a good token, 403 when the token doesn't allow
+// the request, 404 for anything missing, 409 for changes the current state
+// of the league doesn't allow, 422 for requests that don't make sense, and
+// 500 for the rest.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, errBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, errUnauthorized), errors.Is(err, apitoken.ErrInvalidToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, errForbidden), errors.Is(err, apitoken.ErrScopeNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, errNotFound), errors.Is(err, sql.ErrNoRows),
+		errors.Is(err, game.ErrNotCheckedIn):
+		return http.StatusNotFound
+	case errors.Is(err, errConflict),
+		errors.Is(err, game.ErrCheckedIn),
+		errors.Is(err, game.ErrCheckinClosed),
+		errors.Is(err, game.ErrPlayOpen),
+		errors.Is(err, game.ErrMysteryDrawn),
+		errors.Is(err, game.ErrTeamsLocked),
+		errors.Is(err, game.ErrTeamsDrawn),
+		errors.Is(err, game.ErrGameCancelled),
+		errors.Is(err, team.ErrScoresEntered),
+		errors.Is(err, tee.ErrTeeInUse),
+		errors.Is(err, scoring.ErrDuplicateScore):
+		return http.StatusConflict
+	case errors.Is(err, errInvalid),
+		errors.Is(err, apitoken.ErrInvalidName),
+		errors.Is(err, apitoken.ErrUnknownScope),
+		errors.Is(err, game.ErrTeeTimeDay),
+		errors.Is(err, game.ErrRescheduleDay),
+		errors.Is(err, player.ErrInvalidPhone),
+		errors.Is(err, player.ErrInvalidPreferences),
+		errors.Is(err, role.ErrUnknownPermission),
+		errors.Is(err, scoring.ErrInvalidScore),
+		errors.Is(err, scoring.ErrNotCheckedIn):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respond writes v as the JSON body of a response with status.
+func respond(w http.ResponseWriter, status int, v interface{}) {
+	j, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("respond: %s", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "{\"error\": %q}\n", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+	fmt.Fprintf(w, "\n")
+}
+
+// respondError writes err as an errorBody with the status errorStatus gives
+// it.  Server errors are logged, since the caller can't do anything about
+// them.
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("%s %s: %s", r.Method, r.URL.Path, err)
+	}
+
+	respond(w, status, errorBody{Error: err.Error()})
+}
+
+// respondNoContent answers a successful delete.
+func respondNoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decode reads the JSON body of r into req, refusing fields req doesn't
+// have, and validates it.
+func decode(r *http.Request, req request) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errBadRequest, err)
+	}
+
+	return req.validate()
+}
+
+// pathID reads the numeric path variable name.
+func pathID(r *http.Request, name string) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s is not an ID", errBadRequest, name)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mariners/role"
+)
+
+// roleRequest is the body of a role POST or PUT.
+type roleRequest struct {
+	Name string `json:"name" api:"required,minLength=1,maxLength=45"`
+}
+
+func (rr *roleRequest) validate() error {
+	if strings.TrimSpace(rr.Name) == "" {
+		return invalidf("name is required")
+	}
+
+	return nil
+}
+
+// permissionsRequest is the body of a role permissions PUT, which replaces
+// what the role grants.
+type permissionsRequest struct {
+	Permissions []string `json:"permissions" api:"required,enum=players:write|roles:manage|events:manage|messages:send|games:manage|site:admin"`
+}
+
+func (pr *permissionsRequest) validate() error {
+	return nil
+}
+
+// builtinRoles are looked up by name around the code, so they can't be
+// renamed or deleted.
+var builtinRoles = []string{"Administrator", "User", "Game Manager", "Communications", "Tournament"}
+
+// checkBuiltin returns a conflict if role id is one of builtinRoles.
+func checkBuiltin(ctx context.Context, id int64) error {
+	rs, err := role.GetRoleByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, b := range builtinRoles {
+		if rs[id] == b {
+			return conflictf("%s is a built in role", b)
+		}
+	}
+
+	return nil
+}
+
+// checkRoleName returns a conflict if a role other than id is already called
+// name.
+func checkRoleName(ctx context.Context, name string, id int64) error {
+	eid, err := role.GetRoleIDByName(ctx, name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	case eid != id:
+		return conflictf("there is already a role called %s", name)
+	}
+
+	return nil
+}
+
+func GetRolesHandler(w http.ResponseWriter, r *http.Request) {
+	rs, err := role.GetRoles(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m, err := role.GetMatrix(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, roleBodies(rs, m))
+}
+
+func AddRoleHandler(w http.ResponseWriter, r *http.Request) {
+	rr := roleRequest{}
+	err := decode(r, &rr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = checkRoleName(r.Context(), rr.Name, 0)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rs, err := role.AddRole(r.Context(), rr.Name)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, roleBodies(rs, nil)[0])
+}
+
+func GetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rs, err := role.GetRoleByID(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m, err := role.GetMatrix(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, newRoleBody(id, rs[id], m))
+}
+
+func UpdateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = checkBuiltin(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rr := roleRequest{}
+	err = decode(r, &rr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = checkRoleName(r.Context(), rr.Name, id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = role.RenameRole(r.Context(), id, rr.Name)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m, err := role.GetMatrix(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, newRoleBody(id, rr.Name, m))
+}
+
+func DeleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = checkBuiltin(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = role.DeleteRole(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+// UpdateRolePermissionsHandler replaces what a role grants.  The caller can
+// only add permissions they have, and Administrator always keeps
+// roles:manage, so there's always someone who can put things back.
+func UpdateRolePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	pr := permissionsRequest{}
+	err = decode(r, &pr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rs, err := role.GetRoleByID(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m, err := role.GetMatrix(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	c := getCaller(r)
+	want := role.Matrix{id: pr.Permissions}
+	for _, perm := range pr.Permissions {
+		if !m.Grants(id, perm) && !c.can(perm) {
+			respondError(w, r, forbiddenf("%s can't be granted by a caller without it", perm))
+			return
+		}
+	}
+	if rs[id] == "Administrator" && !want.Grants(id, role.RolesManage) {
+		respondError(w, r, conflictf("Administrator can't lose %s", role.RolesManage))
+		return
+	}
+
+	err = role.SetPermissions(r.Context(), id, pr.Permissions)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	m, err = role.GetMatrix(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, newRoleBody(id, rs[id], m))
+}
+
+func GetPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, role.Permissions)
+}
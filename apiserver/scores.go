@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net/http"
+
+	"mariners/role"
+	"mariners/scoring"
+)
+
+// scoreRequest is the body of a score POST or PUT, as in schema/api/add_score.json.
+// On a PUT the player comes from the path, so idplayer is ignored.
+type scoreRequest struct {
+	PlayerID int64 `json:"idplayer"`
+	TeamID   int64 `json:"idteam" api:"required,min=1"`
+	First    int   `json:"first" api:"required"`
+	Second   int   `json:"second" api:"required"`
+	Third    int   `json:"third" api:"required"`
+	Fourth   int   `json:"fourth" api:"required"`
+	Fifth    int   `json:"fifth" api:"required"`
+	Sixth    int   `json:"sixth" api:"required"`
+	Seventh  int   `json:"seventh" api:"required"`
+	Eighth   int   `json:"eighth" api:"required"`
+	Ninth    int   `json:"ninth" api:"required"`
+}
+
+// validate leaves the strokes to the scoring package, which checks them the
+// same way for the web pages.
+func (sr *scoreRequest) validate() error {
+	return nil
+}
+
+func (sr *scoreRequest) score() scoring.Score {
+	s := scoring.Score{}
+	s.Player.ID = sr.PlayerID
+	s.TeamID.ID = sr.TeamID
+	s.Scores = [9]int{sr.First, sr.Second, sr.Third, sr.Fourth, sr.Fifth, sr.Sixth, sr.Seventh, sr.Eighth, sr.Ninth}
+
+	return s
+}
+
+// getScore loads the score for path variable pid in game id.
+func getScore(r *http.Request) (int64, scoring.Score, error) {
+	s := scoring.Score{}
+
+	gid, err := pathID(r, "id")
+	if err != nil {
+		return gid, s, err
+	}
+	pid, err := pathID(r, "pid")
+	if err != nil {
+		return gid, s, err
+	}
+
+	err = s.GetScore(r.Context(), gid, pid)
+
+	return gid, s, err
+}
+
+func GetScoresHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	ss, err := scoring.GetScoresByGameID(r.Context(), g.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, ss)
+}
+
+func AddScoreHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	sr := scoreRequest{}
+	err = decode(r, &sr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if sr.PlayerID == 0 {
+		respondError(w, r, invalidf("idplayer is required"))
+		return
+	}
+	c := getCaller(r)
+	if sr.PlayerID != c.Player.ID && !c.can(role.GamesManage) {
+		respondError(w, r, forbiddenf("only someone with %s can add someone else's card", role.GamesManage))
+		return
+	}
+
+	s := sr.score()
+	err = scoring.AddScore(r.Context(), g.ID, &s)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = s.GetScore(r.Context(), g.ID, s.Player.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, s)
+}
+
+func GetScoreHandler(w http.ResponseWriter, r *http.Request) {
+	_, s, err := getScore(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, s)
+}
+
+func UpdateScoreHandler(w http.ResponseWriter, r *http.Request) {
+	gid, s, err := getScore(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	sr := scoreRequest{}
+	err = decode(r, &sr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	sr.PlayerID = s.Player.ID
+
+	s = sr.score()
+	err = s.UpdateScore(r.Context(), gid)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = s.GetScore(r.Context(), gid, s.Player.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, s)
+}
+
+func DeleteScoreHandler(w http.ResponseWriter, r *http.Request) {
+	gid, s, err := getScore(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = scoring.DeleteScore(r.Context(), gid, s.Player.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+func GetAveragesHandler(w http.ResponseWriter, r *http.Request) {
+	as, err := scoring.GetAverages(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, as)
+}
+
+// par is par on each hole, for setting scores against the weather.
+var par = scoring.DefaultRules.Par
+
+// GetWeatherStatsHandler sets the league's scores against the weather
+// observed while they were played.
+func GetWeatherStatsHandler(w http.ResponseWriter, r *http.Request) {
+	ws, err := scoring.GetWeatherStats(r.Context(), scoring.Rules{Par: par})
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, ws)
+}
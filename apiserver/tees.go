@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mariners/tee"
+)
+
+// teeRequest is the body of a tee POST or PUT.
+type teeRequest struct {
+	Name string `json:"name" api:"required,minLength=1"`
+}
+
+func (tr *teeRequest) validate() error {
+	if strings.TrimSpace(tr.Name) == "" {
+		return invalidf("name is required")
+	}
+
+	return nil
+}
+
+// checkTeeName returns a conflict if a tee other than id is already called
+// name.
+func checkTeeName(ctx context.Context, name string, id int64) error {
+	t := tee.Tee{}
+	err := t.GetTeeByName(ctx, name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	case t.ID != id:
+		return conflictf("there is already a tee called %s", name)
+	}
+
+	return nil
+}
+
+// getTee loads the tee named by path variable id.
+func getTee(r *http.Request) (tee.Tee, error) {
+	t := tee.Tee{}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return t, err
+	}
+
+	err = t.GetTeeByID(r.Context(), id)
+
+	return t, err
+}
+
+func GetTeesHandler(w http.ResponseWriter, r *http.Request) {
+	ts, err := tee.GetTees(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, ts)
+}
+
+func AddTeeHandler(w http.ResponseWriter, r *http.Request) {
+	tr := teeRequest{}
+	err := decode(r, &tr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = checkTeeName(r.Context(), tr.Name, 0)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	t := tee.Tee{Name: tr.Name}
+	err = t.AddTee(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusCreated, t)
+}
+
+func GetTeeHandler(w http.ResponseWriter, r *http.Request) {
+	t, err := getTee(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, t)
+}
+
+func UpdateTeeHandler(w http.ResponseWriter, r *http.Request) {
+	t, err := getTee(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	tr := teeRequest{}
+	err = decode(r, &tr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = checkTeeName(r.Context(), tr.Name, t.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	t.Name = tr.Name
+	err = t.UpdateTee(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, t)
+}
+
+func DeleteTeeHandler(w http.ResponseWriter, r *http.Request) {
+	t, err := getTee(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = t.DeleteTee(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"mariners/db"
+	"mariners/weather"
+
+	"github.com/gorilla/mux"
+)
+
+// RefreshGameWeatherHandler fetches a game's forecast again, updating the
+// hours it has.
+func RefreshGameWeatherHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	err = g.RefreshWeather(r.Context(), weather.ReasonRequested)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, g.Weather)
+}
+
+// GetWeatherRevisionsHandler lists what each fetch of a game's forecast
+// said, newest first.
+func GetWeatherRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rs, err := weather.GetRevisions(r.Context(), g.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, rs)
+}
+
+// RecordGameWeatherHandler fetches the weather observed while a game was
+// played, replacing any it had.
+func RecordGameWeatherHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	wh, err := g.RecordWeather(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, wh)
+}
+
+// GetGameObservedHandler lists the weather observed while a game was played,
+// which is empty until it's been recorded.
+func GetGameObservedHandler(w http.ResponseWriter, r *http.Request) {
+	g, err := getGame(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	wh, err := weather.GetGameObserved(r.Context(), g.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, wh)
+}
+
+func GetWeatherHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	wt := weather.Weather{ID: id}
+	err = wt.GetWeatherByID(r.Context())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, wt)
+}
+
+func GetWeatherByDateHandler(w http.ResponseWriter, r *http.Request) {
+	t, err := db.ParseLocal("2006-01-02", mux.Vars(r)["date"])
+	if err != nil {
+		respondError(w, r, fmt.Errorf("%w: date must be YYYY-MM-DD", errBadRequest))
+		return
+	}
+
+	wh, err := weather.GetWeatherByDate(r.Context(), t)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if len(wh) == 0 {
+		respondError(w, r, notFoundf("no weather for %s", mux.Vars(r)["date"]))
+		return
+	}
+
+	respond(w, http.StatusOK, wh)
+}
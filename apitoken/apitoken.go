@@ -0,0 +1,187 @@
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"mariners/player"
+	"mariners/role"
+	"sort"
+	"strings"
+	"time"
+)
+
+// prefix starts every token, so one is easy to spot in a script or a leak.
+const prefix = "mpat_"
+
+// Token is a personal access token for the API.  Its scopes are permissions,
+// as in role.Permissions: reading the API only needs a token, and changing
+// anything needs the permission as a scope as well as from the player's
+// roles.  The secret itself is only seen once, when it's issued.
+type Token struct {
+	ID          int64     `json:"id"`
+	PlayerID    int64     `json:"player_id"`
+	Name        string    `json:"name"`
+	Scopes      []string  `json:"scopes"`
+	CreatedDate time.Time `json:"created_date"`
+}
+
+type Tokens []Token
+
+var (
+	// ErrInvalidToken is returned for a token that doesn't exist, or whose
+	// player doesn't any more.
+	ErrInvalidToken = errors.New("invalid API token")
+	// ErrInvalidName is returned when issuing a token without a name that
+	// fits.
+	ErrInvalidName = errors.New("a token needs a name of up to 45 characters")
+	// ErrUnknownScope is returned when issuing a token with a scope that
+	// isn't a permission.
+	ErrUnknownScope = errors.New("unknown scope")
+	// ErrScopeNotAllowed is returned when issuing a token with a scope the
+	// player's roles don't allow.
+	ErrScopeNotAllowed = errors.New("scope not allowed")
+)
+
+// Allowed reports whether p can hold scope: their roles grant it, or it's
+// owned, so it lets them change their own things.  Scopes are checked when a
+// token is used as well as when it's issued, so taking a role away takes the
+// scope from their tokens too.
+func Allowed(p player.Player, scope string) bool {
+	perm, err := role.GetPermission(scope)
+	if err != nil {
+		return false
+	}
+
+	return perm.Owned || p.Can(scope)
+}
+
+// Has reports whether t carries scope.
+func (t Token) Has(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hash is how a token is stored and looked up.  Tokens are random enough
+// that a plain SHA-256 can't be worked back.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a token named name for p carrying scopes, and returns it with
+// its secret.
+func Issue(ctx context.Context, p player.Player, name string, scopes []string) (Token, string, error) {
+	t := Token{PlayerID: p.ID, Name: strings.TrimSpace(name)}
+	if t.Name == "" || len(t.Name) > 45 {
+		return t, "", ErrInvalidName
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range scopes {
+		if _, err := role.GetPermission(s); err != nil {
+			return t, "", fmt.Errorf("%w: %s", ErrUnknownScope, s)
+		}
+		if !Allowed(p, s) {
+			return t, "", fmt.Errorf("%w: %s's roles don't allow %s", ErrScopeNotAllowed, p.PreferredName, s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			t.Scopes = append(t.Scopes, s)
+		}
+	}
+	sort.Strings(t.Scopes)
+	if t.Scopes == nil {
+		t.Scopes = make([]string, 0)
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return t, "", err
+	}
+	secret := prefix + base64.RawURLEncoding.EncodeToString(b)
+	t.CreatedDate = time.Now().UTC().Truncate(time.Second)
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err = getStore().AddToken(ctx, &t, hash(secret))
+	if err != nil {
+		return t, "", err
+	}
+
+	return t, secret, nil
+}
+
+// Authenticate finds the token with secret and its player.  The token comes
+// back with only the scopes the player's roles still allow.
+func Authenticate(ctx context.Context, secret string) (Token, player.Player, error) {
+	p := player.Player{}
+	if !strings.HasPrefix(secret, prefix) {
+		return Token{}, p, ErrInvalidToken
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	t, err := getStore().GetTokenByHash(ctx, hash(secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, p, ErrInvalidToken
+	}
+	if err != nil {
+		return t, p, err
+	}
+
+	err = p.GetPlayerByID(ctx, t.PlayerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, p, ErrInvalidToken
+	}
+	if err != nil {
+		return t, p, err
+	}
+
+	scopes := make([]string, 0, len(t.Scopes))
+	for _, s := range t.Scopes {
+		if Allowed(p, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	t.Scopes = scopes
+
+	return t, p, nil
+}
+
+// GetToken loads the token with id, without its secret.
+func GetToken(ctx context.Context, id int64) (Token, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetToken(ctx, id)
+}
+
+// GetTokens lists the player's tokens, oldest first.
+func GetTokens(ctx context.Context, pid int64) (Tokens, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetTokens(ctx, pid)
+}
+
+// Revoke deletes the token with id, so it can't be used again.
+func Revoke(ctx context.Context, id int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().DeleteToken(ctx, id)
+}
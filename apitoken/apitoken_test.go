@@ -0,0 +1,243 @@
+package apitoken
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"mariners/player"
+	"mariners/role"
+	"mariners/sms"
+	"strings"
+	"testing"
+)
+
+func openDB(t *testing.T) {
+	t.Helper()
+
+	dbtest.Open(t)
+	SetStore(nil)
+	player.SetStore(nil)
+	role.SetStore(nil)
+	sms.SetMessenger(sms.NewFakeMessenger(""))
+}
+
+// addPlayer saves a player holding the role called rolename, or none, and
+// loads them back with their permissions.
+func addPlayer(t *testing.T, name string, rolename string) player.Player {
+	t.Helper()
+
+	ctx := context.Background()
+	p := player.Player{Name: name, PreferredName: name, Phone: "4155550100", Roles: role.Roles{}}
+	if rolename != "" {
+		id, err := role.GetRoleIDByName(ctx, rolename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Roles[id] = rolename
+	}
+	err := player.AddPlayer(ctx, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.GetPlayerByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestHash(t *testing.T) {
+	h := hash("mpat_secret")
+	if len(h) != 64 || h != hash("mpat_secret") {
+		t.Errorf("hash %q isn't a stable SHA-256", h)
+	}
+	if h == hash("mpat_secreT") {
+		t.Error("different secrets hashed the same")
+	}
+}
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+	p := addPlayer(t, "Comms", "Communications")
+
+	tok, secret, err := Issue(ctx, p, "  scores script ", []string{role.MessagesSend, role.GamesManage, role.MessagesSend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, prefix) || len(secret) < len(prefix)+40 {
+		t.Errorf("secret %q doesn't look like a token", secret)
+	}
+	if tok.Name != "scores script" {
+		t.Errorf("name %q wasn't trimmed", tok.Name)
+	}
+	if len(tok.Scopes) != 2 || tok.Scopes[0] != role.GamesManage || tok.Scopes[1] != role.MessagesSend {
+		t.Errorf("scopes %v, want %s and %s once each", tok.Scopes, role.GamesManage, role.MessagesSend)
+	}
+
+	var stored string
+	err = db.Con.QueryRow("SELECT token_hash FROM api_token WHERE idtoken=?", tok.ID).Scan(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != hash(secret) {
+		t.Errorf("stored %q, want the secret's hash", stored)
+	}
+
+	got, gp, err := Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != tok.ID || gp.ID != p.ID {
+		t.Errorf("authenticated token %d for player %d, want %d for %d", got.ID, gp.ID, tok.ID, p.ID)
+	}
+	if !got.Has(role.MessagesSend) || !got.Has(role.GamesManage) || got.Has(role.SiteAdmin) {
+		t.Errorf("authenticated scopes %v, want %v", got.Scopes, tok.Scopes)
+	}
+	if !got.CreatedDate.Equal(tok.CreatedDate) {
+		t.Errorf("created %s, want %s", got.CreatedDate, tok.CreatedDate)
+	}
+}
+
+func TestIssueRejects(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+	p := addPlayer(t, "Member", "User")
+
+	tests := []struct {
+		name   string
+		tname  string
+		scopes []string
+		want   error
+	}{
+		{"no name", "   ", nil, ErrInvalidName},
+		{"a long name", strings.Repeat("x", 46), nil, ErrInvalidName},
+		{"an unknown scope", "script", []string{"golf:cheat"}, ErrUnknownScope},
+		{"a scope the roles don't allow", "script", []string{role.MessagesSend}, ErrScopeNotAllowed},
+	}
+	for _, tt := range tests {
+		_, secret, err := Issue(ctx, p, tt.tname, tt.scopes)
+		if !errors.Is(err, tt.want) || secret != "" {
+			t.Errorf("%s: %q, %v, want %v", tt.name, secret, err, tt.want)
+		}
+	}
+
+	tok, _, err := Issue(ctx, p, "own card", []string{role.GamesManage})
+	if err != nil {
+		t.Errorf("an owned scope without the role: %s", err)
+	}
+	if !tok.Has(role.GamesManage) {
+		t.Errorf("scopes %v, want the owned %s", tok.Scopes, role.GamesManage)
+	}
+
+	ts, err := GetTokens(ctx, p.ID)
+	if err != nil || len(ts) != 1 {
+		t.Errorf("%d tokens saved, %v, want only the one issued", len(ts), err)
+	}
+}
+
+func TestAuthenticateInvalid(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+	p := addPlayer(t, "Member", "User")
+	gone := addPlayer(t, "Gone", "User")
+
+	_, secret, err := Issue(ctx, p, "script", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, goneSecret, err := Issue(ctx, gone, "script", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gone.DeletePlayer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]string{
+		"empty":              "",
+		"no prefix":          strings.TrimPrefix(secret, prefix),
+		"another secret":     secret[:len(secret)-1] + "x",
+		"a deleted player's": goneSecret,
+	} {
+		_, _, err := Authenticate(ctx, s)
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestScopesFollowRoles(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+	p := addPlayer(t, "Comms", "Communications")
+
+	_, secret, err := Issue(ctx, p, "texts", []string{role.MessagesSend, role.PlayersWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = role.SetRolesByPlayerID(ctx, p.ID, role.Roles{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, _, err := Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Has(role.MessagesSend) {
+		t.Errorf("kept %s after the role that grants it was taken away", role.MessagesSend)
+	}
+	if !tok.Has(role.PlayersWrite) {
+		t.Errorf("lost the owned %s with the role", role.PlayersWrite)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+	p := addPlayer(t, "Member", "User")
+
+	first, secret, err := Issue(ctx, p, "first", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := Issue(ctx, p, "second", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := GetTokens(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 2 || ts[0].ID != first.ID || ts[1].ID != second.ID {
+		t.Errorf("tokens %+v, want first then second", ts)
+	}
+
+	err = Revoke(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = Authenticate(ctx, secret)
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("a revoked token = %v, want ErrInvalidToken", err)
+	}
+	_, err = GetToken(ctx, first.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("loading a revoked token = %v, want sql.ErrNoRows", err)
+	}
+	err = Revoke(ctx, first.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoking twice = %v, want sql.ErrNoRows", err)
+	}
+
+	ts, err = GetTokens(ctx, p.ID)
+	if err != nil || len(ts) != 1 || ts[0].ID != second.ID {
+		t.Errorf("after revoking, tokens %+v, %v, want only the second", ts, err)
+	}
+}
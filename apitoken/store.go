@@ -0,0 +1,131 @@
+package apitoken
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"mariners/db"
+	"strings"
+)
+
+// TokenStore loads and saves API tokens by the hash of their secret.
+type TokenStore interface {
+	AddToken(ctx context.Context, t *Token, hash string) error
+	GetToken(ctx context.Context, id int64) (Token, error)
+	GetTokenByHash(ctx context.Context, hash string) (Token, error)
+	GetTokens(ctx context.Context, pid int64) (Tokens, error)
+	DeleteToken(ctx context.Context, id int64) error
+}
+
+// SQLTokenStore is a TokenStore backed by the api_token table.
+type SQLTokenStore struct {
+	DB *sql.DB
+}
+
+func NewSQLTokenStore(con *sql.DB) *SQLTokenStore {
+	return &SQLTokenStore{DB: con}
+}
+
+var store TokenStore
+
+// SetStore replaces the TokenStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s TokenStore) {
+	store = s
+}
+
+func getStore() TokenStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLTokenStore(db.Con)
+}
+
+const tokenColumns = "idtoken, idplayer, name, scopes, created_date"
+
+// Scopes are stored space separated, as in an OAuth scope parameter.
+func scanToken(row interface{ Scan(...interface{}) error }, t *Token) error {
+	var scopes string
+	err := row.Scan(&t.ID, &t.PlayerID, &t.Name, &scopes, db.ScanTime(&t.CreatedDate))
+	if err != nil {
+		return err
+	}
+	t.Scopes = strings.Fields(scopes)
+
+	return nil
+}
+
+func (s *SQLTokenStore) AddToken(ctx context.Context, t *Token, hash string) error {
+	query := "INSERT INTO api_token (idtoken, idplayer, name, token_hash, scopes, created_date) VALUES (NULL, ?, ?, ?, ?, ?)"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		t.PlayerID,
+		t.Name,
+		hash,
+		strings.Join(t.Scopes, " "),
+		db.FormatTime(t.CreatedDate))
+	if err != nil {
+		return err
+	}
+	t.ID, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("no token added")
+	}
+
+	return nil
+}
+
+func (s *SQLTokenStore) GetToken(ctx context.Context, id int64) (Token, error) {
+	t := Token{}
+	query := "SELECT " + tokenColumns + " FROM api_token WHERE idtoken=?"
+	err := scanToken(db.Q(ctx, s.DB).QueryRowContext(ctx, query, id), &t)
+
+	return t, err
+}
+
+func (s *SQLTokenStore) GetTokenByHash(ctx context.Context, hash string) (Token, error) {
+	t := Token{}
+	query := "SELECT " + tokenColumns + " FROM api_token WHERE token_hash=?"
+	err := scanToken(db.Q(ctx, s.DB).QueryRowContext(ctx, query, hash), &t)
+
+	return t, err
+}
+
+func (s *SQLTokenStore) GetTokens(ctx context.Context, pid int64) (Tokens, error) {
+	ts := make(Tokens, 0)
+
+	query := "SELECT " + tokenColumns + " FROM api_token WHERE idplayer=? ORDER BY idtoken"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, pid)
+	if err != nil {
+		return ts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := Token{}
+		err = scanToken(rows, &t)
+		if err != nil {
+			return ts, err
+		}
+		ts = append(ts, t)
+	}
+
+	return ts, rows.Err()
+}
+
+func (s *SQLTokenStore) DeleteToken(ctx context.Context, id int64) error {
+	query := "DELETE FROM api_token WHERE idtoken=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return db.OneRow(res)
+}
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -137,13 +138,18 @@ func DBConnection() (*sql.DB, error) {
 	switch dblocation {
 	case "AWS":
 		log.Info().Msg("Using AWS DB...")
+		Dialect = DialectMySQL
 		db, err = sql.Open("mysql", getDSNAWS())
 	case "LOCAL":
 		log.Info().Msg("Using Local DB...")
+		Dialect = DialectMySQL
 		db, err = sql.Open("mysql", getDSNEnv())
 	case "SQLITE":
 		log.Info().Msg("Using sqlite3 DB...")
-		db, err = sql.Open("sqlite3", "../db/mplinksters.db")
+		Dialect = DialectSQLite
+		db, err = sql.Open("sqlite3", sqliteDSN(getEnv("MPSQLITE", "../db/mplinksters.db")))
+	default:
+		return nil, fmt.Errorf("unknown MPDB %s", dblocation)
 	}
 	if err != nil {
 		return nil, err
@@ -166,3 +172,61 @@ func DBConnection() (*sql.DB, error) {
 
 	return db, nil
 }
+
+// sqliteDSN adds to file what every SQLite connection needs: foreign keys
+// are only enforced on connections that ask.
+func sqliteDSN(file string) string {
+	if strings.Contains(file, "?") {
+		return file + "&_foreign_keys=1"
+	}
+
+	return file + "?_foreign_keys=1"
+}
+
+// Timeout is how long a query gets when the caller has no deadline of its own.
+const Timeout = 5 * time.Second
+
+// Context returns a context that expires after Timeout, for callers that
+// aren't handed one.
+func Context() (context.Context, context.CancelFunc) {
+	return WithTimeout(context.Background())
+}
+
+// WithTimeout returns ctx limited to Timeout, so a query made for a request
+// stops when the request goes away or runs too long, whichever is first.
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, Timeout)
+}
+
+// OpenMemory returns a private in-memory SQLite database with every migration
+// applied, for exercising stores without a real database.
+func OpenMemory() (*sql.DB, error) {
+	con, err := sql.Open("sqlite3", sqliteDSN(":memory:"))
+	if err != nil {
+		return nil, err
+	}
+	// Each connection to :memory: is its own database, so keep to one.
+	con.SetMaxOpenConns(1)
+
+	err = Migrate(con, DialectSQLite, -1)
+	if err != nil {
+		con.Close()
+		return nil, err
+	}
+
+	return con, nil
+}
+
+// OneRow returns sql.ErrNoRows if res didn't delete exactly one row, so a
+// delete by ID reports a missing row the way a lookup does.
+func OneRow(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+// Package dbtest has helpers for tests that save to the database.
+package dbtest
+
+import (
+	"database/sql"
+	"mariners/db"
+	"testing"
+)
+
+// Hostile are strings that go wrong if a value is pasted into SQL, escaped
+// twice, trimmed at a NUL or read back in another encoding.  Stores should
+// give each of them back byte for byte.
+var Hostile = []string{
+	`O'Brien`,
+	`''`,
+	`"quoted"`,
+	`back\slash \' \"`,
+	`100% of _all_ %s`,
+	"⛳ 🏌️‍♀️ café naïve",
+	"nul\x00in the middle",
+	"tab\tand\nnewline\r\n",
+	`'); DROP TABLE player; --`,
+	`x' OR '1'='1`,
+	`1; DELETE FROM score WHERE 1=1`,
+	"  padded  ",
+}
+
+// Open points db.Con at a fresh in-memory database with every migration
+// applied, closing it when the test ends.
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+
+	con, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Con = con
+	t.Cleanup(func() { con.Close() })
+
+	return con
+}
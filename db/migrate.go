@@ -0,0 +1,239 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SQL dialects with their own set of migrations.
+const (
+	DialectMySQL  = "mysql"
+	DialectSQLite = "sqlite"
+)
+
+// Dialect is the dialect of Con, set by DBConnection.
+var Dialect = DialectMySQL
+
+//go:embed migrations
+var migrationFS embed.FS
+
+// Migration is one numbered schema change, from
+// migrations/<dialect>/<version>_<name>.up.sql and its .down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrations []Migration
+
+// GetMigrations returns the migrations for dialect in order.
+func GetMigrations(dialect string) (Migrations, error) {
+	dir := path.Join("migrations", dialect)
+	files, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %s", dialect)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, f := range files {
+		name := f.Name()
+		var base string
+		up := strings.HasSuffix(name, ".up.sql")
+		switch {
+		case up:
+			base = strings.TrimSuffix(name, ".up.sql")
+		case strings.HasSuffix(name, ".down.sql"):
+			base = strings.TrimSuffix(name, ".down.sql")
+		default:
+			continue
+		}
+
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>", name)
+		}
+		v, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %s", name, err)
+		}
+
+		b, err := migrationFS.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[v]
+		if !ok {
+			m = &Migration{Version: v, Name: parts[1]}
+			byVersion[v] = m
+		}
+		if up {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	ms := make(Migrations, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down", m.Version, m.Name)
+		}
+		ms = append(ms, *m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+
+	return ms, nil
+}
+
+// Latest is the version the newest migration brings the schema to.
+func (ms Migrations) Latest() int {
+	if len(ms) == 0 {
+		return 0
+	}
+
+	return ms[len(ms)-1].Version
+}
+
+func ensureVersionTable(con *sql.DB) error {
+	query := "CREATE TABLE IF NOT EXISTS schema_version (version INT NOT NULL PRIMARY KEY, name VARCHAR(100) NOT NULL, applied VARCHAR(19) NOT NULL)"
+	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelfunc()
+	_, err := con.ExecContext(ctx, query)
+
+	return err
+}
+
+// SchemaVersion returns the version of the last migration applied to con, or 0
+// for none.
+func SchemaVersion(con *sql.DB) (int, error) {
+	err := ensureVersionTable(con)
+	if err != nil {
+		return 0, err
+	}
+
+	var v sql.NullInt64
+	query := "SELECT MAX(version) FROM schema_version"
+	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelfunc()
+	err = con.QueryRowContext(ctx, query).Scan(&v)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(v.Int64), nil
+}
+
+// Migrate moves con's schema up or down to version target, or to the latest
+// version if target is negative.
+func Migrate(con *sql.DB, dialect string, target int) error {
+	ms, err := GetMigrations(dialect)
+	if err != nil {
+		return err
+	}
+	if target < 0 {
+		target = ms.Latest()
+	}
+	if target > ms.Latest() {
+		return fmt.Errorf("no migration %d, the latest is %d", target, ms.Latest())
+	}
+
+	cur, err := SchemaVersion(con)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range ms {
+		if m.Version > cur && m.Version <= target {
+			log.Info().Msgf("Applying migration %d_%s...", m.Version, m.Name)
+			err = apply(con, m.Up)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %s", m.Version, m.Name, err)
+			}
+			err = setVersion(con, m, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := len(ms) - 1; i >= 0; i-- {
+		m := ms[i]
+		if m.Version <= cur && m.Version > target {
+			log.Info().Msgf("Reverting migration %d_%s...", m.Version, m.Name)
+			err = apply(con, m.Down)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %s", m.Version, m.Name, err)
+			}
+			err = setVersion(con, m, false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func setVersion(con *sql.DB, m Migration, up bool) error {
+	query := "DELETE FROM schema_version WHERE version=?"
+	args := []interface{}{m.Version}
+	if up {
+		query = "INSERT INTO schema_version (version, name, applied) VALUES (?, ?, ?)"
+		args = append(args, m.Name, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelfunc()
+	_, err := con.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+// apply runs each statement in a migration.  Statements end with a semicolon
+// at the end of a line, and lines starting with -- are comments.
+func apply(con *sql.DB, script string) error {
+	var stmt strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		stmt.WriteString(line)
+		stmt.WriteString("\n")
+
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			err := exec(con, stmt.String())
+			if err != nil {
+				return err
+			}
+			stmt.Reset()
+		}
+	}
+
+	if strings.TrimSpace(stmt.String()) != "" {
+		return exec(con, stmt.String())
+	}
+
+	return nil
+}
+
+func exec(con *sql.DB, stmt string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelfunc()
+	_, err := con.ExecContext(ctx, stmt)
+
+	return err
+}
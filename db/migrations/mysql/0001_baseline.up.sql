@@ -0,0 +1,145 @@
+-- Tables as the app used them before migrations existed.  IF NOT EXISTS lets
+-- an existing database adopt migrations without losing anything.
+
+CREATE TABLE IF NOT EXISTS role (
+    idrole INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(45) NOT NULL,
+    PRIMARY KEY (idrole)
+);
+
+INSERT INTO role (name) SELECT 'Administrator' FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'Administrator');
+INSERT INTO role (name) SELECT 'User' FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'User');
+INSERT INTO role (name) SELECT 'Game Manager' FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'Game Manager');
+INSERT INTO role (name) SELECT 'Communications' FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'Communications');
+INSERT INTO role (name) SELECT 'Tournament' FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'Tournament');
+
+CREATE TABLE IF NOT EXISTS player (
+    idplayer INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    preferred_name VARCHAR(45) NOT NULL DEFAULT '',
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    ghin_number VARCHAR(20) NOT NULL DEFAULT '',
+    main_sub_arn VARCHAR(200) NOT NULL DEFAULT '',
+    text_preference VARCHAR(45) NOT NULL DEFAULT '',
+    token VARCHAR(45) NOT NULL DEFAULT '',
+    PRIMARY KEY (idplayer)
+);
+
+CREATE TABLE IF NOT EXISTS role_members (
+    idrole INT NOT NULL,
+    idplayer INT NOT NULL,
+    PRIMARY KEY (idrole, idplayer)
+);
+
+CREATE TABLE IF NOT EXISTS nicknames (
+    idplayer INT NOT NULL,
+    nickname VARCHAR(45) NOT NULL,
+    PRIMARY KEY (idplayer, nickname)
+);
+
+CREATE TABLE IF NOT EXISTS ninthtee (
+    idninthtee INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(45) NOT NULL,
+    PRIMARY KEY (idninthtee)
+);
+
+CREATE TABLE IF NOT EXISTS weather (
+    idweather INT NOT NULL AUTO_INCREMENT,
+    weather_date DATETIME NOT NULL,
+    temperature INT NOT NULL DEFAULT 0,
+    feels_like INT NOT NULL DEFAULT 0,
+    precipitation DECIMAL(5,2) NOT NULL DEFAULT 0,
+    wind DECIMAL(5,1) NOT NULL DEFAULT 0,
+    wind_gust DECIMAL(5,1) NOT NULL DEFAULT 0,
+    wind_direction VARCHAR(5) NOT NULL DEFAULT '',
+    humidity INT NOT NULL DEFAULT 0,
+    cloudcover INT NOT NULL DEFAULT 0,
+    weather_text VARCHAR(100) NOT NULL DEFAULT '',
+    weather_icon VARCHAR(200) NOT NULL DEFAULT '',
+    weather_link VARCHAR(200) NOT NULL DEFAULT '',
+    PRIMARY KEY (idweather),
+    KEY weather_date (weather_date)
+);
+
+CREATE TABLE IF NOT EXISTS game (
+    idgame INT NOT NULL AUTO_INCREMENT,
+    game_date DATE NOT NULL,
+    idninthtee INT NOT NULL DEFAULT 0,
+    ismatch BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (idgame),
+    KEY game_date (game_date)
+);
+
+CREATE TABLE IF NOT EXISTS checkins (
+    idplayer INT NOT NULL,
+    idgame INT NOT NULL,
+    checkin_date DATETIME NOT NULL,
+    PRIMARY KEY (idplayer, idgame)
+);
+
+CREATE TABLE IF NOT EXISTS mysteries (
+    idgame INT NOT NULL,
+    hole INT NOT NULL,
+    PRIMARY KEY (idgame)
+);
+
+CREATE TABLE IF NOT EXISTS team (
+    idteam INT NOT NULL AUTO_INCREMENT,
+    idgame INT NOT NULL,
+    PRIMARY KEY (idteam),
+    KEY team_game (idgame)
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    idteam INT NOT NULL,
+    idplayer INT NOT NULL DEFAULT 0,
+    ghost BOOLEAN NOT NULL DEFAULT FALSE,
+    ninth_dropped BOOLEAN NOT NULL DEFAULT FALSE,
+    KEY team_members_team (idteam)
+);
+
+CREATE TABLE IF NOT EXISTS score (
+    idplayer INT NOT NULL,
+    idteam INT NOT NULL,
+    first INT NOT NULL,
+    second INT NOT NULL,
+    third INT NOT NULL,
+    fourth INT NOT NULL,
+    fifth INT NOT NULL,
+    sixth INT NOT NULL,
+    seventh INT NOT NULL,
+    eighth INT NOT NULL,
+    ninth INT NOT NULL,
+    PRIMARY KEY (idplayer, idteam)
+);
+
+CREATE TABLE IF NOT EXISTS event (
+    idevent INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    event_date DATETIME NOT NULL,
+    paid_event BOOLEAN NOT NULL DEFAULT FALSE,
+    topic_arn VARCHAR(200) NOT NULL DEFAULT '',
+    description TEXT NOT NULL,
+    ownerid INT NOT NULL,
+    invite_only BOOLEAN NOT NULL DEFAULT FALSE,
+    cost DECIMAL(8,2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (idevent)
+);
+
+CREATE TABLE IF NOT EXISTS event_members (
+    idevent INT NOT NULL,
+    idplayer INT NOT NULL,
+    paid BOOLEAN NOT NULL DEFAULT FALSE,
+    subscription_arn VARCHAR(200) NOT NULL DEFAULT '',
+    PRIMARY KEY (idevent, idplayer)
+);
+
+CREATE TABLE IF NOT EXISTS event_messages (
+    idevent INT NOT NULL,
+    idsender INT NOT NULL,
+    message TEXT NOT NULL,
+    message_date DATETIME NOT NULL,
+    idmessage VARCHAR(100) NOT NULL DEFAULT '',
+    KEY event_messages_event (idevent)
+);
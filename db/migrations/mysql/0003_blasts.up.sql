@@ -0,0 +1,28 @@
+-- Queue times are kept as "2006-01-02 15:04:05" strings in league time, with
+-- '' for not yet.
+
+CREATE TABLE blast (
+    idblast INT NOT NULL AUTO_INCREMENT,
+    idsender INT NOT NULL,
+    label VARCHAR(100) NOT NULL DEFAULT '',
+    message TEXT NOT NULL,
+    blast_date VARCHAR(19) NOT NULL,
+    PRIMARY KEY (idblast)
+);
+
+CREATE TABLE blast_messages (
+    idblastmessage INT NOT NULL AUTO_INCREMENT,
+    idblast INT NOT NULL,
+    idplayer INT NOT NULL,
+    channel VARCHAR(10) NOT NULL DEFAULT 'sms',
+    address VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(10) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt VARCHAR(19) NOT NULL,
+    last_error TEXT NOT NULL,
+    idmessage VARCHAR(100) NOT NULL DEFAULT '',
+    sent_date VARCHAR(19) NOT NULL DEFAULT '',
+    PRIMARY KEY (idblastmessage),
+    KEY blast_messages_blast (idblast),
+    KEY blast_messages_due (status, next_attempt)
+);
@@ -0,0 +1,15 @@
+-- Side effects outside the database, like SNS subscriptions, are written here
+-- in the same transaction as the change that needs them and carried out once
+-- it commits.
+
+CREATE TABLE outbox (
+    idoutbox INT NOT NULL AUTO_INCREMENT,
+    kind VARCHAR(45) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL,
+    created_date VARCHAR(19) NOT NULL,
+    PRIMARY KEY (idoutbox),
+    KEY outbox_pending (status, idoutbox)
+);
@@ -0,0 +1,53 @@
+-- Back to local time, with game dates as bare dates again.
+
+UPDATE weather SET weather_date = weather_date - INTERVAL IF(
+    weather_date >= MAKEDATE(YEAR(weather_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(weather_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND weather_date < MAKEDATE(YEAR(weather_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(weather_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE weather_date >= '1000-01-01';
+
+UPDATE game SET game_date = game_date - INTERVAL IF(
+    game_date >= MAKEDATE(YEAR(game_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(game_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND game_date < MAKEDATE(YEAR(game_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(game_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE game_date >= '1000-01-01';
+
+UPDATE checkins SET checkin_date = checkin_date - INTERVAL IF(
+    checkin_date >= MAKEDATE(YEAR(checkin_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(checkin_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND checkin_date < MAKEDATE(YEAR(checkin_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(checkin_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE checkin_date >= '1000-01-01';
+
+UPDATE event SET event_date = event_date - INTERVAL IF(
+    event_date >= MAKEDATE(YEAR(event_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(event_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND event_date < MAKEDATE(YEAR(event_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(event_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE event_date >= '1000-01-01';
+
+UPDATE event_messages SET message_date = message_date - INTERVAL IF(
+    message_date >= MAKEDATE(YEAR(message_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(message_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND message_date < MAKEDATE(YEAR(message_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(message_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE message_date >= '1000-01-01';
+
+ALTER TABLE game MODIFY game_date DATE NOT NULL;
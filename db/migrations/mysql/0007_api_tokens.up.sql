@@ -0,0 +1,15 @@
+-- Personal access tokens for the API.  They're kept apart from player.token,
+-- the web session, so logging in or out of the site leaves scripts working.
+-- Only a SHA-256 of each token is stored.
+
+CREATE TABLE api_token (
+    idtoken INT NOT NULL AUTO_INCREMENT,
+    idplayer INT NOT NULL,
+    name VARCHAR(45) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    created_date DATETIME NOT NULL,
+    PRIMARY KEY (idtoken),
+    UNIQUE KEY api_token_hash (token_hash),
+    KEY api_token_player (idplayer)
+);
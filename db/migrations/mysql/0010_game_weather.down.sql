@@ -0,0 +1,4 @@
+ALTER TABLE weather DROP FOREIGN KEY weather_game;
+ALTER TABLE weather DROP INDEX weather_game_date;
+ALTER TABLE weather DROP COLUMN idgame;
+ALTER TABLE game DROP COLUMN tee_time;
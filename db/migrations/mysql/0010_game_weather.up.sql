@@ -0,0 +1,24 @@
+-- A game's forecast hangs off the game rather than being matched by date, and
+-- refreshing it updates its hours in place.  Games also keep their tee time,
+-- which the hours forecast are worked out from; it was always noon.
+
+ALTER TABLE game ADD COLUMN tee_time DATETIME NULL;
+
+UPDATE game SET tee_time = game_date + INTERVAL 12 HOUR WHERE game_date >= '1000-01-01';
+
+ALTER TABLE weather ADD COLUMN idgame INT NULL;
+
+UPDATE weather INNER JOIN game
+    ON weather.weather_date >= game.game_date
+        AND weather.weather_date < game.game_date + INTERVAL 1 DAY
+SET weather.idgame = game.idgame;
+
+-- Fetching again used to add the hours again, so keep the newest of each.
+DELETE FROM weather WHERE idgame IS NOT NULL AND idweather NOT IN (
+    SELECT idweather FROM (
+        SELECT MAX(idweather) AS idweather FROM weather WHERE idgame IS NOT NULL GROUP BY idgame, weather_date
+    ) AS newest);
+
+ALTER TABLE weather
+    ADD UNIQUE KEY weather_game_date (idgame, weather_date),
+    ADD CONSTRAINT weather_game FOREIGN KEY (idgame) REFERENCES game (idgame) ON DELETE CASCADE;
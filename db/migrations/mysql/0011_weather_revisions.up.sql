@@ -0,0 +1,16 @@
+-- Every time a game's forecast is fetched, what came back is kept here as
+-- JSON, so how the forecast changed up to the game can be looked back on.
+-- reason says what fetched it: the game being added, the schedule, the run-up
+-- to the tee time or someone asking.
+
+CREATE TABLE weather_revision (
+    idrevision INT NOT NULL AUTO_INCREMENT,
+    idgame INT NOT NULL,
+    revision_date DATETIME NOT NULL,
+    reason VARCHAR(45) NOT NULL DEFAULT '',
+    forecast TEXT NOT NULL,
+    PRIMARY KEY (idrevision),
+    KEY weather_revision_game_date (idgame, revision_date),
+    KEY weather_revision_date (revision_date),
+    CONSTRAINT weather_revision_game FOREIGN KEY (idgame) REFERENCES game (idgame) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+ALTER TABLE game DROP FOREIGN KEY game_rescheduled;
+ALTER TABLE game DROP COLUMN idrescheduled;
+ALTER TABLE game DROP COLUMN cancelled;
+ALTER TABLE weather DROP COLUMN chance_of_rain;
@@ -0,0 +1,4 @@
+DELETE FROM weather WHERE observed = TRUE;
+ALTER TABLE weather ADD UNIQUE KEY weather_game_date (idgame, weather_date);
+ALTER TABLE weather DROP INDEX weather_game_observed_date;
+ALTER TABLE weather DROP COLUMN observed;
@@ -0,0 +1,8 @@
+-- Games keep the weather observed while they were played, next to their
+-- forecast, so scores can be compared with it.
+
+ALTER TABLE weather
+    ADD COLUMN observed BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD UNIQUE KEY weather_game_observed_date (idgame, observed, weather_date);
+
+ALTER TABLE weather DROP INDEX weather_game_date;
@@ -0,0 +1,33 @@
+-- Back to league time, with '' for not sent yet.
+
+UPDATE blast SET blast_date = blast_date - INTERVAL IF(
+    blast_date >= MAKEDATE(YEAR(blast_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(blast_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND blast_date < MAKEDATE(YEAR(blast_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(blast_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE blast_date >= '1000-01-01';
+
+UPDATE blast_messages SET next_attempt = next_attempt - INTERVAL IF(
+    next_attempt >= MAKEDATE(YEAR(next_attempt), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(next_attempt), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND next_attempt < MAKEDATE(YEAR(next_attempt), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(next_attempt), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE next_attempt >= '1000-01-01';
+
+UPDATE blast_messages SET sent_date = sent_date - INTERVAL IF(
+    sent_date >= MAKEDATE(YEAR(sent_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(sent_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND sent_date < MAKEDATE(YEAR(sent_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(sent_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE sent_date >= '1000-01-01';
+
+UPDATE blast_messages SET sent_date = '' WHERE sent_date < '1000-01-01';
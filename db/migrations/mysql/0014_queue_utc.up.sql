@@ -0,0 +1,36 @@
+-- Queue times were kept in league time, with '' for not sent yet.  From here
+-- on they're UTC "YYYY-MM-DD HH:MM:SS" like the other dates, with the zero
+-- date for not sent yet.  Pacific daylight time is worked out the same way as
+-- in 0006.
+
+UPDATE blast SET blast_date = blast_date + INTERVAL IF(
+    blast_date >= MAKEDATE(YEAR(blast_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(blast_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 2 HOUR
+    AND blast_date < MAKEDATE(YEAR(blast_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(blast_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 2 HOUR,
+    7, 8) HOUR
+WHERE blast_date >= '1000-01-01';
+
+UPDATE blast_messages SET next_attempt = next_attempt + INTERVAL IF(
+    next_attempt >= MAKEDATE(YEAR(next_attempt), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(next_attempt), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 2 HOUR
+    AND next_attempt < MAKEDATE(YEAR(next_attempt), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(next_attempt), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 2 HOUR,
+    7, 8) HOUR
+WHERE next_attempt >= '1000-01-01';
+
+UPDATE blast_messages SET sent_date = sent_date + INTERVAL IF(
+    sent_date >= MAKEDATE(YEAR(sent_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(sent_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 2 HOUR
+    AND sent_date < MAKEDATE(YEAR(sent_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(sent_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 2 HOUR,
+    7, 8) HOUR
+WHERE sent_date >= '1000-01-01';
+
+UPDATE blast_messages SET sent_date = '0001-01-01 00:00:00' WHERE sent_date = '';
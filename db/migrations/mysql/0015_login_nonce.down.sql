@@ -0,0 +1,2 @@
+ALTER TABLE login_code DROP INDEX login_code_nonce;
+ALTER TABLE login_code DROP COLUMN nonce_hash;
@@ -0,0 +1,8 @@
+-- A login code is tied to the browser that asked for it by a random nonce
+-- kept in its login cookie.  Only the nonce's hash is stored, and the code is
+-- looked up by it, so the cookie no longer names the player.  Codes from
+-- before have no nonce and can't be used.
+
+ALTER TABLE login_code
+    ADD COLUMN nonce_hash CHAR(64) NOT NULL DEFAULT '',
+    ADD KEY login_code_nonce (nonce_hash);
@@ -0,0 +1 @@
+ALTER TABLE blast DROP COLUMN category;
@@ -0,0 +1,11 @@
+-- Back to league time.
+
+UPDATE outbox SET created_date = created_date - INTERVAL IF(
+    created_date >= MAKEDATE(YEAR(created_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(created_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 10 HOUR
+    AND created_date < MAKEDATE(YEAR(created_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(created_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 9 HOUR,
+    7, 8) HOUR
+WHERE created_date >= '1000-01-01';
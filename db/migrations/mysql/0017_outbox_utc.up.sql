@@ -0,0 +1,13 @@
+-- Outbox dates were kept in league time.  From here on they're UTC
+-- "YYYY-MM-DD HH:MM:SS" like the other dates.  Pacific daylight time is
+-- worked out the same way as in 0006.
+
+UPDATE outbox SET created_date = created_date + INTERVAL IF(
+    created_date >= MAKEDATE(YEAR(created_date), 1) + INTERVAL 2 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(created_date), 1) + INTERVAL 2 MONTH)) % 7 + 7) DAY
+        + INTERVAL 2 HOUR
+    AND created_date < MAKEDATE(YEAR(created_date), 1) + INTERVAL 10 MONTH
+        + INTERVAL ((8 - DAYOFWEEK(MAKEDATE(YEAR(created_date), 1) + INTERVAL 10 MONTH)) % 7) DAY
+        + INTERVAL 2 HOUR,
+    7, 8) HOUR
+WHERE created_date >= '1000-01-01';
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS event_messages;
+DROP TABLE IF EXISTS event_members;
+DROP TABLE IF EXISTS event;
+DROP TABLE IF EXISTS score;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS team;
+DROP TABLE IF EXISTS mysteries;
+DROP TABLE IF EXISTS checkins;
+DROP TABLE IF EXISTS game;
+DROP TABLE IF EXISTS weather;
+DROP TABLE IF EXISTS ninthtee;
+DROP TABLE IF EXISTS nicknames;
+DROP TABLE IF EXISTS role_members;
+DROP TABLE IF EXISTS player;
+DROP TABLE IF EXISTS role;
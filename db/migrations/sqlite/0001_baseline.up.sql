@@ -0,0 +1,144 @@
+-- Tables as the app used them before migrations existed.  IF NOT EXISTS lets
+-- an existing database adopt migrations without losing anything.  Dates are
+-- TEXT so they scan into strings the same way MySQL dates do.
+
+CREATE TABLE IF NOT EXISTS role (
+    idrole INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(45) NOT NULL
+);
+
+INSERT INTO role (name) SELECT 'Administrator' WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'Administrator');
+INSERT INTO role (name) SELECT 'User' WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'User');
+INSERT INTO role (name) SELECT 'Game Manager' WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'Game Manager');
+INSERT INTO role (name) SELECT 'Communications' WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'Communications');
+INSERT INTO role (name) SELECT 'Tournament' WHERE NOT EXISTS (SELECT 1 FROM role WHERE name = 'Tournament');
+
+CREATE TABLE IF NOT EXISTS player (
+    idplayer INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    preferred_name VARCHAR(45) NOT NULL DEFAULT '',
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    ghin_number VARCHAR(20) NOT NULL DEFAULT '',
+    main_sub_arn VARCHAR(200) NOT NULL DEFAULT '',
+    text_preference VARCHAR(45) NOT NULL DEFAULT '',
+    token VARCHAR(45) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_members (
+    idrole INT NOT NULL,
+    idplayer INT NOT NULL,
+    PRIMARY KEY (idrole, idplayer)
+);
+
+CREATE TABLE IF NOT EXISTS nicknames (
+    idplayer INT NOT NULL,
+    nickname VARCHAR(45) NOT NULL,
+    PRIMARY KEY (idplayer, nickname)
+);
+
+CREATE TABLE IF NOT EXISTS ninthtee (
+    idninthtee INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(45) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS weather (
+    idweather INTEGER PRIMARY KEY AUTOINCREMENT,
+    weather_date TEXT NOT NULL,
+    temperature INT NOT NULL DEFAULT 0,
+    feels_like INT NOT NULL DEFAULT 0,
+    precipitation DECIMAL(5,2) NOT NULL DEFAULT 0,
+    wind DECIMAL(5,1) NOT NULL DEFAULT 0,
+    wind_gust DECIMAL(5,1) NOT NULL DEFAULT 0,
+    wind_direction VARCHAR(5) NOT NULL DEFAULT '',
+    humidity INT NOT NULL DEFAULT 0,
+    cloudcover INT NOT NULL DEFAULT 0,
+    weather_text VARCHAR(100) NOT NULL DEFAULT '',
+    weather_icon VARCHAR(200) NOT NULL DEFAULT '',
+    weather_link VARCHAR(200) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS weather_date ON weather (weather_date);
+
+CREATE TABLE IF NOT EXISTS game (
+    idgame INTEGER PRIMARY KEY AUTOINCREMENT,
+    game_date TEXT NOT NULL,
+    idninthtee INT NOT NULL DEFAULT 0,
+    ismatch BOOLEAN NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS game_date ON game (game_date);
+
+CREATE TABLE IF NOT EXISTS checkins (
+    idplayer INT NOT NULL,
+    idgame INT NOT NULL,
+    checkin_date TEXT NOT NULL,
+    PRIMARY KEY (idplayer, idgame)
+);
+
+CREATE TABLE IF NOT EXISTS mysteries (
+    idgame INT NOT NULL,
+    hole INT NOT NULL,
+    PRIMARY KEY (idgame)
+);
+
+CREATE TABLE IF NOT EXISTS team (
+    idteam INTEGER PRIMARY KEY AUTOINCREMENT,
+    idgame INT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS team_game ON team (idgame);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    idteam INT NOT NULL,
+    idplayer INT NOT NULL DEFAULT 0,
+    ghost BOOLEAN NOT NULL DEFAULT 0,
+    ninth_dropped BOOLEAN NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS team_members_team ON team_members (idteam);
+
+CREATE TABLE IF NOT EXISTS score (
+    idplayer INT NOT NULL,
+    idteam INT NOT NULL,
+    first INT NOT NULL,
+    second INT NOT NULL,
+    third INT NOT NULL,
+    fourth INT NOT NULL,
+    fifth INT NOT NULL,
+    sixth INT NOT NULL,
+    seventh INT NOT NULL,
+    eighth INT NOT NULL,
+    ninth INT NOT NULL,
+    PRIMARY KEY (idplayer, idteam)
+);
+
+CREATE TABLE IF NOT EXISTS event (
+    idevent INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    event_date TEXT NOT NULL,
+    paid_event BOOLEAN NOT NULL DEFAULT 0,
+    topic_arn VARCHAR(200) NOT NULL DEFAULT '',
+    description TEXT NOT NULL,
+    ownerid INT NOT NULL,
+    invite_only BOOLEAN NOT NULL DEFAULT 0,
+    cost DECIMAL(8,2) NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS event_members (
+    idevent INT NOT NULL,
+    idplayer INT NOT NULL,
+    paid BOOLEAN NOT NULL DEFAULT 0,
+    subscription_arn VARCHAR(200) NOT NULL DEFAULT '',
+    PRIMARY KEY (idevent, idplayer)
+);
+
+CREATE TABLE IF NOT EXISTS event_messages (
+    idevent INT NOT NULL,
+    idsender INT NOT NULL,
+    message TEXT NOT NULL,
+    message_date TEXT NOT NULL,
+    idmessage VARCHAR(100) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS event_messages_event ON event_messages (idevent);
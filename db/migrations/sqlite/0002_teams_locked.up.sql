@@ -0,0 +1,4 @@
+-- Games remember when the Game Manager has locked the team draw, so the
+-- teams can't be drawn again.
+
+ALTER TABLE game ADD COLUMN teams_locked BOOLEAN NOT NULL DEFAULT 0;
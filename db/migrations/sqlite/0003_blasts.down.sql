@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS blast_messages;
+DROP TABLE IF EXISTS blast;
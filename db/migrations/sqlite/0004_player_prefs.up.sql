@@ -0,0 +1,11 @@
+CREATE TABLE player_prefs (
+    idplayer INT NOT NULL,
+    channel VARCHAR(10) NOT NULL DEFAULT 'sms',
+    league BOOLEAN NOT NULL DEFAULT 1,
+    tournament BOOLEAN NOT NULL DEFAULT 1,
+    events BOOLEAN NOT NULL DEFAULT 1,
+    game BOOLEAN NOT NULL DEFAULT 1,
+    quiet_start INT NOT NULL DEFAULT 20,
+    quiet_end INT NOT NULL DEFAULT 8,
+    PRIMARY KEY (idplayer)
+);
@@ -0,0 +1,15 @@
+-- Side effects outside the database, like SNS subscriptions, are written here
+-- in the same transaction as the change that needs them and carried out once
+-- it commits.
+
+CREATE TABLE outbox (
+    idoutbox INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind VARCHAR(45) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL,
+    created_date VARCHAR(19) NOT NULL
+);
+
+CREATE INDEX outbox_pending ON outbox (status, idoutbox);
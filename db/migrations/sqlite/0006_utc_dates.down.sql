@@ -0,0 +1,31 @@
+-- Back to local time, with game dates as bare dates again.
+
+UPDATE weather SET weather_date = datetime(weather_date, CASE
+    WHEN weather_date >= date(strftime('%Y', weather_date) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND weather_date < date(strftime('%Y', weather_date) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE weather_date >= '1000';
+
+UPDATE game SET game_date = date(game_date, CASE
+    WHEN game_date >= date(strftime('%Y', game_date) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND game_date < date(strftime('%Y', game_date) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE game_date >= '1000';
+
+UPDATE checkins SET checkin_date = datetime(checkin_date, CASE
+    WHEN checkin_date >= date(strftime('%Y', checkin_date) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND checkin_date < date(strftime('%Y', checkin_date) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE checkin_date >= '1000';
+
+UPDATE event SET event_date = datetime(event_date, CASE
+    WHEN event_date >= date(strftime('%Y', event_date) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND event_date < date(strftime('%Y', event_date) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE event_date >= '1000';
+
+UPDATE event_messages SET message_date = datetime(message_date, CASE
+    WHEN message_date >= date(strftime('%Y', message_date) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND message_date < date(strftime('%Y', message_date) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE message_date >= '1000';
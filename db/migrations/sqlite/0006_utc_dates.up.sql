@@ -0,0 +1,37 @@
+-- Dates were stored as text in the league's local time, in whichever form the
+-- code writing them used.  From here on they're UTC "YYYY-MM-DD HH:MM:SS".
+-- SQLite has no timezone data, so Pacific daylight time is worked out from
+-- the US rules: from 2 AM on the second Sunday in March to 2 AM on the first
+-- Sunday in November.  Events with no date keep the zero date.
+
+UPDATE weather SET weather_date = datetime(weather_date, CASE
+    WHEN datetime(weather_date) >= date(strftime('%Y', weather_date) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(weather_date) < date(strftime('%Y', weather_date) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE weather_date >= '1000';
+
+UPDATE game SET game_date = datetime(game_date, CASE
+    WHEN datetime(game_date) >= date(strftime('%Y', game_date) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(game_date) < date(strftime('%Y', game_date) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE game_date >= '1000';
+
+UPDATE checkins SET checkin_date = datetime(checkin_date, CASE
+    WHEN datetime(checkin_date) >= date(strftime('%Y', checkin_date) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(checkin_date) < date(strftime('%Y', checkin_date) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE checkin_date >= '1000';
+
+UPDATE event SET event_date = datetime(event_date, CASE
+    WHEN datetime(event_date) >= date(strftime('%Y', event_date) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(event_date) < date(strftime('%Y', event_date) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE event_date >= '1000';
+
+UPDATE event_messages SET message_date = datetime(message_date, CASE
+    WHEN datetime(message_date) >= date(strftime('%Y', message_date) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(message_date) < date(strftime('%Y', message_date) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE message_date >= '1000';
+
+UPDATE event SET event_date = '0001-01-01 00:00:00' WHERE event_date < '1000';
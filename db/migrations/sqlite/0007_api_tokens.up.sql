@@ -0,0 +1,15 @@
+-- Personal access tokens for the API.  They're kept apart from player.token,
+-- the web session, so logging in or out of the site leaves scripts working.
+-- Only a SHA-256 of each token is stored.
+
+CREATE TABLE api_token (
+    idtoken INTEGER PRIMARY KEY AUTOINCREMENT,
+    idplayer INT NOT NULL,
+    name VARCHAR(45) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    created_date TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX api_token_hash ON api_token (token_hash);
+CREATE INDEX api_token_player ON api_token (idplayer);
@@ -0,0 +1,21 @@
+-- The permissions each role grants, by name.  Administrators get them all,
+-- and the other roles what they've always been able to do.
+
+CREATE TABLE role_permissions (
+    idrole INT NOT NULL,
+    permission VARCHAR(45) NOT NULL,
+    PRIMARY KEY (idrole, permission)
+);
+
+INSERT INTO role_permissions (idrole, permission)
+SELECT idrole, 'players:write' FROM role WHERE name IN ('Administrator', 'Roster');
+INSERT INTO role_permissions (idrole, permission)
+SELECT idrole, 'roles:manage' FROM role WHERE name = 'Administrator';
+INSERT INTO role_permissions (idrole, permission)
+SELECT idrole, 'events:manage' FROM role WHERE name IN ('Administrator', 'Calendar');
+INSERT INTO role_permissions (idrole, permission)
+SELECT idrole, 'messages:send' FROM role WHERE name IN ('Administrator', 'Communications');
+INSERT INTO role_permissions (idrole, permission)
+SELECT idrole, 'games:manage' FROM role WHERE name IN ('Administrator', 'Game Manager');
+INSERT INTO role_permissions (idrole, permission)
+SELECT idrole, 'site:admin' FROM role WHERE name = 'Administrator';
@@ -0,0 +1,23 @@
+-- One-time login codes, kept apart from player.token, which is only ever the
+-- web session now.  Codes are stored salted and hashed, expire quickly, can
+-- be used once and only take a few guesses.  ip is who asked for the code, so
+-- requests can be limited per address as well as per player.
+
+CREATE TABLE login_code (
+    idcode INTEGER PRIMARY KEY AUTOINCREMENT,
+    idplayer INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    salt CHAR(32) NOT NULL,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    used BOOLEAN NOT NULL DEFAULT 0,
+    created_date TEXT NOT NULL,
+    expires_date TEXT NOT NULL
+);
+
+CREATE INDEX login_code_player ON login_code (idplayer, created_date);
+CREATE INDEX login_code_ip ON login_code (ip, created_date);
+
+-- Codes used to be written to player.token until they were verified, so an
+-- unverified one worked as a session.  Sessions are UUIDs; drop the rest.
+UPDATE player SET token = '' WHERE LENGTH(token) <> 36;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS weather_game_date;
+ALTER TABLE weather DROP COLUMN idgame;
+ALTER TABLE game DROP COLUMN tee_time;
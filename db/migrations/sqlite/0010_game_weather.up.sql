@@ -0,0 +1,21 @@
+-- A game's forecast hangs off the game rather than being matched by date, and
+-- refreshing it updates its hours in place.  Games also keep their tee time,
+-- which the hours forecast are worked out from; it was always noon.
+
+ALTER TABLE game ADD COLUMN tee_time TEXT;
+
+UPDATE game SET tee_time = datetime(game_date, '+12 hours') WHERE game_date >= '1000';
+
+ALTER TABLE weather ADD COLUMN idgame INTEGER REFERENCES game (idgame) ON DELETE CASCADE;
+
+UPDATE weather SET idgame = (
+    SELECT game.idgame FROM game
+    WHERE weather.weather_date >= game.game_date
+        AND weather.weather_date < datetime(game.game_date, '+1 day')
+    LIMIT 1);
+
+-- Fetching again used to add the hours again, so keep the newest of each.
+DELETE FROM weather WHERE idgame IS NOT NULL AND idweather NOT IN (
+    SELECT MAX(idweather) FROM weather WHERE idgame IS NOT NULL GROUP BY idgame, weather_date);
+
+CREATE UNIQUE INDEX weather_game_date ON weather (idgame, weather_date);
@@ -0,0 +1,15 @@
+-- Every time a game's forecast is fetched, what came back is kept here as
+-- JSON, so how the forecast changed up to the game can be looked back on.
+-- reason says what fetched it: the game being added, the schedule, the run-up
+-- to the tee time or someone asking.
+
+CREATE TABLE weather_revision (
+    idrevision INTEGER PRIMARY KEY AUTOINCREMENT,
+    idgame INTEGER NOT NULL REFERENCES game (idgame) ON DELETE CASCADE,
+    revision_date TEXT NOT NULL,
+    reason VARCHAR(45) NOT NULL DEFAULT '',
+    forecast TEXT NOT NULL
+);
+
+CREATE INDEX weather_revision_game_date ON weather_revision (idgame, revision_date);
+CREATE INDEX weather_revision_date ON weather_revision (revision_date);
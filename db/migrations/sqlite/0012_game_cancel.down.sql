@@ -0,0 +1,3 @@
+ALTER TABLE game DROP COLUMN idrescheduled;
+ALTER TABLE game DROP COLUMN cancelled;
+ALTER TABLE weather DROP COLUMN chance_of_rain;
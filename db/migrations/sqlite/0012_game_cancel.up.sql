@@ -0,0 +1,9 @@
+-- Forecasts keep the chance of rain, which along with the rest of the
+-- forecast puts a game at risk, and a Game Manager can call a game off,
+-- moving it to another day.
+
+ALTER TABLE weather ADD COLUMN chance_of_rain INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE game ADD COLUMN cancelled BOOLEAN NOT NULL DEFAULT 0;
+
+ALTER TABLE game ADD COLUMN idrescheduled INTEGER REFERENCES game (idgame) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+DELETE FROM weather WHERE observed = 1;
+DROP INDEX IF EXISTS weather_game_observed_date;
+CREATE UNIQUE INDEX weather_game_date ON weather (idgame, weather_date);
+ALTER TABLE weather DROP COLUMN observed;
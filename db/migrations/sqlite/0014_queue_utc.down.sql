@@ -0,0 +1,21 @@
+-- Back to league time, with '' for not sent yet.
+
+UPDATE blast SET blast_date = datetime(blast_date, CASE
+    WHEN blast_date >= date(strftime('%Y', blast_date) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND blast_date < date(strftime('%Y', blast_date) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE blast_date >= '1000';
+
+UPDATE blast_messages SET next_attempt = datetime(next_attempt, CASE
+    WHEN next_attempt >= date(strftime('%Y', next_attempt) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND next_attempt < date(strftime('%Y', next_attempt) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE next_attempt >= '1000';
+
+UPDATE blast_messages SET sent_date = datetime(sent_date, CASE
+    WHEN sent_date >= date(strftime('%Y', sent_date) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND sent_date < date(strftime('%Y', sent_date) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE sent_date >= '1000';
+
+UPDATE blast_messages SET sent_date = '' WHERE sent_date < '1000';
@@ -0,0 +1,24 @@
+-- Queue times were kept in league time, with '' for not sent yet.  From here
+-- on they're UTC "YYYY-MM-DD HH:MM:SS" like the other dates, with the zero
+-- date for not sent yet.  Pacific daylight time is worked out the same way as
+-- in 0006.
+
+UPDATE blast SET blast_date = datetime(blast_date, CASE
+    WHEN datetime(blast_date) >= date(strftime('%Y', blast_date) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(blast_date) < date(strftime('%Y', blast_date) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE blast_date >= '1000';
+
+UPDATE blast_messages SET next_attempt = datetime(next_attempt, CASE
+    WHEN datetime(next_attempt) >= date(strftime('%Y', next_attempt) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(next_attempt) < date(strftime('%Y', next_attempt) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE next_attempt >= '1000';
+
+UPDATE blast_messages SET sent_date = datetime(sent_date, CASE
+    WHEN datetime(sent_date) >= date(strftime('%Y', sent_date) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(sent_date) < date(strftime('%Y', sent_date) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE sent_date >= '1000';
+
+UPDATE blast_messages SET sent_date = '0001-01-01 00:00:00' WHERE sent_date = '';
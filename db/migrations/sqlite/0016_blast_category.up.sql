@@ -0,0 +1,5 @@
+-- A blast keeps the category it was sent in, so the queue can check each
+-- player still wants it when their text comes due.  Older blasts have none,
+-- and only a player's STOP holds them back.
+
+ALTER TABLE blast ADD COLUMN category VARCHAR(20) NOT NULL DEFAULT '';
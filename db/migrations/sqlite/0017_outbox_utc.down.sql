@@ -0,0 +1,7 @@
+-- Back to league time.
+
+UPDATE outbox SET created_date = datetime(created_date, CASE
+    WHEN created_date >= date(strftime('%Y', created_date) || '-03-01', 'weekday 0', '+7 days') || ' 10:00:00'
+        AND created_date < date(strftime('%Y', created_date) || '-11-01', 'weekday 0') || ' 09:00:00'
+    THEN '-7 hours' ELSE '-8 hours' END)
+WHERE created_date >= '1000';
@@ -0,0 +1,9 @@
+-- Outbox dates were kept in league time.  From here on they're UTC
+-- "YYYY-MM-DD HH:MM:SS" like the other dates.  Pacific daylight time is
+-- worked out the same way as in 0006.
+
+UPDATE outbox SET created_date = datetime(created_date, CASE
+    WHEN datetime(created_date) >= date(strftime('%Y', created_date) || '-03-01', 'weekday 0', '+7 days') || ' 02:00:00'
+        AND datetime(created_date) < date(strftime('%Y', created_date) || '-11-01', 'weekday 0') || ' 02:00:00'
+    THEN '+7 hours' ELSE '+8 hours' END)
+WHERE created_date >= '1000';
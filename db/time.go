@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	// The league's timezone has to load wherever the binaries run.
+	_ "time/tzdata"
+)
+
+// Zone is the league's timezone.  Times are kept in UTC and only converted to
+// it at the edges: forms, pages, texts and working out which day it is.
+const Zone = "America/Los_Angeles"
+
+// TimeFormat is how times are written to the database, always in UTC.  MySQL
+// DATETIME columns take it as is, and as SQLite text it sorts and compares in
+// time order, so range queries work the same on both.
+const TimeFormat = "2006-01-02 15:04:05"
+
+// timeLayouts are the forms a time might come back from the database in,
+// depending on the driver, its settings and how old the row is.
+var timeLayouts = []string{
+	TimeFormat,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// FormatTime formats t for writing to the database.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// ScanTime returns a destination for Scan that reads a date or time column
+// into t, in UTC, whether the driver hands it over as a time.Time or as text.
+func ScanTime(t *time.Time) sql.Scanner {
+	return timeScanner{t}
+}
+
+type timeScanner struct {
+	t *time.Time
+}
+
+func (s timeScanner) Scan(v interface{}) error {
+	switch tv := v.(type) {
+	case nil:
+		*s.t = time.Time{}
+		return nil
+	case time.Time:
+		*s.t = tv.UTC()
+		return nil
+	case []byte:
+		return s.parse(string(tv))
+	case string:
+		return s.parse(tv)
+	}
+
+	return fmt.Errorf("cannot scan %T into a time", v)
+}
+
+func (s timeScanner) parse(v string) error {
+	for _, l := range timeLayouts {
+		t, err := time.Parse(l, v)
+		if err == nil {
+			*s.t = t.UTC()
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot parse %q as a time", v)
+}
+
+// Location returns the league's timezone.
+func Location() *time.Location {
+	loc, err := time.LoadLocation(Zone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// Local returns t in the league's timezone.
+func Local(t time.Time) time.Time {
+	return t.In(Location())
+}
+
+// ParseLocal parses a time given in the league's timezone, as forms send
+// them, and returns it in UTC.
+func ParseLocal(layout, value string) (time.Time, error) {
+	t, err := time.ParseInLocation(layout, value, Location())
+	if err != nil {
+		return t, err
+	}
+
+	return t.UTC(), nil
+}
+
+// Day returns the start of the league's day that t falls on and the start of
+// the next, in UTC, for queries over [start, end).
+func Day(t time.Time) (time.Time, time.Time) {
+	lt := Local(t)
+	start := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, lt.Location())
+	end := start.AddDate(0, 0, 1)
+
+	return start.UTC(), end.UTC()
+}
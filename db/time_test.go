@@ -0,0 +1,193 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2026, 7, 1, 19, 30, 5, 0, time.UTC), "2026-07-01 19:30:05"},
+		{time.Date(2026, 7, 1, 12, 30, 5, 0, Location()), "2026-07-01 19:30:05"},
+		{time.Date(2026, 1, 15, 20, 0, 0, 0, Location()), "2026-01-16 04:00:00"},
+		{time.Date(2026, 7, 1, 19, 30, 5, 999, time.UTC), "2026-07-01 19:30:05"},
+	}
+	for _, tt := range tests {
+		if got := FormatTime(tt.t); got != tt.want {
+			t.Errorf("FormatTime(%s) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestScanTime(t *testing.T) {
+	utc := time.Date(2026, 7, 1, 19, 30, 5, 0, time.UTC)
+	day := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	minute := time.Date(2026, 7, 1, 19, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want time.Time
+	}{
+		{"the database format", "2026-07-01 19:30:05", utc},
+		{"the database format as bytes", []byte("2026-07-01 19:30:05"), utc},
+		{"RFC 3339", "2026-07-01T19:30:05Z", utc},
+		{"RFC 3339 with an offset", "2026-07-01T12:30:05-07:00", utc},
+		{"RFC 3339 with nanoseconds", "2026-07-01T19:30:05.000000000Z", utc},
+		{"SQLite's time.Time text", "2026-07-01 12:30:05-07:00", utc},
+		{"SQLite's time.Time text with fractions", "2026-07-01 12:30:05.5-07:00", utc.Add(500 * time.Millisecond)},
+		{"a T without a zone", "2026-07-01T19:30:05", utc},
+		{"minutes", "2026-07-01 19:30", minute},
+		{"minutes with a T", "2026-07-01T19:30", minute},
+		{"a date", "2026-07-01", day},
+		{"a time.Time", utc, utc},
+		{"a time.Time elsewhere", time.Date(2026, 7, 1, 12, 30, 5, 0, Location()), utc},
+		{"NULL", nil, time.Time{}},
+	}
+	for _, tt := range tests {
+		got := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
+		err := ScanTime(&got).Scan(tt.v)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("%s: scanned %v as %s, want %s in UTC", tt.name, tt.v, got, tt.want)
+		}
+	}
+
+	for _, v := range []interface{}{"", "yesterday", "07/01/2026", []byte("2026-13-01"), int64(1782934205), 1.5} {
+		var got time.Time
+		if err := ScanTime(&got).Scan(v); err == nil {
+			t.Errorf("scanned %v (%T) as %s, want an error", v, v, got)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2026, 7, 1, 19, 30, 0, 0, time.UTC), "2026-07-01 12:30 PDT"},
+		{time.Date(2026, 1, 16, 4, 0, 0, 0, time.UTC), "2026-01-15 20:00 PST"},
+		// The clocks go forward at 2 AM on 2026-03-08, and back at 2 AM on
+		// 2026-11-01.
+		{time.Date(2026, 3, 8, 9, 59, 0, 0, time.UTC), "2026-03-08 01:59 PST"},
+		{time.Date(2026, 3, 8, 10, 0, 0, 0, time.UTC), "2026-03-08 03:00 PDT"},
+		{time.Date(2026, 11, 1, 8, 30, 0, 0, time.UTC), "2026-11-01 01:30 PDT"},
+		{time.Date(2026, 11, 1, 9, 30, 0, 0, time.UTC), "2026-11-01 01:30 PST"},
+	}
+	for _, tt := range tests {
+		if got := Local(tt.t).Format("2006-01-02 15:04 MST"); got != tt.want {
+			t.Errorf("Local(%s) = %s, want %s", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestParseLocal(t *testing.T) {
+	tests := []struct {
+		v    string
+		want time.Time
+	}{
+		{"2026-07-01T12:30", time.Date(2026, 7, 1, 19, 30, 0, 0, time.UTC)},
+		{"2026-01-15T20:00", time.Date(2026, 1, 16, 4, 0, 0, 0, time.UTC)},
+		{"2026-03-08T01:59", time.Date(2026, 3, 8, 9, 59, 0, 0, time.UTC)},
+		{"2026-03-08T03:00", time.Date(2026, 3, 8, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseLocal("2006-01-02T15:04", tt.v)
+		if err != nil {
+			t.Errorf("ParseLocal(%q): %s", tt.v, err)
+			continue
+		}
+		if !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("ParseLocal(%q) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+
+	// 2:30 never happens the night the clocks go forward, and 1:30 happens
+	// twice the night they go back.  Go doesn't say which side of the change
+	// it picks, only that it's one of them.
+	for _, tt := range []struct {
+		v    string
+		near time.Time
+	}{
+		{"2026-03-08T02:30", time.Date(2026, 3, 8, 10, 0, 0, 0, time.UTC)},
+		{"2026-11-01T01:30", time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)},
+	} {
+		got, err := ParseLocal("2006-01-02T15:04", tt.v)
+		if err != nil {
+			t.Errorf("ParseLocal(%q): %s", tt.v, err)
+			continue
+		}
+		if d := got.Sub(tt.near); d != 30*time.Minute && d != -30*time.Minute {
+			t.Errorf("ParseLocal(%q) = %s, want half an hour either side of %s", tt.v, got, tt.near)
+		}
+	}
+
+	if _, err := ParseLocal("2006-01-02T15:04", "July 1st"); err == nil {
+		t.Error("ParseLocal read nonsense")
+	}
+}
+
+func TestDay(t *testing.T) {
+	tests := []struct {
+		name  string
+		t     time.Time
+		start time.Time
+		hours float64
+	}{
+		{
+			"midday",
+			time.Date(2026, 7, 1, 19, 30, 0, 0, time.UTC),
+			time.Date(2026, 7, 1, 7, 0, 0, 0, time.UTC),
+			24,
+		},
+		{
+			"the evening, when it's already tomorrow in UTC",
+			time.Date(2026, 7, 2, 6, 59, 0, 0, time.UTC),
+			time.Date(2026, 7, 1, 7, 0, 0, 0, time.UTC),
+			24,
+		},
+		{
+			"midnight",
+			time.Date(2026, 7, 2, 7, 0, 0, 0, time.UTC),
+			time.Date(2026, 7, 2, 7, 0, 0, 0, time.UTC),
+			24,
+		},
+		{
+			"winter",
+			time.Date(2026, 1, 15, 20, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC),
+			24,
+		},
+		{
+			"the day the clocks go forward",
+			time.Date(2026, 3, 8, 20, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 8, 8, 0, 0, 0, time.UTC),
+			23,
+		},
+		{
+			"the day the clocks go back",
+			time.Date(2026, 11, 1, 20, 0, 0, 0, time.UTC),
+			time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC),
+			25,
+		},
+	}
+	for _, tt := range tests {
+		start, end := Day(tt.t)
+		if !start.Equal(tt.start) || start.Location() != time.UTC {
+			t.Errorf("%s: day starts %s, want %s", tt.name, start, tt.start)
+		}
+		if h := end.Sub(start).Hours(); h != tt.hours || end.Location() != time.UTC {
+			t.Errorf("%s: day ends %s, %g hours long, want %g", tt.name, end, h, tt.hours)
+		}
+		if tt.t.Before(start) || !tt.t.Before(end) {
+			t.Errorf("%s: %s isn't in [%s, %s)", tt.name, tt.t, start, end)
+		}
+	}
+}
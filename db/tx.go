@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Querier is the part of *sql.DB and *sql.Tx the stores use.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+type txState struct {
+	con   *sql.DB
+	tx    *sql.Tx
+	after []func()
+}
+
+// InTx runs fn in a transaction on con, committing it if fn returns nil and
+// rolling it back otherwise.  Stores built on con join the transaction when
+// they're handed the context fn gets, and an InTx inside fn joins it rather
+// than starting another.  With no con, fn runs without a transaction.
+func InTx(ctx context.Context, con *sql.DB, fn func(ctx context.Context) error) error {
+	if con == nil {
+		return fn(ctx)
+	}
+	if ts, ok := ctx.Value(txKey{}).(*txState); ok && ts.con == con {
+		return fn(ctx)
+	}
+
+	tx, err := con.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	ts := &txState{con: con, tx: tx}
+
+	err = fn(context.WithValue(ctx, txKey{}, ts))
+	if err != nil {
+		rerr := tx.Rollback()
+		if rerr != nil {
+			return fmt.Errorf("%w (rollback failed: %s)", err, rerr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range ts.after {
+		f()
+	}
+
+	return nil
+}
+
+// Q returns the transaction ctx carries if it was begun on con, or con itself.
+func Q(ctx context.Context, con *sql.DB) Querier {
+	if ts, ok := ctx.Value(txKey{}).(*txState); ok && ts.con == con {
+		return ts.tx
+	}
+
+	return con
+}
+
+// AfterCommit runs f once the transaction ctx carries has committed, and not at
+// all if it rolls back.  Outside a transaction f runs straight away.
+func AfterCommit(ctx context.Context, f func()) {
+	if ts, ok := ctx.Value(txKey{}).(*txState); ok {
+		ts.after = append(ts.after, f)
+		return
+	}
+
+	f()
+}
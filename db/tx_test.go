@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func openTx(t *testing.T) *sql.DB {
+	t.Helper()
+
+	con, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { con.Close() })
+
+	_, err = con.Exec("CREATE TABLE note (body TEXT NOT NULL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return con
+}
+
+func add(ctx context.Context, con *sql.DB, body string) error {
+	_, err := Q(ctx, con).ExecContext(ctx, "INSERT INTO note (body) VALUES (?)", body)
+	return err
+}
+
+func notes(t *testing.T, con *sql.DB) int {
+	t.Helper()
+
+	var n int
+	err := con.QueryRow("SELECT COUNT(*) FROM note").Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestInTxCommits(t *testing.T) {
+	con := openTx(t)
+
+	ran := false
+	err := InTx(context.Background(), con, func(ctx context.Context) error {
+		AfterCommit(ctx, func() { ran = true })
+		err := add(ctx, con, "one")
+		if err != nil {
+			return err
+		}
+		if ran {
+			t.Error("AfterCommit ran before the commit")
+		}
+		return add(ctx, con, "two")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := notes(t, con); n != 2 {
+		t.Errorf("%d notes committed, want 2", n)
+	}
+	if !ran {
+		t.Error("AfterCommit didn't run after the commit")
+	}
+}
+
+func TestInTxRollsBack(t *testing.T) {
+	con := openTx(t)
+
+	ran := false
+	failed := errors.New("second note failed")
+	err := InTx(context.Background(), con, func(ctx context.Context) error {
+		AfterCommit(ctx, func() { ran = true })
+		err := add(ctx, con, "one")
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("InTx = %v, want fn's error", err)
+	}
+
+	if n := notes(t, con); n != 0 {
+		t.Errorf("%d notes kept after a rollback", n)
+	}
+	if ran {
+		t.Error("AfterCommit ran after a rollback")
+	}
+}
+
+func TestInTxNested(t *testing.T) {
+	con := openTx(t)
+
+	ran := 0
+	failed := errors.New("outer failed")
+	err := InTx(context.Background(), con, func(ctx context.Context) error {
+		err := InTx(ctx, con, func(ctx context.Context) error {
+			AfterCommit(ctx, func() { ran++ })
+			return add(ctx, con, "inner")
+		})
+		if err != nil {
+			return err
+		}
+		if ran != 0 {
+			t.Error("the inner AfterCommit ran before the outer transaction committed")
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("InTx = %v, want the outer error", err)
+	}
+
+	if n := notes(t, con); n != 0 {
+		t.Errorf("the inner transaction's note survived the outer rollback")
+	}
+	if ran != 0 {
+		t.Errorf("the inner AfterCommit ran %d times after the outer rollback", ran)
+	}
+}
+
+func TestAfterCommitOutsideTx(t *testing.T) {
+	ran := false
+	AfterCommit(context.Background(), func() { ran = true })
+	if !ran {
+		t.Error("AfterCommit outside a transaction didn't run straight away")
+	}
+}
+
+func TestInTxWithoutCon(t *testing.T) {
+	called := false
+	err := InTx(context.Background(), nil, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	if err != nil || !called {
+		t.Errorf("InTx without a con = %v, called %t", err, called)
+	}
+}
@@ -2,13 +2,21 @@ package game
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"fmt"
 	"mariners/db"
+	"mariners/mpevent"
 	"mariners/player"
+	"mariners/queue"
+	"mariners/role"
 	"mariners/tee"
 	"mariners/weather"
-	"regexp"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -17,258 +25,788 @@ import (
 type Game struct {
 	Weather weather.WeatherHours
 	Tee     tee.Tee
-	ID      int64  `json:"id"`
-	Date    string `json:"date"`
-	IsMatch bool   `json:"is_match"`
+	ID      int64 `json:"id"`
+	// Date is the start of the day of the game in the league's timezone.
+	Date time.Time `json:"date"`
+	// TeeTime is when play starts, which the forecast's hours run from.
+	TeeTime time.Time `json:"tee_time"`
+	IsMatch bool      `json:"is_match"`
+	Mystery Mystery   `json:"mystery"`
+	// TeamsLocked is set once the Game Manager is happy with the team draw.
+	TeamsLocked bool `json:"teams_locked"`
+	// Cancelled is set once a Game Manager calls the game off, and
+	// RescheduledID is the game it was moved to, if it was.
+	Cancelled     bool  `json:"cancelled"`
+	RescheduledID int64 `json:"rescheduled_id"`
+	// Risks are what in the forecast goes over RiskLimits.  A game with any
+	// is at risk.
+	Risks []string `json:"risks"`
 	Checkins
 }
 
+// Mystery is the hole drawn after play closes for the mystery hole side game.
+// Hole is 0 until it has been drawn.
+type Mystery struct {
+	GameID int64 `json:"game_id"`
+	Hole   int   `json:"hole"`
+}
+
+// PlayCloseHour is the hour, in the league's timezone, after which play is
+// closed for the day and the mystery hole can be drawn.
+var PlayCloseHour = 17
+
+// DefaultTeeTime is how long after midnight on the day of the game, in the
+// league's timezone, play starts unless the game says otherwise.
+var DefaultTeeTime = 12 * time.Hour
+
+// PlayTime is how long play lasts from the tee time, and so how many hours of
+// forecast a game gets.
+var PlayTime = 4 * time.Hour
+
+// RiskLimits are how bad a game's forecast can get before the game is at
+// risk.
+var RiskLimits = weather.Limits{ChanceOfRain: 60, Precipitation: 0.1, Wind: 20, WindGust: 30}
+
+// CheckinCutoff is how long after midnight on the day of the game, in the
+// league's timezone, check-in closes.
+var CheckinCutoff = 12*time.Hour + 30*time.Minute
+
+var (
+	ErrCheckinClosed = errors.New("check-in has closed for this game")
+	ErrCheckedIn     = errors.New("player is already checked in to this game")
+	ErrNotCheckedIn  = errors.New("player is not checked in to this game")
+	ErrPlayOpen      = errors.New("play has not closed for this game")
+	ErrMysteryDrawn  = errors.New("the mystery hole has already been drawn for this game")
+	ErrTeamsLocked   = errors.New("the teams have been locked for this game")
+	ErrTeamsDrawn    = errors.New("teams have been drawn for this game")
+	ErrTeeTimeDay    = errors.New("the tee time has to be on the day of the game")
+	ErrGameCancelled = errors.New("the game has been cancelled")
+	ErrRescheduleDay = errors.New("a game can only be moved to a later day")
+)
+
 type Games []Game
 
 type Checkin struct {
 	PlayerID int64
 	GameID   int64
-	Date     string
+	Date     time.Time
 }
 
 type Checkins []Checkin
 
-func (g *Game) AddGame() error {
-	w, err := weather.AddWeather()
+// AddGame adds today's game, teeing off at DefaultTeeTime unless g has a tee
+// time, along with its forecast.  The forecast is fetched first, so a game
+// isn't added without one.
+func (g *Game) AddGame(ctx context.Context) error {
+	g.Date, _ = db.Day(time.Now())
+	if g.TeeTime.IsZero() {
+		g.TeeTime = teeTimeOn(g.Date, DefaultTeeTime)
+	}
+	err := g.checkTeeTime()
 	if err != nil {
 		return err
 	}
-	g.Weather = w
 
-	for _, id := range g.Weather {
-		log.Info().Msgf("Game has weather ID %d", id)
+	w, err := weather.Fetch(g.TeeTime, PlayTime)
+	if err != nil {
+		return err
 	}
 
-	loc, err := time.LoadLocation("America/Los_Angeles")
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err = getStore().AddGame(ctx, g)
 	if err != nil {
 		return err
 	}
-	t := time.Now().In(loc)
-	g.Date = t.Format("2006-01-02")
 
-	query := fmt.Sprintf("INSERT INTO game (idgame, game_date, idninthtee, ismatch) VALUES (NULL, \"%s\", %d, %t);\n",
-		g.Date,
-		g.Tee.ID,
-		g.IsMatch)
+	err = weather.SetGameWeather(ctx, g.ID, w, weather.ReasonAdded)
+	if err != nil {
+		return err
+	}
+	g.setWeather(ctx, w, nil)
+
+	for _, id := range g.Weather {
+		log.Info().Msgf("Game has weather ID %d", id.ID)
+	}
+
+	return nil
+}
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (g *Game) UpdateGame(ctx context.Context) error {
+	if g.TeeTime.IsZero() {
+		g.TeeTime = teeTimeOn(g.Date, DefaultTeeTime)
+	}
+	err := g.checkTeeTime()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
+
+	return getStore().UpdateGame(ctx, g)
+}
+
+// RefreshWeather fetches the game's forecast again for reason and saves it
+// over the old one.
+func (g *Game) RefreshWeather(ctx context.Context, reason string) error {
+	if g.TeeTime.IsZero() {
+		g.TeeTime = teeTimeOn(g.Date, DefaultTeeTime)
+	}
+
+	old, err := weather.GetGameWeather(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+
+	w, err := weather.Fetch(g.TeeTime, PlayTime)
 	if err != nil {
 		return err
 	}
 
-	g.ID, err = res.LastInsertId()
+	err = weather.SetGameWeather(ctx, g.ID, w, reason)
 	if err != nil {
 		return err
 	}
+	g.setWeather(ctx, w, old.Risks(RiskLimits))
 
 	return nil
 }
 
-func (g *Game) UpdateGame() error {
-	query := fmt.Sprintf("UPDATE game set idninthtee = %d, ismatch=%t where idplayer=%d;", g.Tee.ID, g.IsMatch, g.ID)
+// RefreshTodaysWeather fetches today's game's forecast again for reason.
+// Without a game today, or with one that's been cancelled, there's nothing to
+// do.
+func RefreshTodaysWeather(ctx context.Context, reason string) error {
+	s, f := db.Day(time.Now())
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
+	g, err := getStore().GetGameBetween(ctx, s, f)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Info().Msg("No game today, so no weather to refresh")
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	rows, err := res.RowsAffected()
+	if g.Cancelled {
+		log.Info().Msg("Today's game is cancelled, so no weather to refresh")
+		return nil
+	}
+
+	return g.RefreshWeather(ctx, reason)
+}
+
+// RecordWeather fetches the weather observed while the game was played and
+// saves it over any it had.  It can only be done once play has closed, and
+// not for a cancelled game, which wasn't played.
+func (g *Game) RecordWeather(ctx context.Context) (weather.WeatherHours, error) {
+	if g.Cancelled {
+		return nil, ErrGameCancelled
+	}
+	closed, err := g.PlayClosed()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !closed {
+		return nil, ErrPlayOpen
 	}
-	if rows != 1 {
-		return fmt.Errorf("UpdateGame: No game where idgame = %d", g.ID)
+	if g.TeeTime.IsZero() {
+		g.TeeTime = teeTimeOn(g.Date, DefaultTeeTime)
 	}
 
-	return nil
+	w, err := weather.FetchObserved(g.TeeTime, PlayTime)
+	if err != nil {
+		return nil, err
+	}
+
+	err = weather.SetGameObserved(ctx, g.ID, w)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
 }
 
-func (g *Game) GetGameByID(id int64) error {
-	query := "SELECT idgame, game_date, idninthtee, ismatch FROM game WHERE idgame=?"
+// RecordTodaysWeather records the weather observed during today's game.
+// Without a game today, or with one that's been cancelled, there's nothing to
+// do.
+func RecordTodaysWeather(ctx context.Context) error {
+	s, f := db.Day(time.Now())
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query, id).Scan(
-		&g.ID,
-		&g.Date,
-		&g.Tee.ID,
-		&g.IsMatch)
+	g, err := getStore().GetGameBetween(ctx, s, f)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Info().Msg("No game today, so no weather to record")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if g.Cancelled {
+		log.Info().Msg("Today's game is cancelled, so no weather to record")
+		return nil
+	}
+
+	_, err = g.RecordWeather(ctx)
+
+	return err
+}
+
+// GetWeather loads the game's forecast and works out its risks.
+func (g *Game) GetWeather(ctx context.Context) error {
+	w, err := weather.GetGameWeather(ctx, g.ID)
 	if err != nil {
 		return err
 	}
+	g.Weather = w
+	g.Risks = w.Risks(RiskLimits)
 
 	return nil
 }
 
-func GetGameByDate(t time.Time) (Game, error) {
-	g := Game{}
-	d := fmt.Sprintf("%d-%02d-%02d 00:00", t.Year(), t.Month(), t.Day())
-	e := fmt.Sprintf("%d-%02d-%02d 23:59", t.Year(), t.Month(), t.Day())
-	query := fmt.Sprintf(
-		"SELECT "+
-			"idgame, "+
-			"game_date, "+
-			"idninthtee, "+
-			"ismatch "+
-			"FROM game WHERE "+
-			"UNIXEPOCH(game_date) BETWEEN UNIXEPOCH('%s') AND UNIXEPOCH('%s')",
-		d, e)
-
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(
-		&g.ID,
-		&g.Date,
-		&g.Tee.ID,
-		&g.IsMatch)
+// setWeather makes w the game's forecast after a fetch, and texts the Game
+// Managers if it puts the game at risk when the risks it had before didn't.
+// The forecast stands even if the text can't be queued.
+func (g *Game) setWeather(ctx context.Context, w weather.WeatherHours, before []string) {
+	g.Weather = w
+	g.Risks = w.Risks(RiskLimits)
+	if len(g.Risks) == 0 || len(before) != 0 || g.Cancelled {
+		return
+	}
 
+	err := g.warnManagers(ctx)
 	if err != nil {
-		return g, err
-	} else {
-		w, err := weather.GetWeatherByDate(g.Date)
-		if err != nil {
-			return g, err
-		}
-		log.Info().Msgf("%#v", w)
-		g.Weather = w
+		log.Error().Msgf("setWeather: %s\n", err)
+	}
+}
 
-		err = g.Tee.GetTeeByID(g.Tee.ID)
-		if err != nil {
-			return g, err
-		}
+// warnManagers texts the players who can manage games that the game is at
+// risk, so they can decide whether to call it off.
+func (g *Game) warnManagers(ctx context.Context) error {
+	ps, err := player.GetPlayers(ctx)
+	if err != nil {
+		return err
+	}
 
-		err = g.GetCheckinsByDate(t)
-		if err != nil && err != sql.ErrNoRows {
-			return g, err
+	ms := make(player.Players, 0)
+	for _, p := range ps {
+		if p.Can(role.GamesManage) {
+			ms = append(ms, p)
 		}
 	}
 
-	return g, nil
+	msg := fmt.Sprintf("The game on %s is at risk: %s.  You can cancel or postpone it from the game page.", g.Day(), strings.Join(g.Risks, ", "))
+	_, err = queue.Enqueue(ctx, 0, player.CategoryGame, "At Risk", msg, ms)
+
+	return err
 }
 
-func GetGames() (Games, error) {
-	gs := make(Games, 0)
+// Cancel calls the game off for player sid and texts everyone it affects: the
+// players checked in and the members of events on the day of the game.  Given
+// a time to, the game moves to that day: a game teeing off then is added
+// unless the day has one already, and the check-ins carry over to it.  Its
+// forecast comes when the schedule refreshes it on the day.  Nothing is saved
+// unless the texts can be queued.
+func (g *Game) Cancel(ctx context.Context, sid int64, to time.Time) error {
+	if g.Cancelled {
+		return ErrGameCancelled
+	}
 
-	query := "SELECT idgame, idweather, game_date, idninthtee, ismatch FROM game"
+	moved := Game{Tee: g.Tee, IsMatch: g.IsMatch}
+	if !to.IsZero() {
+		moved.Date, _ = db.Day(to)
+		moved.TeeTime = to.UTC()
+		if !moved.Date.After(g.Date) {
+			return ErrRescheduleDay
+		}
+	}
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	err := g.GetCheckins(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	ps, err := g.affected(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	err = db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		msg := fmt.Sprintf("The game on %s is cancelled.", g.Day())
+		if !to.IsZero() {
+			err := moved.takeCheckins(ctx, g.Checkins)
+			if err != nil {
+				return err
+			}
+			msg = fmt.Sprintf("The game on %s is cancelled and moved to %s, teeing off at %s.  Check-ins carry over.", g.Day(), moved.Day(), db.Local(moved.TeeTime).Format("3:04 PM"))
+		}
 
-	re, err := regexp.Compile("T")
+		err := getStore().CancelGame(ctx, g.ID, moved.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = queue.Enqueue(ctx, sid, player.CategoryGame, "Cancelled", msg, ps)
+
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
+	g.Cancelled = true
+	g.RescheduledID = moved.ID
+
+	return nil
+}
 
-	for rows.Next() {
-		var g Game
-		err := rows.Scan(
-			&g.ID,
-			&g.Date,
-			&g.Tee.ID,
-			&g.IsMatch)
+// takeCheckins checks cs in to the game on the game's day, as part of ctx's
+// transaction, adding the game if the day doesn't have one.  A player already
+// checked in to it stays as they are.
+func (g *Game) takeCheckins(ctx context.Context, cs Checkins) error {
+	s, f := db.Day(g.Date)
+	have, err := getStore().GetGameBetween(ctx, s, f)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = getStore().AddGame(ctx, g)
 		if err != nil {
-			return nil, err
+			return err
+		}
+	case err != nil:
+		return err
+	case have.Cancelled:
+		return fmt.Errorf("the game on %s: %w", have.Day(), ErrGameCancelled)
+	default:
+		*g = have
+		g.Checkins, err = getStore().GetCheckins(ctx, g.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ci := range cs {
+		if g.HasCheckin(ci.PlayerID) {
+			continue
 		}
 
-		var t time.Time
-		awsdate := re.Match([]byte(g.Date))
+		ci.GameID = g.ID
+		err = getStore().AddCheckin(ctx, ci)
+		if err != nil {
+			return err
+		}
+		g.Checkins = append(g.Checkins, ci)
+	}
+
+	return nil
+}
+
+// affected returns the players checked in to the game and the members of
+// events on its day, each once.
+func (g *Game) affected(ctx context.Context) (player.Players, error) {
+	ps := make(player.Players, 0)
+	seen := make(map[int64]bool)
+
+	for _, ci := range g.Checkins {
+		if seen[ci.PlayerID] {
+			continue
+		}
+		p := player.Player{}
+		err := p.GetPlayerByID(ctx, ci.PlayerID)
 		if err != nil {
 			return nil, err
 		}
-		if awsdate {
-			t, err = time.Parse("2006-01-02T15:04:00Z", g.Date)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			t, err = time.Parse("2006-01-02 15:04:00", g.Date)
-			if err != nil {
-				return nil, err
+		seen[p.ID] = true
+		ps = append(ps, p)
+	}
+
+	es, err := mpevent.GetEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range es {
+		d, _ := db.Day(e.Date)
+		if !d.Equal(g.Date) {
+			continue
+		}
+		for _, m := range e.Members {
+			if seen[m.Player.ID] {
+				continue
 			}
+			seen[m.Player.ID] = true
+			ps = append(ps, m.Player)
 		}
+	}
+
+	return ps, nil
+}
+
+// GetTeeTime returns the tee time of the game on the league's day that t
+// falls on.
+func GetTeeTime(ctx context.Context, t time.Time) (time.Time, error) {
+	s, f := db.Day(t)
 
-		err = g.GetCheckinsByDate(t)
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	g, err := getStore().GetGameBetween(ctx, s, f)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if g.TeeTime.IsZero() {
+		return teeTimeOn(g.Date, DefaultTeeTime), nil
+	}
+
+	return g.TeeTime, nil
+}
+
+// checkTeeTime makes sure the tee time is on the day of the game.
+func (g *Game) checkTeeTime() error {
+	if g.TeeTime.IsZero() {
+		return nil
+	}
+	d, _ := db.Day(g.TeeTime)
+	if !d.Equal(g.Date) {
+		return ErrTeeTimeDay
+	}
+
+	return nil
+}
+
+// teeTimeOn returns the time that's after long after midnight on the league's
+// day that starts at day, going by the clock, so it's the same on the days the
+// clocks change.
+func teeTimeOn(day time.Time, after time.Duration) time.Time {
+	ld := db.Local(day)
+	m := int(after / time.Minute)
+
+	return time.Date(ld.Year(), ld.Month(), ld.Day(), m/60, m%60, 0, 0, ld.Location()).UTC()
+}
+
+// Configure reads the tee time and how long play lasts from the environment:
+// MPTEETIME as HH:MM, and MPPLAYTIME as a duration like 4h.  It also reads the
+// RiskLimits: MPRAINCHANCE in percent, MPRAININCHES an hour, and MPWINDMPH and
+// MPGUSTMPH, any of which can be 0 to not check it.
+func Configure() error {
+	if v := getEnv("MPTEETIME", ""); v != "" {
+		c, err := time.Parse("15:04", v)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("MPTEETIME: %s isn't HH:MM", v)
 		}
-
-		g.Weather, err = weather.GetWeatherByDate(g.Date)
+		DefaultTeeTime = time.Duration(c.Hour())*time.Hour + time.Duration(c.Minute())*time.Minute
+	}
+	if v := getEnv("MPPLAYTIME", ""); v != "" {
+		d, err := time.ParseDuration(v)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("MPPLAYTIME: %s", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("MPPLAYTIME: %s isn't positive", v)
 		}
+		PlayTime = d
+	}
 
-		gs = append(gs, g)
+	if v := getEnv("MPRAINCHANCE", ""); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 || n > 100 {
+			return fmt.Errorf("MPRAINCHANCE: %s isn't a percentage", v)
+		}
+		RiskLimits.ChanceOfRain = n
+	}
+	for _, l := range []struct {
+		key   string
+		limit *float64
+	}{
+		{"MPRAININCHES", &RiskLimits.Precipitation},
+		{"MPWINDMPH", &RiskLimits.Wind},
+		{"MPGUSTMPH", &RiskLimits.WindGust},
+	} {
+		v := getEnv(l.key, "")
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return fmt.Errorf("%s: %s isn't a positive number", l.key, v)
+		}
+		*l.limit = f
 	}
 
-	return gs, nil
+	return nil
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func (g *Game) GetGameByID(ctx context.Context, id int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	ng, err := getStore().GetGame(ctx, id)
+	if err != nil {
+		return err
+	}
+	*g = ng
+
+	return g.GetMystery(ctx)
 }
 
-func (g *Game) AddCheckin(p player.Player) error {
-	loc, err := time.LoadLocation("America/Los_Angeles")
+// DeleteGame removes the game along with its check-ins and mystery hole, and
+// its forecast goes with it by foreign key.
+// Games that teams have been drawn for can't be deleted until the teams are.
+func (g *Game) DeleteGame(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().DeleteGame(ctx, g.ID)
+}
+
+// LockTeams stops the teams for the game from being redrawn.
+func (g *Game) LockTeams(ctx context.Context) error {
+	if g.TeamsLocked {
+		return ErrTeamsLocked
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err := getStore().LockTeams(ctx, g.ID)
 	if err != nil {
 		return err
 	}
-	t := time.Now().In(loc)
-	d := t.Format("2006-01-02 03:04")
+	g.TeamsLocked = true
 
-	query := fmt.Sprintf("INSERT INTO checkins (%d, %d, %s)", p.ID, g.ID, d)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	return nil
+}
+
+// Day is the date of the game in the league's timezone, as shown on pages and
+// in texts.
+func (g *Game) Day() string {
+	return db.Local(g.Date).Format("2006-01-02")
+}
+
+// PlayClosed reports whether play has closed for the day of the game.
+func (g *Game) PlayClosed() (bool, error) {
+	if g.Date.IsZero() {
+		return false, fmt.Errorf("game %d has no date", g.ID)
+	}
+	d, _ := db.Day(g.Date)
+	close := d.Add(time.Duration(PlayCloseHour) * time.Hour)
+
+	return !time.Now().Before(close), nil
+}
+
+func (g *Game) GetMystery(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	_, err = db.Con.ExecContext(ctx, query)
+	m, err := getStore().GetMystery(ctx, g.ID)
 	if err != nil {
 		return err
 	}
+	g.Mystery = m
 
 	return nil
 }
 
-func (g *Game) GetCheckinsByDate(t time.Time) error {
-	g.Checkins = nil
+// DrawMystery picks the mystery hole at random and stores it.  It can only be
+// done once per game, after play has closed.
+func (g *Game) DrawMystery(ctx context.Context) error {
+	closed, err := g.PlayClosed()
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return ErrPlayOpen
+	}
+
+	err = g.GetMystery(ctx)
+	if err != nil {
+		return err
+	}
+	if g.Mystery.Hole != 0 {
+		return ErrMysteryDrawn
+	}
 
-	today := fmt.Sprintf("%d-%d-%d", t.Year(), t.Month(), t.Day())
+	n, err := rand.Int(rand.Reader, big.NewInt(9))
+	if err != nil {
+		return err
+	}
+	hole := int(n.Int64()) + 1
 
-	query := fmt.Sprintf("SELECT idgame FROM game WHERE game_date=%s", today)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	m := Mystery{GameID: g.ID, Hole: hole}
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(&g.ID)
+	err = getStore().AddMystery(ctx, m)
 	if err != nil {
 		return err
 	}
+	g.Mystery = m
+
+	return nil
+}
+
+// GetGameByDate loads the game played on the league's day that t falls on.
+func GetGameByDate(ctx context.Context, t time.Time) (Game, error) {
+	s, f := db.Day(t)
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	g, err := getStore().GetGameBetween(ctx, s, f)
+	if err != nil {
+		return g, err
+	}
+
+	err = g.GetWeather(ctx)
+	if err != nil {
+		return g, err
+	}
+
+	err = g.Tee.GetTeeByID(ctx, g.Tee.ID)
+	if err != nil {
+		return g, err
+	}
+
+	err = g.GetMystery(ctx)
+	if err != nil {
+		return g, err
+	}
+
+	err = g.GetCheckins(ctx)
+	if err != nil {
+		return g, err
+	}
+
+	return g, nil
+}
+
+func GetGames(ctx context.Context) (Games, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	gs, err := getStore().GetGames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range gs {
+		err = gs[i].GetCheckins(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = gs[i].GetWeather(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return gs, nil
+}
+
+// CheckinOpen reports whether check-in is still open for the game, which it
+// is until CheckinCutoff on the day of the game.
+func (g *Game) CheckinOpen() (bool, error) {
+	if g.Date.IsZero() {
+		return false, fmt.Errorf("game %d has no date", g.ID)
+	}
+	d, _ := db.Day(g.Date)
+
+	return time.Now().Before(d.Add(CheckinCutoff)), nil
+}
+
+// HasCheckin reports whether player pid is checked in to the game.
+func (g *Game) HasCheckin(pid int64) bool {
+	for _, ci := range g.Checkins {
+		if ci.PlayerID == pid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddCheckin checks player p in to the game.  Once check-in has closed only a
+// Game Manager can check people in, by passing late.  Nobody can check in to a
+// cancelled game.
+func (g *Game) AddCheckin(ctx context.Context, p player.Player, late bool) error {
+	if g.Cancelled {
+		return ErrGameCancelled
+	}
+	if !late {
+		open, err := g.CheckinOpen()
+		if err != nil {
+			return err
+		}
+		if !open {
+			return ErrCheckinClosed
+		}
+	}
 
-	err = g.GetGameByID(g.ID)
+	err := g.GetCheckins(ctx)
 	if err != nil {
 		return err
 	}
+	if g.HasCheckin(p.ID) {
+		return ErrCheckedIn
+	}
 
-	query = fmt.Sprintf("SELECT idplayer, checkin_date FROM checkins where idgame=%d", g.ID)
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
+	ci := Checkin{PlayerID: p.ID, GameID: g.ID, Date: time.Now().UTC()}
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	err = getStore().AddCheckin(ctx, ci)
 	if err != nil {
 		return err
 	}
+	g.Checkins = append(g.Checkins, ci)
+
+	return nil
+}
 
-	for rows.Next() {
-		var ci Checkin
-		err = rows.Scan(&ci.PlayerID, &ci.Date)
+// RemoveCheckin checks player p out of the game, with the same cutoff rules
+// as AddCheckin.
+func (g *Game) RemoveCheckin(ctx context.Context, p player.Player, late bool) error {
+	if !late {
+		open, err := g.CheckinOpen()
 		if err != nil {
 			return err
 		}
-		ci.GameID = g.ID
-		g.Checkins = append(g.Checkins, ci)
+		if !open {
+			return ErrCheckinClosed
+		}
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err := getStore().RemoveCheckin(ctx, g.ID, p.ID)
+	if err != nil {
+		return err
+	}
+
+	return g.GetCheckins(ctx)
+}
+
+func (g *Game) GetCheckins(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	cs, err := getStore().GetCheckins(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+	g.Checkins = cs
+
+	return nil
+}
+
+// GetCheckinsByDate loads the game played on the day of t, along with its
+// check-ins.
+func (g *Game) GetCheckinsByDate(ctx context.Context, t time.Time) error {
+	gd, err := GetGameByDate(ctx, t)
+	if err != nil {
+		return err
 	}
+	*g = gd
 
 	return nil
 }
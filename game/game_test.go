@@ -0,0 +1,230 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"mariners/player"
+	"mariners/queue"
+	"mariners/role"
+	"mariners/sms"
+	"mariners/tee"
+	"mariners/weather"
+	"testing"
+	"time"
+)
+
+// openDB points the package at a fresh in-memory database holding a tee, with
+// a fake messenger and the captured forecasts.
+func openDB(t *testing.T) tee.Tee {
+	t.Helper()
+
+	dbtest.Open(t)
+	SetStore(nil)
+
+	sms.SetMessenger(sms.NewFakeMessenger(""))
+	weather.SetProvider(weather.NewFixtureProvider(""))
+	t.Cleanup(func() { weather.SetProvider(nil) })
+
+	te := tee.Tee{Name: "Blue"}
+	err := te.AddTee(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return te
+}
+
+// addGame adds a game days from today, without a forecast, so games can be
+// put on days AddGame won't.
+func addGame(t *testing.T, te tee.Tee, days int) Game {
+	t.Helper()
+
+	g := Game{Tee: te}
+	g.Date, _ = db.Day(time.Now().AddDate(0, 0, days))
+	g.TeeTime = teeTimeOn(g.Date, DefaultTeeTime)
+
+	err := NewSQLGameStore(db.Con).AddGame(context.Background(), &g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func addPlayers(t *testing.T, names ...string) player.Players {
+	t.Helper()
+
+	ctx := context.Background()
+	ps := make(player.Players, 0, len(names))
+	for i, n := range names {
+		p := player.Player{Name: n, PreferredName: n, Phone: fmt.Sprintf("41555501%02d", i), Roles: role.Roles{2: "User"}}
+		err := player.AddPlayer(ctx, &p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.GetPlayerByID(ctx, p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps = append(ps, p)
+	}
+
+	return ps
+}
+
+func TestCheckin(t *testing.T) {
+	ctx := context.Background()
+	te := openDB(t)
+	g := addGame(t, te, 1)
+	ps := addPlayers(t, "Bobby", "Ben")
+
+	for _, p := range ps {
+		err := g.AddCheckin(ctx, p, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := g.AddCheckin(ctx, ps[0], false)
+	if !errors.Is(err, ErrCheckedIn) {
+		t.Errorf("second check-in = %v, want ErrCheckedIn", err)
+	}
+
+	got, err := GetGameByDate(ctx, g.Date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != g.ID || len(got.Checkins) != 2 || !got.HasCheckin(ps[0].ID) || !got.HasCheckin(ps[1].ID) {
+		t.Errorf("game %d has check-ins %+v, want game %d with both players", got.ID, got.Checkins, g.ID)
+	}
+	for _, ci := range got.Checkins {
+		if ci.Date.IsZero() || ci.Date.Location() != time.UTC {
+			t.Errorf("check-in date %s isn't a UTC time", ci.Date)
+		}
+	}
+
+	err = got.RemoveCheckin(ctx, ps[0], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HasCheckin(ps[0].ID) || len(got.Checkins) != 1 {
+		t.Errorf("after checking out, check-ins are %+v", got.Checkins)
+	}
+	err = got.RemoveCheckin(ctx, ps[0], false)
+	if !errors.Is(err, ErrNotCheckedIn) {
+		t.Errorf("second check-out = %v, want ErrNotCheckedIn", err)
+	}
+}
+
+func TestCheckinClosed(t *testing.T) {
+	ctx := context.Background()
+	te := openDB(t)
+	g := addGame(t, te, -1)
+	ps := addPlayers(t, "Sam")
+
+	err := g.AddCheckin(ctx, ps[0], false)
+	if !errors.Is(err, ErrCheckinClosed) {
+		t.Errorf("check-in after the cutoff = %v, want ErrCheckinClosed", err)
+	}
+
+	err = g.AddCheckin(ctx, ps[0], true)
+	if err != nil {
+		t.Fatalf("late check-in: %s", err)
+	}
+	err = g.RemoveCheckin(ctx, ps[0], false)
+	if !errors.Is(err, ErrCheckinClosed) {
+		t.Errorf("check-out after the cutoff = %v, want ErrCheckinClosed", err)
+	}
+	err = g.RemoveCheckin(ctx, ps[0], true)
+	if err != nil {
+		t.Errorf("late check-out: %s", err)
+	}
+}
+
+func TestCancelCarriesCheckins(t *testing.T) {
+	ctx := context.Background()
+	te := openDB(t)
+	g := addGame(t, te, 1)
+	ps := addPlayers(t, "Arnold", "Jack", "Gary")
+
+	for _, p := range ps {
+		err := g.AddCheckin(ctx, p, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	to := g.TeeTime.AddDate(0, 0, 7)
+	err := g.Cancel(ctx, ps[0].ID, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Cancelled || g.RescheduledID == 0 {
+		t.Fatalf("after cancelling, cancelled is %t and rescheduled to %d", g.Cancelled, g.RescheduledID)
+	}
+
+	moved := Game{}
+	err = moved.GetGameByID(ctx, g.RescheduledID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = moved.GetCheckins(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !moved.TeeTime.Equal(to) || len(moved.Checkins) != len(ps) {
+		t.Errorf("moved game tees off %s with %d check-ins, want %s with %d", moved.TeeTime, len(moved.Checkins), to, len(ps))
+	}
+
+	bs, err := queue.GetBlasts(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bs) != 1 || bs[0].Label != "Cancelled" || len(bs[0].Messages) != len(ps) {
+		t.Errorf("queued %+v, want one Cancelled text to each player", bs)
+	}
+
+	err = g.AddCheckin(ctx, ps[0], true)
+	if !errors.Is(err, ErrGameCancelled) {
+		t.Errorf("check-in to the cancelled game = %v, want ErrGameCancelled", err)
+	}
+	err = g.Cancel(ctx, ps[0].ID, time.Time{})
+	if !errors.Is(err, ErrGameCancelled) {
+		t.Errorf("cancelling again = %v, want ErrGameCancelled", err)
+	}
+}
+
+func TestCancelRescheduleDay(t *testing.T) {
+	te := openDB(t)
+	g := addGame(t, te, 1)
+
+	err := g.Cancel(context.Background(), 0, g.TeeTime.Add(-time.Hour))
+	if !errors.Is(err, ErrRescheduleDay) {
+		t.Errorf("moving to the same day = %v, want ErrRescheduleDay", err)
+	}
+}
+
+func TestLockTeams(t *testing.T) {
+	ctx := context.Background()
+	te := openDB(t)
+	g := addGame(t, te, 1)
+
+	err := g.LockTeams(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Game{}
+	err = got.GetGameByID(ctx, g.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.TeamsLocked {
+		t.Error("teams aren't locked after reading the game back")
+	}
+	err = got.LockTeams(ctx)
+	if !errors.Is(err, ErrTeamsLocked) {
+		t.Errorf("locking again = %v, want ErrTeamsLocked", err)
+	}
+}
@@ -0,0 +1,285 @@
+package game
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"time"
+)
+
+// GameStore loads and saves games along with their mystery holes and
+// check-ins.  Games come back with only the columns of the game row filled in;
+// weather, tee and check-ins are loaded by the package functions.
+type GameStore interface {
+	AddGame(ctx context.Context, g *Game) error
+	UpdateGame(ctx context.Context, g *Game) error
+	GetGame(ctx context.Context, id int64) (Game, error)
+	// GetGameBetween returns the game dated from s up to, but not
+	// including, f.
+	GetGameBetween(ctx context.Context, s, f time.Time) (Game, error)
+	GetGames(ctx context.Context) (Games, error)
+	// DeleteGame returns ErrTeamsDrawn if the game has teams.
+	DeleteGame(ctx context.Context, id int64) error
+	LockTeams(ctx context.Context, id int64) error
+	// CancelGame marks game id cancelled, and moved to game rid unless rid
+	// is 0.
+	CancelGame(ctx context.Context, id int64, rid int64) error
+	GetMystery(ctx context.Context, gid int64) (Mystery, error)
+	AddMystery(ctx context.Context, m Mystery) error
+	GetCheckins(ctx context.Context, gid int64) (Checkins, error)
+	AddCheckin(ctx context.Context, ci Checkin) error
+	// RemoveCheckin returns ErrNotCheckedIn if the player wasn't checked in.
+	RemoveCheckin(ctx context.Context, gid int64, pid int64) error
+}
+
+// SQLGameStore is a GameStore backed by the game, mysteries and checkins
+// tables.
+type SQLGameStore struct {
+	DB *sql.DB
+}
+
+func NewSQLGameStore(con *sql.DB) *SQLGameStore {
+	return &SQLGameStore{DB: con}
+}
+
+var store GameStore
+
+// SetStore replaces the GameStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s GameStore) {
+	store = s
+}
+
+func getStore() GameStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLGameStore(db.Con)
+}
+
+const gameColumns = "idgame, game_date, tee_time, idninthtee, ismatch, teams_locked, cancelled, idrescheduled"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanGame(row scanner, g *Game) error {
+	var rid sql.NullInt64
+	err := row.Scan(
+		&g.ID,
+		db.ScanTime(&g.Date),
+		db.ScanTime(&g.TeeTime),
+		&g.Tee.ID,
+		&g.IsMatch,
+		&g.TeamsLocked,
+		&g.Cancelled,
+		&rid)
+	g.RescheduledID = rid.Int64
+
+	return err
+}
+
+func (s *SQLGameStore) AddGame(ctx context.Context, g *Game) error {
+	query := "INSERT INTO game (idgame, game_date, tee_time, idninthtee, ismatch) VALUES (NULL, ?, ?, ?, ?)"
+
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		db.FormatTime(g.Date),
+		db.FormatTime(g.TeeTime),
+		g.Tee.ID,
+		g.IsMatch)
+	if err != nil {
+		return err
+	}
+
+	g.ID, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLGameStore) UpdateGame(ctx context.Context, g *Game) error {
+	query := "UPDATE game set tee_time=?, idninthtee=?, ismatch=? where idgame=?"
+
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, db.FormatTime(g.TeeTime), g.Tee.ID, g.IsMatch, g.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return fmt.Errorf("UpdateGame: No game where idgame = %d", g.ID)
+	}
+
+	return nil
+}
+
+func (s *SQLGameStore) GetGame(ctx context.Context, id int64) (Game, error) {
+	g := Game{}
+
+	query := "SELECT " + gameColumns + " FROM game WHERE idgame=?"
+	err := scanGame(db.Q(ctx, s.DB).QueryRowContext(ctx, query, id), &g)
+
+	return g, err
+}
+
+func (s *SQLGameStore) GetGameBetween(ctx context.Context, st, f time.Time) (Game, error) {
+	g := Game{}
+
+	query := "SELECT " + gameColumns + " FROM game WHERE game_date >= ? AND game_date < ?"
+	err := scanGame(db.Q(ctx, s.DB).QueryRowContext(ctx, query, db.FormatTime(st), db.FormatTime(f)), &g)
+
+	return g, err
+}
+
+func (s *SQLGameStore) GetGames(ctx context.Context) (Games, error) {
+	gs := make(Games, 0)
+
+	query := "SELECT " + gameColumns + " FROM game"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g Game
+		err := scanGame(rows, &g)
+		if err != nil {
+			return nil, err
+		}
+
+		gs = append(gs, g)
+	}
+
+	return gs, rows.Err()
+}
+
+func (s *SQLGameStore) DeleteGame(ctx context.Context, id int64) error {
+	return db.InTx(ctx, s.DB, func(ctx context.Context) error {
+		var count int64
+		query := "SELECT COUNT(*) FROM team WHERE idgame=?"
+		err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, id).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			return ErrTeamsDrawn
+		}
+
+		query = "DELETE FROM checkins WHERE idgame=?"
+		_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		query = "DELETE FROM mysteries WHERE idgame=?"
+		_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		query = "DELETE FROM game WHERE idgame=?"
+		res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		return db.OneRow(res)
+	})
+}
+
+func (s *SQLGameStore) LockTeams(ctx context.Context, id int64) error {
+	query := "UPDATE game SET teams_locked=? WHERE idgame=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, true, id)
+
+	return err
+}
+
+func (s *SQLGameStore) CancelGame(ctx context.Context, id int64, rid int64) error {
+	var moved interface{}
+	if rid != 0 {
+		moved = rid
+	}
+
+	query := "UPDATE game SET cancelled=?, idrescheduled=? WHERE idgame=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, true, moved, id)
+	if err != nil {
+		return err
+	}
+
+	return db.OneRow(res)
+}
+
+// GetMystery returns a Mystery with no Hole if it hasn't been drawn.
+func (s *SQLGameStore) GetMystery(ctx context.Context, gid int64) (Mystery, error) {
+	m := Mystery{GameID: gid}
+
+	query := "SELECT idgame, hole FROM mysteries WHERE idgame=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, gid).Scan(&m.GameID, &m.Hole)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return m, err
+	}
+
+	return m, nil
+}
+
+func (s *SQLGameStore) AddMystery(ctx context.Context, m Mystery) error {
+	query := "INSERT INTO mysteries (idgame, hole) VALUES (?, ?)"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, m.GameID, m.Hole)
+
+	return err
+}
+
+func (s *SQLGameStore) GetCheckins(ctx context.Context, gid int64) (Checkins, error) {
+	var cs Checkins
+
+	query := "SELECT idplayer, checkin_date FROM checkins WHERE idgame=?"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, gid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ci Checkin
+		err = rows.Scan(&ci.PlayerID, db.ScanTime(&ci.Date))
+		if err != nil {
+			return nil, err
+		}
+		ci.GameID = gid
+		cs = append(cs, ci)
+	}
+
+	return cs, rows.Err()
+}
+
+func (s *SQLGameStore) AddCheckin(ctx context.Context, ci Checkin) error {
+	query := "INSERT INTO checkins (idplayer, idgame, checkin_date) VALUES (?, ?, ?)"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, ci.PlayerID, ci.GameID, db.FormatTime(ci.Date))
+
+	return err
+}
+
+func (s *SQLGameStore) RemoveCheckin(ctx context.Context, gid int64, pid int64) error {
+	query := "DELETE FROM checkins WHERE idplayer=? AND idgame=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, pid, gid)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotCheckedIn
+	}
+
+	return nil
+}
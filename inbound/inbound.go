@@ -0,0 +1,362 @@
+package inbound
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mariners/game"
+	"mariners/mpevent"
+	"mariners/player"
+	"mariners/scoring"
+	"mariners/team"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Message is a text sent to the club's number.
+type Message struct {
+	From string `json:"from"`
+	Body string `json:"body"`
+}
+
+// ErrSubscriptionConfirmed is returned by FromRequest when the request was an
+// SNS subscription confirmation rather than a message.  It has already been
+// confirmed and there is nothing to reply to.
+var ErrSubscriptionConfirmed = errors.New("sns subscription confirmed")
+
+const help = "Text IN or OUT for today's game, STATUS to see who's in, SCORE and your nine holes to post a card, JOIN and an event name to sign up, STOP to stop league texts, or START to get them again."
+
+// snsNotification is the envelope SNS posts to HTTP subscribers.
+type snsNotification struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// snsSMS is the inbound SMS payload SNS wraps in a notification.
+type snsSMS struct {
+	OriginationNumber string `json:"originationNumber"`
+	MessageBody       string `json:"messageBody"`
+}
+
+// FromRequest reads the message out of a webhook request.  SNS notifications
+// are checked against TopicARN and their signature, then unwrapped, form
+// posts use the From and Body fields most SMS providers send, and plain JSON
+// is read as a Message so texts can be simulated with curl.
+func FromRequest(r *http.Request) (Message, error) {
+	m := Message{}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		m.From = r.FormValue("From")
+		m.Body = r.FormValue("Body")
+		if m.From == "" {
+			return m, fmt.Errorf("form has no From")
+		}
+		return m, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		return m, err
+	}
+
+	if r.Header.Get("x-amz-sns-message-type") != "" {
+		n := snsNotification{}
+		err = json.Unmarshal(body, &n)
+		if err != nil {
+			return m, err
+		}
+		err = verify(n)
+		if err != nil {
+			return m, err
+		}
+
+		switch n.Type {
+		case "SubscriptionConfirmation":
+			err = confirm(n.SubscribeURL)
+			if err != nil {
+				return m, err
+			}
+			return m, ErrSubscriptionConfirmed
+		case "Notification":
+			s := snsSMS{}
+			err = json.Unmarshal([]byte(n.Message), &s)
+			if err != nil {
+				return m, err
+			}
+			m.From = s.OriginationNumber
+			m.Body = s.MessageBody
+		default:
+			return m, fmt.Errorf("unexpected sns message type %s", n.Type)
+		}
+	} else {
+		err = json.Unmarshal(body, &m)
+		if err != nil {
+			return m, err
+		}
+	}
+
+	if m.From == "" {
+		return m, fmt.Errorf("message has no sender")
+	}
+
+	return m, nil
+}
+
+// confirm visits an SNS SubscribeURL, refusing anything that isn't an AWS
+// endpoint.
+func confirm(su string) error {
+	u, err := url.Parse(su)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return fmt.Errorf("refusing to confirm subscription at %s", su)
+	}
+
+	c := http.Client{Timeout: 10 * time.Second}
+	resp, err := c.Get(u.String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("subscription confirmation returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Handle runs the command in m for the player who sent it and returns the
+// reply to text back.
+func Handle(ctx context.Context, m Message) string {
+	p := player.Player{}
+	err := p.GetPlayerByPhone(ctx, m.From)
+	if err != nil {
+		log.Error().Msgf("inbound: %s\n", err)
+		return "Sorry, we don't recognize this number.  Ask a league admin to add it to your profile."
+	}
+
+	fields := strings.Fields(m.Body)
+	if len(fields) == 0 {
+		return help
+	}
+	args := fields[1:]
+
+	var reply string
+	switch strings.ToUpper(fields[0]) {
+	case "IN":
+		reply, err = checkin(ctx, p)
+	case "OUT":
+		reply, err = checkout(ctx, p)
+	case "STATUS":
+		reply, err = status(ctx, p)
+	case "SCORE":
+		reply, err = score(ctx, p, args)
+	case "JOIN":
+		reply, err = join(ctx, p, strings.Join(args, " "))
+	case "STOP":
+		reply, err = stop(ctx, p)
+	case "START", "UNSTOP":
+		reply, err = start(ctx, p)
+	default:
+		reply = help
+	}
+	if err != nil {
+		log.Error().Msgf("inbound: %s: %s\n", p.PreferredName, err)
+		return "Sorry, something went wrong.  Try again, or use the website."
+	}
+
+	return reply
+}
+
+// today loads the game on the league's day today.
+func today(ctx context.Context) (game.Game, error) {
+	return game.GetGameByDate(ctx, time.Now())
+}
+
+func checkin(ctx context.Context, p player.Player) (string, error) {
+	g, err := today(ctx)
+	if err != nil {
+		return "There's no game today.", nil
+	}
+
+	err = g.AddCheckin(ctx, p, false)
+	switch {
+	case errors.Is(err, game.ErrCheckedIn):
+		return "You're already checked in for today's game.", nil
+	case errors.Is(err, game.ErrCheckinClosed):
+		return "Check-in is closed for today.  Ask a Game Manager to add you.", nil
+	case err != nil:
+		return "", err
+	}
+
+	return fmt.Sprintf("You're in for today's game.  %d checked in so far.", len(g.Checkins)), nil
+}
+
+func checkout(ctx context.Context, p player.Player) (string, error) {
+	g, err := today(ctx)
+	if err != nil {
+		return "There's no game today.", nil
+	}
+
+	err = g.RemoveCheckin(ctx, p, false)
+	switch {
+	case errors.Is(err, game.ErrNotCheckedIn):
+		return "You weren't checked in for today's game.", nil
+	case errors.Is(err, game.ErrCheckinClosed):
+		return "Check-in is closed for today.  Ask a Game Manager to take you off.", nil
+	case err != nil:
+		return "", err
+	}
+
+	return "You're out for today's game.", nil
+}
+
+func status(ctx context.Context, p player.Player) (string, error) {
+	g, err := today(ctx)
+	if err != nil {
+		return "There's no game today.", nil
+	}
+
+	in := "You're not checked in"
+	if g.HasCheckin(p.ID) {
+		in = "You're checked in"
+	}
+
+	t := team.Team{}
+	err = team.GetTeamByPlayer(ctx, g.ID, p.ID, &t)
+	if err == nil && g.TeamsLocked {
+		return fmt.Sprintf("%s, on team %d.  %d players today.", in, t.ID, len(g.Checkins)), nil
+	}
+
+	return fmt.Sprintf("%s.  %d players today.", in, len(g.Checkins)), nil
+}
+
+// score posts the player's card for today from nine hole scores, or with no
+// scores replies with the card already posted.
+func score(ctx context.Context, p player.Player, args []string) (string, error) {
+	g, err := today(ctx)
+	if err != nil {
+		return "There's no game today.", nil
+	}
+
+	s := scoring.Score{}
+	if len(args) == 0 {
+		err = s.GetScore(ctx, g.ID, p.ID)
+		if err != nil {
+			return "You haven't posted a score today.  Text SCORE and your nine hole scores.", nil
+		}
+		return fmt.Sprintf("Your card today: %s = %d", holes(s.Scores), s.Total()), nil
+	}
+
+	if len(args) != 9 {
+		return "Send all nine holes, like: SCORE 5 4 5 3 6 4 5 4 5", nil
+	}
+	for i, a := range args {
+		s.Scores[i], err = strconv.Atoi(a)
+		if err != nil {
+			return fmt.Sprintf("%q isn't a score.  Send all nine holes, like: SCORE 5 4 5 3 6 4 5 4 5", a), nil
+		}
+	}
+
+	err = team.GetTeamByPlayer(ctx, g.ID, p.ID, &s.TeamID)
+	if err != nil {
+		return "You're not on a team today, so your score can't be posted yet.", nil
+	}
+	s.Player = p
+
+	err = scoring.AddScore(ctx, g.ID, &s)
+	if errors.Is(err, scoring.ErrDuplicateScore) {
+		err = s.UpdateScore(ctx, g.ID)
+	}
+	switch {
+	case errors.Is(err, scoring.ErrInvalidScore):
+		return fmt.Sprintf("Hole scores have to be between %d and %d.", scoring.MinStrokes, scoring.MaxStrokes), nil
+	case errors.Is(err, scoring.ErrNotCheckedIn):
+		return "You're not checked in for today's game.", nil
+	case err != nil:
+		return "", err
+	}
+
+	return fmt.Sprintf("Posted %s = %d", holes(s.Scores), s.Total()), nil
+}
+
+func holes(ss [9]int) string {
+	h := make([]string, len(ss))
+	for i, s := range ss {
+		h[i] = strconv.Itoa(s)
+	}
+
+	return strings.Join(h, " ")
+}
+
+// join signs p up for the event called name.  JOIN is also a carrier opt-in
+// keyword, so it turns texts back on for a player who had texted STOP.
+func join(ctx context.Context, p player.Player, name string) (string, error) {
+	if p.Preferences.Channel == player.ChannelNone {
+		reply, err := start(ctx, p)
+		if err != nil || name == "" {
+			return reply, err
+		}
+	}
+	if name == "" {
+		return "Text JOIN and the event name.", nil
+	}
+
+	e := mpevent.Event{}
+	err := e.GetEventByName(ctx, name)
+	if err != nil {
+		return fmt.Sprintf("There's no event called %s.", name), nil
+	}
+	if e.HasMember(p) {
+		return fmt.Sprintf("You're already signed up for %s.", e.Name), nil
+	}
+	if e.InviteOnly {
+		return fmt.Sprintf("%s is invite only.  Ask %s to add you.", e.Name, e.Owner.PreferredName), nil
+	}
+
+	err = e.AddMember(ctx, p.ID, false)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("You're signed up for %s.", e.Name), nil
+}
+
+// stop turns off every league message for p, which the queue honours, and
+// takes them off the main topic.
+func stop(ctx context.Context, p player.Player) (string, error) {
+	err := p.OptOut(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return "You won't get league texts anymore.  Text START to turn them back on.", nil
+}
+
+// start reverses stop.
+func start(ctx context.Context, p player.Player) (string, error) {
+	err := p.OptIn(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return "League texts are back on.  Text STOP to turn them off.", nil
+}
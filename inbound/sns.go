@@ -0,0 +1,161 @@
+package inbound
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TopicARN is the SNS topic inbound texts arrive on.  Notifications from any
+// other topic are refused, as are all of them until it's set.
+var TopicARN string
+
+// ErrBadSignature is returned by FromRequest for SNS requests that aren't
+// signed by SNS, or that come from a topic other than TopicARN.
+var ErrBadSignature = errors.New("sns message failed verification")
+
+// Configure reads the inbound topic from MPINBOUNDTOPIC.
+func Configure() error {
+	TopicARN = os.Getenv("MPINBOUNDTOPIC")
+
+	return nil
+}
+
+// certHost matches the hosts SNS serves its signing certificates from.
+var certHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+var (
+	certsMu sync.Mutex
+	certs   = map[string]*x509.Certificate{}
+)
+
+// verify checks that n came from TopicARN and carries a valid SNS signature.
+func verify(n snsNotification) error {
+	if TopicARN == "" {
+		return fmt.Errorf("%w: MPINBOUNDTOPIC isn't set", ErrBadSignature)
+	}
+	if n.TopicArn != TopicARN {
+		return fmt.Errorf("%w: unexpected topic %s", ErrBadSignature, n.TopicArn)
+	}
+
+	var hash crypto.Hash
+	switch n.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("%w: unknown signature version %q", ErrBadSignature, n.SignatureVersion)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(n.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBadSignature, err)
+	}
+
+	cert, err := signingCert(n.SigningCertURL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBadSignature, err)
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: certificate key isn't RSA", ErrBadSignature)
+	}
+
+	h := hash.New()
+	h.Write([]byte(n.signed()))
+
+	err = rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), sig)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBadSignature, err)
+	}
+
+	return nil
+}
+
+// signed builds the string SNS signs, which depends on the message type.
+func (n snsNotification) signed() string {
+	var b strings.Builder
+	field := func(k, v string) {
+		b.WriteString(k)
+		b.WriteString("\n")
+		b.WriteString(v)
+		b.WriteString("\n")
+	}
+
+	field("Message", n.Message)
+	field("MessageId", n.MessageID)
+	if n.Type == "Notification" {
+		if n.Subject != "" {
+			field("Subject", n.Subject)
+		}
+	} else {
+		field("SubscribeURL", n.SubscribeURL)
+	}
+	field("Timestamp", n.Timestamp)
+	if n.Type != "Notification" {
+		field("Token", n.Token)
+	}
+	field("TopicArn", n.TopicArn)
+	field("Type", n.Type)
+
+	return b.String()
+}
+
+// signingCert fetches the certificate at cu, refusing anything SNS doesn't
+// serve.  Certificates are kept once fetched.
+func signingCert(cu string) (*x509.Certificate, error) {
+	u, err := url.Parse(cu)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || !certHost.MatchString(u.Hostname()) || !strings.HasSuffix(u.Path, ".pem") {
+		return nil, fmt.Errorf("refusing signing certificate at %s", cu)
+	}
+
+	certsMu.Lock()
+	defer certsMu.Unlock()
+	if c, ok := certs[cu]; ok {
+		return c, nil
+	}
+
+	c := http.Client{Timeout: 10 * time.Second}
+	resp, err := c.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signing certificate returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, fmt.Errorf("signing certificate at %s isn't PEM", cu)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	certs[cu] = cert
+
+	return cert, nil
+}
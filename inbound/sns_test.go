@@ -0,0 +1,106 @@
+package inbound
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const (
+	testTopic   = "arn:aws:sns:us-east-1:939932615330:MarinersInbound"
+	testCertURL = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+)
+
+// signedNotification returns a notification signed by a throwaway key whose
+// certificate is cached under testCertURL.
+func signedNotification(t *testing.T) (snsNotification, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certsMu.Lock()
+	certs[testCertURL] = cert
+	certsMu.Unlock()
+
+	n := snsNotification{
+		Type:             "Notification",
+		MessageID:        "b2c1e8f4-3f0e-5d4a-9c6e-3a8d7e2f1b00",
+		TopicArn:         testTopic,
+		Message:          `{"originationNumber":"+14155550100","messageBody":"IN"}`,
+		Timestamp:        "2024-06-01T17:30:00.000Z",
+		SignatureVersion: "2",
+		SigningCertURL:   testCertURL,
+	}
+	sign(t, &n, key)
+
+	return n, key
+}
+
+func sign(t *testing.T, n *snsNotification, key *rsa.PrivateKey) {
+	t.Helper()
+
+	d := sha256.Sum256([]byte(n.signed()))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, d[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Signature = base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	TopicARN = testTopic
+	defer func() { TopicARN = "" }()
+
+	n, key := signedNotification(t)
+	if err := verify(n); err != nil {
+		t.Fatalf("verify signed notification: %s", err)
+	}
+
+	forged := n
+	forged.Message = `{"originationNumber":"+14155550100","messageBody":"STOP"}`
+	if err := verify(forged); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("verify altered message = %v, want ErrBadSignature", err)
+	}
+
+	other := n
+	other.TopicArn = "arn:aws:sns:us-east-1:111111111111:Elsewhere"
+	sign(t, &other, key)
+	if err := verify(other); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("verify other topic = %v, want ErrBadSignature", err)
+	}
+
+	elsewhere := n
+	elsewhere.SigningCertURL = "https://example.com/SimpleNotificationService-test.pem"
+	if err := verify(elsewhere); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("verify certificate off SNS = %v, want ErrBadSignature", err)
+	}
+
+	TopicARN = ""
+	if err := verify(n); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("verify with no topic configured = %v, want ErrBadSignature", err)
+	}
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// ErrNotConfigured is returned when there's no SMTP server to send through.
+var ErrNotConfigured = errors.New("email is not configured, set MPSMTPHOST")
+
+// Send emails body to address through the SMTP server in MPSMTPHOST and
+// MPSMTPPORT, signing in with MPSMTPUSER and MPSMTPPASSWORD if set, from
+// MPMAILFROM.
+func Send(address string, subject string, body string) error {
+	host := getEnv("MPSMTPHOST", "")
+	if host == "" {
+		return ErrNotConfigured
+	}
+	port := getEnv("MPSMTPPORT", "587")
+	from := getEnv("MPMAILFROM", "noreply@mplinksters.club")
+
+	var auth smtp.Auth
+	if user := getEnv("MPSMTPUSER", ""); user != "" {
+		auth = smtp.PlainAuth("", user, getEnv("MPSMTPPASSWORD", ""), host)
+	}
+
+	subject = strings.NewReplacer("\r", "", "\n", " ").Replace(subject)
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		from,
+		address,
+		subject,
+		body)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{address}, []byte(msg))
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"mariners/db"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// migrate brings the database named by the usual MPDB settings up to date, or
+// to the version given with -to.
+func main() {
+	to := flag.Int("to", -1, "version to migrate to, the latest if unset")
+	down := flag.Bool("down", false, "revert the last migration")
+	status := flag.Bool("status", false, "print the schema version and exit")
+	flag.Parse()
+
+	var err error
+	db.Con, err = db.DBConnection()
+	if err != nil {
+		log.Fatal().Msgf("Could not connect to database:  %s", err)
+	}
+	defer db.Con.Close()
+
+	ms, err := db.GetMigrations(db.Dialect)
+	if err != nil {
+		log.Fatal().Msgf("Could not load migrations: %s", err)
+	}
+
+	cur, err := db.SchemaVersion(db.Con)
+	if err != nil {
+		log.Fatal().Msgf("Could not read schema version: %s", err)
+	}
+
+	if *status {
+		fmt.Printf("%s schema is at version %d of %d\n", db.Dialect, cur, ms.Latest())
+		for _, m := range ms {
+			mark := " "
+			if m.Version <= cur {
+				mark = "*"
+			}
+			fmt.Printf("%s %04d %s\n", mark, m.Version, m.Name)
+		}
+		os.Exit(0)
+	}
+
+	target := *to
+	if *down {
+		target = 0
+		for _, m := range ms {
+			if m.Version < cur {
+				target = m.Version
+			}
+		}
+	}
+
+	err = db.Migrate(db.Con, db.Dialect, target)
+	if err != nil {
+		log.Fatal().Msgf("Migration failed: %s", err)
+	}
+
+	cur, err = db.SchemaVersion(db.Con)
+	if err != nil {
+		log.Fatal().Msgf("Could not read schema version: %s", err)
+	}
+	fmt.Printf("%s schema is at version %d\n", db.Dialect, cur)
+}
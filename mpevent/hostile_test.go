@@ -0,0 +1,82 @@
+package mpevent
+
+import (
+	"context"
+	"mariners/db/dbtest"
+	"mariners/player"
+	"mariners/sms"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventHostileStrings(t *testing.T) {
+	ctx := context.Background()
+	dbtest.Open(t)
+	SetStore(nil)
+	sms.SetMessenger(sms.NewFakeMessenger(""))
+
+	o := player.Player{Name: "Owner", PreferredName: "Owner", Phone: "4155550100"}
+	err := player.AddPlayer(ctx, &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, h := range dbtest.Hostile {
+		e := Event{Name: h, Description: h, Date: time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC), Owner: o}
+		err = e.CreateEvent(ctx)
+		if err != nil {
+			t.Fatalf("creating %q: %s", h, err)
+		}
+		got := checkEvent(t, "created", e.ID, h, h)
+		if want := "arn:fake:sns:local:" + strings.Replace(h, " ", "-", -1); got.TopicArn != want {
+			t.Errorf("topic read back as %q, want %q", got.TopicArn, want)
+		}
+
+		err = got.SendEventMessage(ctx, h, o.ID)
+		if err != nil {
+			t.Fatalf("sending %q: %s", h, err)
+		}
+		got = checkEvent(t, "messaged", e.ID, h, h)
+		if want := "Message from Owner: " + h; len(got.Messages) != 1 || got.Messages[0].Message != want {
+			t.Errorf("messages read back as %+v, want %q", got.Messages, want)
+		}
+
+		u := dbtest.Hostile[(i+1)%len(dbtest.Hostile)]
+		// The name is fixed once the topic is made, so only the
+		// description changes.
+		got.Description = u
+		err = got.UpdateEvent(ctx)
+		if err != nil {
+			t.Fatalf("updating to %q: %s", u, err)
+		}
+		checkEvent(t, "updated", e.ID, h, u)
+
+		err = got.DeleteEvent(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkEvent(t *testing.T, how string, id int64, name, desc string) Event {
+	t.Helper()
+
+	ctx := context.Background()
+	got := Event{}
+	err := got.GetEventByID(ctx, id)
+	if err != nil {
+		t.Fatalf("%s %q: %s", how, name, err)
+	}
+	if got.Name != name || got.Description != desc {
+		t.Errorf("%s event read back as %q / %q, want %q / %q", how, got.Name, got.Description, name, desc)
+	}
+
+	byName := Event{}
+	err = byName.GetEventByName(ctx, name)
+	if err != nil || byName.ID != id {
+		t.Errorf("%s: looking up %q found %d, %v, want %d", how, name, byName.ID, err, id)
+	}
+
+	return got
+}
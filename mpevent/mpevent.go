@@ -2,26 +2,28 @@ package mpevent
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"mariners/db"
+	"mariners/outbox"
 	"mariners/player"
+	"mariners/queue"
 	"mariners/sms"
-	"regexp"
 	"strings"
 	"time"
-
-	"github.com/nyaruka/phonenumbers"
 )
 
 type Event struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Date        string  `json:"date"`
-	PaidEvent   bool    `json:"paid_event"`
-	Cost        float64 `json:"cost"`
-	TopicArn    string  `json:"topic_arn"`
-	Description string  `json:"description"`
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Date        time.Time `json:"date"`
+	PaidEvent   bool      `json:"paid_event"`
+	Cost        float64   `json:"cost"`
+	TopicArn    string    `json:"topic_arn"`
+	Description string    `json:"description"`
 	Owner       player.Player
 	InviteOnly  bool `json:"invite_only"`
 	Members     EventMembers
@@ -38,7 +40,7 @@ type EventMessage struct {
 	Player    player.Player
 	Message   string
 	MessageID string
-	Date      string
+	Date      time.Time
 }
 
 type Events []Event
@@ -47,472 +49,381 @@ type EventMembers []EventMember
 
 type EventMessages []EventMessage
 
-func (e *Event) CreateEvent() error {
-	topicName := strings.Replace(e.Name, " ", "-", -1)
+// Outbox entries that set up an event's SNS topic and subscriptions once the
+// change that needs them has been saved.
+const (
+	KindCreateTopic = "event.create_topic"
+	KindSubscribe   = "event.subscribe"
+)
+
+// subscription is the payload of a KindSubscribe entry.
+type subscription struct {
+	EventID  int64 `json:"event_id"`
+	PlayerID int64 `json:"player_id"`
+}
 
-	topicARN, err := sms.CreateTopic(topicName)
+func init() {
+	outbox.Register(KindCreateTopic, runCreateTopic)
+	outbox.Register(KindSubscribe, runSubscribe)
+}
+
+// CreateEvent saves the event with its owner as the first member.  The SNS
+// topic is created once the event has been saved, so TopicArn is empty until
+// then.
+func (e *Event) CreateEvent(ctx context.Context) error {
+	o := player.Player{}
+	err := o.GetPlayerByID(ctx, e.Owner.ID)
 	if err != nil {
 		return err
 	}
-	e.TopicArn = topicARN
-
-	query := fmt.Sprintf("INSERT INTO event (name, event_date, paid_event, topic_arn, description, ownerid, invite_only, cost) VALUES (\"%s\", \"%s\", %t, \"%s\", \"%s\", %d, %t, %f)",
-		e.Name,
-		e.Date,
-		e.PaidEvent,
-		e.TopicArn,
-		e.Description,
-		e.Owner.ID,
-		e.InviteOnly,
-		e.Cost)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
+	_, err = player.NormalizePhone(o.Phone)
 	if err != nil {
-		log.Println("error is from createevent query")
 		return err
 	}
 
-	e.ID, err = res.LastInsertId()
-	if err != nil {
-		log.Println("error is from lastinsertid")
-		return err
-	}
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
 
-	err = e.AddMember(e.Owner.ID, true)
-	if err != nil {
-		log.Println("error is from addmember")
-		return err
-	}
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		e.TopicArn = ""
+		err := getStore().CreateEvent(ctx, e)
+		if err != nil {
+			log.Println("error is from createevent query")
+			return err
+		}
 
-	return nil
+		err = outbox.Add(ctx, KindCreateTopic, e.ID)
+		if err != nil {
+			return err
+		}
+
+		err = e.addMember(ctx, o, true)
+		if err != nil {
+			log.Println("error is from addmember")
+			return err
+		}
+
+		return nil
+	})
 }
 
-func (e *Event) UpdateEvent() error {
-	query := fmt.Sprintf("UPDATE event set event_date=\"%s\", paid_event=%t, description=\"%s\", ownerid=%d, invite_only=%t, cost=%f WHERE idevent=%d",
-		e.Date,
-		e.PaidEvent,
-		e.Description,
-		e.Owner.ID,
-		e.InviteOnly,
-		e.Cost,
-		e.ID)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *Event) UpdateEvent(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	_, err := db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
 
-	return nil
+	return getStore().UpdateEvent(ctx, e)
 }
 
-func (e *Event) AddMember(id int64, paid bool) error {
+func (e *Event) AddMember(ctx context.Context, id int64, paid bool) error {
 	p := player.Player{}
-	err := p.GetPlayerByID(id)
+	err := p.GetPlayerByID(ctx, id)
 	if err != nil {
 		return err
 	}
-
-	num, err := phonenumbers.Parse(p.Phone, "US")
+	_, err = player.NormalizePhone(p.Phone)
 	if err != nil {
 		return err
 	}
-	phone := phonenumbers.Format(num, phonenumbers.E164)
 
-	subARN, err := sms.SubscribeUser(phone, e.TopicArn)
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		return e.addMember(ctx, p, paid)
+	})
+}
+
+// addMember adds p to the event and tells them about it as part of ctx's
+// transaction.  They're subscribed to the event's topic after it commits.
+func (e *Event) addMember(ctx context.Context, p player.Player, paid bool) error {
+	err := getStore().AddMember(ctx, e.ID, EventMember{Player: p, Paid: paid})
 	if err != nil {
 		return err
 	}
 
-	query := fmt.Sprintf("INSERT INTO event_members (idevent, idplayer, paid, subscription_arn) VALUES (%d, %d, %t, \"%s\")",
-		e.ID,
-		id,
-		paid,
-		subARN)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	_, err = db.Con.ExecContext(ctx, query)
+	err = outbox.Add(ctx, KindSubscribe, subscription{EventID: e.ID, PlayerID: p.ID})
 	if err != nil {
 		return err
 	}
 
-	// TODO: sms stuff should be handled by ui.go
-	if (paid) || (e.Cost == 0) {
-		msg := fmt.Sprintf("You have been added to the event \"%s\".", e.Name)
-		_, err := sms.SendTextPhone(msg, phone)
-		if err != nil {
-			return err
-		}
-	} else {
-		msg := fmt.Sprintf("You have been added to \"%s\".  The cost is $%.2f.  Please see %s to pay!", e.Name, e.Cost, e.Owner.PreferredName)
-		_, err := sms.SendTextPhone(msg, phone)
-		if err != nil {
-			return err
-		}
+	msg := fmt.Sprintf("You have been added to the event \"%s\".", e.Name)
+	if !paid && e.Cost != 0 {
+		msg = fmt.Sprintf("You have been added to \"%s\".  The cost is $%.2f.  Please see %s to pay!", e.Name, e.Cost, e.Owner.PreferredName)
 	}
 
-	return nil
+	return e.notify(ctx, p, msg)
 }
 
-func (e *Event) UpdateMember(id int64, paid bool) error {
+func (e *Event) UpdateMember(ctx context.Context, id int64, paid bool) error {
 	p := player.Player{}
-	err := p.GetPlayerByID(id)
+	err := p.GetPlayerByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	query := fmt.Sprintf("UPDATE event_members set paid=%t WHERE idevent=%d and idplayer=%d",
-		paid,
-		e.ID,
-		id)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	_, err = db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
+	msg := fmt.Sprintf("You have been marked as paid for %s.", e.Name)
+	if !paid && e.Cost != 0 {
+		msg = fmt.Sprintf("You have been marked as NOT paid for %s.", e.Name)
 	}
 
-	// TODO: sms stuff should be handled by ui.go
-	num, err := phonenumbers.Parse(p.Phone, "US")
-	if err != nil {
-		return err
-	}
-	phone := phonenumbers.Format(num, phonenumbers.E164)
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
 
-	if (paid) || (e.Cost == 0) {
-		msg := fmt.Sprintf("You have been marked as paid for %s.", e.Name)
-		_, err = sms.SendTextPhone(msg, phone)
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		err := getStore().UpdateMember(ctx, e.ID, id, paid)
 		if err != nil {
 			return err
 		}
-	} else {
-		msg := fmt.Sprintf("You have been marked as NOT paid for %s.", e.Name)
-		_, err = sms.SendTextPhone(msg, phone)
+
+		return e.notify(ctx, p, msg)
+	})
+}
+
+// notify queues msg to a member about a change to their membership, subject
+// to their notification preferences, as part of ctx's transaction.
+func (e *Event) notify(ctx context.Context, p player.Player, msg string) error {
+	_, err := queue.Enqueue(ctx, e.Owner.ID, player.CategoryEvents, e.Name, msg, player.Players{p})
+
+	return err
+}
+
+// DeleteMember removes player id from the event.  They're unsubscribed from
+// the event's topic once the change has been saved.
+func (e *Event) DeleteMember(ctx context.Context, id int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		ms, err := getStore().GetMembers(ctx, e.ID)
 		if err != nil {
 			return err
 		}
-	}
-
-	return nil
-}
 
-func (e *Event) DeleteMember(id int64) error {
-	var err error
-	for _, m := range e.Members {
-		if m.Player.ID == id {
-			err := sms.RemoveSubscriber(m.SubscriptionArn)
-			if err != nil {
-				return err
+		for _, m := range ms {
+			if m.Player.ID != id {
+				continue
 			}
-			num, err := phonenumbers.Parse(m.Player.Phone, "US")
+
+			err = outbox.Unsubscribe(ctx, m.SubscriptionArn)
 			if err != nil {
 				return err
 			}
-			phone := phonenumbers.Format(num, phonenumbers.E164)
-			msg := fmt.Sprintf("You have been removed from event %s.", e.Name)
-			_, err = sms.SendTextPhone(msg, phone)
-			if err != nil {
-				return err
+
+			for _, em := range e.Members {
+				if em.Player.ID == id {
+					msg := fmt.Sprintf("You have been removed from event %s.", e.Name)
+					err = e.notify(ctx, em.Player, msg)
+					if err != nil {
+						return err
+					}
+				}
 			}
 			break
 		}
-	}
-	if err != nil {
-		return err
-	}
 
-	query := fmt.Sprintf("DELETE FROM event_members WHERE idevent=%d and idplayer=%d",
-		e.ID,
-		id)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	_, err = db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
-
-	return nil
+		return getStore().DeleteMember(ctx, e.ID, id)
+	})
 }
 
-func (e *Event) SendEventMessage(msg string, sid int64) error {
+func (e *Event) SendEventMessage(ctx context.Context, msg string, sid int64) error {
 	p := player.Player{}
-	p.GetPlayerByID(sid)
+	p.GetPlayerByID(ctx, sid)
 	text := fmt.Sprintf("Message from %s: %s", p.PreferredName, msg)
 	//	mid, err := sms.SendTextTopic(text, e.TopicArn)
 	//	if err != nil {
 	//		return err
 	//	}
 
+	ps := make(player.Players, 0)
 	for _, m := range e.Members {
-		num, err := phonenumbers.Parse(m.Player.Phone, "US")
-		if err != nil {
-			return err
-		}
-		phone := phonenumbers.Format(num, phonenumbers.E164)
-		sms.SendTextPhone(text, phone)
-		time.Sleep(time.Second)
+		ps = append(ps, m.Player)
 	}
 
 	m := EventMessage{}
-	loc, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		return err
-	}
-	t := time.Now().In(loc)
-	m.Date = t.Format("2006-01-02T15:04")
+	m.Date = time.Now().UTC()
 	m.Message = text
 	m.Player = p
-	e.Messages = append(e.Messages, m)
 
-	query := fmt.Sprintf("INSERT INTO event_messages (idevent, idsender, message, message_date, idmessage) VALUES (%d, %d, \"%s\", \"%s\", \"\")",
-		e.ID,
-		m.Player.ID,
-		m.Message,
-		m.Date)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	_, err = db.Con.ExecContext(ctx, query)
+	err := db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		_, err := queue.Enqueue(ctx, p.ID, player.CategoryEvents, e.Name, text, ps)
+		if err != nil {
+			return err
+		}
+
+		return getStore().AddMessage(ctx, e.ID, m)
+	})
 	if err != nil {
 		return err
 	}
+	e.Messages = append(e.Messages, m)
 
 	return nil
 }
 
-func (e *Event) GetEventByID(id int64) error {
-	query := fmt.Sprintf("SELECT idevent, name, event_date, paid_event, topic_arn, description, ownerid, invite_only, cost FROM event WHERE idevent=%d", id)
-
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(
-		&e.ID,
-		&e.Name,
-		&e.Date,
-		&e.PaidEvent,
-		&e.TopicArn,
-		&e.Description,
-		&e.Owner.ID,
-		&e.InviteOnly,
-		&e.Cost)
+// runCreateTopic carries out a KindCreateTopic entry.  The event is looked up
+// again so a topic isn't made for an event that was deleted, or already has
+// one, since the entry was added.
+func runCreateTopic(ctx context.Context, payload []byte) error {
+	var id int64
+	err := json.Unmarshal(payload, &id)
 	if err != nil {
 		return err
 	}
 
-	var t time.Time
-	awsdate, err := regexp.Match(`T`, []byte(e.Date))
+	e, err := getStore().GetEvent(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	if awsdate {
-		t, err = time.Parse("2006-01-02T15:04:00Z", e.Date)
-		if err != nil {
-			return err
-		}
-	} else {
-		t, err = time.Parse("2006-01-02 15:04:00", e.Date)
-		if err != nil {
-			return err
-		}
+	if e.TopicArn != "" {
+		return nil
 	}
-	e.Date = t.Format("2006-01-02T15:04")
 
-	e.Owner.GetPlayerByID(e.Owner.ID)
+	arn, err := sms.CreateTopic(strings.Replace(e.Name, " ", "-", -1))
 	if err != nil {
 		return err
 	}
 
-	query = fmt.Sprintf("SELECT idplayer, paid, subscription_arn FROM event_members WHERE idevent=%d", e.ID)
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	return getStore().SetTopic(ctx, id, arn)
+}
+
+// runSubscribe carries out a KindSubscribe entry.  It's retried until the
+// event's topic has been created, and skipped if the event or the member has
+// gone since the entry was added.
+func runSubscribe(ctx context.Context, payload []byte) error {
+	sub := subscription{}
+	err := json.Unmarshal(payload, &sub)
 	if err != nil {
 		return err
 	}
-	for rows.Next() {
-		var m EventMember
-		if err := rows.Scan(&m.Player.ID, &m.Paid, &m.SubscriptionArn); err != nil {
-			return err
-		}
-		m.Player.GetPlayerByID(m.Player.ID)
-		e.Members = append(e.Members, m)
-	}
 
-	query = fmt.Sprintf("SELECT idsender, message, message_date FROM event_messages WHERE idevent=%d", e.ID)
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	rows, err = db.Con.QueryContext(ctx, query)
+	e, err := getStore().GetEvent(ctx, sub.EventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	for rows.Next() {
-		var m EventMessage
-		if err := rows.Scan(&m.Player.ID, &m.Message, &m.Date); err != nil {
-			return err
-		}
-		m.Player.GetPlayerByID(m.Player.ID)
-		e.Messages = append(e.Messages, m)
+	if e.TopicArn == "" {
+		return fmt.Errorf("event %d has no topic yet", e.ID)
 	}
 
-	return nil
-}
-
-func (e *Event) GetEventByName(name string) error {
-	query := fmt.Sprintf("SELECT idevent, name, event_date, paid_event, topic_arn, description, ownerid, invite_only, cost FROM event WHERE name=%s", name)
-
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(
-		&e.ID,
-		&e.Name,
-		&e.Date,
-		&e.PaidEvent,
-		&e.TopicArn,
-		&e.Description,
-		&e.Owner.ID,
-		&e.InviteOnly,
-		&e.Cost)
+	ms, err := getStore().GetMembers(ctx, e.ID)
 	if err != nil {
 		return err
 	}
+	for _, m := range ms {
+		if m.Player.ID != sub.PlayerID || m.SubscriptionArn != "" {
+			continue
+		}
 
-	var t time.Time
-	awsdate, err := regexp.Match(`T`, []byte(e.Date))
-	if err != nil {
-		return err
-	}
-	if awsdate {
-		t, err = time.Parse("2006-01-02T15:04:00Z", e.Date)
+		p := player.Player{}
+		err = p.GetPlayerByID(ctx, sub.PlayerID)
 		if err != nil {
 			return err
 		}
-	} else {
-		t, err = time.Parse("2006-01-02 15:04:00", e.Date)
+		phone, err := player.NormalizePhone(p.Phone)
 		if err != nil {
 			return err
 		}
-	}
-	e.Date = t.Format("2006-01-02T15:04")
 
-	e.Owner.GetPlayerByID(e.Owner.ID)
-	if err != nil {
-		return err
+		arn, err := sms.SubscribeUser(phone, e.TopicArn)
+		if err != nil {
+			return err
+		}
+
+		return getStore().SetMemberSubscription(ctx, e.ID, p.ID, arn)
 	}
 
-	query = fmt.Sprintf("SELECT idplayer, paid, subscription_arn FROM event_members WHERE idevent=%d", e.ID)
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
+	return nil
+}
+
+func (e *Event) GetEventByID(ctx context.Context, id int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	ne, err := getStore().GetEvent(ctx, id)
 	if err != nil {
 		return err
 	}
+	*e = ne
 
-	for rows.Next() {
-		var m EventMember
-		if err := rows.Scan(&m.Player.ID, &m.Paid, &m.SubscriptionArn); err != nil {
-			return err
-		}
-		m.Player.GetPlayerByID(m.Player.ID)
-		e.Members = append(e.Members, m)
-	}
+	return e.load(ctx)
+}
 
-	query = fmt.Sprintf("SELECT idsender, message, message_date FROM event_messages WHERE idevent=%d", e.ID)
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
+func (e *Event) GetEventByName(ctx context.Context, name string) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	rows, err = db.Con.QueryContext(ctx, query)
+	ne, err := getStore().GetEventByName(ctx, name)
 	if err != nil {
 		return err
 	}
-	for rows.Next() {
-		var m EventMessage
-		if err := rows.Scan(&m.Player.ID, &m.Message, &m.Date); err != nil {
-			return err
-		}
-		m.Player.GetPlayerByID(m.Player.ID)
-		e.Messages = append(e.Messages, m)
-	}
+	*e = ne
 
-	return nil
+	return e.load(ctx)
 }
 
-func GetEvents() (Events, error) {
-	es := make(Events, 0)
-
-	query := "SELECT idevent, name, event_date, paid_event, topic_arn, description, ownerid, invite_only, cost FROM event"
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+func GetEvents(ctx context.Context) (Events, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	es, err := getStore().GetEvents(ctx)
 	if err != nil {
 		return es, err
 	}
 
-	for rows.Next() {
-		var e Event
-		if err := rows.Scan(&e.ID, &e.Name, &e.Date, &e.PaidEvent, &e.TopicArn, &e.Description, &e.Owner.ID, &e.InviteOnly, &e.Cost); err != nil {
-			return es, err
-		}
-
-		var t time.Time
-		awsdate := strings.Contains(e.Date, "T")
+	for i := range es {
+		err = es[i].load(ctx)
 		if err != nil {
 			return es, err
 		}
-		if awsdate {
-			t, err = time.Parse("2006-01-02T15:04:00Z", e.Date)
-			if err != nil {
-				return es, err
-			}
-		} else {
-			t, err = time.Parse("2006-01-02 15:04:00", e.Date)
-			if err != nil {
-				return es, err
-			}
-		}
-		e.Date = t.Format("2006-01-02T15:04")
+	}
 
-		e.Owner.GetPlayerByID(e.Owner.ID)
-		if err != nil {
-			return es, err
-		}
-		es = append(es, e)
+	return es, nil
+}
+
+// load fills in what the event row doesn't hold: the owner, members and
+// messages.
+func (e *Event) load(ctx context.Context) error {
+	e.Owner.GetPlayerByID(ctx, e.Owner.ID)
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	var err error
+	e.Members, err = getStore().GetMembers(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+	for i := range e.Members {
+		e.Members[i].Player.GetPlayerByID(ctx, e.Members[i].Player.ID)
 	}
 
-	for i, e := range es {
-		query := fmt.Sprintf("SELECT idplayer, paid, subscription_arn FROM event_members WHERE idevent=%d", e.ID)
-		ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelfunc()
-		rows, err := db.Con.QueryContext(ctx, query)
-		if err != nil {
-			return es, err
-		}
+	e.Messages, err = getStore().GetMessages(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+	for i := range e.Messages {
+		e.Messages[i].Player.GetPlayerByID(ctx, e.Messages[i].Player.ID)
+	}
 
-		for rows.Next() {
-			var m EventMember
-			if err := rows.Scan(&m.Player.ID, &m.Paid, &m.SubscriptionArn); err != nil {
-				return es, err
-			}
-			m.Player.GetPlayerByID(m.Player.ID)
-			es[i].Members = append(es[i].Members, m)
-		}
+	return nil
+}
 
-		query = fmt.Sprintf("SELECT idsender, message, message_date FROM event_messages WHERE idevent=%d", e.ID)
-		ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelfunc()
-		rows, err = db.Con.QueryContext(ctx, query)
-		if err != nil {
-			return es, err
-		}
-		for rows.Next() {
-			var m EventMessage
-			if err := rows.Scan(&m.Player.ID, &m.Message, &m.Date); err != nil {
-				return es, err
-			}
-			m.Player.GetPlayerByID(m.Player.ID)
-			e.Messages = append(e.Messages, m)
-		}
+// LocalDate is the event's date and time in the league's timezone, in the
+// form datetime-local inputs use, or empty if it has no date.
+func (e *Event) LocalDate() string {
+	if e.Date.IsZero() {
+		return ""
 	}
 
-	return es, nil
+	return db.Local(e.Date).Format("2006-01-02T15:04")
+}
+
+// LocalDate is when the message was sent, in the league's timezone.
+func (m *EventMessage) LocalDate() string {
+	return db.Local(m.Date).Format("2006-01-02 15:04")
 }
 
 func (e *Event) HasMember(p player.Player) bool {
@@ -527,59 +438,41 @@ func (e *Event) HasMember(p player.Player) bool {
 	return hm
 }
 
-func (e *Event) DeleteEvent() error {
-	for _, m := range e.Members {
-		num, err := phonenumbers.Parse(m.Player.Phone, "US")
+// DeleteEvent removes the event and tells its members.  Their subscriptions
+// and the event's topic are removed from SNS once the change has been saved.
+func (e *Event) DeleteEvent(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		for _, m := range e.Members {
+			msg := fmt.Sprintf("You have been removed from event %s. The event is being deleted.", e.Name)
+			err := e.notify(ctx, m.Player, msg)
+			if err != nil {
+				return err
+			}
+		}
+
+		ms, err := getStore().GetMembers(ctx, e.ID)
 		if err != nil {
 			return err
 		}
-		phone := phonenumbers.Format(num, phonenumbers.E164)
-		msg := fmt.Sprintf("You have been removed from event %s. The event is being deleted.", e.Name)
-		_, err = sms.SendTextPhone(msg, phone)
+		for _, m := range ms {
+			err = outbox.Unsubscribe(ctx, m.SubscriptionArn)
+			if err != nil {
+				return err
+			}
+		}
+
+		ce, err := getStore().GetEvent(ctx, e.ID)
 		if err != nil {
 			return err
 		}
-		err = sms.RemoveSubscriber(m.SubscriptionArn)
+		err = outbox.DeleteTopic(ctx, ce.TopicArn)
 		if err != nil {
 			return err
 		}
-	}
-
-	err := sms.DeleteTopic(e.TopicArn)
-	if err != nil {
-		return err
-	}
 
-	query := fmt.Sprintf("DELETE FROM event_members WHERE idevent=%d", e.ID)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	_, err = db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
-
-	query = fmt.Sprintf("DELETE FROM event_messages WHERE idevent=%d", e.ID)
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	_, err = db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
-
-	query = fmt.Sprintf("DELETE FROM event WHERE idevent=%d", e.ID)
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return fmt.Errorf("no event deleted")
-	}
-
-	return nil
+		return getStore().DeleteEvent(ctx, e.ID)
+	})
 }
@@ -0,0 +1,270 @@
+package mpevent
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"mariners/db"
+)
+
+// EventStore loads and saves events, their members and the messages sent to
+// them.  Members and messages come back with only the player ID filled in.
+type EventStore interface {
+	CreateEvent(ctx context.Context, e *Event) error
+	UpdateEvent(ctx context.Context, e *Event) error
+	SetTopic(ctx context.Context, id int64, arn string) error
+	GetEvent(ctx context.Context, id int64) (Event, error)
+	GetEventByName(ctx context.Context, name string) (Event, error)
+	GetEvents(ctx context.Context) (Events, error)
+	// DeleteEvent removes the event along with its members and messages.
+	DeleteEvent(ctx context.Context, id int64) error
+	AddMember(ctx context.Context, eid int64, m EventMember) error
+	UpdateMember(ctx context.Context, eid int64, pid int64, paid bool) error
+	SetMemberSubscription(ctx context.Context, eid int64, pid int64, arn string) error
+	DeleteMember(ctx context.Context, eid int64, pid int64) error
+	GetMembers(ctx context.Context, eid int64) (EventMembers, error)
+	AddMessage(ctx context.Context, eid int64, m EventMessage) error
+	GetMessages(ctx context.Context, eid int64) (EventMessages, error)
+}
+
+// SQLEventStore is an EventStore backed by the event, event_members and
+// event_messages tables.
+type SQLEventStore struct {
+	DB *sql.DB
+}
+
+func NewSQLEventStore(con *sql.DB) *SQLEventStore {
+	return &SQLEventStore{DB: con}
+}
+
+var store EventStore
+
+// SetStore replaces the EventStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s EventStore) {
+	store = s
+}
+
+func getStore() EventStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLEventStore(db.Con)
+}
+
+const eventColumns = "idevent, name, event_date, paid_event, topic_arn, description, ownerid, invite_only, cost"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEvent(row scanner, e *Event) error {
+	return row.Scan(
+		&e.ID,
+		&e.Name,
+		db.ScanTime(&e.Date),
+		&e.PaidEvent,
+		&e.TopicArn,
+		&e.Description,
+		&e.Owner.ID,
+		&e.InviteOnly,
+		&e.Cost)
+}
+
+func (s *SQLEventStore) CreateEvent(ctx context.Context, e *Event) error {
+	query := "INSERT INTO event (name, event_date, paid_event, topic_arn, description, ownerid, invite_only, cost) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		e.Name,
+		db.FormatTime(e.Date),
+		e.PaidEvent,
+		e.TopicArn,
+		e.Description,
+		e.Owner.ID,
+		e.InviteOnly,
+		e.Cost)
+	if err != nil {
+		return err
+	}
+
+	e.ID, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLEventStore) UpdateEvent(ctx context.Context, e *Event) error {
+	query := "UPDATE event set event_date=?, paid_event=?, description=?, ownerid=?, invite_only=?, cost=? WHERE idevent=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		db.FormatTime(e.Date),
+		e.PaidEvent,
+		e.Description,
+		e.Owner.ID,
+		e.InviteOnly,
+		e.Cost,
+		e.ID)
+
+	return err
+}
+
+func (s *SQLEventStore) SetTopic(ctx context.Context, id int64, arn string) error {
+	query := "UPDATE event set topic_arn=? WHERE idevent=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, arn, id)
+
+	return err
+}
+
+func (s *SQLEventStore) GetEvent(ctx context.Context, id int64) (Event, error) {
+	e := Event{}
+
+	query := "SELECT " + eventColumns + " FROM event WHERE idevent=?"
+	err := scanEvent(db.Q(ctx, s.DB).QueryRowContext(ctx, query, id), &e)
+
+	return e, err
+}
+
+func (s *SQLEventStore) GetEventByName(ctx context.Context, name string) (Event, error) {
+	e := Event{}
+
+	query := "SELECT " + eventColumns + " FROM event WHERE name=?"
+	err := scanEvent(db.Q(ctx, s.DB).QueryRowContext(ctx, query, name), &e)
+
+	return e, err
+}
+
+func (s *SQLEventStore) GetEvents(ctx context.Context) (Events, error) {
+	es := make(Events, 0)
+
+	query := "SELECT " + eventColumns + " FROM event"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query)
+	if err != nil {
+		return es, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Event
+		if err := scanEvent(rows, &e); err != nil {
+			return es, err
+		}
+		es = append(es, e)
+	}
+
+	return es, rows.Err()
+}
+
+func (s *SQLEventStore) DeleteEvent(ctx context.Context, id int64) error {
+	query := "DELETE FROM event_members WHERE idevent=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	query = "DELETE FROM event_messages WHERE idevent=?"
+	_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	query = "DELETE FROM event WHERE idevent=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("no event deleted")
+	}
+
+	return nil
+}
+
+func (s *SQLEventStore) AddMember(ctx context.Context, eid int64, m EventMember) error {
+	query := "INSERT INTO event_members (idevent, idplayer, paid, subscription_arn) VALUES (?, ?, ?, ?)"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		eid,
+		m.Player.ID,
+		m.Paid,
+		m.SubscriptionArn)
+
+	return err
+}
+
+func (s *SQLEventStore) UpdateMember(ctx context.Context, eid int64, pid int64, paid bool) error {
+	query := "UPDATE event_members set paid=? WHERE idevent=? and idplayer=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, paid, eid, pid)
+
+	return err
+}
+
+func (s *SQLEventStore) SetMemberSubscription(ctx context.Context, eid int64, pid int64, arn string) error {
+	query := "UPDATE event_members set subscription_arn=? WHERE idevent=? and idplayer=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, arn, eid, pid)
+
+	return err
+}
+
+func (s *SQLEventStore) DeleteMember(ctx context.Context, eid int64, pid int64) error {
+	query := "DELETE FROM event_members WHERE idevent=? and idplayer=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, eid, pid)
+
+	return err
+}
+
+func (s *SQLEventStore) GetMembers(ctx context.Context, eid int64) (EventMembers, error) {
+	var ms EventMembers
+
+	query := "SELECT idplayer, paid, subscription_arn FROM event_members WHERE idevent=?"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, eid)
+	if err != nil {
+		return ms, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m EventMember
+		if err := rows.Scan(&m.Player.ID, &m.Paid, &m.SubscriptionArn); err != nil {
+			return ms, err
+		}
+		ms = append(ms, m)
+	}
+
+	return ms, rows.Err()
+}
+
+func (s *SQLEventStore) AddMessage(ctx context.Context, eid int64, m EventMessage) error {
+	query := "INSERT INTO event_messages (idevent, idsender, message, message_date, idmessage) VALUES (?, ?, ?, ?, '')"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		eid,
+		m.Player.ID,
+		m.Message,
+		db.FormatTime(m.Date))
+
+	return err
+}
+
+func (s *SQLEventStore) GetMessages(ctx context.Context, eid int64) (EventMessages, error) {
+	var ms EventMessages
+
+	query := "SELECT idsender, message, message_date FROM event_messages WHERE idevent=?"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, eid)
+	if err != nil {
+		return ms, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m EventMessage
+		if err := rows.Scan(&m.Player.ID, &m.Message, db.ScanTime(&m.Date)); err != nil {
+			return ms, err
+		}
+		ms = append(ms, m)
+	}
+
+	return ms, rows.Err()
+}
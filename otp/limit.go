@@ -0,0 +1,58 @@
+package otp
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to n events per key in any window, such as requests from
+// one address.  It's kept in memory, so each server counts for itself.
+type Limiter struct {
+	mu     sync.Mutex
+	n      int
+	window time.Duration
+	seen   map[string][]time.Time
+}
+
+func NewLimiter(n int, window time.Duration) *Limiter {
+	return &Limiter{n: n, window: window, seen: make(map[string][]time.Time)}
+}
+
+// Allow records an event for key and reports whether it's within the limit.
+// Events over the limit aren't recorded, so a key that keeps trying gets
+// through again once its window has passed.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.seen) > 10000 {
+		for k := range l.seen {
+			l.prune(k, now)
+		}
+	}
+
+	if l.prune(key, now) >= l.n {
+		return false
+	}
+	l.seen[key] = append(l.seen[key], now)
+
+	return true
+}
+
+// prune forgets key's events from before the window and returns how many are
+// left.
+func (l *Limiter) prune(key string, now time.Time) int {
+	ts := l.seen[key]
+	i := 0
+	for i < len(ts) && now.Sub(ts[i]) >= l.window {
+		i++
+	}
+	if i == len(ts) {
+		delete(l.seen, key)
+		return 0
+	}
+	l.seen[key] = ts[i:]
+
+	return len(ts) - i
+}
@@ -0,0 +1,219 @@
+package otp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"math/big"
+	"time"
+)
+
+const (
+	// Digits is how long a code is.
+	Digits = 6
+	// TTL is how long a code works for.
+	TTL = 10 * time.Minute
+	// MaxAttempts is how many guesses a code takes before it stops working.
+	MaxAttempts = 5
+	// Window is the time MaxPerPlayer and MaxPerIP are counted over.
+	Window = 15 * time.Minute
+	// MaxPerPlayer is how many codes a player can ask for in a Window.
+	MaxPerPlayer = 3
+	// MaxPerIP is how many codes one address can ask for in a Window.
+	MaxPerIP = 10
+	// keep is how long codes are kept once they're no use.
+	keep = 24 * time.Hour
+)
+
+// Code is a one-time login code sent to a player's phone.  The code itself
+// is only seen when it's sent, and the nonce that ties it to the browser
+// that asked for it only by that browser.
+type Code struct {
+	ID          int64
+	PlayerID    int64
+	Hash        string
+	Salt        string
+	NonceHash   string
+	IP          string
+	Attempts    int
+	Used        bool
+	CreatedDate time.Time
+	ExpiresDate time.Time
+}
+
+var (
+	// ErrTooManyRequests is returned when a player or an address has asked
+	// for too many codes lately.
+	ErrTooManyRequests = errors.New("too many login codes asked for, try again later")
+	// ErrInvalidCode is returned for a code that's wrong, used, expired or
+	// was never sent.
+	ErrInvalidCode = errors.New("that code isn't right or has expired")
+	// ErrTooManyAttempts is returned once a code has taken MaxAttempts
+	// guesses.
+	ErrTooManyAttempts = errors.New("too many wrong codes, ask for a new one")
+)
+
+// hash is how a code is stored.  The salt keeps the same code from hashing
+// the same twice, and hashing in the player binds it to whoever asked for it.
+func hash(pid int64, salt, code string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s:%s", pid, salt, code)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// newCode returns Digits random digits.
+func newCode() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(Digits), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", Digits, n), nil
+}
+
+// newSalt returns 16 random bytes as hex.
+func newSalt() (string, error) {
+	return randomHex(16)
+}
+
+// newNonce returns 32 random bytes as hex.
+func newNonce() (string, error) {
+	return randomHex(32)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// hashNonce is how a nonce is stored and looked up.  Nonces are random
+// enough that a plain SHA-256 can't be worked back.
+func hashNonce(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Request makes a new code for player pid, asked for from ip, and returns it
+// to be sent along with a nonce for the browser that asked to keep.  The
+// code only works with the nonce.  Any code the player had before stops
+// working.
+func Request(ctx context.Context, pid int64, ip string) (string, string, error) {
+	code, err := newCode()
+	if err != nil {
+		return "", "", err
+	}
+	salt, err := newSalt()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	c := Code{
+		PlayerID:    pid,
+		Hash:        hash(pid, salt, code),
+		Salt:        salt,
+		NonceHash:   hashNonce(nonce),
+		IP:          ip,
+		CreatedDate: now,
+		ExpiresDate: now.Add(TTL),
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	err = db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		err := getStore().DeleteCodes(ctx, now.Add(-keep))
+		if err != nil {
+			return err
+		}
+
+		n, err := getStore().CountPlayerCodes(ctx, pid, now.Add(-Window))
+		if err != nil {
+			return err
+		}
+		if n >= MaxPerPlayer {
+			return ErrTooManyRequests
+		}
+		if ip != "" {
+			n, err = getStore().CountIPCodes(ctx, ip, now.Add(-Window))
+			if err != nil {
+				return err
+			}
+			if n >= MaxPerIP {
+				return ErrTooManyRequests
+			}
+		}
+
+		return getStore().AddCode(ctx, &c)
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return code, nonce, nil
+}
+
+// Verify checks code against the one asked for by the browser holding nonce,
+// uses it up if it's right and returns the player it was for.  Every guess
+// counts against the code's MaxAttempts, right or wrong.
+func Verify(ctx context.Context, nonce string, code string) (int64, error) {
+	if nonce == "" {
+		return 0, ErrInvalidCode
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	c, err := getStore().GetCodeByNonce(ctx, hashNonce(nonce))
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidCode
+	}
+	if err != nil {
+		return 0, err
+	}
+	if c.Used || time.Now().UTC().After(c.ExpiresDate) {
+		return 0, ErrInvalidCode
+	}
+
+	err = getStore().AddAttempt(ctx, c.ID, MaxAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTooManyAttempts
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash(c.PlayerID, c.Salt, code)), []byte(c.Hash)) != 1 {
+		if c.Attempts+1 >= MaxAttempts {
+			return 0, ErrTooManyAttempts
+		}
+		return 0, ErrInvalidCode
+	}
+
+	err = getStore().UseCode(ctx, c.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidCode
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return c.PlayerID, nil
+}
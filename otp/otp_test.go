@@ -0,0 +1,214 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"testing"
+	"time"
+)
+
+func openDB(t *testing.T) {
+	t.Helper()
+
+	dbtest.Open(t)
+	SetStore(nil)
+}
+
+func TestNewCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		c, err := newCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c) != Digits {
+			t.Fatalf("code %q is %d long, want %d", c, len(c), Digits)
+		}
+		for _, r := range c {
+			if r < '0' || r > '9' {
+				t.Fatalf("code %q isn't all digits", c)
+			}
+		}
+		seen[c] = true
+	}
+	if len(seen) < 45 {
+		t.Errorf("50 codes had only %d different ones", len(seen))
+	}
+}
+
+func TestHash(t *testing.T) {
+	h := hash(1, "salt", "123456")
+	if h != hash(1, "salt", "123456") {
+		t.Error("the same code hashed differently")
+	}
+	for name, other := range map[string]string{
+		"another code":   hash(1, "salt", "123457"),
+		"another salt":   hash(1, "pepper", "123456"),
+		"another player": hash(2, "salt", "123456"),
+	} {
+		if other == h {
+			t.Errorf("%s hashed the same", name)
+		}
+	}
+	if hashNonce("a") == hashNonce("b") {
+		t.Error("different nonces hashed the same")
+	}
+}
+
+func TestRequestAndVerify(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	code, nonce, err := Request(ctx, 7, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	err = db.Con.QueryRow("SELECT COUNT(*) FROM login_code WHERE code_hash=? OR nonce_hash=?", code, nonce).Scan(&n)
+	if err != nil || n != 0 {
+		t.Errorf("the code or nonce was stored as it is: %d rows, %v", n, err)
+	}
+
+	_, err = Verify(ctx, "", code)
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("no nonce = %v, want ErrInvalidCode", err)
+	}
+	_, err = Verify(ctx, nonce+"0", code)
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("another browser's nonce = %v, want ErrInvalidCode", err)
+	}
+
+	pid, err := Verify(ctx, nonce, code)
+	if err != nil || pid != 7 {
+		t.Fatalf("verifying = %d, %v, want player 7", pid, err)
+	}
+	_, err = Verify(ctx, nonce, code)
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("using the code again = %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestNewerCodeRetiresOlder(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	old, oldNonce, err := Request(ctx, 7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, nonce, err := Request(ctx, 7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Verify(ctx, oldNonce, old)
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("the older code = %v, want ErrInvalidCode", err)
+	}
+	pid, err := Verify(ctx, nonce, code)
+	if err != nil || pid != 7 {
+		t.Errorf("the newer code = %d, %v, want player 7", pid, err)
+	}
+}
+
+func TestExpiredCode(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	code, nonce, err := Request(ctx, 7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Con.Exec("UPDATE login_code SET expires_date=?", db.FormatTime(time.Now().Add(-time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Verify(ctx, nonce, code)
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("an expired code = %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestTooManyAttempts(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	code, nonce, err := Request(ctx, 7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := fmt.Sprintf("%0*d", Digits, 0)
+	if wrong == code {
+		wrong = fmt.Sprintf("%0*d", Digits, 1)
+	}
+
+	for i := 1; i < MaxAttempts; i++ {
+		_, err = Verify(ctx, nonce, wrong)
+		if !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("wrong guess %d = %v, want ErrInvalidCode", i, err)
+		}
+	}
+	_, err = Verify(ctx, nonce, wrong)
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("wrong guess %d = %v, want ErrTooManyAttempts", MaxAttempts, err)
+	}
+	_, err = Verify(ctx, nonce, code)
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("the right code after too many guesses = %v, want ErrTooManyAttempts", err)
+	}
+}
+
+func TestRequestLimits(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	for i := 0; i < MaxPerPlayer; i++ {
+		_, _, err := Request(ctx, 7, "")
+		if err != nil {
+			t.Fatalf("code %d: %s", i+1, err)
+		}
+	}
+	_, _, err := Request(ctx, 7, "")
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("code %d for a player = %v, want ErrTooManyRequests", MaxPerPlayer+1, err)
+	}
+
+	for i := 0; i < MaxPerIP; i++ {
+		_, _, err := Request(ctx, int64(100+i), "10.0.0.2")
+		if err != nil {
+			t.Fatalf("code %d from an address: %s", i+1, err)
+		}
+	}
+	_, _, err = Request(ctx, 200, "10.0.0.2")
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("code %d from an address = %v, want ErrTooManyRequests", MaxPerIP+1, err)
+	}
+	_, _, err = Request(ctx, 200, "10.0.0.3")
+	if err != nil {
+		t.Errorf("a code from another address: %s", err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(2, 50*time.Millisecond)
+
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatal("the first two weren't allowed")
+	}
+	if l.Allow("a") {
+		t.Error("a third was allowed within the window")
+	}
+	if !l.Allow("b") {
+		t.Error("another key was held to the first's limit")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !l.Allow("a") {
+		t.Error("not allowed again once the window passed")
+	}
+}
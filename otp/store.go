@@ -0,0 +1,158 @@
+package otp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"mariners/db"
+	"time"
+)
+
+// CodeStore loads and saves login codes.  Only a salted hash of each code is
+// kept.
+type CodeStore interface {
+	// AddCode saves c, and retires the player's other unused codes so only
+	// the newest one works.
+	AddCode(ctx context.Context, c *Code) error
+	// GetCodeByNonce returns the code whose nonce hashes to hash.
+	GetCodeByNonce(ctx context.Context, hash string) (Code, error)
+	// CountPlayerCodes counts the codes player pid has asked for since.
+	CountPlayerCodes(ctx context.Context, pid int64, since time.Time) (int, error)
+	// CountIPCodes counts the codes asked for from ip since.
+	CountIPCodes(ctx context.Context, ip string, since time.Time) (int, error)
+	// AddAttempt uses up one of the guesses of code id, and returns
+	// sql.ErrNoRows if it has none left or has been used.
+	AddAttempt(ctx context.Context, id int64, max int) error
+	// UseCode marks code id used, and returns sql.ErrNoRows if it already
+	// was.
+	UseCode(ctx context.Context, id int64) error
+	// DeleteCodes deletes the codes asked for before before.
+	DeleteCodes(ctx context.Context, before time.Time) error
+}
+
+// SQLCodeStore is a CodeStore backed by the login_code table.
+type SQLCodeStore struct {
+	DB *sql.DB
+}
+
+func NewSQLCodeStore(con *sql.DB) *SQLCodeStore {
+	return &SQLCodeStore{DB: con}
+}
+
+var store CodeStore
+
+// SetStore replaces the CodeStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s CodeStore) {
+	store = s
+}
+
+func getStore() CodeStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLCodeStore(db.Con)
+}
+
+func (s *SQLCodeStore) AddCode(ctx context.Context, c *Code) error {
+	return db.InTx(ctx, s.DB, func(ctx context.Context) error {
+		query := "UPDATE login_code SET used=? WHERE idplayer=? AND used=?"
+		_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, true, c.PlayerID, false)
+		if err != nil {
+			return err
+		}
+
+		query = "INSERT INTO login_code (idcode, idplayer, code_hash, salt, nonce_hash, ip, attempts, used, created_date, expires_date) VALUES (NULL, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+			c.PlayerID,
+			c.Hash,
+			c.Salt,
+			c.NonceHash,
+			c.IP,
+			c.Attempts,
+			c.Used,
+			db.FormatTime(c.CreatedDate),
+			db.FormatTime(c.ExpiresDate))
+		if err != nil {
+			return err
+		}
+		c.ID, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("no login code added")
+		}
+
+		return nil
+	})
+}
+
+func (s *SQLCodeStore) GetCodeByNonce(ctx context.Context, hash string) (Code, error) {
+	c := Code{}
+
+	query := "SELECT idcode, idplayer, code_hash, salt, nonce_hash, ip, attempts, used, created_date, expires_date FROM login_code WHERE nonce_hash=? AND nonce_hash<>''"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, hash).Scan(
+		&c.ID,
+		&c.PlayerID,
+		&c.Hash,
+		&c.Salt,
+		&c.NonceHash,
+		&c.IP,
+		&c.Attempts,
+		&c.Used,
+		db.ScanTime(&c.CreatedDate),
+		db.ScanTime(&c.ExpiresDate))
+
+	return c, err
+}
+
+func (s *SQLCodeStore) CountPlayerCodes(ctx context.Context, pid int64, since time.Time) (int, error) {
+	var n int
+
+	query := "SELECT COUNT(*) FROM login_code WHERE idplayer=? AND created_date>=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, pid, db.FormatTime(since)).Scan(&n)
+
+	return n, err
+}
+
+func (s *SQLCodeStore) CountIPCodes(ctx context.Context, ip string, since time.Time) (int, error) {
+	var n int
+
+	query := "SELECT COUNT(*) FROM login_code WHERE ip=? AND created_date>=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, ip, db.FormatTime(since)).Scan(&n)
+
+	return n, err
+}
+
+func (s *SQLCodeStore) AddAttempt(ctx context.Context, id int64, max int) error {
+	query := "UPDATE login_code SET attempts=attempts+1 WHERE idcode=? AND attempts<? AND used=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id, max, false)
+	if err != nil {
+		return err
+	}
+
+	return db.OneRow(res)
+}
+
+func (s *SQLCodeStore) UseCode(ctx context.Context, id int64) error {
+	query := "UPDATE login_code SET used=? WHERE idcode=? AND used=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, true, id, false)
+	if err != nil {
+		return err
+	}
+
+	return db.OneRow(res)
+}
+
+func (s *SQLCodeStore) DeleteCodes(ctx context.Context, before time.Time) error {
+	query := "DELETE FROM login_code WHERE created_date<?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, db.FormatTime(before))
+
+	return err
+}
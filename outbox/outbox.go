@@ -0,0 +1,213 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"mariners/sms"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Statuses an outbox entry moves through.
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Kinds of entry the outbox carries out itself.  Packages register their own
+// with Register.
+const (
+	KindUnsubscribe = "sms.unsubscribe"
+	KindDeleteTopic = "sms.delete_topic"
+)
+
+// MaxAttempts is how many times an entry is tried before it's marked failed
+// and the entries after it are let through.
+var MaxAttempts = 10
+
+// Entry is one side effect waiting for, or done after, the change that needed
+// it.
+type Entry struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Payload   string    `json:"payload"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	Date      time.Time `json:"date"`
+}
+
+// Handler carries out an entry of one kind from its JSON payload.
+type Handler func(ctx context.Context, payload []byte) error
+
+var (
+	handlers = make(map[string]Handler)
+	// flushing keeps two flushes from running the same entry, or running
+	// entries out of order.
+	flushing sync.Mutex
+)
+
+func init() {
+	Register(KindUnsubscribe, func(ctx context.Context, payload []byte) error {
+		var arn string
+		err := json.Unmarshal(payload, &arn)
+		if err != nil {
+			return err
+		}
+
+		return sms.RemoveSubscriber(arn)
+	})
+	Register(KindDeleteTopic, func(ctx context.Context, payload []byte) error {
+		var arn string
+		err := json.Unmarshal(payload, &arn)
+		if err != nil {
+			return err
+		}
+
+		return sms.DeleteTopic(arn)
+	})
+}
+
+// Register sets the handler for kind.  It's meant to be called from init.
+func Register(kind string, h Handler) {
+	handlers[kind] = h
+}
+
+// Add records an entry of kind with v as its payload.  Called with a
+// transaction's context, the entry is saved with the rest of the transaction
+// and carried out once it commits.
+func Add(ctx context.Context, kind string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	e := Entry{
+		Kind:    kind,
+		Payload: string(payload),
+		Status:  StatusPending,
+		Date:    time.Now().UTC(),
+	}
+	err = getStore().AddEntry(ctx, &e)
+	if err != nil {
+		return err
+	}
+
+	db.AfterCommit(ctx, func() {
+		err := Flush()
+		if err != nil {
+			log.Error().Msgf("outbox: %s\n", err)
+		}
+	})
+
+	return nil
+}
+
+// Unsubscribe removes SNS subscription arn once ctx's transaction commits.
+func Unsubscribe(ctx context.Context, arn string) error {
+	if arn == "" {
+		return nil
+	}
+
+	return Add(ctx, KindUnsubscribe, arn)
+}
+
+// DeleteTopic deletes SNS topic arn once ctx's transaction commits.
+func DeleteTopic(ctx context.Context, arn string) error {
+	if arn == "" {
+		return nil
+	}
+
+	return Add(ctx, KindDeleteTopic, arn)
+}
+
+// Flush carries out pending entries in the order they were added.  It stops at
+// the first one that fails, so the entries after it, which may depend on it,
+// wait for the next flush.
+func Flush() error {
+	flushing.Lock()
+	defer flushing.Unlock()
+
+	for {
+		ctx, cancelfunc := db.Context()
+		e, err := getStore().NextEntry(ctx)
+		cancelfunc()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = run(e)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// run carries out e and records how it went.  Entries that fail for good, or
+// run out of attempts, are marked failed so they stop holding up the rest.
+func run(e Entry) error {
+	ctx, cancelfunc := db.Context()
+	defer cancelfunc()
+
+	var rerr error
+	h, ok := handlers[e.Kind]
+	if ok {
+		rerr = h(ctx, []byte(e.Payload))
+	} else {
+		rerr = fmt.Errorf("no handler for %s", e.Kind)
+	}
+	e.Attempts++
+
+	switch {
+	case rerr == nil:
+		e.Status = StatusDone
+		e.LastError = ""
+	case !ok || sms.Permanent(rerr) || e.Attempts >= MaxAttempts:
+		e.Status = StatusFailed
+		e.LastError = rerr.Error()
+		log.Error().Msgf("outbox: giving up on %s %d: %s\n", e.Kind, e.ID, rerr)
+		rerr = nil
+	default:
+		e.LastError = rerr.Error()
+	}
+
+	err := getStore().UpdateEntry(ctx, e)
+	if err != nil {
+		return err
+	}
+	if rerr != nil {
+		return fmt.Errorf("%s %d: %w", e.Kind, e.ID, rerr)
+	}
+
+	return nil
+}
+
+// Run flushes the outbox every interval until ctx is done, picking up entries
+// whose first try failed or whose flush never ran.
+func Run(ctx context.Context, every time.Duration) error {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+
+	for {
+		err := Flush()
+		if err != nil {
+			log.Error().Msgf("outbox: %s\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
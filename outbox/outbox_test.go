@@ -0,0 +1,219 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"testing"
+	"time"
+)
+
+func openDB(t *testing.T) {
+	t.Helper()
+
+	dbtest.Open(t)
+	SetStore(nil)
+}
+
+// recorder registers a kind whose handler records the payloads it runs, and
+// fails while fail is set.
+type recorder struct {
+	ran  []string
+	fail error
+}
+
+func record(kind string) *recorder {
+	r := &recorder{}
+	Register(kind, func(ctx context.Context, payload []byte) error {
+		if r.fail != nil {
+			return r.fail
+		}
+		r.ran = append(r.ran, string(payload))
+		return nil
+	})
+
+	return r
+}
+
+func entries(t *testing.T) []Entry {
+	t.Helper()
+
+	rows, err := db.Con.Query("SELECT idoutbox, kind, status, attempts, last_error, created_date FROM outbox ORDER BY idoutbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var es []Entry
+	for rows.Next() {
+		var e Entry
+		err = rows.Scan(&e.ID, &e.Kind, &e.Status, &e.Attempts, &e.LastError, db.ScanTime(&e.Date))
+		if err != nil {
+			t.Fatal(err)
+		}
+		es = append(es, e)
+	}
+
+	return es
+}
+
+func TestAddStoresUTC(t *testing.T) {
+	openDB(t)
+	r := record("test.utc")
+
+	before := time.Now().UTC().Add(-time.Second)
+	err := Add(context.Background(), "test.utc", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw string
+	err = db.Con.QueryRow("SELECT created_date FROM outbox").Scan(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := time.Parse(db.TimeFormat, raw)
+	if err != nil {
+		t.Fatalf("created date %q isn't in the database format: %s", raw, err)
+	}
+	if got.Before(before) || got.After(time.Now().UTC().Add(time.Second)) {
+		t.Errorf("created date %s isn't now in UTC", raw)
+	}
+
+	es := entries(t)
+	if len(es) != 1 || es[0].Status != StatusDone || !es[0].Date.Equal(got) {
+		t.Errorf("entries %+v, want one done at %s", es, got)
+	}
+	if len(r.ran) != 1 {
+		t.Errorf("ran %d times outside a transaction, want once", len(r.ran))
+	}
+}
+
+func TestAddRunsAfterCommit(t *testing.T) {
+	openDB(t)
+	r := record("test.commit")
+
+	err := db.InTx(context.Background(), db.Con, func(ctx context.Context) error {
+		err := Add(ctx, "test.commit", "first")
+		if err != nil {
+			return err
+		}
+		if len(r.ran) != 0 {
+			t.Error("ran before the transaction committed")
+		}
+		return Add(ctx, "test.commit", "second")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.ran) != 2 || r.ran[0] != `"first"` || r.ran[1] != `"second"` {
+		t.Errorf("ran %v, want first then second", r.ran)
+	}
+}
+
+func TestAddRolledBack(t *testing.T) {
+	openDB(t)
+	r := record("test.rollback")
+
+	failed := errors.New("change failed")
+	err := db.InTx(context.Background(), db.Con, func(ctx context.Context) error {
+		err := Add(ctx, "test.rollback", 1)
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("InTx = %v, want the change's error", err)
+	}
+
+	if es := entries(t); len(es) != 0 {
+		t.Errorf("a rolled back entry was kept: %+v", es)
+	}
+	if len(r.ran) != 0 {
+		t.Errorf("ran %v for a rolled back change", r.ran)
+	}
+}
+
+func TestFlushStopsAtFailure(t *testing.T) {
+	openDB(t)
+	first, second := record("test.first"), record("test.second")
+
+	first.fail = errors.New("provider down")
+	ctx := context.Background()
+	err := db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		err := Add(ctx, "test.first", 1)
+		if err != nil {
+			return err
+		}
+		return Add(ctx, "test.second", 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(second.ran) != 0 {
+		t.Error("the entry after a failed one ran")
+	}
+	es := entries(t)
+	if len(es) != 2 || es[0].Status != StatusPending || es[0].Attempts == 0 || es[0].LastError != "provider down" {
+		t.Fatalf("entries %+v, want the first pending after a failed attempt", es)
+	}
+
+	first.fail = nil
+	err = Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.ran) != 1 || len(second.ran) != 1 {
+		t.Errorf("ran %v and %v, want each once", first.ran, second.ran)
+	}
+	for _, e := range entries(t) {
+		if e.Status != StatusDone || e.LastError != "" {
+			t.Errorf("entry %d is %s (%q), want done", e.ID, e.Status, e.LastError)
+		}
+	}
+}
+
+func TestFlushGivesUp(t *testing.T) {
+	openDB(t)
+	stuck, after := record("test.stuck"), record("test.after")
+	stuck.fail = errors.New("still down")
+
+	old := MaxAttempts
+	MaxAttempts = 2
+	t.Cleanup(func() { MaxAttempts = old })
+
+	ctx := context.Background()
+	for _, kind := range []string{"test.stuck", "test.unknown", "test.after"} {
+		err := NewSQLOutboxStore(db.Con).AddEntry(ctx, &Entry{Kind: kind, Payload: "1", Status: StatusPending, Date: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := Flush()
+	if err == nil {
+		t.Fatal("the first flush didn't report the failure")
+	}
+	err = Flush()
+	if err != nil {
+		t.Fatalf("flushing once the stuck entry gave up: %s", err)
+	}
+
+	es := entries(t)
+	want := []string{StatusFailed, StatusFailed, StatusDone}
+	for i, e := range es {
+		if e.Status != want[i] {
+			t.Errorf("%s is %s, want %s", e.Kind, e.Status, want[i])
+		}
+	}
+	if es[0].Attempts != 2 {
+		t.Errorf("stuck entry tried %d times, want 2", es[0].Attempts)
+	}
+	if len(after.ran) != 1 {
+		t.Errorf("the entry after the failed ones ran %d times, want once", len(after.ran))
+	}
+}
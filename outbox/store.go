@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"mariners/db"
+)
+
+// OutboxStore loads and saves outbox entries.
+type OutboxStore interface {
+	AddEntry(ctx context.Context, e *Entry) error
+	// NextEntry returns the oldest pending entry, or sql.ErrNoRows if there
+	// are none.
+	NextEntry(ctx context.Context) (Entry, error)
+	UpdateEntry(ctx context.Context, e Entry) error
+}
+
+// SQLOutboxStore is an OutboxStore backed by the outbox table.
+type SQLOutboxStore struct {
+	DB *sql.DB
+}
+
+func NewSQLOutboxStore(con *sql.DB) *SQLOutboxStore {
+	return &SQLOutboxStore{DB: con}
+}
+
+var store OutboxStore
+
+// SetStore replaces the OutboxStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s OutboxStore) {
+	store = s
+}
+
+func getStore() OutboxStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLOutboxStore(db.Con)
+}
+
+func (s *SQLOutboxStore) AddEntry(ctx context.Context, e *Entry) error {
+	query := "INSERT INTO outbox (idoutbox, kind, payload, status, attempts, last_error, created_date) VALUES (NULL, ?, ?, ?, ?, ?, ?)"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, e.Kind, e.Payload, e.Status, e.Attempts, e.LastError, db.FormatTime(e.Date))
+	if err != nil {
+		return err
+	}
+	e.ID, err = res.LastInsertId()
+
+	return err
+}
+
+func (s *SQLOutboxStore) NextEntry(ctx context.Context) (Entry, error) {
+	e := Entry{}
+
+	query := "SELECT idoutbox, kind, payload, status, attempts, last_error, created_date FROM outbox WHERE status=? ORDER BY idoutbox LIMIT 1"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, StatusPending).Scan(&e.ID, &e.Kind, &e.Payload, &e.Status, &e.Attempts, &e.LastError, db.ScanTime(&e.Date))
+
+	return e, err
+}
+
+func (s *SQLOutboxStore) UpdateEntry(ctx context.Context, e Entry) error {
+	query := "UPDATE outbox SET status=?, attempts=?, last_error=? WHERE idoutbox=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, e.Status, e.Attempts, e.LastError, e.ID)
+
+	return err
+}
@@ -0,0 +1,66 @@
+package player
+
+import (
+	"context"
+	"mariners/db/dbtest"
+	"testing"
+)
+
+func TestPlayerHostileStrings(t *testing.T) {
+	ctx := context.Background()
+	dbtest.Open(t)
+	SetStore(nil)
+
+	for i, h := range dbtest.Hostile {
+		p := Player{Name: h, PreferredName: h, Phone: h, Email: h, GhinNumber: h, TextPreference: h}
+		err := AddPlayer(ctx, &p)
+		if err != nil {
+			t.Fatalf("adding %q: %s", h, err)
+		}
+		checkPlayer(t, "added", p.ID, h)
+
+		// Update with the next string, so a value left over from the add
+		// would show.
+		u := dbtest.Hostile[(i+1)%len(dbtest.Hostile)]
+		p.Name, p.PreferredName, p.Phone, p.Email, p.GhinNumber, p.TextPreference = u, u, u, u, u, u
+		err = p.UpdatePlayer(ctx)
+		if err != nil {
+			t.Fatalf("updating to %q: %s", u, err)
+		}
+		checkPlayer(t, "updated", p.ID, u)
+
+		err = p.DeletePlayer(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkPlayer(t *testing.T, how string, id int64, want string) {
+	t.Helper()
+
+	ctx := context.Background()
+	got := Player{}
+	err := got.GetPlayerByID(ctx, id)
+	if err != nil {
+		t.Fatalf("%s %q: %s", how, want, err)
+	}
+	for name, v := range map[string]string{
+		"name":            got.Name,
+		"preferred name":  got.PreferredName,
+		"phone":           got.Phone,
+		"email":           got.Email,
+		"GHIN number":     got.GhinNumber,
+		"text preference": got.TextPreference,
+	} {
+		if v != want {
+			t.Errorf("%s %s read back as %q, want %q", how, name, v, want)
+		}
+	}
+
+	byName := Player{}
+	err = byName.GetPlayerByPreferredName(ctx, want)
+	if err != nil || byName.ID != id {
+		t.Errorf("%s: looking up %q found %d, %v, want %d", how, want, byName.ID, err, id)
+	}
+}
@@ -2,11 +2,14 @@ package player
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"mariners/db"
+	"mariners/outbox"
 	"mariners/role"
 	"mariners/sms"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/nyaruka/phonenumbers"
@@ -14,144 +17,67 @@ import (
 
 type Player struct {
 	Roles               role.Roles
-	ID                  int64  `json:"id"`
-	Name                string `json:"name"`
-	PreferredName       string `json:"preferred_name"`
-	Phone               string `json:"phone"`
-	Email               string `json:"email"`
-	GhinNumber          string `json:"ghin_number"`
-	MainSubscriptionARN string `json:"main_sub_arn"`
-	TextPreference      string `json:"text_preference"`
-	IconRatio           string `json:"icon_ratio"`
-	FormSize            string `json:"form_size"`
+	ID                  int64       `json:"id"`
+	Name                string      `json:"name"`
+	PreferredName       string      `json:"preferred_name"`
+	Phone               string      `json:"phone"`
+	Email               string      `json:"email"`
+	GhinNumber          string      `json:"ghin_number"`
+	MainSubscriptionARN string      `json:"main_sub_arn"`
+	TextPreference      string      `json:"text_preference"`
+	IconRatio           string      `json:"icon_ratio"`
+	FormSize            string      `json:"form_size"`
+	Preferences         Preferences `json:"preferences"`
+	// Permissions are what the player's roles allow between them.
+	Permissions []string `json:"permissions"`
 }
 
 type Players []Player
 
-func AddPlayer(p *Player) error {
-	query := fmt.Sprintf("INSERT INTO player (idplayer, name, preferred_name, phone, email, ghin_number, text_preference) VALUES (NULL, \"%s\", \"%s\", \"%s\", \"%s\", \"%s\", \"%s\");\n",
-		p.Name,
-		p.PreferredName,
-		p.Phone,
-		p.Email,
-		p.GhinNumber,
-		p.TextPreference)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	p.ID, err = res.LastInsertId()
-	if err != nil {
-		return err
-	}
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return fmt.Errorf("no player added")
-	}
+// KindSubscribe is the outbox entry that subscribes a player to the main SNS
+// topic.
+const KindSubscribe = "player.subscribe"
 
-	switch p.TextPreference {
-	case "uk-text-small":
-		p.IconRatio = "0.8"
-		p.FormSize = "uk-form-small"
-	case "uk-text-large":
-		p.IconRatio = "1.5"
-		p.FormSize = "uk-form-large"
-	default:
-		p.IconRatio = "1"
-		p.FormSize = ""
-	}
+func init() {
+	outbox.Register(KindSubscribe, runSubscribe)
+}
 
-	for rk := range p.Roles {
-		query = fmt.Sprintf("INSERT INTO role_members (idrole, idplayer) VALUES (%d, %d)",
-			rk,
-			p.ID)
-		ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelfunc()
-		res, err := db.Con.ExecContext(ctx, query)
+// AddPlayer saves a new player.  Users are subscribed to the main topic once
+// the player has been saved.
+func AddPlayer(ctx context.Context, p *Player) error {
+	if p.HasRole("User") {
+		_, err := NormalizePhone(p.Phone)
 		if err != nil {
 			return err
 		}
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return err
-		}
-		if rows == 0 {
-			return fmt.Errorf("no role added for player %d", p.ID)
-		}
 	}
 
-	if p.HasRole("User") && p.MainSubscriptionARN == "" {
-		num, err := phonenumbers.Parse(p.Phone, "US")
-		if err != nil {
-			return err
-		}
-		phone := phonenumbers.Format(num, phonenumbers.E164)
-		sa, err := sms.SubscribeUser(phone, sms.MainTopicARN)
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err := db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		err := getStore().AddPlayer(ctx, p)
 		if err != nil {
 			return err
 		}
-		query := fmt.Sprintf("UPDATE player set main_sub_arn = \"%s\" WHERE idplayer=%d", sa, p.ID)
 
-		ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelfunc()
-		_, err = db.Con.ExecContext(ctx, query)
-		if err != nil {
-			return err
+		if p.HasRole("User") && p.MainSubscriptionARN == "" {
+			return outbox.Add(ctx, KindSubscribe, p.ID)
 		}
-		p.MainSubscriptionARN = sa
-	}
-
-	return nil
-}
 
-func (p *Player) GetPlayerByID(id int64) error {
-	query := "SELECT idplayer, name, preferred_name, phone, email, ghin_number, main_sub_arn, text_preference FROM player WHERE idplayer=?"
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query, id).Scan(&p.ID, &p.Name, &p.PreferredName, &p.Phone, &p.Email, &p.GhinNumber, &p.MainSubscriptionARN, &p.TextPreference)
-	if err != nil {
-		return err
-	}
-
-	p.Roles, err = role.GetRolesByPlayerID(p.ID)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	switch p.TextPreference {
-	case "uk-text-small":
-		p.IconRatio = "0.8"
-		p.FormSize = "uk-form-small"
-	case "uk-text-large":
-		p.IconRatio = "1.5"
-		p.FormSize = "uk-form-large"
-	default:
-		p.IconRatio = "1"
-		p.FormSize = ""
-	}
+	p.setTextSize()
 
 	return nil
 }
 
-func (p *Player) GetPlayerByPreferredName(name string) error {
-	query := "SELECT idplayer, name, preferred_name, phone, email, ghin_number, main_sub_arn, text_preference FROM player WHERE preferred_name=?"
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query, name).Scan(&p.ID, &p.Name, &p.PreferredName, &p.Phone, &p.Email, &p.GhinNumber, &p.MainSubscriptionARN, &p.TextPreference)
-	if err != nil {
-		return fmt.Errorf("error looking up name: %s: %s", name, err)
-	}
-
-	p.Roles, err = role.GetRolesByPlayerID(p.ID)
-	if err != nil {
-		return err
-	}
-
+// setTextSize fills in the icon and form sizes that go with the player's text
+// preference.
+func (p *Player) setTextSize() {
 	switch p.TextPreference {
 	case "uk-text-small":
 		p.IconRatio = "0.8"
@@ -163,286 +89,288 @@ func (p *Player) GetPlayerByPreferredName(name string) error {
 		p.IconRatio = "1"
 		p.FormSize = ""
 	}
-
-	return nil
 }
 
-func GetPlayers() (Players, error) {
-	p := make(Players, 0)
-
-	query := "SELECT idplayer, name, preferred_name, phone, email, ghin_number, main_sub_arn, text_preference FROM player ORDER BY preferred_name"
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+// runSubscribe carries out a KindSubscribe entry.  The player is looked up
+// again so a player who was deleted, or stopped being a User, since the entry
+// was added isn't subscribed.
+func runSubscribe(ctx context.Context, payload []byte) error {
+	var id int64
+	err := json.Unmarshal(payload, &id)
 	if err != nil {
-		return p, err
+		return err
 	}
 
-	for rows.Next() {
-		var player Player
-		if err := rows.Scan(&player.ID, &player.Name, &player.PreferredName, &player.Phone, &player.Email, &player.GhinNumber, &player.MainSubscriptionARN, &player.TextPreference); err != nil {
-			return p, err
-		}
-		player.Roles, err = role.GetRolesByPlayerID(player.ID)
-		if err != nil {
-			return p, err
-		}
-		switch player.TextPreference {
-		case "uk-text-small":
-			player.IconRatio = "0.8"
-			player.FormSize = "uk-form-small"
-		case "uk-text-large":
-			player.IconRatio = "1.5"
-			player.FormSize = "uk-form-large"
-		default:
-			player.IconRatio = "1"
-			player.FormSize = ""
-		}
-		p = append(p, player)
+	p, err := getStore().GetPlayer(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
 	}
-
-	return p, nil
-}
-
-func (p *Player) GetPlayerByToken(token string) error {
-	query := "SELECT idplayer, name, preferred_name, phone, email, ghin_number, main_sub_arn, text_preference FROM player WHERE token=?"
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query, token).Scan(&p.ID, &p.Name, &p.PreferredName, &p.Phone, &p.Email, &p.GhinNumber, &p.MainSubscriptionARN, &p.TextPreference)
 	if err != nil {
 		return err
 	}
+	if !p.HasRole("User") || p.MainSubscriptionARN != "" {
+		return nil
+	}
 
-	p.Roles, err = role.GetRolesByPlayerID(p.ID)
+	phone, err := NormalizePhone(p.Phone)
 	if err != nil {
 		return err
 	}
-
-	switch p.TextPreference {
-	case "uk-text-small":
-		p.IconRatio = "0.8"
-		p.FormSize = "uk-form-small"
-	case "uk-text-large":
-		p.IconRatio = "1.5"
-		p.FormSize = "uk-form-large"
-	default:
-		p.IconRatio = "1"
-		p.FormSize = ""
+	sa, err := sms.SubscribeUser(phone, sms.MainTopicARN)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return getStore().SetSubscription(ctx, p.ID, sa)
 }
 
-func (p *Player) WriteToken(token string) error {
-	query := fmt.Sprintf("UPDATE player set token = \"%s\" WHERE idplayer = %d;\n", token, p.ID)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (p *Player) GetPlayerByID(ctx context.Context, id int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
+	np, err := getStore().GetPlayer(ctx, id)
 	if err != nil {
 		return err
 	}
+	*p = np
+
+	return nil
+}
 
-	rows, err := res.RowsAffected()
+func (p *Player) GetPlayerByPreferredName(ctx context.Context, name string) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	np, err := getStore().GetPlayerByPreferredName(ctx, name)
 	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return fmt.Errorf("no token added")
+		return fmt.Errorf("error looking up name: %s: %s", name, err)
 	}
+	*p = np
 
 	return nil
 }
 
-func (p *Player) RemoveToken() error {
-	query := fmt.Sprintf("UPDATE player set token = null WHERE idplayer = %d;\n", p.ID)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+func GetPlayers(ctx context.Context) (Players, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
+
+	return getStore().GetPlayers(ctx)
+}
+
+// GetPlayerByToken loads the player whose web session is token.  Players
+// without a session have an empty token, so it never matches one.
+func (p *Player) GetPlayerByToken(ctx context.Context, token string) error {
+	if token == "" {
+		return sql.ErrNoRows
 	}
 
-	rows, err := res.RowsAffected()
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	np, err := getStore().GetPlayerByToken(ctx, token)
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
-		return fmt.Errorf("no token removed")
-	}
+	*p = np
 
 	return nil
 }
 
-func (p *Player) UpdatePlayer() error {
-	query := fmt.Sprintf("UPDATE player set name = \"%s\", preferred_name = \"%s\", phone = \"%s\", email = \"%s\", ghin_number = \"%s\", main_sub_arn = \"%s\", text_preference = \"%s\" WHERE idplayer = %d;\n",
-		p.Name,
-		p.PreferredName,
-		p.Phone,
-		p.Email,
-		p.GhinNumber,
-		p.MainSubscriptionARN,
-		p.TextPreference,
-		p.ID,
-	)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (p *Player) WriteToken(ctx context.Context, token string) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	_, err := db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
 
-	query = fmt.Sprintf("DELETE FROM role_members WHERE idplayer = %d",
-		p.ID)
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
+	return getStore().WriteToken(ctx, p.ID, token)
+}
+
+func (p *Player) RemoveToken(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	_, err = db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
 
-	for rk := range p.Roles {
-		query = fmt.Sprintf("INSERT INTO role_members (idrole, idplayer) VALUES (%d, %d)",
-			rk,
-			p.ID)
-		ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelfunc()
-		_, err := db.Con.ExecContext(ctx, query)
+	return getStore().RemoveToken(ctx, p.ID)
+}
+
+// UpdatePlayer saves the player.  Players who have become Users are
+// subscribed to the main topic, and those who no longer are unsubscribed, once
+// the player has been saved.
+func (p *Player) UpdatePlayer(ctx context.Context) error {
+	if p.HasRole("User") {
+		_, err := NormalizePhone(p.Phone)
 		if err != nil {
 			return err
 		}
 	}
 
-	if p.HasRole("User") && p.MainSubscriptionARN == "" {
-		num, err := phonenumbers.Parse(p.Phone, "US")
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err := db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		// The subscription isn't edited with the rest of the player, so
+		// go by the one on record.
+		cur, err := getStore().GetPlayer(ctx, p.ID)
 		if err != nil {
 			return err
 		}
-		phone := phonenumbers.Format(num, phonenumbers.E164)
-		sa, err := sms.SubscribeUser(phone, sms.MainTopicARN)
+		p.MainSubscriptionARN = cur.MainSubscriptionARN
+
+		err = getStore().UpdatePlayer(ctx, p)
 		if err != nil {
 			return err
 		}
-		query := fmt.Sprintf("UPDATE player set main_sub_arn = \"%s\" WHERE idplayer=%d", sa, p.ID)
 
-		ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelfunc()
-		_, err = db.Con.ExecContext(ctx, query)
-		if err != nil {
-			return err
+		switch {
+		case p.HasRole("User") && p.MainSubscriptionARN == "":
+			return outbox.Add(ctx, KindSubscribe, p.ID)
+		case !p.HasRole("User") && p.MainSubscriptionARN != "":
+			return p.unsubscribe(ctx)
 		}
-		p.MainSubscriptionARN = sa
-	} else if !p.HasRole("User") && p.MainSubscriptionARN != "" {
-		err = sms.RemoveSubscriber(p.MainSubscriptionARN)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.setTextSize()
+
+	return nil
+}
+
+// DeletePlayer removes the player, and their main topic subscription once
+// they're gone.
+func (p *Player) DeletePlayer(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		cur, err := getStore().GetPlayer(ctx, p.ID)
 		if err != nil {
 			return err
 		}
-		query = fmt.Sprintf("UPDATE player SET main_sub_arn = \"\" WHERE idplayer=%d", p.ID)
-		ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelfunc()
-		_, err = db.Con.ExecContext(ctx, query)
+
+		err = getStore().DeletePlayer(ctx, p.ID)
 		if err != nil {
 			return err
 		}
-	}
 
-	switch p.TextPreference {
-	case "uk-text-small":
-		p.IconRatio = "0.8"
-		p.FormSize = "uk-form-small"
-	case "uk-text-large":
-		p.IconRatio = "1.5"
-		p.FormSize = "uk-form-large"
-	default:
-		p.IconRatio = "1"
-		p.FormSize = ""
+		return outbox.Unsubscribe(ctx, cur.MainSubscriptionARN)
+	})
+}
+
+// Can reports whether the player's roles grant perm.  Owned permissions
+// also cover the player's own things, which is up to the caller to check.
+func (p *Player) Can(perm string) bool {
+	for _, pp := range p.Permissions {
+		if pp == perm {
+			return true
+		}
 	}
 
-	return nil
+	return false
 }
 
-func (p *Player) DeletePlayer() error {
-	query := fmt.Sprintf("DELETE FROM role_members WHERE idplayer=%d", p.ID)
+func (p *Player) HasRole(rolename string) bool {
+	hr := false
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	_, err := db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
+	for _, r := range p.Roles {
+		if r == rolename {
+			hr = true
+		}
 	}
 
-	query = fmt.Sprintf("DELETE FROM player WHERE idplayer=%d", p.ID)
+	return hr
+}
 
-	ctx, cancelfunc = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
+func AddRoleAll(ctx context.Context, id int64) error {
+	ps, err := GetPlayers(ctx)
 	if err != nil {
 		return err
 	}
 
-	rows, err := res.RowsAffected()
+	rs, err := role.GetRoles(ctx)
 	if err != nil {
 		return err
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("no player deleted")
+	for _, p := range ps {
+		if p.Roles[id] == "" {
+			p.Roles[id] = rs[id]
+		}
+
+		err = p.UpdatePlayer(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (p *Player) HasRole(rolename string) bool {
-	hr := false
+// ErrInvalidPhone is returned for a phone number that can't be texted.
+var ErrInvalidPhone = errors.New("invalid phone number")
 
-	for _, r := range p.Roles {
-		if r == rolename {
-			hr = true
-		}
+// NormalizePhone formats phone as E.164, reading numbers without a country
+// code as US numbers.
+func NormalizePhone(phone string) (string, error) {
+	num, err := phonenumbers.Parse(phone, "US")
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %s", ErrInvalidPhone, phone, err)
 	}
 
-	return hr
+	return phonenumbers.Format(num, phonenumbers.E164), nil
 }
 
-func AddRoleAll(id int64) error {
-	ps, err := GetPlayers()
+// GetPlayerByPhone loads the player whose phone number matches phone once both
+// are normalized, since numbers are stored the way they were entered.
+func (p *Player) GetPlayerByPhone(ctx context.Context, phone string) error {
+	want, err := NormalizePhone(phone)
 	if err != nil {
 		return err
 	}
 
-	rs, err := role.GetRoles()
+	ps, err := GetPlayers(ctx)
 	if err != nil {
 		return err
 	}
 
-	for _, p := range ps {
-		if p.Roles[id] == "" {
-			p.Roles[id] = rs[id]
-			if (rs[id] == "User") && (p.Phone != "") {
-				num, err := phonenumbers.Parse(p.Phone, "US")
-				if err != nil {
-					return err
-				}
-				phone := phonenumbers.Format(num, phonenumbers.E164)
-				sa, err := sms.SubscribeUser(phone, sms.MainTopicARN)
-				if err != nil {
-					return err
-				}
-				query := fmt.Sprintf("UPDATE player set main_sub_arn = \"%s\" WHERE idplayer=%d", sa, p.ID)
-
-				ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-				defer cancelfunc()
-				_, err = db.Con.ExecContext(ctx, query)
-				if err != nil {
-					return err
-				}
-			}
+	for _, pl := range ps {
+		if pl.Phone == "" {
+			continue
 		}
-
-		err = p.UpdatePlayer()
+		have, err := NormalizePhone(pl.Phone)
 		if err != nil {
-			return err
+			continue
+		}
+		if have == want {
+			*p = pl
+			return nil
 		}
 	}
 
+	return fmt.Errorf("no player with phone %s", want)
+}
+
+// Unsubscribe removes the player from the main SNS topic.
+func (p *Player) Unsubscribe(ctx context.Context) error {
+	if p.MainSubscriptionARN == "" {
+		return nil
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return db.InTx(ctx, db.Con, p.unsubscribe)
+}
+
+// unsubscribe clears the player's subscription and removes it from SNS once
+// ctx's transaction commits.
+func (p *Player) unsubscribe(ctx context.Context) error {
+	err := getStore().SetSubscription(ctx, p.ID, "")
+	if err != nil {
+		return err
+	}
+
+	err = outbox.Unsubscribe(ctx, p.MainSubscriptionARN)
+	if err != nil {
+		return err
+	}
+	p.MainSubscriptionARN = ""
+
 	return nil
 }
@@ -0,0 +1,182 @@
+package player
+
+import (
+	"context"
+	"mariners/db/dbtest"
+	"mariners/role"
+	"mariners/sms"
+	"testing"
+)
+
+// openDB points the package at a fresh in-memory database and a fake
+// messenger.
+func openDB(t *testing.T) *sms.FakeMessenger {
+	t.Helper()
+
+	dbtest.Open(t)
+	SetStore(nil)
+
+	f := sms.NewFakeMessenger("")
+	sms.SetMessenger(f)
+
+	return f
+}
+
+func TestPlayerStore(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	p := Player{
+		Name:           "Walter Hagen",
+		PreferredName:  "Walter",
+		Phone:          "(415) 555-0100",
+		Email:          "walter@example.com",
+		GhinNumber:     "1234567",
+		TextPreference: "uk-text-large",
+		Roles:          role.Roles{2: "User", 3: "Game Manager"},
+	}
+	err := AddPlayer(ctx, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID == 0 {
+		t.Fatal("AddPlayer didn't set the ID")
+	}
+
+	got := Player{}
+	err = got.GetPlayerByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != p.Name || got.PreferredName != p.PreferredName || got.Phone != p.Phone || got.Email != p.Email || got.GhinNumber != p.GhinNumber {
+		t.Errorf("read back %+v, want %+v", got, p)
+	}
+	if got.IconRatio != "1.5" || got.FormSize != "uk-form-large" {
+		t.Errorf("text size %s/%s, want 1.5/uk-form-large", got.IconRatio, got.FormSize)
+	}
+	if !got.HasRole("User") || !got.Can(role.GamesManage) || got.Can(role.SiteAdmin) {
+		t.Errorf("roles %v grant permissions %v", got.Roles, got.Permissions)
+	}
+	if got.Preferences != DefaultPreferences {
+		t.Errorf("preferences %+v, want the defaults", got.Preferences)
+	}
+	if got.MainSubscriptionARN == "" {
+		t.Error("a new User wasn't subscribed to the main topic")
+	}
+
+	byPhone := Player{}
+	err = byPhone.GetPlayerByPhone(ctx, "+14155550100")
+	if err != nil || byPhone.ID != p.ID {
+		t.Errorf("GetPlayerByPhone found %d, %v, want %d", byPhone.ID, err, p.ID)
+	}
+
+	got.PreferredName = "The Haig"
+	got.Email = ""
+	err = got.UpdatePlayer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again := Player{}
+	err = again.GetPlayerByPreferredName(ctx, "The Haig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != p.ID || again.Email != "" {
+		t.Errorf("after update read back %+v", again)
+	}
+
+	err = again.DeletePlayer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = again.GetPlayerByID(ctx, p.ID); err == nil {
+		t.Error("deleted player is still there")
+	}
+}
+
+func TestAddUserNeedsPhone(t *testing.T) {
+	openDB(t)
+
+	p := Player{Name: "No Phone", PreferredName: "NP", Roles: role.Roles{2: "User"}}
+	err := AddPlayer(context.Background(), &p)
+	if err == nil {
+		t.Error("added a User without a phone number")
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	p := Player{Name: "Gene Sarazen", PreferredName: "Gene", Phone: "4155550101", Roles: role.Roles{2: "User"}}
+	err := AddPlayer(ctx, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Preferences = Preferences{Channel: ChannelEmail, League: true, Game: true, QuietStart: 21, QuietEnd: 7}
+	err = p.UpdatePreferences(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Player{ID: p.ID}
+	err = got.GetPreferences(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Preferences != p.Preferences {
+		t.Errorf("read back %+v, want %+v", got.Preferences, p.Preferences)
+	}
+
+	p.Preferences.Channel = "pigeon"
+	if err = p.UpdatePreferences(ctx); err == nil {
+		t.Error("saved an unknown channel")
+	}
+}
+
+func TestOptOutAndIn(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	p := Player{Name: "Byron Nelson", PreferredName: "Byron", Phone: "4155550102", Roles: role.Roles{2: "User"}}
+	err := AddPlayer(ctx, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.GetPlayerByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.OptOut(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := Player{}
+	err = out.GetPlayerByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Preferences.Channel != ChannelNone || out.Preferences.Wants(CategoryLeague) {
+		t.Errorf("after opting out the channel is %s", out.Preferences.Channel)
+	}
+	if out.MainSubscriptionARN != "" {
+		t.Error("still subscribed to the main topic after opting out")
+	}
+
+	err = out.OptIn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := Player{}
+	err = in.GetPlayerByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Preferences.Channel != ChannelSMS || !in.Preferences.Wants(CategoryLeague) {
+		t.Errorf("after opting in the channel is %s", in.Preferences.Channel)
+	}
+	if in.MainSubscriptionARN == "" {
+		t.Error("not subscribed to the main topic after opting in")
+	}
+}
@@ -0,0 +1,196 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"mariners/outbox"
+	"time"
+)
+
+// Channels a player can be reached on.
+const (
+	ChannelSMS   = "sms"
+	ChannelEmail = "email"
+	ChannelNone  = "none"
+)
+
+// Categories of message a player can opt out of.
+const (
+	CategoryLeague     = "league"
+	CategoryTournament = "tournament"
+	CategoryEvents     = "events"
+	CategoryGame       = "game"
+)
+
+// Preferences are how and when a player wants to hear from the league.  Quiet
+// hours run from QuietStart up to QuietEnd, in whole hours of league time, and
+// can wrap past midnight.  Equal hours mean no quiet hours.
+type Preferences struct {
+	Channel    string `json:"channel"`
+	League     bool   `json:"league"`
+	Tournament bool   `json:"tournament"`
+	Events     bool   `json:"events"`
+	Game       bool   `json:"game"`
+	QuietStart int    `json:"quiet_start"`
+	QuietEnd   int    `json:"quiet_end"`
+}
+
+// DefaultPreferences are used for players who haven't changed theirs: texts
+// for everything, but not between 8 PM and 8 AM.
+var DefaultPreferences = Preferences{
+	Channel:    ChannelSMS,
+	League:     true,
+	Tournament: true,
+	Events:     true,
+	Game:       true,
+	QuietStart: 20,
+	QuietEnd:   8,
+}
+
+// ErrInvalidPreferences is returned when preferences can't be saved.
+var ErrInvalidPreferences = errors.New("invalid notification preferences")
+
+func (pr Preferences) validate() error {
+	switch pr.Channel {
+	case ChannelSMS, ChannelEmail, ChannelNone:
+	default:
+		return fmt.Errorf("%w: unknown channel %q", ErrInvalidPreferences, pr.Channel)
+	}
+	if pr.QuietStart < 0 || pr.QuietStart > 23 || pr.QuietEnd < 0 || pr.QuietEnd > 23 {
+		return fmt.Errorf("%w: quiet hours must be between 0 and 23", ErrInvalidPreferences)
+	}
+
+	return nil
+}
+
+// Wants reports whether the player wants messages in category at all.
+func (pr Preferences) Wants(category string) bool {
+	if pr.Channel == ChannelNone {
+		return false
+	}
+
+	switch category {
+	case CategoryLeague:
+		return pr.League
+	case CategoryTournament:
+		return pr.Tournament
+	case CategoryEvents:
+		return pr.Events
+	case CategoryGame:
+		return pr.Game
+	}
+
+	return true
+}
+
+// Quiet reports whether t falls in the player's quiet hours, which are in
+// the league's timezone.
+func (pr Preferences) Quiet(t time.Time) bool {
+	h := db.Local(t).Hour()
+
+	switch {
+	case pr.QuietStart == pr.QuietEnd:
+		return false
+	case pr.QuietStart < pr.QuietEnd:
+		return h >= pr.QuietStart && h < pr.QuietEnd
+	default:
+		return h >= pr.QuietStart || h < pr.QuietEnd
+	}
+}
+
+// NextSend returns the first time at or after t the player can be messaged.
+func (pr Preferences) NextSend(t time.Time) time.Time {
+	if !pr.Quiet(t) {
+		return t
+	}
+
+	lt := db.Local(t)
+	end := time.Date(lt.Year(), lt.Month(), lt.Day(), pr.QuietEnd, 0, 0, 0, lt.Location())
+	if !end.After(lt) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return end.UTC()
+}
+
+// GetPreferences loads the player's preferences, falling back to
+// DefaultPreferences if they've never saved any.
+func (p *Player) GetPreferences(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	pr, err := getStore().GetPreferences(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	p.Preferences = pr
+
+	return nil
+}
+
+// UpdatePreferences saves the player's preferences.
+func (p *Player) UpdatePreferences(ctx context.Context) error {
+	err := p.Preferences.validate()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().SetPreferences(ctx, p.ID, p.Preferences)
+}
+
+// OptOut turns off all league messages for the player, as texting STOP does,
+// and takes them off the main topic.  Their other preferences are kept so
+// OptIn can put them back.
+func (p *Player) OptOut(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		pr, err := getStore().GetPreferences(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		pr.Channel = ChannelNone
+		err = getStore().SetPreferences(ctx, p.ID, pr)
+		if err != nil {
+			return err
+		}
+		p.Preferences = pr
+
+		if p.MainSubscriptionARN == "" {
+			return nil
+		}
+		return p.unsubscribe(ctx)
+	})
+}
+
+// OptIn reverses OptOut: messages come by text again and Users are put back
+// on the main topic.
+func (p *Player) OptIn(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		pr, err := getStore().GetPreferences(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if pr.Channel == ChannelNone {
+			pr.Channel = ChannelSMS
+			err = getStore().SetPreferences(ctx, p.ID, pr)
+			if err != nil {
+				return err
+			}
+		}
+		p.Preferences = pr
+
+		if !p.HasRole("User") || p.MainSubscriptionARN != "" {
+			return nil
+		}
+		return outbox.Add(ctx, KindSubscribe, p.ID)
+	})
+}
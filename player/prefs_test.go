@@ -0,0 +1,91 @@
+package player
+
+import (
+	"mariners/db"
+	"testing"
+	"time"
+)
+
+// at is a time in the league's timezone.
+func at(t *testing.T, s string) time.Time {
+	t.Helper()
+
+	v, err := db.ParseLocal("2006-01-02 15:04", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestQuiet(t *testing.T) {
+	day := Preferences{QuietStart: 9, QuietEnd: 17}
+	night := Preferences{QuietStart: 21, QuietEnd: 7}
+	never := Preferences{QuietStart: 8, QuietEnd: 8}
+
+	tests := []struct {
+		name string
+		pr   Preferences
+		t    string
+		want bool
+	}{
+		{"before a daytime range", day, "2026-06-01 08:59", false},
+		{"start of a daytime range", day, "2026-06-01 09:00", true},
+		{"in a daytime range", day, "2026-06-01 12:30", true},
+		{"end of a daytime range", day, "2026-06-01 17:00", false},
+		{"before an overnight range", night, "2026-06-01 20:59", false},
+		{"start of an overnight range", night, "2026-06-01 21:00", true},
+		{"midnight in an overnight range", night, "2026-06-02 00:00", true},
+		{"early morning in an overnight range", night, "2026-06-02 06:59", true},
+		{"end of an overnight range", night, "2026-06-02 07:00", false},
+		{"midday outside an overnight range", night, "2026-06-02 12:00", false},
+		{"no quiet hours", never, "2026-06-02 08:00", false},
+		{"no quiet hours at night", never, "2026-06-02 03:00", false},
+	}
+	for _, tt := range tests {
+		if got := tt.pr.Quiet(at(t, tt.t)); got != tt.want {
+			t.Errorf("%s: Quiet(%s) = %t, want %t", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestQuietUsesLeagueTime(t *testing.T) {
+	night := Preferences{QuietStart: 21, QuietEnd: 7}
+
+	// 4 AM in UTC is 9 PM the day before in the league in summer.
+	if !night.Quiet(time.Date(2026, 6, 2, 4, 0, 0, 0, time.UTC)) {
+		t.Error("9 PM league time wasn't quiet")
+	}
+	// 3 PM in UTC is 8 AM in the league.
+	if night.Quiet(time.Date(2026, 6, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Error("8 AM league time was quiet")
+	}
+}
+
+func TestNextSend(t *testing.T) {
+	day := Preferences{QuietStart: 9, QuietEnd: 17}
+	night := Preferences{QuietStart: 21, QuietEnd: 7}
+
+	tests := []struct {
+		name string
+		pr   Preferences
+		t    string
+		want string
+	}{
+		{"outside quiet hours", night, "2026-06-01 12:00", "2026-06-01 12:00"},
+		{"evening in an overnight range", night, "2026-06-01 22:15", "2026-06-02 07:00"},
+		{"after midnight in an overnight range", night, "2026-06-02 01:00", "2026-06-02 07:00"},
+		{"in a daytime range", day, "2026-06-01 10:00", "2026-06-01 17:00"},
+		{"the night clocks go forward", night, "2026-03-07 23:00", "2026-03-08 07:00"},
+		{"the night clocks go back", night, "2026-10-31 22:00", "2026-11-01 07:00"},
+	}
+	for _, tt := range tests {
+		got := tt.pr.NextSend(at(t, tt.t))
+		if want := at(t, tt.want); !got.Equal(want) {
+			t.Errorf("%s: NextSend(%s) = %s, want %s", tt.name, tt.t, db.Local(got), db.Local(want))
+		}
+		if tt.pr.Quiet(got) {
+			t.Errorf("%s: NextSend(%s) is in quiet hours", tt.name, tt.t)
+		}
+	}
+}
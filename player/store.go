@@ -0,0 +1,295 @@
+package player
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"mariners/role"
+)
+
+// PlayerStore loads and saves players, their roles and their notification
+// preferences.  Loaded players come back with Roles, Permissions and
+// Preferences filled in.
+type PlayerStore interface {
+	AddPlayer(ctx context.Context, p *Player) error
+	GetPlayer(ctx context.Context, id int64) (Player, error)
+	GetPlayerByPreferredName(ctx context.Context, name string) (Player, error)
+	GetPlayerByToken(ctx context.Context, token string) (Player, error)
+	GetPlayers(ctx context.Context) (Players, error)
+	UpdatePlayer(ctx context.Context, p *Player) error
+	DeletePlayer(ctx context.Context, id int64) error
+	SetSubscription(ctx context.Context, id int64, arn string) error
+	WriteToken(ctx context.Context, id int64, token string) error
+	RemoveToken(ctx context.Context, id int64) error
+	GetPreferences(ctx context.Context, id int64) (Preferences, error)
+	SetPreferences(ctx context.Context, id int64, pr Preferences) error
+}
+
+// SQLPlayerStore is a PlayerStore backed by the player, role_members and
+// player_prefs tables.
+type SQLPlayerStore struct {
+	DB    *sql.DB
+	roles role.RoleStore
+}
+
+func NewSQLPlayerStore(con *sql.DB) *SQLPlayerStore {
+	return &SQLPlayerStore{DB: con, roles: role.NewSQLRoleStore(con)}
+}
+
+var store PlayerStore
+
+// SetStore replaces the PlayerStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s PlayerStore) {
+	store = s
+}
+
+func getStore() PlayerStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLPlayerStore(db.Con)
+}
+
+const playerColumns = "idplayer, name, preferred_name, phone, email, ghin_number, main_sub_arn, text_preference"
+
+func (s *SQLPlayerStore) AddPlayer(ctx context.Context, p *Player) error {
+	query := "INSERT INTO player (idplayer, name, preferred_name, phone, email, ghin_number, text_preference) VALUES (NULL, ?, ?, ?, ?, ?, ?)"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		p.Name,
+		p.PreferredName,
+		p.Phone,
+		p.Email,
+		p.GhinNumber,
+		p.TextPreference)
+	if err != nil {
+		return err
+	}
+	p.ID, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("no player added")
+	}
+
+	return s.roles.SetPlayerRoles(ctx, p.ID, p.Roles)
+}
+
+func (s *SQLPlayerStore) GetPlayer(ctx context.Context, id int64) (Player, error) {
+	return s.getPlayer(ctx, "SELECT "+playerColumns+" FROM player WHERE idplayer=?", id)
+}
+
+func (s *SQLPlayerStore) GetPlayerByPreferredName(ctx context.Context, name string) (Player, error) {
+	return s.getPlayer(ctx, "SELECT "+playerColumns+" FROM player WHERE preferred_name=?", name)
+}
+
+func (s *SQLPlayerStore) GetPlayerByToken(ctx context.Context, token string) (Player, error) {
+	return s.getPlayer(ctx, "SELECT "+playerColumns+" FROM player WHERE token=?", token)
+}
+
+func (s *SQLPlayerStore) getPlayer(ctx context.Context, query string, arg interface{}) (Player, error) {
+	p := Player{}
+
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, arg).Scan(&p.ID, &p.Name, &p.PreferredName, &p.Phone, &p.Email, &p.GhinNumber, &p.MainSubscriptionARN, &p.TextPreference)
+	if err != nil {
+		return p, err
+	}
+
+	err = s.fill(ctx, &p)
+
+	return p, err
+}
+
+// fill loads the roles, permissions and preferences that live outside the
+// player row.
+func (s *SQLPlayerStore) fill(ctx context.Context, p *Player) error {
+	var err error
+
+	p.Roles, err = s.roles.GetPlayerRoles(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+
+	p.Permissions, err = s.roles.GetPlayerPermissions(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+
+	p.Preferences, err = s.GetPreferences(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+
+	p.setTextSize()
+
+	return nil
+}
+
+func (s *SQLPlayerStore) GetPlayers(ctx context.Context) (Players, error) {
+	p := make(Players, 0)
+
+	query := "SELECT " + playerColumns + " FROM player ORDER BY preferred_name"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query)
+	if err != nil {
+		return p, err
+	}
+
+	for rows.Next() {
+		var player Player
+		if err := rows.Scan(&player.ID, &player.Name, &player.PreferredName, &player.Phone, &player.Email, &player.GhinNumber, &player.MainSubscriptionARN, &player.TextPreference); err != nil {
+			rows.Close()
+			return p, err
+		}
+		p = append(p, player)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return p, err
+	}
+
+	// Roles and preferences are loaded once the rows are closed so a
+	// single connection database isn't asked for two result sets at once.
+	for i := range p {
+		err = s.fill(ctx, &p[i])
+		if err != nil {
+			return p, err
+		}
+	}
+
+	return p, nil
+}
+
+// UpdatePlayer saves the player row and replaces their roles.  The
+// subscription is left alone; SetSubscription changes that.
+func (s *SQLPlayerStore) UpdatePlayer(ctx context.Context, p *Player) error {
+	query := "UPDATE player set name=?, preferred_name=?, phone=?, email=?, ghin_number=?, text_preference=? WHERE idplayer=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		p.Name,
+		p.PreferredName,
+		p.Phone,
+		p.Email,
+		p.GhinNumber,
+		p.TextPreference,
+		p.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.roles.SetPlayerRoles(ctx, p.ID, p.Roles)
+}
+
+func (s *SQLPlayerStore) DeletePlayer(ctx context.Context, id int64) error {
+	query := "DELETE FROM role_members WHERE idplayer=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	query = "DELETE FROM api_token WHERE idplayer=?"
+	_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	query = "DELETE FROM login_code WHERE idplayer=?"
+	_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	query = "DELETE FROM player WHERE idplayer=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("no player deleted")
+	}
+
+	return nil
+}
+
+func (s *SQLPlayerStore) SetSubscription(ctx context.Context, id int64, arn string) error {
+	query := "UPDATE player SET main_sub_arn = ? WHERE idplayer=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, arn, id)
+
+	return err
+}
+
+func (s *SQLPlayerStore) WriteToken(ctx context.Context, id int64, token string) error {
+	query := "UPDATE player set token=? WHERE idplayer=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, token, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("no token added")
+	}
+
+	return nil
+}
+
+func (s *SQLPlayerStore) RemoveToken(ctx context.Context, id int64) error {
+	query := "UPDATE player set token=NULL WHERE idplayer=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("no token removed")
+	}
+
+	return nil
+}
+
+// GetPreferences returns DefaultPreferences for players who've never saved
+// any.
+func (s *SQLPlayerStore) GetPreferences(ctx context.Context, id int64) (Preferences, error) {
+	pr := Preferences{}
+
+	query := "SELECT channel, league, tournament, events, game, quiet_start, quiet_end FROM player_prefs WHERE idplayer=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, id).Scan(&pr.Channel, &pr.League, &pr.Tournament, &pr.Events, &pr.Game, &pr.QuietStart, &pr.QuietEnd)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultPreferences, nil
+	}
+
+	return pr, err
+}
+
+func (s *SQLPlayerStore) SetPreferences(ctx context.Context, id int64, pr Preferences) error {
+	query := "DELETE FROM player_prefs WHERE idplayer=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	query = "INSERT INTO player_prefs (idplayer, channel, league, tournament, events, game, quiet_start, quiet_end) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, id, pr.Channel, pr.League, pr.Tournament, pr.Events, pr.Game, pr.QuietStart, pr.QuietEnd)
+
+	return err
+}
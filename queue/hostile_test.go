@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"mariners/player"
+	"testing"
+)
+
+func TestQueueHostileStrings(t *testing.T) {
+	openQueue(t)
+	ctx := context.Background()
+
+	for i, h := range dbtest.Hostile {
+		emailer := player.Player{ID: 99, Email: h, Preferences: player.DefaultPreferences}
+		emailer.Preferences.Channel = player.ChannelEmail
+		b, err := Enqueue(ctx, 1, player.CategoryLeague, h, h, append(texters(t, 1), emailer))
+		if err != nil {
+			t.Fatalf("queueing %q: %s", h, err)
+		}
+
+		got := checkBlast(t, "queued", b.ID, h)
+		if len(got.Messages) != 2 || got.Messages[1].Address != h {
+			t.Fatalf("messages read back as %+v, want the second to %q", got.Messages, h)
+		}
+
+		u := dbtest.Hostile[(i+1)%len(dbtest.Hostile)]
+		m := got.Messages[1]
+		m.Status, m.LastError, m.MessageID = StatusFailed, u, u
+		err = NewSQLQueueStore(db.Con).UpdateMessage(ctx, m)
+		if err != nil {
+			t.Fatalf("updating to %q: %s", u, err)
+		}
+		got = checkBlast(t, "updated", b.ID, h)
+		if m := got.Messages[1]; m.LastError != u || m.MessageID != u || m.Address != h {
+			t.Errorf("updated message read back as %q / %q / %q, want %q / %q / %q", m.LastError, m.MessageID, m.Address, u, u, h)
+		}
+	}
+}
+
+func checkBlast(t *testing.T, how string, id int64, want string) Blast {
+	t.Helper()
+
+	got := Blast{}
+	err := got.GetBlastByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("%s %q: %s", how, want, err)
+	}
+	if got.Label != want || got.Message != want {
+		t.Errorf("%s blast read back as %q / %q, want %q", how, got.Label, got.Message, want)
+	}
+
+	return got
+}
@@ -0,0 +1,432 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"mariners/mail"
+	"mariners/player"
+	"mariners/sms"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Statuses a queued text moves through.
+const (
+	StatusQueued  = "queued"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Blast is one message sent to a group of players.
+type Blast struct {
+	ID       int64     `json:"id"`
+	SenderID int64     `json:"sender_id"`
+	Category string    `json:"category"`
+	Label    string    `json:"label"`
+	Message  string    `json:"message"`
+	Date     time.Time `json:"date"`
+	Queued   int       `json:"queued"`
+	Sent     int       `json:"sent"`
+	Failed   int       `json:"failed"`
+	Skipped  int       `json:"skipped"`
+	Messages Messages
+}
+
+type Blasts []Blast
+
+// Message is a blast's text or email to one player.
+type Message struct {
+	ID          int64 `json:"id"`
+	BlastID     int64 `json:"blast_id"`
+	Player      player.Player
+	Channel     string    `json:"channel"`
+	Address     string    `json:"address"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error"`
+	MessageID   string    `json:"message_id"`
+	SentDate    time.Time `json:"sent_date"`
+}
+
+type Messages []Message
+
+// Done reports whether every text in the blast has been sent or given up on.
+func (b *Blast) Done() bool {
+	return b.Queued == 0
+}
+
+// Enqueue queues text in category for each of ps and returns the blast.  Each
+// player gets it on their own channel once their quiet hours are over, and
+// players who don't want the category are recorded as skipped.  Players who
+// can't be reached are recorded as failed rather than dropped, so the sender
+// can see who missed out.  Called with a transaction's context, nothing is
+// sent unless it commits.
+func Enqueue(ctx context.Context, sid int64, category string, label string, text string, ps player.Players) (Blast, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	t := time.Now().UTC()
+	b := Blast{SenderID: sid, Category: category, Label: label, Message: text, Date: t}
+
+	err := db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		err := getStore().AddBlast(ctx, &b)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range ps {
+			m := address(p, category)
+			m.BlastID = b.ID
+			m.NextAttempt = p.Preferences.NextSend(t).UTC()
+
+			err = getStore().AddMessage(ctx, &m)
+			if err != nil {
+				return err
+			}
+
+			switch m.Status {
+			case StatusFailed:
+				b.Failed++
+			case StatusSkipped:
+				b.Skipped++
+			default:
+				b.Queued++
+			}
+			b.Messages = append(b.Messages, m)
+		}
+
+		return nil
+	})
+
+	return b, err
+}
+
+// address works out where p's copy of a category message goes.
+func address(p player.Player, category string) Message {
+	m := Message{Player: p, Channel: p.Preferences.Channel, Status: StatusQueued}
+
+	if !p.Preferences.Wants(category) {
+		m.Status = StatusSkipped
+		return m
+	}
+
+	switch m.Channel {
+	case player.ChannelEmail:
+		m.Address = p.Email
+		if m.Address == "" {
+			m.Status = StatusFailed
+			m.LastError = "no email address"
+		}
+	default:
+		m.Channel = player.ChannelSMS
+		phone, err := player.NormalizePhone(p.Phone)
+		if err != nil {
+			m.Status = StatusFailed
+			m.LastError = err.Error()
+		}
+		m.Address = phone
+	}
+
+	return m
+}
+
+// GetBlasts loads the most recent blasts, newest first, with their delivery
+// counts.
+func GetBlasts(ctx context.Context, limit int) (Blasts, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	bs, err := getStore().GetBlasts(ctx, limit)
+	if err != nil {
+		return bs, err
+	}
+
+	for i := range bs {
+		err = bs[i].GetMessages(ctx)
+		if err != nil {
+			return bs, err
+		}
+	}
+
+	return bs, nil
+}
+
+// GetBlastByID loads blast id with its messages.
+func (b *Blast) GetBlastByID(ctx context.Context, id int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	nb, err := getStore().GetBlast(ctx, id)
+	if err != nil {
+		return err
+	}
+	*b = nb
+
+	return b.GetMessages(ctx)
+}
+
+// GetMessages loads the blast's messages and counts them by status.
+func (b *Blast) GetMessages(ctx context.Context) error {
+	b.Queued, b.Sent, b.Failed, b.Skipped = 0, 0, 0, 0
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	ms, err := getStore().GetMessages(ctx, b.ID)
+	if err != nil {
+		return err
+	}
+	b.Messages = ms
+
+	for _, m := range b.Messages {
+		switch m.Status {
+		case StatusSent:
+			b.Sent++
+		case StatusFailed:
+			b.Failed++
+		case StatusSkipped:
+			b.Skipped++
+		default:
+			b.Queued++
+		}
+	}
+
+	return nil
+}
+
+// Worker sends queued texts in the background.
+type Worker struct {
+	// Rate is the time between sends.
+	Rate time.Duration
+	// Batch is how many due texts are claimed at a time.  The blasts they
+	// belong to are loaded once for the batch.
+	Batch int
+	// MaxAttempts is how many times a text is tried before it's marked
+	// failed.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled for each retry
+	// after that.
+	Backoff time.Duration
+}
+
+// DefaultWorker sends one text a second, claiming up to a minute's worth at a
+// time, and retries for about a quarter of an hour.
+var DefaultWorker = Worker{
+	Rate:        time.Second,
+	Batch:       60,
+	MaxAttempts: 5,
+	Backoff:     time.Minute,
+}
+
+// Run sends queued texts until ctx is done.  Texts left sending by a previous
+// run that stopped partway are queued again first.
+func (wk Worker) Run(ctx context.Context) error {
+	err := requeue()
+	if err != nil {
+		return err
+	}
+
+	tick := time.NewTicker(wk.Rate)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+			_, err := wk.SendBatch(ctx, tick.C)
+			if err != nil {
+				log.Error().Msgf("queue: %s\n", err)
+			}
+		}
+	}
+}
+
+// SendBatch claims up to wk.Batch texts that are due and sends them, the
+// first straight away and each of the rest on the next value from tick.  It
+// returns how many it claimed.  Texts it doesn't get to before ctx is done
+// go back on the queue.
+func (wk Worker) SendBatch(ctx context.Context, tick <-chan time.Time) (int, error) {
+	ms, err := claim(wk.Batch)
+	if err != nil || len(ms) == 0 {
+		return 0, err
+	}
+
+	bs, err := blasts(ms)
+	if err != nil {
+		rerr := release(ms)
+		if rerr != nil {
+			log.Error().Msgf("queue: %s\n", rerr)
+		}
+		return len(ms), err
+	}
+
+	for i, m := range ms {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return len(ms), release(ms[i:])
+			case <-tick:
+			}
+		}
+
+		err = wk.send(bs[m.BlastID], m)
+		if err != nil {
+			log.Error().Msgf("queue: %s\n", err)
+		}
+	}
+
+	return len(ms), nil
+}
+
+// send delivers one claimed text and records how it went.  The player is
+// loaded again first, since they may have texted STOP, turned the category
+// off, gone quiet or changed their number since it was queued: the text is
+// skipped, put off until their quiet hours end, or sent to where they want it
+// now.
+func (wk Worker) send(b Blast, m Message) error {
+	now := time.Now().UTC()
+
+	ctx, cancelfunc := db.Context()
+	defer cancelfunc()
+	p := player.Player{}
+	err := p.GetPlayerByID(ctx, m.Player.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		m.Status = StatusSkipped
+		m.LastError = "player deleted"
+		return update(m)
+	}
+	if err != nil {
+		rerr := release(Messages{m})
+		if rerr != nil {
+			return rerr
+		}
+		return fmt.Errorf("loading player %d: %w", m.Player.ID, err)
+	}
+
+	a := address(p, b.Category)
+	if a.Status != StatusQueued {
+		m.Status = a.Status
+		m.LastError = a.LastError
+		return update(m)
+	}
+	m.Player = p
+	m.Channel = a.Channel
+	m.Address = a.Address
+	if p.Preferences.Quiet(now) {
+		m.Status = StatusQueued
+		m.NextAttempt = p.Preferences.NextSend(now)
+		return update(m)
+	}
+
+	mid, err := deliver(b, m)
+	m.Attempts++
+	if err != nil {
+		return wk.retry(m, err)
+	}
+
+	m.Status = StatusSent
+	m.MessageID = mid
+	m.SentDate = time.Now().UTC()
+	m.LastError = ""
+
+	return update(m)
+}
+
+// retry puts m back on the queue after a failed send, or marks it failed if
+// the error is permanent or it has run out of attempts.  A retry that would
+// fall in the player's quiet hours waits until they end.
+func (wk Worker) retry(m Message, serr error) error {
+	m.Status = StatusQueued
+	if sms.Permanent(serr) || errors.Is(serr, mail.ErrNotConfigured) || m.Attempts >= wk.MaxAttempts {
+		m.Status = StatusFailed
+	}
+	wait := wk.Backoff << (m.Attempts - 1)
+	m.NextAttempt = m.Player.Preferences.NextSend(time.Now().UTC().Add(wait))
+
+	m.LastError = serr.Error()
+
+	err := update(m)
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("sending to player %d: %w", m.Player.ID, serr)
+}
+
+// update saves m's delivery state.
+func update(m Message) error {
+	ctx, cancelfunc := db.Context()
+	defer cancelfunc()
+
+	return getStore().UpdateMessage(ctx, m)
+}
+
+// deliver sends b to m on its channel and returns the provider's message ID.
+func deliver(b Blast, m Message) (string, error) {
+	if m.Channel == player.ChannelEmail {
+		return "", mail.Send(m.Address, fmt.Sprintf("Mariners: %s", b.Label), b.Message)
+	}
+
+	return sms.SendTextPhone(b.Message, m.Address)
+}
+
+// claim marks up to limit of the oldest due texts as sending and returns them.
+func claim(limit int) (Messages, error) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	ctx, cancelfunc := db.Context()
+	defer cancelfunc()
+
+	return getStore().Claim(ctx, time.Now().UTC(), limit)
+}
+
+// blasts loads each blast ms belong to once, without their messages.
+func blasts(ms Messages) (map[int64]Blast, error) {
+	ctx, cancelfunc := db.Context()
+	defer cancelfunc()
+
+	bs := make(map[int64]Blast)
+	for _, m := range ms {
+		if _, ok := bs[m.BlastID]; ok {
+			continue
+		}
+		b, err := getStore().GetBlast(ctx, m.BlastID)
+		if err != nil {
+			return bs, err
+		}
+		bs[m.BlastID] = b
+	}
+
+	return bs, nil
+}
+
+// release puts claimed texts that weren't sent back on the queue as they
+// were.
+func release(ms Messages) error {
+	ctx, cancelfunc := db.Context()
+	defer cancelfunc()
+
+	for _, m := range ms {
+		m.Status = StatusQueued
+		err := getStore().UpdateMessage(ctx, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func requeue() error {
+	ctx, cancelfunc := db.Context()
+	defer cancelfunc()
+
+	return getStore().Requeue(ctx)
+}
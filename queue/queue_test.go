@@ -0,0 +1,289 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"mariners/player"
+	"mariners/sms"
+	"testing"
+	"time"
+)
+
+// openQueue points the package at a fresh in-memory database and a fake
+// messenger.
+func openQueue(t *testing.T) *sms.FakeMessenger {
+	t.Helper()
+
+	dbtest.Open(t)
+	SetStore(nil)
+	player.SetStore(nil)
+	t.Cleanup(func() { SetStore(nil) })
+
+	f := sms.NewFakeMessenger("")
+	sms.SetMessenger(f)
+
+	return f
+}
+
+// texters saves n players who want texts at any hour.
+func texters(t *testing.T, n int) player.Players {
+	t.Helper()
+
+	ctx := context.Background()
+	ps := make(player.Players, 0, n)
+	for i := 1; i <= n; i++ {
+		p := player.Player{Name: fmt.Sprintf("Texter %d", i), Phone: fmt.Sprintf("41555501%02d", i), Preferences: player.DefaultPreferences}
+		p.Preferences.QuietStart, p.Preferences.QuietEnd = 0, 0
+		err := player.AddPlayer(ctx, &p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.UpdatePreferences(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps = append(ps, p)
+	}
+
+	return ps
+}
+
+// countingStore counts the blasts a worker loads.
+type countingStore struct {
+	QueueStore
+	blasts int
+}
+
+func (s *countingStore) GetBlast(ctx context.Context, id int64) (Blast, error) {
+	s.blasts++
+	return s.QueueStore.GetBlast(ctx, id)
+}
+
+func TestSendBatch(t *testing.T) {
+	ctx := context.Background()
+	f := openQueue(t)
+
+	before := time.Now().UTC().Add(-time.Second)
+	b, err := Enqueue(ctx, 1, player.CategoryLeague, "League", "Range opens at 8", texters(t, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Queued != 3 {
+		t.Fatalf("queued %d, want 3", b.Queued)
+	}
+
+	cs := &countingStore{QueueStore: NewSQLQueueStore(db.Con)}
+	SetStore(cs)
+
+	tick := make(chan time.Time, 3)
+	for i := 0; i < 3; i++ {
+		tick <- time.Now()
+	}
+	wk := DefaultWorker
+	n, err := wk.SendBatch(ctx, tick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("claimed %d, want 3", n)
+	}
+	if cs.blasts != 1 {
+		t.Errorf("loaded the blast %d times for one batch, want 1", cs.blasts)
+	}
+	if got := len(f.Texts()); got != 3 {
+		t.Errorf("sent %d texts, want 3", got)
+	}
+
+	err = b.GetBlastByID(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Sent != 3 {
+		t.Errorf("%d sent, want 3", b.Sent)
+	}
+	if b.Date.Location() != time.UTC || b.Date.Before(before) {
+		t.Errorf("blast date %s, want UTC after %s", b.Date, before)
+	}
+	for _, m := range b.Messages {
+		if m.SentDate.Location() != time.UTC || m.SentDate.Before(before) {
+			t.Errorf("message %d sent %s, want UTC after %s", m.ID, m.SentDate, before)
+		}
+	}
+
+	n, err = wk.SendBatch(ctx, tick)
+	if err != nil || n != 0 {
+		t.Errorf("second batch claimed %d, %v, want nothing", n, err)
+	}
+}
+
+func TestSendBatchReleasesUnsent(t *testing.T) {
+	openQueue(t)
+
+	b, err := Enqueue(context.Background(), 1, player.CategoryLeague, "League", "Shotgun start", texters(t, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err := DefaultWorker.SendBatch(ctx, make(chan time.Time))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("claimed %d, want 3", n)
+	}
+
+	err = b.GetBlastByID(context.Background(), b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Sent != 1 || b.Queued != 2 {
+		t.Errorf("%d sent and %d queued, want 1 and 2", b.Sent, b.Queued)
+	}
+	for _, m := range b.Messages {
+		if m.Status == StatusSending {
+			t.Errorf("message %d left sending", m.ID)
+		}
+	}
+}
+
+func TestQuietHoursStoredInUTC(t *testing.T) {
+	ctx := context.Background()
+	openQueue(t)
+
+	p := texters(t, 1)[0]
+	h := db.Local(time.Now()).Hour()
+	p.Preferences.QuietStart, p.Preferences.QuietEnd = h, (h+2)%24
+
+	b, err := Enqueue(ctx, 1, player.CategoryLeague, "League", "Tee times posted", player.Players{p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.GetBlastByID(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := b.Messages[0].NextAttempt
+	if next.Location() != time.UTC {
+		t.Errorf("next attempt %s isn't UTC", next)
+	}
+	if db.Local(next).Hour() != p.Preferences.QuietEnd {
+		t.Errorf("next attempt %s is %d:00 league time, want %d:00", next, db.Local(next).Hour(), p.Preferences.QuietEnd)
+	}
+
+	ms, err := claim(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 0 {
+		t.Errorf("claimed %d texts during quiet hours", len(ms))
+	}
+}
+
+func TestSendRechecksPreferences(t *testing.T) {
+	ctx := context.Background()
+	f := openQueue(t)
+
+	ps := texters(t, 5)
+	b, err := Enqueue(ctx, 1, player.CategoryLeague, "League", "Greens are aerated", ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped, off, quiet, moved, gone := ps[0], ps[1], ps[2], ps[3], ps[4]
+	err = stopped.OptOut(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	off.Preferences.League = false
+	err = off.UpdatePreferences(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := db.Local(time.Now()).Hour()
+	quiet.Preferences.QuietStart, quiet.Preferences.QuietEnd = h, (h+2)%24
+	err = quiet.UpdatePreferences(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved.Phone = "4155550199"
+	err = moved.UpdatePlayer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gone.DeletePlayer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tick := make(chan time.Time, 5)
+	for i := 0; i < 5; i++ {
+		tick <- time.Now()
+	}
+	_, err = DefaultWorker.SendBatch(ctx, tick)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	texts := f.Texts()
+	if len(texts) != 1 || texts[0].Phone != "+14155550199" {
+		t.Errorf("sent %+v, want one text to the new number", texts)
+	}
+
+	err = b.GetBlastByID(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]string{
+		stopped.ID: StatusSkipped,
+		off.ID:     StatusSkipped,
+		quiet.ID:   StatusQueued,
+		moved.ID:   StatusSent,
+		gone.ID:    StatusSkipped,
+	}
+	for _, m := range b.Messages {
+		if m.Status != want[m.Player.ID] {
+			t.Errorf("player %d's text is %s, want %s", m.Player.ID, m.Status, want[m.Player.ID])
+		}
+		if m.Player.ID == quiet.ID && db.Local(m.NextAttempt).Hour() != quiet.Preferences.QuietEnd {
+			t.Errorf("quiet player's text put off until %s, want %d:00", db.Local(m.NextAttempt), quiet.Preferences.QuietEnd)
+		}
+	}
+}
+
+func TestRetryWaitsOutQuietHours(t *testing.T) {
+	ctx := context.Background()
+	openQueue(t)
+
+	p := texters(t, 1)[0]
+	b, err := Enqueue(ctx, 1, player.CategoryLeague, "League", "Carts are path only", player.Players{p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := db.Local(time.Now()).Hour()
+	m := b.Messages[0]
+	m.Player.Preferences.QuietStart, m.Player.Preferences.QuietEnd = h, (h+2)%24
+	m.Attempts = 1
+	err = DefaultWorker.retry(m, errors.New("provider busy"))
+	if err == nil {
+		t.Fatal("retry returned no error")
+	}
+
+	err = b.GetBlastByID(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := b.Messages[0]
+	if got.Status != StatusQueued {
+		t.Errorf("retried text is %s, want %s", got.Status, StatusQueued)
+	}
+	if db.Local(got.NextAttempt).Hour() != m.Player.Preferences.QuietEnd {
+		t.Errorf("retry at %s, want %d:00 when quiet hours end", db.Local(got.NextAttempt), m.Player.Preferences.QuietEnd)
+	}
+}
@@ -0,0 +1,186 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"mariners/db"
+	"time"
+)
+
+// QueueStore loads and saves blasts and the messages queued for them.
+type QueueStore interface {
+	AddBlast(ctx context.Context, b *Blast) error
+	AddMessage(ctx context.Context, m *Message) error
+	GetBlast(ctx context.Context, id int64) (Blast, error)
+	// GetBlasts returns the most recent blasts, newest first, without
+	// their messages.
+	GetBlasts(ctx context.Context, limit int) (Blasts, error)
+	GetMessages(ctx context.Context, bid int64) (Messages, error)
+	// Claim marks up to limit of the oldest queued messages due by due as
+	// sending and returns them.
+	Claim(ctx context.Context, due time.Time, limit int) (Messages, error)
+	// UpdateMessage saves the message's delivery state.
+	UpdateMessage(ctx context.Context, m Message) error
+	// Requeue puts messages left sending back on the queue.
+	Requeue(ctx context.Context) error
+}
+
+// SQLQueueStore is a QueueStore backed by the blast and blast_messages tables.
+type SQLQueueStore struct {
+	DB *sql.DB
+}
+
+func NewSQLQueueStore(con *sql.DB) *SQLQueueStore {
+	return &SQLQueueStore{DB: con}
+}
+
+var store QueueStore
+
+// SetStore replaces the QueueStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s QueueStore) {
+	store = s
+}
+
+func getStore() QueueStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLQueueStore(db.Con)
+}
+
+func (s *SQLQueueStore) AddBlast(ctx context.Context, b *Blast) error {
+	query := "INSERT INTO blast (idblast, idsender, category, label, message, blast_date) VALUES (NULL, ?, ?, ?, ?, ?)"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, b.SenderID, b.Category, b.Label, b.Message, db.FormatTime(b.Date))
+	if err != nil {
+		return err
+	}
+	b.ID, err = res.LastInsertId()
+
+	return err
+}
+
+func (s *SQLQueueStore) AddMessage(ctx context.Context, m *Message) error {
+	query := "INSERT INTO blast_messages (idblastmessage, idblast, idplayer, channel, address, status, attempts, next_attempt, last_error, idmessage, sent_date) VALUES (NULL, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, m.BlastID, m.Player.ID, m.Channel, m.Address, m.Status, m.Attempts, db.FormatTime(m.NextAttempt), m.LastError, m.MessageID, db.FormatTime(m.SentDate))
+	if err != nil {
+		return err
+	}
+	m.ID, err = res.LastInsertId()
+
+	return err
+}
+
+func (s *SQLQueueStore) GetBlast(ctx context.Context, id int64) (Blast, error) {
+	b := Blast{}
+
+	query := "SELECT idblast, idsender, category, label, message, blast_date FROM blast WHERE idblast=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, id).Scan(&b.ID, &b.SenderID, &b.Category, &b.Label, &b.Message, db.ScanTime(&b.Date))
+
+	return b, err
+}
+
+func (s *SQLQueueStore) GetBlasts(ctx context.Context, limit int) (Blasts, error) {
+	bs := make(Blasts, 0)
+
+	query := "SELECT idblast, idsender, category, label, message, blast_date FROM blast ORDER BY idblast DESC LIMIT ?"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, limit)
+	if err != nil {
+		return bs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b Blast
+		err = rows.Scan(&b.ID, &b.SenderID, &b.Category, &b.Label, &b.Message, db.ScanTime(&b.Date))
+		if err != nil {
+			return bs, err
+		}
+		bs = append(bs, b)
+	}
+
+	return bs, rows.Err()
+}
+
+func (s *SQLQueueStore) GetMessages(ctx context.Context, bid int64) (Messages, error) {
+	var ms Messages
+
+	query := "SELECT idblastmessage, idplayer, channel, address, status, attempts, next_attempt, last_error, idmessage, sent_date FROM blast_messages WHERE idblast=? ORDER BY idblastmessage"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, bid)
+	if err != nil {
+		return ms, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Message
+		err = rows.Scan(&m.ID, &m.Player.ID, &m.Channel, &m.Address, &m.Status, &m.Attempts, db.ScanTime(&m.NextAttempt), &m.LastError, &m.MessageID, db.ScanTime(&m.SentDate))
+		if err != nil {
+			return ms, err
+		}
+		m.BlastID = bid
+		ms = append(ms, m)
+	}
+
+	return ms, rows.Err()
+}
+
+// Claim relies on the status check in the update to keep two workers from
+// sending the same message.  Messages another worker claims first are left
+// out, so fewer than limit can come back even when more are due.
+func (s *SQLQueueStore) Claim(ctx context.Context, due time.Time, limit int) (Messages, error) {
+	ms := make(Messages, 0)
+
+	query := "SELECT idblastmessage, idblast, idplayer, channel, address, attempts, next_attempt, last_error, idmessage, sent_date FROM blast_messages WHERE status=? AND next_attempt <= ? ORDER BY next_attempt, idblastmessage LIMIT ?"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, StatusQueued, db.FormatTime(due), limit)
+	if err != nil {
+		return ms, err
+	}
+	var found Messages
+	for rows.Next() {
+		var m Message
+		err = rows.Scan(&m.ID, &m.BlastID, &m.Player.ID, &m.Channel, &m.Address, &m.Attempts, db.ScanTime(&m.NextAttempt), &m.LastError, &m.MessageID, db.ScanTime(&m.SentDate))
+		if err != nil {
+			rows.Close()
+			return ms, err
+		}
+		found = append(found, m)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return ms, err
+	}
+
+	query = "UPDATE blast_messages SET status=? WHERE idblastmessage=? AND status=?"
+	for _, m := range found {
+		res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, StatusSending, m.ID, StatusQueued)
+		if err != nil {
+			return ms, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return ms, err
+		}
+		if n == 1 {
+			m.Status = StatusSending
+			ms = append(ms, m)
+		}
+	}
+
+	return ms, nil
+}
+
+func (s *SQLQueueStore) UpdateMessage(ctx context.Context, m Message) error {
+	query := "UPDATE blast_messages SET status=?, attempts=?, next_attempt=?, last_error=?, idmessage=?, sent_date=? WHERE idblastmessage=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, m.Status, m.Attempts, db.FormatTime(m.NextAttempt), m.LastError, m.MessageID, db.FormatTime(m.SentDate), m.ID)
+
+	return err
+}
+
+func (s *SQLQueueStore) Requeue(ctx context.Context) error {
+	query := "UPDATE blast_messages SET status=? WHERE status=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, StatusQueued, StatusSending)
+
+	return err
+}
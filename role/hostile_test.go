@@ -0,0 +1,66 @@
+package role
+
+import (
+	"context"
+	"mariners/db/dbtest"
+	"testing"
+)
+
+func TestRoleHostileStrings(t *testing.T) {
+	ctx := context.Background()
+	dbtest.Open(t)
+	SetStore(nil)
+
+	for i, h := range dbtest.Hostile {
+		r, err := AddRole(ctx, h)
+		if err != nil {
+			t.Fatalf("adding %q: %s", h, err)
+		}
+		var id int64
+		for k := range r {
+			id = k
+		}
+		checkRole(t, "added", id, h)
+
+		err = SetRolesByPlayerID(ctx, 1000, Roles{id: h})
+		if err != nil {
+			t.Fatal(err)
+		}
+		held, err := GetRolesByPlayerID(ctx, 1000)
+		if err != nil || held[id] != h {
+			t.Errorf("player's role read back as %q, %v, want %q", held[id], err, h)
+		}
+
+		u := dbtest.Hostile[(i+1)%len(dbtest.Hostile)]
+		err = RenameRole(ctx, id, u)
+		if err != nil {
+			t.Fatalf("renaming to %q: %s", u, err)
+		}
+		checkRole(t, "renamed", id, u)
+
+		err = DeleteRole(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkRole(t *testing.T, how string, id int64, want string) {
+	t.Helper()
+
+	ctx := context.Background()
+	r, err := GetRoleByID(ctx, id)
+	if err != nil || r[id] != want {
+		t.Errorf("%s role read back as %q, %v, want %q", how, r[id], err, want)
+	}
+
+	got, err := GetRoleIDByName(ctx, want)
+	if err != nil || got != id {
+		t.Errorf("%s: looking up %q found %d, %v, want %d", how, want, got, err, id)
+	}
+
+	rs, err := GetRoles(ctx)
+	if err != nil || rs[id] != want {
+		t.Errorf("%s role listed as %q, %v, want %q", how, rs[id], err, want)
+	}
+}
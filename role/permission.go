@@ -0,0 +1,167 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"sort"
+)
+
+// Permissions a role can grant.  They're also the scopes of API tokens.
+const (
+	PlayersWrite = "players:write"
+	RolesManage  = "roles:manage"
+	EventsManage = "events:manage"
+	MessagesSend = "messages:send"
+	GamesManage  = "games:manage"
+	SiteAdmin    = "site:admin"
+)
+
+// Permission is a permission and what it lets a player do.  Owned
+// permissions also cover players' own things without the role, like their
+// own profile, the events they own or their own card.
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Owned       bool   `json:"owned"`
+}
+
+// Permissions are all the permissions, in the order the permissions page
+// lists them.
+var Permissions = []Permission{
+	{PlayersWrite, "Add, change and delete players", true},
+	{RolesManage, "Add, rename and delete roles, and change what they allow", false},
+	{EventsManage, "Change any event, its members and who has paid", true},
+	{MessagesSend, "Text the league and the tournament players", false},
+	{GamesManage, "Run the day's game: check-ins, teams, the mystery hole and scores", true},
+	{SiteAdmin, "Site upkeep, like giving everyone a role", false},
+}
+
+// ErrUnknownPermission is returned for a permission that isn't one of
+// Permissions.
+var ErrUnknownPermission = errors.New("unknown permission")
+
+// GetPermission returns the permission called name.
+func GetPermission(name string) (Permission, error) {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	return Permission{}, fmt.Errorf("%w: %s", ErrUnknownPermission, name)
+}
+
+// Matrix is the permissions each role grants, by role ID.
+type Matrix map[int64][]string
+
+// Grants reports whether role id grants perm.
+func (m Matrix) Grants(id int64, perm string) bool {
+	for _, p := range m[id] {
+		if p == perm {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *SQLRoleStore) GetMatrix(ctx context.Context) (Matrix, error) {
+	m := make(Matrix)
+
+	query := "SELECT idrole, permission FROM role_permissions ORDER BY idrole, permission"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query)
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var perm string
+		if err := rows.Scan(&id, &perm); err != nil {
+			return m, err
+		}
+		m[id] = append(m[id], perm)
+	}
+
+	return m, rows.Err()
+}
+
+// SetPermissions replaces the permissions role id grants with perms.
+func (s *SQLRoleStore) SetPermissions(ctx context.Context, id int64, perms []string) error {
+	return db.InTx(ctx, s.DB, func(ctx context.Context) error {
+		query := "DELETE FROM role_permissions WHERE idrole=?"
+		_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		query = "INSERT INTO role_permissions (idrole, permission) VALUES (?, ?)"
+		for _, p := range perms {
+			_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id, p)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *SQLRoleStore) GetPlayerPermissions(ctx context.Context, pid int64) ([]string, error) {
+	perms := make([]string, 0)
+
+	query := "SELECT DISTINCT role_permissions.permission FROM role_permissions INNER JOIN role_members ON role_permissions.idrole=role_members.idrole WHERE role_members.idplayer=? ORDER BY role_permissions.permission"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, pid)
+	if err != nil {
+		return perms, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var perm string
+		if err := rows.Scan(&perm); err != nil {
+			return perms, err
+		}
+		perms = append(perms, perm)
+	}
+
+	return perms, rows.Err()
+}
+
+// GetMatrix returns the permissions every role grants.
+func GetMatrix(ctx context.Context) (Matrix, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetMatrix(ctx)
+}
+
+// SetPermissions replaces the permissions role id grants with perms.
+func SetPermissions(ctx context.Context, id int64, perms []string) error {
+	seen := make(map[string]bool)
+	ps := make([]string, 0, len(perms))
+	for _, p := range perms {
+		_, err := GetPermission(p)
+		if err != nil {
+			return err
+		}
+		if !seen[p] {
+			seen[p] = true
+			ps = append(ps, p)
+		}
+	}
+	sort.Strings(ps)
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	_, err := getStore().GetRoleName(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return getStore().SetPermissions(ctx, id, ps)
+}
@@ -0,0 +1,118 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"testing"
+)
+
+// seeded are the permissions the migrations give each of the roles they
+// add.
+var seeded = map[string][]string{
+	"Administrator":  {PlayersWrite, RolesManage, EventsManage, MessagesSend, GamesManage, SiteAdmin},
+	"User":           nil,
+	"Game Manager":   {GamesManage},
+	"Communications": {MessagesSend},
+	"Tournament":     nil,
+}
+
+func TestSeededMatrix(t *testing.T) {
+	ctx := context.Background()
+	dbtest.Open(t)
+	SetStore(nil)
+
+	m, err := GetMatrix(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, perms := range seeded {
+		id, err := GetRoleIDByName(ctx, name)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		for _, p := range Permissions {
+			want := false
+			for _, sp := range perms {
+				want = want || sp == p.Name
+			}
+			if got := m.Grants(id, p.Name); got != want {
+				t.Errorf("%s grants %s: %t, want %t", name, p.Name, got, want)
+			}
+		}
+	}
+}
+
+func TestPlayerPermissions(t *testing.T) {
+	ctx := context.Background()
+	dbtest.Open(t)
+	SetStore(nil)
+
+	gm, err := GetRoleIDByName(ctx, "Game Manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	comms, err := GetRoleIDByName(ctx, "Communications")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SetRolesByPlayerID(ctx, 1000, Roles{gm: "Game Manager", comms: "Communications"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	perms, err := NewSQLRoleStore(db.Con).GetPlayerPermissions(ctx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perms) != 2 || perms[0] != GamesManage || perms[1] != MessagesSend {
+		t.Errorf("a Game Manager in Communications can %v, want %s and %s", perms, GamesManage, MessagesSend)
+	}
+
+	perms, err = NewSQLRoleStore(db.Con).GetPlayerPermissions(ctx, 1001)
+	if err != nil || len(perms) != 0 {
+		t.Errorf("a player without roles can %v, %v", perms, err)
+	}
+}
+
+func TestSetPermissions(t *testing.T) {
+	ctx := context.Background()
+	dbtest.Open(t)
+	SetStore(nil)
+
+	id, err := GetRoleIDByName(ctx, "Tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SetPermissions(ctx, id, []string{MessagesSend, EventsManage, MessagesSend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := GetMatrix(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m[id]; len(got) != 2 || got[0] != EventsManage || got[1] != MessagesSend {
+		t.Errorf("Tournament grants %v, want %s and %s once each", got, EventsManage, MessagesSend)
+	}
+
+	err = SetPermissions(ctx, id, []string{"golf:cheat"})
+	if !errors.Is(err, ErrUnknownPermission) {
+		t.Errorf("granting golf:cheat = %v, want ErrUnknownPermission", err)
+	}
+	err = SetPermissions(ctx, 9999, []string{GamesManage})
+	if err == nil {
+		t.Error("granted a permission to a role that doesn't exist")
+	}
+
+	err = SetPermissions(ctx, id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = GetMatrix(ctx)
+	if err != nil || len(m[id]) != 0 {
+		t.Errorf("after clearing, Tournament grants %v, %v", m[id], err)
+	}
+}
@@ -2,81 +2,109 @@ package role
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"mariners/db"
-	"time"
 )
 
 type Roles map[int64]string
 
-func AddRole(n string) (Roles, error) {
-	r := make(Roles)
+// RoleStore loads and saves roles and who holds them.
+type RoleStore interface {
+	AddRole(ctx context.Context, name string) (int64, error)
+	GetRoleName(ctx context.Context, id int64) (string, error)
+	GetRoleID(ctx context.Context, name string) (int64, error)
+	GetRoles(ctx context.Context) (Roles, error)
+	GetPlayerRoles(ctx context.Context, pid int64) (Roles, error)
+	SetPlayerRoles(ctx context.Context, pid int64, r Roles) error
+	RenameRole(ctx context.Context, id int64, name string) error
+	// DeleteRole removes the role from everyone who holds it, and its
+	// permissions, as well.
+	DeleteRole(ctx context.Context, id int64) error
+	GetMatrix(ctx context.Context) (Matrix, error)
+	SetPermissions(ctx context.Context, id int64, perms []string) error
+	// GetPlayerPermissions returns the permissions all player pid's roles
+	// grant between them.
+	GetPlayerPermissions(ctx context.Context, pid int64) ([]string, error)
+}
 
-	query := fmt.Sprintf("INSERT INTO roles VALUES (null, \"%s\");", n)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
+// SQLRoleStore is a RoleStore backed by the role, role_members and
+// role_permissions tables.
+type SQLRoleStore struct {
+	DB *sql.DB
+}
+
+func NewSQLRoleStore(con *sql.DB) *SQLRoleStore {
+	return &SQLRoleStore{DB: con}
+}
+
+var store RoleStore
+
+// SetStore replaces the RoleStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s RoleStore) {
+	store = s
+}
+
+func getStore() RoleStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLRoleStore(db.Con)
+}
+
+func (s *SQLRoleStore) AddRole(ctx context.Context, name string) (int64, error) {
+	query := "INSERT INTO role (idrole, name) VALUES (NULL, ?)"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, name)
 	if err != nil {
-		return r, err
+		return 0, err
 	}
 	rows, err := res.RowsAffected()
 	if err != nil {
-		return r, err
+		return 0, err
 	}
 	if rows == 0 {
-		return r, fmt.Errorf("no role added")
-	}
-
-	id, err := res.LastInsertId()
-	if err != nil {
-		return r, err
+		return 0, fmt.Errorf("no role added")
 	}
 
-	r[id] = n
-
-	return r, nil
+	return res.LastInsertId()
 }
 
-func GetRoleByID(id int64) (Roles, error) {
-	r := make(Roles)
+func (s *SQLRoleStore) GetRoleName(ctx context.Context, id int64) (string, error) {
 	var name string
 
-	query := fmt.Sprintf("SELECT name FROM role WHERE idrole=%d", id)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(&name)
-	if err != nil {
-		return r, err
-	}
-	r[id] = name
+	query := "SELECT name FROM role WHERE idrole=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, id).Scan(&name)
 
-	return r, nil
+	return name, err
 }
 
-func GetRoleIDByName(name string) (int64, error) {
+func (s *SQLRoleStore) GetRoleID(ctx context.Context, name string) (int64, error) {
 	var id int64
 
-	query := fmt.Sprintf("SELECT idrole FROM role WHERE name=\"%s\"", name)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(&id)
-	if err != nil {
-		return id, err
-	}
+	query := "SELECT idrole FROM role WHERE name=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, name).Scan(&id)
 
-	return id, nil
+	return id, err
 }
 
-func GetRoles() (Roles, error) {
+func (s *SQLRoleStore) GetRoles(ctx context.Context) (Roles, error) {
+	return s.roles(ctx, "SELECT idrole, name FROM role")
+}
+
+func (s *SQLRoleStore) GetPlayerRoles(ctx context.Context, pid int64) (Roles, error) {
+	return s.roles(ctx, "SELECT role.idrole, role.name FROM role INNER JOIN role_members ON role.idrole=role_members.idrole WHERE role_members.idplayer=?", pid)
+}
+
+func (s *SQLRoleStore) roles(ctx context.Context, query string, args ...interface{}) (Roles, error) {
 	r := make(Roles)
 
-	query := "SELECT idrole, name FROM role"
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, args...)
 	if err != nil {
 		return r, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var id int64
@@ -87,27 +115,129 @@ func GetRoles() (Roles, error) {
 		r[id] = name
 	}
 
-	return r, nil
+	return r, rows.Err()
 }
 
-func GetRolesByPlayerID(id int64) (Roles, error) {
+// SetPlayerRoles replaces the roles player pid holds with r.
+func (s *SQLRoleStore) SetPlayerRoles(ctx context.Context, pid int64, r Roles) error {
+	query := "DELETE FROM role_members WHERE idplayer=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, pid)
+	if err != nil {
+		return err
+	}
+
+	query = "INSERT INTO role_members (idrole, idplayer) VALUES (?, ?)"
+	for rk := range r {
+		_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, rk, pid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLRoleStore) RenameRole(ctx context.Context, id int64, name string) error {
+	query := "UPDATE role SET name=? WHERE idrole=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, name, id)
+
+	return err
+}
+
+func (s *SQLRoleStore) DeleteRole(ctx context.Context, id int64) error {
+	return db.InTx(ctx, s.DB, func(ctx context.Context) error {
+		query := "DELETE FROM role_members WHERE idrole=?"
+		_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		query = "DELETE FROM role_permissions WHERE idrole=?"
+		_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		query = "DELETE FROM role WHERE idrole=?"
+		res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		return db.OneRow(res)
+	})
+}
+
+func AddRole(ctx context.Context, n string) (Roles, error) {
 	r := make(Roles)
 
-	query := fmt.Sprintf("SELECT role.idrole, role.name FROM role INNER JOIN role_members ON role.idrole=role_members.idrole WHERE role_members.idplayer=%d", id)
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	id, err := getStore().AddRole(ctx, n)
 	if err != nil {
 		return r, err
 	}
-	for rows.Next() {
-		var id int64
-		var name string
-		if err := rows.Scan(&id, &name); err != nil {
-			return r, err
-		}
-		r[id] = name
+
+	r[id] = n
+
+	return r, nil
+}
+
+func GetRoleByID(ctx context.Context, id int64) (Roles, error) {
+	r := make(Roles)
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	name, err := getStore().GetRoleName(ctx, id)
+	if err != nil {
+		return r, err
 	}
+	r[id] = name
 
 	return r, nil
 }
+
+func GetRoleIDByName(ctx context.Context, name string) (int64, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetRoleID(ctx, name)
+}
+
+func GetRoles(ctx context.Context) (Roles, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetRoles(ctx)
+}
+
+func GetRolesByPlayerID(ctx context.Context, id int64) (Roles, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetPlayerRoles(ctx, id)
+}
+
+// SetRolesByPlayerID replaces the roles player id holds with r.
+func SetRolesByPlayerID(ctx context.Context, id int64, r Roles) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().SetPlayerRoles(ctx, id, r)
+}
+
+// RenameRole changes the name of role id.
+func RenameRole(ctx context.Context, id int64, name string) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().RenameRole(ctx, id, name)
+}
+
+// DeleteRole removes role id and takes it away from everyone who holds it.
+func DeleteRole(ctx context.Context, id int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().DeleteRole(ctx, id)
+}
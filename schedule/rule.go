@@ -0,0 +1,184 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule is a cron-style rule: minute, hour, day of the month, month and day of
+// the week, in a timezone.  Each field is *, a number, a range like 1-5, a
+// step like */15 or 1-5/2, or a list of those separated by commas.  Days of
+// the week run from 0 for Sunday to 6, and 7 is Sunday too.  As with cron, a
+// time matches either day field when both are restricted.
+type Rule struct {
+	Spec string
+	Loc  *time.Location
+
+	minute, hour, dom, month, dow field
+}
+
+// field is the values a field of a rule allows, in order as well as by value,
+// and whether it was *.
+type field struct {
+	allow  map[int]bool
+	values []int
+	any    bool
+}
+
+// bounds are the values each field can take, in order.
+var bounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// Parse reads spec as a Rule in loc.
+func Parse(spec string, loc *time.Location) (Rule, error) {
+	r := Rule{Spec: spec, Loc: loc}
+
+	fs := strings.Fields(spec)
+	if len(fs) != 5 {
+		return r, fmt.Errorf("rule %q: want 5 fields, got %d", spec, len(fs))
+	}
+
+	var parsed [5]field
+	for i, f := range fs {
+		var err error
+		parsed[i], err = parseField(f, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return r, fmt.Errorf("rule %q: %s", spec, err)
+		}
+	}
+	if parsed[4].allow[7] {
+		parsed[4].allow[0] = true
+	}
+	r.minute, r.hour, r.dom, r.month, r.dow = parsed[0], parsed[1], parsed[2], parsed[3], parsed[4]
+
+	return r, nil
+}
+
+func parseField(s string, min, max int) (field, error) {
+	f := field{allow: make(map[int]bool), any: s == "*"}
+
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return f, fmt.Errorf("bad step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			i := strings.Index(rng, "-")
+			var err error
+			if i < 0 {
+				lo, err = strconv.Atoi(rng)
+				hi = lo
+			} else {
+				lo, err = strconv.Atoi(rng[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(rng[i+1:])
+				}
+			}
+			if err != nil {
+				return f, fmt.Errorf("bad value in %q", part)
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return f, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			f.allow[v] = true
+		}
+	}
+	for v := min; v <= max; v++ {
+		if f.allow[v] {
+			f.values = append(f.values, v)
+		}
+	}
+
+	return f, nil
+}
+
+// Matches reports whether the rule fires in the minute t falls in.
+func (r Rule) Matches(t time.Time) bool {
+	lt := t.In(r.Loc)
+
+	return r.minute.allow[lt.Minute()] && r.hour.allow[lt.Hour()] && r.day(lt)
+}
+
+// day reports whether the rule fires on the day lt falls on.
+func (r Rule) day(lt time.Time) bool {
+	if !r.month.allow[int(lt.Month())] {
+		return false
+	}
+
+	dom, dow := r.dom.allow[lt.Day()], r.dow.allow[int(lt.Weekday())]
+	if r.dom.any || r.dow.any {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// Next returns the first minute after t that the rule fires in, looking up to
+// a year ahead, or the zero time if it never does.  It works a day at a time
+// rather than a minute at a time: the first day the rule fires on, and the
+// first of its hours and minutes that day that's after t.  As with Matches,
+// times the clocks skip never fire, and times they repeat fire both times.
+func (r Rule) Next(t time.Time) time.Time {
+	after := t.Truncate(time.Minute)
+	end := after.Add(time.Minute).AddDate(1, 0, 0)
+
+	lt := after.In(r.Loc)
+	// Noon is never skipped or repeated, so stepping a day from it stays on
+	// the calendar.
+	d := time.Date(lt.Year(), lt.Month(), lt.Day(), 12, 0, 0, 0, r.Loc)
+	for ; d.Before(end.Add(24 * time.Hour)); d = d.AddDate(0, 0, 1) {
+		if !r.day(d) {
+			continue
+		}
+
+		var next time.Time
+		for _, h := range r.hour.values {
+			for _, m := range r.minute.values {
+				for _, c := range r.instants(d, h, m) {
+					if c.After(after) && (next.IsZero() || c.Before(next)) {
+						next = c
+					}
+				}
+			}
+		}
+		if !next.IsZero() {
+			if !next.Before(end) {
+				return time.Time{}
+			}
+			return next
+		}
+	}
+
+	return time.Time{}
+}
+
+// instants returns the times the clock reads h:m on day d: none if the clocks
+// skip it, two if they go back over it, and one otherwise.
+func (r Rule) instants(d time.Time, h, m int) []time.Time {
+	c := time.Date(d.Year(), d.Month(), d.Day(), h, m, 0, 0, r.Loc)
+
+	var is []time.Time
+	for _, o := range []time.Duration{-time.Hour, 0, time.Hour} {
+		i := c.Add(o)
+		li := i.In(r.Loc)
+		if li.Day() == d.Day() && li.Hour() == h && li.Minute() == m {
+			is = append(is, i)
+		}
+	}
+
+	return is
+}
+
+func (r Rule) String() string {
+	return r.Spec
+}
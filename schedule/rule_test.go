@@ -0,0 +1,206 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func la(t *testing.T) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc
+}
+
+// at is a time in loc.
+func at(t *testing.T, loc *time.Location, s string) time.Time {
+	t.Helper()
+
+	v, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func parse(t *testing.T, spec string, loc *time.Location) Rule {
+	t.Helper()
+
+	r, err := Parse(spec, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{
+		"* * * * *",
+		"0 12 * * 0,6",
+		"*/15 9-17 * * 1-5",
+		"0 0 1-31/2 1,6,12 7",
+	} {
+		if _, err := Parse(spec, time.UTC); err != nil {
+			t.Errorf("Parse(%q): %s", spec, err)
+		}
+	}
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"noon * * * *",
+		"1, * * * *",
+	} {
+		if _, err := Parse(spec, time.UTC); err == nil {
+			t.Errorf("Parse(%q) didn't fail", spec)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	loc := la(t)
+
+	tests := []struct {
+		spec string
+		t    string
+		want bool
+	}{
+		{"* * * * *", "2026-10-17 03:41", true},
+		{"0 12 * * *", "2026-10-17 12:00", true},
+		{"0 12 * * *", "2026-10-17 12:01", false},
+		{"*/15 * * * *", "2026-10-17 12:45", true},
+		{"*/15 * * * *", "2026-10-17 12:50", false},
+		{"10-20/5 * * * *", "2026-10-17 12:15", true},
+		{"10-20/5 * * * *", "2026-10-17 12:25", false},
+		{"0 9-17 * * *", "2026-10-17 17:00", true},
+		{"0 9-17 * * *", "2026-10-17 18:00", false},
+		{"0 12 * 1,10 *", "2026-10-17 12:00", true},
+		{"0 12 * 1,11 *", "2026-10-17 12:00", false},
+		// 2026-10-17 is a Saturday.
+		{"0 12 * * 6", "2026-10-17 12:00", true},
+		{"0 12 * * 1-5", "2026-10-17 12:00", false},
+		{"0 12 * * 7", "2026-10-18 12:00", true},
+		{"0 12 * * 0", "2026-10-18 12:00", true},
+		// With both day fields restricted either one will do, otherwise
+		// both must.
+		{"0 12 1 * 6", "2026-10-17 12:00", true},
+		{"0 12 17 * 1", "2026-10-17 12:00", true},
+		{"0 12 1 * 1", "2026-10-17 12:00", false},
+		{"0 12 17 * *", "2026-10-17 12:00", true},
+		{"0 12 1 * *", "2026-10-17 12:00", false},
+	}
+	for _, tt := range tests {
+		if got := parse(t, tt.spec, loc).Matches(at(t, loc, tt.t)); got != tt.want {
+			t.Errorf("%q.Matches(%s) = %t, want %t", tt.spec, tt.t, got, tt.want)
+		}
+	}
+
+	// Matching is in the rule's timezone, whatever t's is.
+	if !parse(t, "0 12 * * *", loc).Matches(time.Date(2026, 10, 17, 19, 0, 0, 0, time.UTC)) {
+		t.Error("noon in Los Angeles, given in UTC, didn't match")
+	}
+}
+
+func TestNext(t *testing.T) {
+	loc := la(t)
+
+	tests := []struct {
+		name string
+		spec string
+		t    string
+		want string
+	}{
+		{"later the same hour", "*/15 * * * *", "2026-10-17 10:07", "2026-10-17 10:15"},
+		{"not the minute it fires in", "0 12 * * *", "2026-10-17 12:00", "2026-10-18 12:00"},
+		{"partway through the minute it fires in", "0 12 * * *", "2026-10-17 11:59", "2026-10-17 12:00"},
+		{"the weekend", "0 12 * * 0,6", "2026-10-19 08:00", "2026-10-24 12:00"},
+		{"a weekday or the 1st", "0 9 1 * 1", "2026-10-27 10:00", "2026-11-01 09:00"},
+		{"next year", "0 0 1 1 *", "2026-10-17 12:00", "2027-01-01 00:00"},
+		{"a leap day", "0 12 29 2 *", "2027-10-17 12:00", "2028-02-29 12:00"},
+		{"a leap day too far off", "0 12 29 2 *", "2026-10-17 12:00", ""},
+		{"a time the clocks skip", "30 2 * * *", "2026-03-07 23:00", "2026-03-09 02:30"},
+		{"an hour after the clocks go forward", "0 3 * * *", "2026-03-07 23:00", "2026-03-08 03:00"},
+	}
+	for _, tt := range tests {
+		got := parse(t, tt.spec, loc).Next(at(t, loc, tt.t))
+		want := time.Time{}
+		if tt.want != "" {
+			want = at(t, loc, tt.want)
+		}
+		if !got.Equal(want) {
+			t.Errorf("%s: %q.Next(%s) = %s, want %s", tt.name, tt.spec, tt.t, got.In(loc), want.In(loc))
+		}
+	}
+}
+
+func TestNextClocksGoBack(t *testing.T) {
+	loc := la(t)
+	r := parse(t, "30 1 * * *", loc)
+
+	// 1:30 comes round twice on 2026-11-01, first in daylight time.
+	first := time.Date(2026, 11, 1, 8, 30, 0, 0, time.UTC)
+	second := time.Date(2026, 11, 1, 9, 30, 0, 0, time.UTC)
+
+	if got := r.Next(at(t, loc, "2026-10-31 23:00")); !got.Equal(first) {
+		t.Errorf("Next = %s, want %s", got.UTC(), first)
+	}
+	if got := r.Next(first); !got.Equal(second) {
+		t.Errorf("Next after the first 1:30 = %s, want %s", got.UTC(), second)
+	}
+	if got, want := r.Next(second), at(t, loc, "2026-11-02 01:30"); !got.Equal(want) {
+		t.Errorf("Next after the second 1:30 = %s, want %s", got.In(loc), want.In(loc))
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	if got := parse(t, "0 12 30 2 *", la(t)).Next(time.Now()); !got.IsZero() {
+		t.Errorf("February 30th came round at %s", got)
+	}
+}
+
+// TestNextAgreesWithMatches checks Next against a minute by minute scan with
+// Matches, across both changes of the clocks.
+func TestNextAgreesWithMatches(t *testing.T) {
+	loc := la(t)
+
+	for _, spec := range []string{"*/20 1-3 * * *", "0 12 * * 0,6", "59 23 * * *", "0 0 1 * *", "15 2 8 3 *"} {
+		r := parse(t, spec, loc)
+		for _, start := range []string{"2026-03-07 00:00", "2026-10-31 00:00", "2026-12-30 22:00"} {
+			from := at(t, loc, start)
+			for i := 0; i < 5; i++ {
+				want := time.Time{}
+				for m := from.Add(time.Minute); m.Before(from.AddDate(0, 0, 40)); m = m.Add(time.Minute) {
+					if r.Matches(m) {
+						want = m
+						break
+					}
+				}
+				if want.IsZero() {
+					break
+				}
+
+				got := r.Next(from)
+				if !got.Equal(want) {
+					t.Errorf("%q.Next(%s) = %s, want %s", spec, from.In(loc), got.In(loc), want.In(loc))
+					break
+				}
+				from = got
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Job is work the Scheduler runs at the minutes its Rules match, and at any
+// extra minutes When matches, for times that move, like an hour before a
+// game's tee time.
+type Job struct {
+	Name  string
+	Rules []Rule
+	When  func(t time.Time) bool
+	Run   func() error
+}
+
+// due reports whether j runs in the minute t falls in.
+func (j Job) due(t time.Time) bool {
+	for _, r := range j.Rules {
+		if r.Matches(t) {
+			return true
+		}
+	}
+
+	return j.When != nil && j.When(t)
+}
+
+// Status is how a job has been getting on since the Scheduler started.
+type Status struct {
+	Name        string
+	Rules       []Rule
+	LastRun     time.Time
+	LastSuccess time.Time
+	LastError   string
+	Next        time.Time
+}
+
+// Scheduler runs jobs in-process.  It checks once a minute, and catches up
+// on any minutes it missed, so a job still runs once when its minute passes
+// while the process is busy.  A job that's still running when it's due again
+// is skipped rather than run twice.
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    []Job
+	status  map[string]*Status
+	running map[string]bool
+}
+
+// New returns a Scheduler for jobs.
+func New(jobs ...Job) *Scheduler {
+	s := &Scheduler{
+		jobs:    jobs,
+		status:  make(map[string]*Status),
+		running: make(map[string]bool),
+	}
+	for _, j := range jobs {
+		s.status[j.Name] = &Status{Name: j.Name, Rules: j.Rules}
+	}
+
+	return s
+}
+
+// Run checks for due jobs every minute until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) error {
+	last := time.Now().Truncate(time.Minute)
+
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-tick.C:
+			now = now.Truncate(time.Minute)
+			for m := last.Add(time.Minute); !m.After(now); m = m.Add(time.Minute) {
+				s.runDue(m)
+			}
+			last = now
+		}
+	}
+}
+
+// runDue starts the jobs due in minute t.
+func (s *Scheduler) runDue(t time.Time) {
+	for _, j := range s.jobs {
+		if !j.due(t) {
+			continue
+		}
+
+		s.mu.Lock()
+		busy := s.running[j.Name]
+		s.running[j.Name] = true
+		s.mu.Unlock()
+		if busy {
+			log.Info().Msgf("schedule: %s is still running, skipping %s", j.Name, t.Format(time.RFC3339))
+			continue
+		}
+
+		go s.run(j)
+	}
+}
+
+// run runs j now and records how it went.
+func (s *Scheduler) run(j Job) {
+	start := time.Now()
+	err := j.Run()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running[j.Name] = false
+	st := s.status[j.Name]
+	st.LastRun = start
+	if err != nil {
+		st.LastError = err.Error()
+		log.Error().Msgf("schedule: %s: %s\n", j.Name, err)
+		return
+	}
+	st.LastSuccess = start
+	st.LastError = ""
+}
+
+// Statuses returns how each job is getting on, in the order they were given,
+// with when its rules next fire.  When they next fire is worked out again only
+// once that time has come.
+func (s *Scheduler) Statuses() []Status {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sts := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		st := s.status[j.Name]
+		if !st.Next.After(now) {
+			st.Next = next(st.Rules, now)
+		}
+		sts = append(sts, *st)
+	}
+
+	return sts
+}
+
+// next returns the first time after t that any of rules fires, or the zero
+// time if none ever do.
+func next(rules []Rule, t time.Time) time.Time {
+	var n time.Time
+	for _, r := range rules {
+		rn := r.Next(t)
+		if !rn.IsZero() && (n.IsZero() || rn.Before(n)) {
+			n = rn
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,81 @@
+package scoring
+
+import (
+	"context"
+	"mariners/db/dbtest"
+	"testing"
+)
+
+// TestScoreHostileStrings checks the names that come back with cards and team
+// results.  The score, team and team_members tables only hold numbers, so the
+// strings that go through them are the players'.
+func TestScoreHostileStrings(t *testing.T) {
+	ctx := context.Background()
+	sg := openGame(t)
+	gid := sg.game.ID
+	ps := sg.players
+	rules := Rules{TeamSize: 2, BestBalls: 1, GhostScore: DefaultRules.GhostScore}
+
+	for r := 0; r < len(dbtest.Hostile); r += len(ps) {
+		names := make(map[int64]string)
+		for i := range ps {
+			h := dbtest.Hostile[(r+i)%len(dbtest.Hostile)]
+			ps[i].Name, ps[i].PreferredName = h, h
+			err := ps[i].UpdatePlayer(ctx)
+			if err != nil {
+				t.Fatalf("renaming to %q: %s", h, err)
+			}
+			names[ps[i].ID] = h
+
+			s := card(ps[i].ID, 4, 4, 4, 4, 4, 4, 4, 4, 4)
+			s.TeamID = sg.teams[i/2]
+			err = AddScore(ctx, gid, &s)
+			if err != nil {
+				t.Fatalf("card for %q: %s", h, err)
+			}
+			s.Scores[0] = 3 + i
+			err = s.UpdateScore(ctx, gid)
+			if err != nil {
+				t.Fatalf("updating card for %q: %s", h, err)
+			}
+
+			got := Score{}
+			err = got.GetScore(ctx, gid, ps[i].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Player.Name != h || got.Player.PreferredName != h || got.Scores != s.Scores {
+				t.Errorf("card read back for %q / %q with %v, want %q with %v", got.Player.Name, got.Player.PreferredName, got.Scores, h, s.Scores)
+			}
+		}
+
+		ss, err := GetScoresByGameID(ctx, gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range ss {
+			if s.Player.PreferredName != names[s.Player.ID] {
+				t.Errorf("game card for player %d read back as %q, want %q", s.Player.ID, s.Player.PreferredName, names[s.Player.ID])
+			}
+		}
+
+		trs, err := GetTeamResults(ctx, gid, rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range trs {
+			for _, m := range tr.Members {
+				if m.Player.PreferredName != names[m.Player.ID] {
+					t.Errorf("team member %d read back as %q, want %q", m.Player.ID, m.Player.PreferredName, names[m.Player.ID])
+				}
+			}
+		}
+
+		for _, p := range ps {
+			err = DeleteScore(ctx, gid, p.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package scoring
+
+import (
+	"context"
+	"fmt"
+)
+
+// MysteryResult is the outcome of the mystery hole side game.  Everyone who
+// shares the lowest score on the mystery hole is a winner.
+type MysteryResult struct {
+	Hole    int
+	Strokes int
+	Winners Scores
+}
+
+func ScoreMystery(hole int, ss Scores) (MysteryResult, error) {
+	mr := MysteryResult{Hole: hole, Winners: make(Scores, 0)}
+
+	if hole < 1 || hole > 9 {
+		return mr, fmt.Errorf("mystery hole %d is not between 1 and 9", hole)
+	}
+
+	for _, s := range ss {
+		strokes := s.Scores[hole-1]
+		switch {
+		case len(mr.Winners) == 0 || strokes < mr.Strokes:
+			mr.Strokes = strokes
+			mr.Winners = Scores{s}
+		case strokes == mr.Strokes:
+			mr.Winners = append(mr.Winners, s)
+		}
+	}
+
+	return mr, nil
+}
+
+// GetMysteryResult scores the mystery hole side game from the cards entered
+// for game gid.
+func GetMysteryResult(ctx context.Context, gid int64, hole int) (MysteryResult, error) {
+	ss, err := GetScoresByGameID(ctx, gid)
+	if err != nil {
+		return MysteryResult{Hole: hole}, err
+	}
+
+	return ScoreMystery(hole, ss)
+}
@@ -0,0 +1,91 @@
+package scoring
+
+import "testing"
+
+func TestScoreMystery(t *testing.T) {
+	tests := []struct {
+		name    string
+		hole    int
+		cards   Scores
+		strokes int
+		winners []int64
+	}{
+		{
+			name:    "one winner",
+			hole:    4,
+			cards:   Scores{card(1, 3, 3, 3, 4), card(2, 3, 3, 3, 2), card(3, 3, 3, 3, 5)},
+			strokes: 2,
+			winners: []int64{2},
+		},
+		{
+			name:    "a tie",
+			hole:    1,
+			cards:   Scores{card(1, 3), card(2, 4), card(3, 3)},
+			strokes: 3,
+			winners: []int64{1, 3},
+		},
+		{
+			name:    "a tie beaten later",
+			hole:    9,
+			cards:   Scores{card(1, 3, 3, 3, 3, 3, 3, 3, 3, 4), card(2, 3, 3, 3, 3, 3, 3, 3, 3, 4), card(3, 3, 3, 3, 3, 3, 3, 3, 3, 2)},
+			strokes: 2,
+			winners: []int64{3},
+		},
+		{
+			name:    "everyone tied",
+			hole:    2,
+			cards:   Scores{card(1, 5, 3), card(2, 4, 3)},
+			strokes: 3,
+			winners: []int64{1, 2},
+		},
+		{
+			name:    "one card",
+			hole:    2,
+			cards:   Scores{card(1, 5, 6)},
+			strokes: 6,
+			winners: []int64{1},
+		},
+		{
+			name:    "no cards",
+			hole:    5,
+			cards:   Scores{},
+			winners: []int64{},
+		},
+		{
+			name:    "no cards at all",
+			hole:    5,
+			winners: []int64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, err := ScoreMystery(tt.hole, tt.cards)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mr.Hole != tt.hole || mr.Strokes != tt.strokes {
+				t.Errorf("hole %d in %d, want hole %d in %d", mr.Hole, mr.Strokes, tt.hole, tt.strokes)
+			}
+			if mr.Winners == nil {
+				t.Error("winners is nil, want a list")
+			}
+			if len(mr.Winners) != len(tt.winners) {
+				t.Fatalf("%d winners, want %v", len(mr.Winners), tt.winners)
+			}
+			for i, w := range mr.Winners {
+				if w.Player.ID != tt.winners[i] {
+					t.Errorf("winner %d is player %d, want %d", i, w.Player.ID, tt.winners[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScoreMysteryBadHole(t *testing.T) {
+	for _, hole := range []int{0, -1, 10} {
+		_, err := ScoreMystery(hole, Scores{card(1, 3, 3, 3, 3, 3, 3, 3, 3, 3)})
+		if err == nil {
+			t.Errorf("scored mystery hole %d", hole)
+		}
+	}
+}
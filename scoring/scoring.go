@@ -2,27 +2,38 @@ package scoring
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"mariners/db"
 	"mariners/player"
 	"mariners/team"
-	"strconv"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/secretsmanager"
-	"google.golang.org/api/option"
-	"google.golang.org/api/sheets/v4"
+	"math"
+	"sort"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// MinStrokes and MaxStrokes bound the number of strokes that can be entered
+// for a single hole.
+const (
+	MinStrokes = 1
+	MaxStrokes = 15
+)
+
+var (
+	ErrInvalidScore   = errors.New("invalid score")
+	ErrNotCheckedIn   = errors.New("player is not checked in to this game")
+	ErrDuplicateScore = errors.New("player already has a score for this game")
+)
+
 type Score struct {
 	Player player.Player
 	TeamID team.Team
-	Scores [9]int
+	Scores [9]int `json:"scores"`
 }
+
+type Scores []Score
 type MPAverage struct {
 	Player  player.Player
 	Average float64
@@ -33,157 +44,231 @@ type MPAverage struct {
 
 type MPAverages []MPAverage
 
-type apiKey map[string]string
+// LastRounds is the number of most recent rounds in the rolling average.
+const LastRounds = 20
+
+// Round is the total for one player's card in one game.
+type Round struct {
+	PlayerID int64
+	Date     time.Time
+	Total    int
+}
+
+func getRounds(ctx context.Context) ([]Round, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetRounds(ctx)
+}
+
+// seasonYear is the current season, which runs with the calendar year in the
+// league's timezone.
+func seasonYear() int {
+	return db.Local(time.Now()).Year()
+}
+
+// averages computes the season average, last LastRounds average and season
+// round count for each player with at least one round, from rounds sorted
+// most recent first.  Players are ranked by their last LastRounds average,
+// lowest first, and players with equal averages share a rank.
+func averages(rs []Round, season int) MPAverages {
+	type tally struct {
+		lastTotal   int
+		lastCount   int
+		seasonTotal int
+		seasonCount int
+	}
+
+	order := make([]int64, 0)
+	ts := make(map[int64]*tally)
+	for _, r := range rs {
+		t, ok := ts[r.PlayerID]
+		if !ok {
+			t = &tally{}
+			ts[r.PlayerID] = t
+			order = append(order, r.PlayerID)
+		}
+		if t.lastCount < LastRounds {
+			t.lastTotal += r.Total
+			t.lastCount++
+		}
+		if db.Local(r.Date).Year() == season {
+			t.seasonTotal += r.Total
+			t.seasonCount++
+		}
+	}
+
+	as := make(MPAverages, 0, len(order))
+	for _, id := range order {
+		t := ts[id]
+		var avg MPAverage
+		avg.Player.ID = id
+		avg.Last20 = math.Round(float64(t.lastTotal)/float64(t.lastCount)*100) / 100
+		if t.seasonCount > 0 {
+			avg.Average = math.Round(float64(t.seasonTotal)/float64(t.seasonCount)*100) / 100
+		}
+		avg.Rounds = int64(t.seasonCount)
+		as = append(as, avg)
+	}
+
+	sort.SliceStable(as, func(i, j int) bool {
+		return as[i].Last20 < as[j].Last20
+	})
+	for i := range as {
+		if i > 0 && as[i].Last20 == as[i-1].Last20 {
+			as[i].Rank = as[i-1].Rank
+		} else {
+			as[i].Rank = int64(i + 1)
+		}
+	}
 
-func getSecret() string {
-	secretName := "ennead"
-	region := "us-east-1"
+	return as
+}
 
-	//Create a Secrets Manager client
-	sess, err := session.NewSession()
+func (mp *MPAverage) GetAverage(ctx context.Context) error {
+	as, err := GetAverages(ctx)
 	if err != nil {
-		// Handle session creation error
-		fmt.Println(err.Error())
-		return ""
+		return err
 	}
-	svc := secretsmanager.New(sess,
-		aws.NewConfig().WithRegion(region))
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId:     aws.String(secretName),
-		VersionStage: aws.String("AWSCURRENT"), // VersionStage defaults to AWSCURRENT if unspecified
+
+	for _, a := range as {
+		if a.Player.ID == mp.Player.ID {
+			mp.Rank = a.Rank
+			mp.Last20 = a.Last20
+			mp.Average = a.Average
+			mp.Rounds = a.Rounds
+			return nil
+		}
 	}
 
-	// In this sample we only handle the specific exceptions for the 'GetSecretValue' API.
-	// See https://docs.aws.amazon.com/secretsmanager/latest/apireference/API_GetSecretValue.html
+	mp.Rank = 0
+	mp.Last20 = 0
+	mp.Average = 0
+	mp.Rounds = 0
 
-	result, err := svc.GetSecretValue(input)
+	return nil
+}
+
+func GetAverages(ctx context.Context) (MPAverages, error) {
+	rs, err := getRounds(ctx)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case secretsmanager.ErrCodeDecryptionFailure:
-				// Secrets Manager can't decrypt the protected secret text using the provided KMS key.
-				log.Error().Msgf("%s: %s", secretsmanager.ErrCodeDecryptionFailure, aerr.Error())
-
-			case secretsmanager.ErrCodeInternalServiceError:
-				// An error occurred on the server side.
-				log.Error().Msgf("%s: %s", secretsmanager.ErrCodeInternalServiceError, aerr.Error())
-
-			case secretsmanager.ErrCodeInvalidParameterException:
-				// You provided an invalid value for a parameter.
-				log.Error().Msgf("%s: %s", secretsmanager.ErrCodeInvalidParameterException, aerr.Error())
-
-			case secretsmanager.ErrCodeInvalidRequestException:
-				// You provided a parameter value that is not valid for the current state of the resource.
-				log.Error().Msgf("%s: %s", secretsmanager.ErrCodeInvalidRequestException, aerr.Error())
-
-			case secretsmanager.ErrCodeResourceNotFoundException:
-				// We can't find the resource that you asked for.
-				log.Error().Msgf("%s: %s", secretsmanager.ErrCodeResourceNotFoundException, aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			log.Error().Msg(err.Error())
+		return make(MPAverages, 0), err
+	}
+
+	as := averages(rs, seasonYear())
+	for i := range as {
+		err = as[i].Player.GetPlayerByID(ctx, as[i].Player.ID)
+		if err != nil {
+			log.Error().Msgf("GetAverages: no player with id %d: %s", as[i].Player.ID, err)
+		}
+	}
+
+	return as, nil
+}
+
+func (s Score) Total() int {
+	t := 0
+	for _, h := range s.Scores {
+		t += h
+	}
+
+	return t
+}
+
+// validate checks the hole values, that the team belongs to game gid and that
+// the player is checked in to that game.
+func (s *Score) validate(ctx context.Context, gid int64) error {
+	for i, h := range s.Scores {
+		if h < MinStrokes || h > MaxStrokes {
+			return fmt.Errorf("%w: hole %d has %d strokes, must be between %d and %d", ErrInvalidScore, i+1, h, MinStrokes, MaxStrokes)
 		}
-		return ""
 	}
 
-	// Decrypts secret using the associated KMS key.
-	// Depending on whether the secret is a string or binary, one of these fields will be populated.
-	var secretString string
-	if result.SecretString != nil {
-		secretString = *result.SecretString
-	} else {
-		return ""
+	err := team.GetTeam(ctx, s.TeamID.ID, &s.TeamID)
+	if err != nil {
+		return err
+	}
+	if s.TeamID.GameID != gid {
+		return fmt.Errorf("%w: team %d is not playing in game %d", ErrInvalidScore, s.TeamID.ID, gid)
 	}
 
-	k := apiKey{}
-	err = json.Unmarshal([]byte(secretString), &k)
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	in, err := getStore().CheckedIn(ctx, gid, s.Player.ID)
 	if err != nil {
-		fmt.Println(err.Error())
-		return ""
+		return err
+	}
+	if !in {
+		return ErrNotCheckedIn
 	}
-	return k["GoogleAPIKey"]
+
+	return nil
 }
 
-func (mp *MPAverage) GetAverage() error {
-	ctx := context.Background()
-	srv, err := sheets.NewService(ctx, option.WithAPIKey(getSecret()))
+func AddScore(ctx context.Context, gid int64, s *Score) error {
+	err := s.validate(ctx, gid)
 	if err != nil {
 		return err
 	}
 
-	sheetId := "1H2lhew-tk1jWQg8cDI-hMiR2xDtBDkw-862s_pP82uI"
-	readRange := "2022!A2:E45"
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	dup, err := getStore().HasScore(ctx, gid, s.Player.ID)
+	if err != nil {
+		return err
+	}
+	if dup {
+		return ErrDuplicateScore
+	}
 
-	resp, err := srv.Spreadsheets.Values.Get(sheetId, readRange).Do()
+	return getStore().AddScore(ctx, s)
+}
+
+func (s *Score) UpdateScore(ctx context.Context, gid int64) error {
+	err := s.validate(ctx, gid)
 	if err != nil {
-		log.Error().Msgf("Unable to retrieve data from sheet: %v", err)
-	}
-
-	if len(resp.Values) == 0 {
-		log.Error().Msg("No data found.")
-	} else {
-		for _, row := range resp.Values {
-			if row[1] == mp.Player.PreferredName {
-				mp.Rank = row[0].(int64)
-				mp.Last20 = row[2].(float64)
-				mp.Average = row[3].(float64)
-				mp.Rounds = row[4].(int64)
-			}
-		}
+		return err
 	}
 
-	return nil
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().UpdateScore(ctx, gid, s)
 }
 
-func GetAverages() (MPAverages, error) {
-	as := make(MPAverages, 0)
+func DeleteScore(ctx context.Context, gid int64, pid int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().DeleteScore(ctx, gid, pid)
+}
 
-	ctx := context.Background()
-	srv, err := sheets.NewService(ctx, option.WithAPIKey(getSecret()))
+func (s *Score) GetScore(ctx context.Context, gid int64, pid int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	ns, err := getStore().GetScore(ctx, gid, pid)
 	if err != nil {
-		return as, err
+		return err
 	}
+	*s = ns
 
-	sheetId := "1H2lhew-tk1jWQg8cDI-hMiR2xDtBDkw-862s_pP82uI"
-	readRange := "2022!A2:E45"
+	return s.Player.GetPlayerByID(ctx, s.Player.ID)
+}
 
-	resp, err := srv.Spreadsheets.Values.Get(sheetId, readRange).Do()
+func GetScoresByGameID(ctx context.Context, gid int64) (Scores, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	ss, err := getStore().GetScoresByGameID(ctx, gid)
 	if err != nil {
-		log.Error().Msgf("Unable to retrieve data from sheet: %v", err)
-		return as, err
-	}
-
-	if len(resp.Values) == 0 {
-		log.Error().Msg("No data found.")
-		return as, err
-	} else {
-		for _, row := range resp.Values {
-			var avg MPAverage
-			avg.Rank, err = strconv.ParseInt(row[0].(string), 10, 64)
-			if err != nil {
-				return as, err
-			}
-			err = avg.Player.GetPlayerByPreferredName(row[1].(string))
-			if err != nil {
-				log.Error().Msgf("No name in scores spreadsheet matches \"%s\", or maybe something else: %s", row[1].(string), err)
-			}
-			avg.Average, err = strconv.ParseFloat(row[3].(string), 64)
-			if err != nil {
-				return as, err
-			}
-			avg.Last20, err = strconv.ParseFloat(row[2].(string), 64)
-			if err != nil {
-				return as, err
-			}
-			avg.Rounds, err = strconv.ParseInt(row[4].(string), 10, 64)
-			if err != nil {
-				return as, err
-			}
-			as = append(as, avg)
+		return ss, err
+	}
+
+	for i := range ss {
+		err = ss[i].Player.GetPlayerByID(ctx, ss[i].Player.ID)
+		if err != nil {
+			return ss, err
 		}
 	}
 
-	return as, nil
+	return ss, nil
 }
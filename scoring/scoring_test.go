@@ -0,0 +1,202 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"mariners/game"
+	"mariners/player"
+	"mariners/team"
+	"testing"
+	"time"
+)
+
+// scoringGame is a game played today with its teams drawn and its
+// players checked in.
+type scoringGame struct {
+	game    game.Game
+	players player.Players
+	teams   team.Teams
+}
+
+// openGame points the package at a fresh in-memory database holding a game
+// with two teams of two.
+func openGame(t *testing.T) scoringGame {
+	t.Helper()
+
+	con := dbtest.Open(t)
+	SetStore(nil)
+
+	sg := scoringGame{}
+	ctx := context.Background()
+	gs := game.NewSQLGameStore(con)
+
+	sg.game.Date, _ = db.Day(time.Now())
+	sg.game.TeeTime = sg.game.Date.Add(12 * time.Hour)
+	err := gs.AddGame(ctx, &sg.game)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []string{"Tom", "Lee", "Hale", "Curtis"} {
+		p := player.Player{Name: n, PreferredName: n}
+		err = player.AddPlayer(ctx, &p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = gs.AddCheckin(ctx, game.Checkin{PlayerID: p.ID, GameID: sg.game.ID, Date: sg.game.TeeTime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sg.players = append(sg.players, p)
+	}
+
+	ps := sg.players
+	sg.teams, err = team.SaveDraw(ctx, sg.game.ID, []team.TeamMembers{
+		{{PlayerID: ps[0].ID}, {PlayerID: ps[1].ID}},
+		{{PlayerID: ps[2].ID}, {PlayerID: ps[3].ID}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sg
+}
+
+func TestScores(t *testing.T) {
+	ctx := context.Background()
+	sg := openGame(t)
+	gid := sg.game.ID
+
+	s := card(sg.players[0].ID, 4, 5, 3, 4, 4, 5, 3, 4, 5)
+	s.TeamID = sg.teams[0]
+	err := AddScore(ctx, gid, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddScore(ctx, gid, &s)
+	if !errors.Is(err, ErrDuplicateScore) {
+		t.Errorf("second card = %v, want ErrDuplicateScore", err)
+	}
+
+	got := Score{}
+	err = got.GetScore(ctx, gid, sg.players[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Scores != s.Scores || got.TeamID.ID != sg.teams[0].ID || got.Player.PreferredName != "Tom" {
+		t.Errorf("read back %+v, want %+v", got, s)
+	}
+	if got.Total() != 37 {
+		t.Errorf("total %d, want 37", got.Total())
+	}
+
+	got.Scores[0] = 3
+	err = got.UpdateScore(ctx, gid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := GetScoresByGameID(ctx, gid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) != 1 || ss[0].Scores[0] != 3 {
+		t.Errorf("after update scores are %+v", ss)
+	}
+
+	err = DeleteScore(ctx, gid, sg.players[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err = GetScoresByGameID(ctx, gid)
+	if err != nil || len(ss) != 0 {
+		t.Errorf("after delete there are %d scores, %v", len(ss), err)
+	}
+}
+
+func TestAddScoreRefused(t *testing.T) {
+	ctx := context.Background()
+	sg := openGame(t)
+	gid := sg.game.ID
+
+	bad := card(sg.players[0].ID, 4, 5, 3, 4, 0, 5, 3, 4, 5)
+	bad.TeamID = sg.teams[0]
+	err := AddScore(ctx, gid, &bad)
+	if !errors.Is(err, ErrInvalidScore) {
+		t.Errorf("a hole of 0 = %v, want ErrInvalidScore", err)
+	}
+
+	wrong := card(sg.players[0].ID, 4, 4, 4, 4, 4, 4, 4, 4, 4)
+	wrong.TeamID = sg.teams[0]
+	err = AddScore(ctx, gid+1, &wrong)
+	if !errors.Is(err, ErrInvalidScore) {
+		t.Errorf("a team from another game = %v, want ErrInvalidScore", err)
+	}
+
+	p := player.Player{Name: "Walk Up", PreferredName: "Walk"}
+	err = player.AddPlayer(ctx, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := card(p.ID, 4, 4, 4, 4, 4, 4, 4, 4, 4)
+	out.TeamID = sg.teams[0]
+	err = AddScore(ctx, gid, &out)
+	if !errors.Is(err, ErrNotCheckedIn) {
+		t.Errorf("a player not checked in = %v, want ErrNotCheckedIn", err)
+	}
+}
+
+func TestTeamResultsAndAverages(t *testing.T) {
+	ctx := context.Background()
+	sg := openGame(t)
+	gid := sg.game.ID
+
+	cards := []struct {
+		team  int
+		score Score
+	}{
+		{0, card(sg.players[0].ID, 4, 4, 4, 4, 4, 4, 4, 4, 4)},
+		{0, card(sg.players[1].ID, 5, 5, 5, 5, 5, 5, 5, 5, 5)},
+		{1, card(sg.players[2].ID, 3, 5, 3, 5, 3, 5, 3, 5, 3)},
+		{1, card(sg.players[3].ID, 6, 6, 6, 6, 6, 6, 6, 6, 6)},
+	}
+	for _, c := range cards {
+		s := c.score
+		s.TeamID = sg.teams[c.team]
+		err := AddScore(ctx, gid, &s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := Rules{TeamSize: 2, BestBalls: 1, GhostScore: DefaultRules.GhostScore}
+	trs, err := GetTeamResults(ctx, gid, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trs) != 2 {
+		t.Fatalf("%d team results, want 2", len(trs))
+	}
+	if trs[0].Team.ID != sg.teams[1].ID || trs[0].Total != 35 || trs[0].Rank != 1 {
+		t.Errorf("first is team %d with %d ranked %d, want team %d with 35 ranked 1", trs[0].Team.ID, trs[0].Total, trs[0].Rank, sg.teams[1].ID)
+	}
+	if trs[1].Total != 36 || trs[1].Rank != 2 {
+		t.Errorf("second has %d ranked %d, want 36 ranked 2", trs[1].Total, trs[1].Rank)
+	}
+	if ws := trs.Winners(); len(ws) != 1 {
+		t.Errorf("%d winners, want 1", len(ws))
+	}
+
+	as, err := GetAverages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 4 {
+		t.Fatalf("%d averages, want 4", len(as))
+	}
+	if as[0].Player.PreferredName != "Hale" || as[0].Average != 35 || as[0].Rank != 1 || as[0].Rounds != 1 {
+		t.Errorf("leader is %+v, want Hale averaging 35 over 1 round", as[0])
+	}
+}
@@ -0,0 +1,238 @@
+package scoring
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"mariners/db"
+)
+
+// ScoreStore loads and saves scorecards.  Scores come back with only the
+// player and team IDs filled in.
+type ScoreStore interface {
+	AddScore(ctx context.Context, s *Score) error
+	UpdateScore(ctx context.Context, gid int64, s *Score) error
+	DeleteScore(ctx context.Context, gid int64, pid int64) error
+	GetScore(ctx context.Context, gid int64, pid int64) (Score, error)
+	GetScoresByGameID(ctx context.Context, gid int64) (Scores, error)
+	// HasScore reports whether player pid has a card in game gid.
+	HasScore(ctx context.Context, gid int64, pid int64) (bool, error)
+	// CheckedIn reports whether player pid is checked in to game gid.
+	CheckedIn(ctx context.Context, gid int64, pid int64) (bool, error)
+	// GetRounds returns every stored round, most recent first.
+	GetRounds(ctx context.Context) ([]Round, error)
+	// GetWindRounds returns every round of a game with observed weather,
+	// most recent first.
+	GetWindRounds(ctx context.Context) ([]WindRound, error)
+}
+
+// SQLScoreStore is a ScoreStore backed by the score table.
+type SQLScoreStore struct {
+	DB *sql.DB
+}
+
+func NewSQLScoreStore(con *sql.DB) *SQLScoreStore {
+	return &SQLScoreStore{DB: con}
+}
+
+var store ScoreStore
+
+// SetStore replaces the ScoreStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s ScoreStore) {
+	store = s
+}
+
+func getStore() ScoreStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLScoreStore(db.Con)
+}
+
+const scoreColumns = "score.idplayer, score.idteam, team.idgame, first, second, third, fourth, fifth, sixth, seventh, eighth, ninth "
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScore(row scanner, s *Score) error {
+	return row.Scan(
+		&s.Player.ID,
+		&s.TeamID.ID,
+		&s.TeamID.GameID,
+		&s.Scores[0],
+		&s.Scores[1],
+		&s.Scores[2],
+		&s.Scores[3],
+		&s.Scores[4],
+		&s.Scores[5],
+		&s.Scores[6],
+		&s.Scores[7],
+		&s.Scores[8])
+}
+
+func (st *SQLScoreStore) AddScore(ctx context.Context, s *Score) error {
+	query := "INSERT INTO score (idplayer, idteam, first, second, third, fourth, fifth, sixth, seventh, eighth, ninth) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := db.Q(ctx, st.DB).ExecContext(ctx, query,
+		s.Player.ID,
+		s.TeamID.ID,
+		s.Scores[0],
+		s.Scores[1],
+		s.Scores[2],
+		s.Scores[3],
+		s.Scores[4],
+		s.Scores[5],
+		s.Scores[6],
+		s.Scores[7],
+		s.Scores[8])
+
+	return err
+}
+
+func (st *SQLScoreStore) UpdateScore(ctx context.Context, gid int64, s *Score) error {
+	query := "UPDATE score SET idteam=?, first=?, second=?, third=?, fourth=?, fifth=?, sixth=?, seventh=?, eighth=?, ninth=? " +
+		"WHERE idplayer=? AND idteam IN (SELECT idteam FROM team WHERE idgame=?)"
+	res, err := db.Q(ctx, st.DB).ExecContext(ctx, query,
+		s.TeamID.ID,
+		s.Scores[0],
+		s.Scores[1],
+		s.Scores[2],
+		s.Scores[3],
+		s.Scores[4],
+		s.Scores[5],
+		s.Scores[6],
+		s.Scores[7],
+		s.Scores[8],
+		s.Player.ID,
+		gid)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("UpdateScore: no score for player %d in game %d", s.Player.ID, gid)
+	}
+
+	return nil
+}
+
+func (st *SQLScoreStore) DeleteScore(ctx context.Context, gid int64, pid int64) error {
+	query := "DELETE FROM score WHERE idplayer=? AND idteam IN (SELECT idteam FROM team WHERE idgame=?)"
+	res, err := db.Q(ctx, st.DB).ExecContext(ctx, query, pid, gid)
+	if err != nil {
+		return err
+	}
+
+	return db.OneRow(res)
+}
+
+func (st *SQLScoreStore) GetScore(ctx context.Context, gid int64, pid int64) (Score, error) {
+	s := Score{}
+
+	query := "SELECT " + scoreColumns +
+		"FROM score INNER JOIN team ON score.idteam=team.idteam WHERE score.idplayer=? AND team.idgame=?"
+	err := scanScore(db.Q(ctx, st.DB).QueryRowContext(ctx, query, pid, gid), &s)
+
+	return s, err
+}
+
+func (st *SQLScoreStore) GetScoresByGameID(ctx context.Context, gid int64) (Scores, error) {
+	ss := make(Scores, 0)
+
+	query := "SELECT " + scoreColumns +
+		"FROM score INNER JOIN team ON score.idteam=team.idteam WHERE team.idgame=? ORDER BY score.idteam"
+	rows, err := db.Q(ctx, st.DB).QueryContext(ctx, query, gid)
+	if err != nil {
+		return ss, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Score
+		err := scanScore(rows, &s)
+		if err != nil {
+			return ss, err
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, rows.Err()
+}
+
+func (st *SQLScoreStore) HasScore(ctx context.Context, gid int64, pid int64) (bool, error) {
+	var count int64
+
+	query := "SELECT COUNT(*) FROM score INNER JOIN team ON score.idteam=team.idteam WHERE score.idplayer=? AND team.idgame=?"
+	err := db.Q(ctx, st.DB).QueryRowContext(ctx, query, pid, gid).Scan(&count)
+
+	return count != 0, err
+}
+
+func (st *SQLScoreStore) CheckedIn(ctx context.Context, gid int64, pid int64) (bool, error) {
+	var count int64
+
+	query := "SELECT COUNT(*) FROM checkins WHERE idplayer=? AND idgame=?"
+	err := db.Q(ctx, st.DB).QueryRowContext(ctx, query, pid, gid).Scan(&count)
+
+	return count != 0, err
+}
+
+func (st *SQLScoreStore) GetRounds(ctx context.Context) ([]Round, error) {
+	rs := make([]Round, 0)
+
+	query := "SELECT score.idplayer, game.game_date, " +
+		"score.first + score.second + score.third + score.fourth + score.fifth + score.sixth + score.seventh + score.eighth + score.ninth " +
+		"FROM score " +
+		"INNER JOIN team ON score.idteam=team.idteam " +
+		"INNER JOIN game ON team.idgame=game.idgame " +
+		"ORDER BY game.game_date DESC"
+	rows, err := db.Q(ctx, st.DB).QueryContext(ctx, query)
+	if err != nil {
+		return rs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Round
+		if err := rows.Scan(&r.PlayerID, db.ScanTime(&r.Date), &r.Total); err != nil {
+			return rs, err
+		}
+		rs = append(rs, r)
+	}
+
+	return rs, rows.Err()
+}
+
+func (st *SQLScoreStore) GetWindRounds(ctx context.Context) ([]WindRound, error) {
+	rs := make([]WindRound, 0)
+
+	query := "SELECT score.idplayer, game.idgame, game.game_date, " +
+		"score.first + score.second + score.third + score.fourth + score.fifth + score.sixth + score.seventh + score.eighth + score.ninth, " +
+		"played.wind " +
+		"FROM score " +
+		"INNER JOIN team ON score.idteam=team.idteam " +
+		"INNER JOIN game ON team.idgame=game.idgame " +
+		"INNER JOIN (SELECT idgame, AVG(wind) AS wind FROM weather WHERE observed=? GROUP BY idgame) played ON played.idgame=game.idgame " +
+		"ORDER BY game.game_date DESC"
+	rows, err := db.Q(ctx, st.DB).QueryContext(ctx, query, true)
+	if err != nil {
+		return rs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r WindRound
+		err = rows.Scan(&r.PlayerID, &r.GameID, db.ScanTime(&r.Date), &r.Total, &r.Wind)
+		if err != nil {
+			return rs, err
+		}
+		rs = append(rs, r)
+	}
+
+	return rs, rows.Err()
+}
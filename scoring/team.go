@@ -0,0 +1,228 @@
+package scoring
+
+import (
+	"context"
+	"fmt"
+	"mariners/player"
+	"mariners/team"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rules describe how a team's score is built from its members' cards.
+type Rules struct {
+	// TeamSize is the number of players on a full team.  Teams with fewer
+	// members have their empty slots filled by ghosts.
+	TeamSize int
+	// BestBalls is the number of scores counted on each hole.
+	BestBalls int
+	// GhostScore is the stand-in score a ghost shoots on each hole.
+	GhostScore [9]int
+	// Par is par on each hole of the course.
+	Par [9]int
+}
+
+var DefaultRules = Rules{
+	TeamSize:   4,
+	BestBalls:  2,
+	GhostScore: [9]int{5, 5, 5, 5, 5, 5, 5, 5, 5},
+	Par:        [9]int{3, 3, 3, 3, 3, 3, 3, 3, 3},
+}
+
+// CoursePar is par for all nine holes.
+func (r Rules) CoursePar() int {
+	n := 0
+	for _, p := range r.Par {
+		n += p
+	}
+
+	return n
+}
+
+// ParseHoles reads a number for each hole from s, either nine separated by
+// commas or one for all of them.
+func ParseHoles(s string) ([9]int, error) {
+	var hs [9]int
+
+	fs := strings.Split(s, ",")
+	if len(fs) != 1 && len(fs) != len(hs) {
+		return hs, fmt.Errorf("%q: want 1 or %d holes, got %d", s, len(hs), len(fs))
+	}
+	for i := range hs {
+		n, err := strconv.Atoi(strings.TrimSpace(fs[i%len(fs)]))
+		if err != nil {
+			return hs, fmt.Errorf("%q: %s", s, err)
+		}
+		hs[i] = n
+	}
+
+	return hs, nil
+}
+
+// HoleResult is one hole of a team's score.  Counted holds the IDs of the
+// players whose scores counted, with 0 standing for a ghost.
+type HoleResult struct {
+	Hole    int
+	Score   int
+	Counted []int64
+}
+
+type TeamResultMember struct {
+	Player       player.Player
+	Ghost        bool
+	NinthDropped bool
+	Scores       [9]int
+}
+
+type TeamResult struct {
+	Team    team.Team
+	Members []TeamResultMember
+	Holes   [9]HoleResult
+	Total   int
+	Rank    int
+}
+
+type TeamResults []TeamResult
+
+func (r Rules) validate() error {
+	if r.BestBalls < 1 {
+		return fmt.Errorf("%d best balls: at least one score must count", r.BestBalls)
+	}
+	if r.BestBalls > r.TeamSize {
+		return fmt.Errorf("%d best balls is more than a team of %d", r.BestBalls, r.TeamSize)
+	}
+	for i, g := range r.GhostScore {
+		if g < MinStrokes || g > MaxStrokes {
+			return fmt.Errorf("ghost score of %d on hole %d is not between %d and %d", g, i+1, MinStrokes, MaxStrokes)
+		}
+	}
+
+	return nil
+}
+
+// ScoreTeam adds up team t from its members and their cards.  On each hole the
+// lowest rules.BestBalls scores count.  Members without a card don't count at
+// all, and ghosts fill the team up to rules.TeamSize from the members who do,
+// shooting rules.GhostScore.  A member whose team_members row has NinthDropped
+// set has their ninth hole left out; which ninth tee the game is played from
+// doesn't change the scoring.
+func ScoreTeam(t team.Team, ms team.TeamMembers, ss Scores, rules Rules) (TeamResult, error) {
+	tr := TeamResult{Team: t}
+
+	err := rules.validate()
+	if err != nil {
+		return tr, err
+	}
+	if len(ms) > rules.TeamSize {
+		return tr, fmt.Errorf("team %d has %d members, more than a team of %d", t.ID, len(ms), rules.TeamSize)
+	}
+
+	cards := make(map[int64]Score)
+	for _, s := range ss {
+		cards[s.Player.ID] = s
+	}
+
+	for _, m := range ms {
+		rm := TeamResultMember{Ghost: m.Ghost, NinthDropped: m.NinthDropped}
+		if m.Ghost {
+			rm.Scores = rules.GhostScore
+			tr.Members = append(tr.Members, rm)
+			continue
+		}
+		s, ok := cards[m.PlayerID]
+		if !ok {
+			continue
+		}
+		rm.Player = s.Player
+		rm.Scores = s.Scores
+		tr.Members = append(tr.Members, rm)
+	}
+	for len(tr.Members) < rules.TeamSize {
+		tr.Members = append(tr.Members, TeamResultMember{Ghost: true, Scores: rules.GhostScore})
+	}
+
+	type ball struct {
+		pid     int64
+		strokes int
+	}
+
+	for h := range tr.Holes {
+		bs := make([]ball, 0, len(tr.Members))
+		for _, m := range tr.Members {
+			if h == 8 && m.NinthDropped {
+				continue
+			}
+			bs = append(bs, ball{pid: m.Player.ID, strokes: m.Scores[h]})
+		}
+		for len(bs) < rules.BestBalls {
+			bs = append(bs, ball{pid: 0, strokes: rules.GhostScore[h]})
+		}
+		sort.SliceStable(bs, func(i, j int) bool {
+			return bs[i].strokes < bs[j].strokes
+		})
+
+		hr := HoleResult{Hole: h + 1}
+		for _, b := range bs[:rules.BestBalls] {
+			hr.Score += b.strokes
+			hr.Counted = append(hr.Counted, b.pid)
+		}
+		tr.Holes[h] = hr
+		tr.Total += hr.Score
+	}
+
+	return tr, nil
+}
+
+// GetTeamResults scores every team in game gid, lowest total first.  Teams with
+// the same total share a rank, so more than one team can win the day.
+func GetTeamResults(ctx context.Context, gid int64, rules Rules) (TeamResults, error) {
+	trs := make(TeamResults, 0)
+
+	ts, err := team.GetTeamsByGameID(ctx, gid)
+	if err != nil {
+		return trs, err
+	}
+
+	ss, err := GetScoresByGameID(ctx, gid)
+	if err != nil {
+		return trs, err
+	}
+
+	for _, t := range ts {
+		ms, err := team.GetTeamMembers(ctx, t.ID)
+		if err != nil {
+			return trs, err
+		}
+		tr, err := ScoreTeam(t, ms, ss, rules)
+		if err != nil {
+			return trs, err
+		}
+		trs = append(trs, tr)
+	}
+
+	sort.SliceStable(trs, func(i, j int) bool {
+		return trs[i].Total < trs[j].Total
+	})
+	for i := range trs {
+		if i > 0 && trs[i].Total == trs[i-1].Total {
+			trs[i].Rank = trs[i-1].Rank
+		} else {
+			trs[i].Rank = i + 1
+		}
+	}
+
+	return trs, nil
+}
+
+// Winners returns the teams that share first place.
+func (trs TeamResults) Winners() TeamResults {
+	ws := make(TeamResults, 0)
+	for _, tr := range trs {
+		if tr.Rank == 1 {
+			ws = append(ws, tr)
+		}
+	}
+
+	return ws
+}
@@ -0,0 +1,157 @@
+package scoring
+
+import (
+	"mariners/player"
+	"mariners/team"
+	"testing"
+)
+
+func card(pid int64, holes ...int) Score {
+	s := Score{Player: player.Player{ID: pid}}
+	copy(s.Scores[:], holes)
+	return s
+}
+
+func TestScoreTeam(t *testing.T) {
+	rules := Rules{TeamSize: 4, BestBalls: 2, GhostScore: [9]int{6, 6, 6, 6, 6, 6, 6, 6, 6}}
+	tm := team.Team{ID: 1, GameID: 1}
+
+	tests := []struct {
+		name    string
+		members team.TeamMembers
+		cards   Scores
+		ghosts  int
+		total   int
+		ninth   HoleResult
+	}{
+		{
+			name: "full team",
+			members: team.TeamMembers{
+				{PlayerID: 1}, {PlayerID: 2}, {PlayerID: 3}, {PlayerID: 4},
+			},
+			cards: Scores{
+				card(1, 4, 4, 4, 4, 4, 4, 4, 4, 4),
+				card(2, 5, 5, 5, 5, 5, 5, 5, 5, 5),
+				card(3, 3, 3, 3, 3, 3, 3, 3, 3, 7),
+				card(4, 7, 7, 7, 7, 7, 7, 7, 7, 7),
+			},
+			total: 8*7 + 9,
+			ninth: HoleResult{Hole: 9, Score: 9, Counted: []int64{1, 2}},
+		},
+		{
+			name:    "short team gets ghosts",
+			members: team.TeamMembers{{PlayerID: 1}, {PlayerID: 2}},
+			cards: Scores{
+				card(1, 4, 4, 4, 4, 4, 4, 4, 4, 4),
+				card(2, 8, 8, 8, 8, 8, 8, 8, 8, 8),
+			},
+			ghosts: 2,
+			total:  9 * 10,
+			ninth:  HoleResult{Hole: 9, Score: 10, Counted: []int64{1, 0}},
+		},
+		{
+			name: "members without a card are replaced by ghosts",
+			members: team.TeamMembers{
+				{PlayerID: 1}, {PlayerID: 2}, {PlayerID: 3}, {PlayerID: 4},
+			},
+			cards: Scores{
+				card(1, 7, 7, 7, 7, 7, 7, 7, 7, 7),
+				card(2, 7, 7, 7, 7, 7, 7, 7, 7, 7),
+			},
+			ghosts: 2,
+			total:  9 * 12,
+			ninth:  HoleResult{Hole: 9, Score: 12, Counted: []int64{0, 0}},
+		},
+		{
+			name: "dropped ninth is left out",
+			members: team.TeamMembers{
+				{PlayerID: 1, NinthDropped: true}, {PlayerID: 2}, {PlayerID: 3}, {PlayerID: 4},
+			},
+			cards: Scores{
+				card(1, 3, 3, 3, 3, 3, 3, 3, 3, 3),
+				card(2, 5, 5, 5, 5, 5, 5, 5, 5, 5),
+				card(3, 5, 5, 5, 5, 5, 5, 5, 5, 5),
+				card(4, 5, 5, 5, 5, 5, 5, 5, 5, 5),
+			},
+			total: 8*8 + 10,
+			ninth: HoleResult{Hole: 9, Score: 10, Counted: []int64{2, 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := ScoreTeam(tm, tt.members, tt.cards, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(tr.Members) != rules.TeamSize {
+				t.Errorf("%d members, want %d", len(tr.Members), rules.TeamSize)
+			}
+			ghosts := 0
+			for _, m := range tr.Members {
+				if m.Ghost {
+					ghosts++
+				}
+			}
+			if ghosts != tt.ghosts {
+				t.Errorf("%d ghosts, want %d", ghosts, tt.ghosts)
+			}
+			if tr.Total != tt.total {
+				t.Errorf("total %d, want %d", tr.Total, tt.total)
+			}
+
+			got := tr.Holes[8]
+			if got.Score != tt.ninth.Score || len(got.Counted) != len(tt.ninth.Counted) {
+				t.Fatalf("ninth hole %+v, want %+v", got, tt.ninth)
+			}
+			for i := range got.Counted {
+				if got.Counted[i] != tt.ninth.Counted[i] {
+					t.Errorf("ninth hole counted %v, want %v", got.Counted, tt.ninth.Counted)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestScoreTeamTooManyMembers(t *testing.T) {
+	ms := team.TeamMembers{{PlayerID: 1}, {PlayerID: 2}, {PlayerID: 3}}
+	_, err := ScoreTeam(team.Team{ID: 1}, ms, nil, Rules{TeamSize: 2, BestBalls: 1, GhostScore: DefaultRules.GhostScore})
+	if err == nil {
+		t.Error("scored a team bigger than TeamSize")
+	}
+}
+
+func TestParseHoles(t *testing.T) {
+	tests := []struct {
+		s    string
+		want [9]int
+	}{
+		{"3", [9]int{3, 3, 3, 3, 3, 3, 3, 3, 3}},
+		{"3,3,4,3,3,3,3,4,3", [9]int{3, 3, 4, 3, 3, 3, 3, 4, 3}},
+		{" 4, 3,3,3,3,3,3,3,5 ", [9]int{4, 3, 3, 3, 3, 3, 3, 3, 5}},
+	}
+	for _, tt := range tests {
+		got, err := ParseHoles(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseHoles(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "three", "3,3", "3,3,3,3,3,3,3,3,3,3", "3,3,3,3,x,3,3,3,3"} {
+		if _, err := ParseHoles(s); err == nil {
+			t.Errorf("ParseHoles(%q) didn't fail", s)
+		}
+	}
+}
+
+func TestCoursePar(t *testing.T) {
+	if got := DefaultRules.CoursePar(); got != 27 {
+		t.Errorf("default course par %d, want 27", got)
+	}
+	r := Rules{Par: [9]int{3, 3, 4, 3, 3, 3, 3, 4, 3}}
+	if got := r.CoursePar(); got != 29 {
+		t.Errorf("course par %d, want 29", got)
+	}
+}
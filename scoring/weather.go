@@ -0,0 +1,193 @@
+package scoring
+
+import (
+	"context"
+	"mariners/db"
+	"mariners/player"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// WindBand is a range of wind speeds, from Min mph up to but not including
+// Max, or without an upper limit when Max is 0.
+type WindBand struct {
+	Name string  `json:"name"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+}
+
+// WindBands are the bands rounds are grouped into by the average wind
+// observed while they were played, calmest first.
+var WindBands = []WindBand{
+	{Name: "Calm", Min: 0, Max: 8},
+	{Name: "Breezy", Min: 8, Max: 15},
+	{Name: "Windy", Min: 15},
+}
+
+func (b WindBand) contains(mph float64) bool {
+	return mph >= b.Min && (b.Max == 0 || mph < b.Max)
+}
+
+// WindRound is the total for one player's card in one game, with the average
+// wind observed while the game was played.
+type WindRound struct {
+	PlayerID int64
+	GameID   int64
+	Date     time.Time
+	Total    int
+	Wind     float64
+}
+
+// BandResult is how the rounds played in a wind band went.  OverPar is the
+// average strokes over par for the course.
+type BandResult struct {
+	Band    WindBand `json:"band"`
+	Games   int      `json:"games"`
+	Rounds  int      `json:"rounds"`
+	OverPar float64  `json:"over_par"`
+}
+
+// PlayerWindResult compares a player's average on windy days, those in the
+// windiest band, with their average on calm days, those in the calmest.
+// Difference is how many more strokes they take when it's windy.
+type PlayerWindResult struct {
+	Player      player.Player `json:"player"`
+	Windy       float64       `json:"windy"`
+	WindyRounds int           `json:"windy_rounds"`
+	Calm        float64       `json:"calm"`
+	CalmRounds  int           `json:"calm_rounds"`
+	Difference  float64       `json:"difference"`
+}
+
+// WeatherStats are the league's scores set against the weather observed
+// while they were played.
+type WeatherStats struct {
+	Bands   []BandResult       `json:"bands"`
+	Players []PlayerWindResult `json:"players"`
+}
+
+// Rounds is how many rounds were played in observed weather.
+func (ws WeatherStats) Rounds() int {
+	n := 0
+	for _, b := range ws.Bands {
+		n += b.Rounds
+	}
+
+	return n
+}
+
+// round2 rounds f to hundredths, as averages are shown.
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// weatherStats works out the stats from rounds sorted most recent first, on a
+// course where par is par.  Only players with both windy and calm rounds are
+// compared, the ones the wind costs most first.
+func weatherStats(rs []WindRound, par int) WeatherStats {
+	ws := WeatherStats{
+		Bands:   make([]BandResult, len(WindBands)),
+		Players: make([]PlayerWindResult, 0),
+	}
+	if len(WindBands) == 0 {
+		return ws
+	}
+
+	type tally struct {
+		windyTotal, windyCount int
+		calmTotal, calmCount   int
+	}
+
+	overs := make([]int, len(WindBands))
+	games := make([]map[int64]bool, len(WindBands))
+	for i, b := range WindBands {
+		ws.Bands[i].Band = b
+		games[i] = make(map[int64]bool)
+	}
+
+	order := make([]int64, 0)
+	ts := make(map[int64]*tally)
+	for _, r := range rs {
+		for i, b := range WindBands {
+			if !b.contains(r.Wind) {
+				continue
+			}
+			ws.Bands[i].Rounds++
+			games[i][r.GameID] = true
+			overs[i] += r.Total - par
+		}
+
+		windy := WindBands[len(WindBands)-1].contains(r.Wind)
+		calm := WindBands[0].contains(r.Wind)
+		if !windy && !calm {
+			continue
+		}
+		t, ok := ts[r.PlayerID]
+		if !ok {
+			t = &tally{}
+			ts[r.PlayerID] = t
+			order = append(order, r.PlayerID)
+		}
+		if windy {
+			t.windyTotal += r.Total
+			t.windyCount++
+		}
+		if calm {
+			t.calmTotal += r.Total
+			t.calmCount++
+		}
+	}
+
+	for i := range ws.Bands {
+		ws.Bands[i].Games = len(games[i])
+		if ws.Bands[i].Rounds > 0 {
+			ws.Bands[i].OverPar = round2(float64(overs[i]) / float64(ws.Bands[i].Rounds))
+		}
+	}
+
+	for _, id := range order {
+		t := ts[id]
+		if t.windyCount == 0 || t.calmCount == 0 {
+			continue
+		}
+
+		var pw PlayerWindResult
+		pw.Player.ID = id
+		pw.Windy = round2(float64(t.windyTotal) / float64(t.windyCount))
+		pw.WindyRounds = t.windyCount
+		pw.Calm = round2(float64(t.calmTotal) / float64(t.calmCount))
+		pw.CalmRounds = t.calmCount
+		pw.Difference = round2(pw.Windy - pw.Calm)
+		ws.Players = append(ws.Players, pw)
+	}
+
+	sort.SliceStable(ws.Players, func(i, j int) bool {
+		return ws.Players[i].Difference > ws.Players[j].Difference
+	})
+
+	return ws
+}
+
+// GetWeatherStats sets every round played in observed weather against it,
+// counting strokes over rules.Par.
+func GetWeatherStats(ctx context.Context, rules Rules) (WeatherStats, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	rs, err := getStore().GetWindRounds(ctx)
+	if err != nil {
+		return weatherStats(nil, rules.CoursePar()), err
+	}
+
+	ws := weatherStats(rs, rules.CoursePar())
+	for i := range ws.Players {
+		err = ws.Players[i].Player.GetPlayerByID(ctx, ws.Players[i].Player.ID)
+		if err != nil {
+			log.Error().Msgf("GetWeatherStats: no player with id %d: %s", ws.Players[i].Player.ID, err)
+		}
+	}
+
+	return ws, nil
+}
@@ -0,0 +1,130 @@
+package scoring
+
+import "testing"
+
+func TestWeatherStatsEmpty(t *testing.T) {
+	ws := weatherStats(nil, 27)
+
+	if len(ws.Bands) != len(WindBands) {
+		t.Fatalf("%d bands, want %d", len(ws.Bands), len(WindBands))
+	}
+	for i, b := range ws.Bands {
+		if b.Band != WindBands[i] || b.Games != 0 || b.Rounds != 0 || b.OverPar != 0 {
+			t.Errorf("band %d is %+v, want %s and empty", i, b, WindBands[i].Name)
+		}
+	}
+	if ws.Players == nil || len(ws.Players) != 0 {
+		t.Errorf("players %v, want an empty list", ws.Players)
+	}
+	if ws.Rounds() != 0 {
+		t.Errorf("%d rounds, want none", ws.Rounds())
+	}
+}
+
+func TestWeatherStatsBands(t *testing.T) {
+	rs := []WindRound{
+		{PlayerID: 1, GameID: 3, Total: 30, Wind: 0},
+		{PlayerID: 2, GameID: 3, Total: 27, Wind: 7.9},
+		{PlayerID: 1, GameID: 2, Total: 33, Wind: 8},
+		{PlayerID: 2, GameID: 2, Total: 32, Wind: 14.99},
+		{PlayerID: 3, GameID: 2, Total: 34, Wind: 14.99},
+		{PlayerID: 1, GameID: 1, Total: 40, Wind: 15},
+	}
+
+	tests := []struct {
+		par  int
+		want []BandResult
+	}{
+		{27, []BandResult{
+			{Games: 1, Rounds: 2, OverPar: 1.5},
+			{Games: 1, Rounds: 3, OverPar: 6},
+			{Games: 1, Rounds: 1, OverPar: 13},
+		}},
+		{29, []BandResult{
+			{Games: 1, Rounds: 2, OverPar: -0.5},
+			{Games: 1, Rounds: 3, OverPar: 4},
+			{Games: 1, Rounds: 1, OverPar: 11},
+		}},
+	}
+	for _, tt := range tests {
+		ws := weatherStats(rs, tt.par)
+		for i, b := range ws.Bands {
+			want := tt.want[i]
+			if b.Games != want.Games || b.Rounds != want.Rounds || b.OverPar != want.OverPar {
+				t.Errorf("par %d: %s band %+v, want %+v", tt.par, b.Band.Name, b, want)
+			}
+		}
+		if ws.Rounds() != len(rs) {
+			t.Errorf("par %d: %d rounds, want %d", tt.par, ws.Rounds(), len(rs))
+		}
+	}
+}
+
+func TestWeatherStatsGamesCountedOnce(t *testing.T) {
+	rs := []WindRound{
+		{PlayerID: 1, GameID: 2, Total: 30, Wind: 3},
+		{PlayerID: 2, GameID: 2, Total: 30, Wind: 3},
+		{PlayerID: 3, GameID: 2, Total: 30, Wind: 3},
+		{PlayerID: 1, GameID: 1, Total: 30, Wind: 4},
+	}
+
+	calm := weatherStats(rs, 27).Bands[0]
+	if calm.Games != 2 || calm.Rounds != 4 {
+		t.Errorf("calm band has %d games and %d rounds, want 2 and 4", calm.Games, calm.Rounds)
+	}
+}
+
+func TestWeatherStatsPlayers(t *testing.T) {
+	rs := []WindRound{
+		// Player 1 takes 4.67 more strokes when it's windy.
+		{PlayerID: 1, GameID: 4, Total: 36, Wind: 20},
+		{PlayerID: 1, GameID: 3, Total: 30, Wind: 2},
+		{PlayerID: 1, GameID: 2, Total: 31, Wind: 2},
+		{PlayerID: 1, GameID: 1, Total: 31, Wind: 2},
+		// Player 2 takes one more, and player 4 too, and 2 played most
+		// recently.
+		{PlayerID: 2, GameID: 5, Total: 29, Wind: 16},
+		{PlayerID: 2, GameID: 3, Total: 28, Wind: 2},
+		{PlayerID: 4, GameID: 4, Total: 35, Wind: 20},
+		{PlayerID: 4, GameID: 3, Total: 34, Wind: 2},
+		// Player 3 does better in the wind.
+		{PlayerID: 3, GameID: 4, Total: 30, Wind: 20},
+		{PlayerID: 3, GameID: 3, Total: 33, Wind: 2},
+		// Player 5 hasn't played when it's windy, and breezy days don't
+		// count.
+		{PlayerID: 5, GameID: 2, Total: 40, Wind: 10},
+		{PlayerID: 5, GameID: 3, Total: 30, Wind: 2},
+		// Player 6 hasn't played when it's calm.
+		{PlayerID: 6, GameID: 4, Total: 40, Wind: 20},
+	}
+
+	want := []PlayerWindResult{
+		{Windy: 36, WindyRounds: 1, Calm: 30.67, CalmRounds: 3, Difference: 5.33},
+		{Windy: 29, WindyRounds: 1, Calm: 28, CalmRounds: 1, Difference: 1},
+		{Windy: 35, WindyRounds: 1, Calm: 34, CalmRounds: 1, Difference: 1},
+		{Windy: 30, WindyRounds: 1, Calm: 33, CalmRounds: 1, Difference: -3},
+	}
+	ids := []int64{1, 2, 4, 3}
+
+	ps := weatherStats(rs, 27).Players
+	if len(ps) != len(want) {
+		t.Fatalf("%d players compared, want %d: %+v", len(ps), len(want), ps)
+	}
+	for i, p := range ps {
+		w := want[i]
+		if p.Player.ID != ids[i] || p.Windy != w.Windy || p.WindyRounds != w.WindyRounds || p.Calm != w.Calm || p.CalmRounds != w.CalmRounds || p.Difference != w.Difference {
+			t.Errorf("%d: player %d %+v, want player %d %+v", i, p.Player.ID, p, ids[i], w)
+		}
+	}
+}
+
+func TestWeatherStatsNoBands(t *testing.T) {
+	old := WindBands
+	WindBands = nil
+	t.Cleanup(func() { WindBands = old })
+
+	ws := weatherStats([]WindRound{{PlayerID: 1, GameID: 1, Total: 30, Wind: 5}}, 27)
+	if len(ws.Bands) != 0 || len(ws.Players) != 0 {
+		t.Errorf("stats %+v without any bands, want none", ws)
+	}
+}
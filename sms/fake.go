@@ -0,0 +1,178 @@
+package sms
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Text is a message the FakeMessenger would have sent.  Texts to a topic are
+// recorded once per subscriber, with TopicARN set.
+type Text struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Phone    string    `json:"phone"`
+	TopicARN string    `json:"topic_arn,omitempty"`
+	Message  string    `json:"message"`
+}
+
+type Texts []Text
+
+// FakeMessenger records texts instead of sending them, for tests and local
+// development.  Topics and subscriptions are kept in memory so topic texts
+// reach the same phones they would through SNS.  Topics and subscriptions it
+// hasn't seen, such as ARNs in a copy of the production database, are treated
+// as empty rather than as errors.
+type FakeMessenger struct {
+	mu     sync.Mutex
+	path   string
+	next   int
+	texts  Texts
+	topics map[string]map[string]string
+}
+
+// NewFakeMessenger returns a FakeMessenger that also appends every text to
+// path as a line of JSON, unless path is empty.
+func NewFakeMessenger(path string) *FakeMessenger {
+	return &FakeMessenger{
+		path:   path,
+		topics: make(map[string]map[string]string),
+	}
+}
+
+// Texts returns everything sent so far.
+func (f *FakeMessenger) Texts() Texts {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ts := make(Texts, len(f.texts))
+	copy(ts, f.texts)
+
+	return ts
+}
+
+// TextsTo returns everything sent to phone so far.
+func (f *FakeMessenger) TextsTo(phone string) Texts {
+	ts := make(Texts, 0)
+	for _, t := range f.Texts() {
+		if t.Phone == phone {
+			ts = append(ts, t)
+		}
+	}
+
+	return ts
+}
+
+// Reset forgets the texts sent so far, keeping topics and subscriptions.
+func (f *FakeMessenger) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.texts = nil
+}
+
+func (f *FakeMessenger) id() string {
+	f.next++
+
+	return fmt.Sprintf("fake-%d", f.next)
+}
+
+func (f *FakeMessenger) record(t Text) error {
+	t.Time = time.Now()
+	f.texts = append(f.texts, t)
+
+	if f.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(b, '\n'))
+
+	return err
+}
+
+func (f *FakeMessenger) SendTextPhone(msg string, phone string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.id()
+	err := f.record(Text{ID: id, Phone: phone, Message: msg})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (f *FakeMessenger) SendTextTopic(msg string, topicARN string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.id()
+	for _, phone := range f.topics[topicARN] {
+		err := f.record(Text{ID: id, Phone: phone, TopicARN: topicARN, Message: msg})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return id, nil
+}
+
+func (f *FakeMessenger) SubscribeUser(phone string, topicARN string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	subs, ok := f.topics[topicARN]
+	if !ok {
+		subs = make(map[string]string)
+		f.topics[topicARN] = subs
+	}
+
+	sa := fmt.Sprintf("%s:%s", topicARN, f.id())
+	subs[sa] = phone
+
+	return sa, nil
+}
+
+func (f *FakeMessenger) RemoveSubscriber(subscriptionArn string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, subs := range f.topics {
+		delete(subs, subscriptionArn)
+	}
+
+	return nil
+}
+
+func (f *FakeMessenger) CreateTopic(topic string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	arn := fmt.Sprintf("arn:fake:sns:local:%s", topic)
+	if _, ok := f.topics[arn]; !ok {
+		f.topics[arn] = make(map[string]string)
+	}
+
+	return arn, nil
+}
+
+func (f *FakeMessenger) DeleteTopic(topicARN string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.topics, topicARN)
+
+	return nil
+}
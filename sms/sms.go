@@ -1,195 +1,154 @@
-// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
-// SPDX - License - Identifier: Apache - 2.0
 package sms
 
 import (
-	"context"
+	"fmt"
 	"log"
-
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/sns"
-	"github.com/aws/aws-sdk-go/aws"
+	"os"
+	"sync"
 )
 
 const MainTopicARN = "arn:aws:sns:us-east-1:939932615330:Mariners"
 
-// SNSPublishAPI defines the interface for the Publish function.
-// We use this interface to test the function using a mocked service.
-type SNSPublishAPI interface {
-	Publish(ctx context.Context,
-		params *sns.PublishInput,
-		optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+// Messenger is a provider that can text players and manage the topics they
+// are subscribed to.
+type Messenger interface {
+	SendTextPhone(msg string, phone string) (string, error)
+	SendTextTopic(msg string, topicARN string) (string, error)
+	SubscribeUser(phone string, topicARN string) (string, error)
+	RemoveSubscriber(subscriptionArn string) error
+	CreateTopic(topic string) (string, error)
+	DeleteTopic(topicARN string) error
 }
 
-// PublishMessage publishes a message to an Amazon Simple Notification Service (Amazon SNS) topic
-// Inputs:
-//
-//	c is the context of the method call, which includes the Region
-//	api is the interface that defines the method call
-//	input defines the input arguments to the service call.
-//
-// Output:
-//
-//	If success, a PublishOutput object containing the result of the service call and nil
-//	Otherwise, nil and an error from the call to Publish
-func PublishMessage(c context.Context, api SNSPublishAPI, input *sns.PublishInput) (*sns.PublishOutput, error) {
-	return api.Publish(c, input)
+var (
+	mu      sync.Mutex
+	current Messenger
+)
+
+// Configure picks the Messenger from the environment.  MPSMS is "sns" (the
+// default), "memory" to keep texts in memory, or "file" to also append them
+// to MPSMSFILE.  AWS_REGION sets the SNS region, us-east-1 by default.
+func Configure() error {
+	var m Messenger
+	var err error
+
+	switch getEnv("MPSMS", "sns") {
+	case "sns":
+		m, err = NewSNSMessenger(getEnv("AWS_REGION", "us-east-1"))
+		if err != nil {
+			return err
+		}
+	case "memory":
+		m = NewFakeMessenger("")
+	case "file":
+		m = NewFakeMessenger(getEnv("MPSMSFILE", "sms.log"))
+	default:
+		return fmt.Errorf("unknown MPSMS provider %s", os.Getenv("MPSMS"))
+	}
+
+	SetMessenger(m)
+
+	return nil
 }
 
-func SendTextPhone(msg string, phone string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		return "", err
-	}
-	cfg.Region = "us-east-1"
+// SetMessenger replaces the Messenger used by the package functions.
+func SetMessenger(m Messenger) {
+	mu.Lock()
+	defer mu.Unlock()
 
-	client := sns.NewFromConfig(cfg)
+	current = m
+}
 
-	input := &sns.PublishInput{
-		Message:     &msg,
-		PhoneNumber: &phone,
-	}
+// GetMessenger returns the Messenger in use, configuring one from the
+// environment the first time it's needed.
+func GetMessenger() (Messenger, error) {
+	mu.Lock()
+	m := current
+	mu.Unlock()
 
-	result, err := PublishMessage(context.TODO(), client, input)
-	if err != nil {
-		return "", err
+	if m != nil {
+		return m, nil
 	}
 
-	return *result.MessageId, nil
-}
-
-func SendTextTopic(msg string, topicARN string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	err := Configure()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	cfg.Region = "us-east-1"
 
-	client := sns.NewFromConfig(cfg)
+	mu.Lock()
+	defer mu.Unlock()
 
-	input := &sns.PublishInput{
-		Message:  &msg,
-		TopicArn: &topicARN,
-	}
+	return current, nil
+}
 
-	result, err := PublishMessage(context.TODO(), client, input)
+func SendTextPhone(msg string, phone string) (string, error) {
+	m, err := GetMessenger()
 	if err != nil {
 		return "", err
 	}
 
-	return *result.MessageId, nil
+	return m.SendTextPhone(msg, phone)
 }
 
-func SubscribeUser(phone string, topicARN string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+func SendTextTopic(msg string, topicARN string) (string, error) {
+	m, err := GetMessenger()
 	if err != nil {
 		return "", err
 	}
-	cfg.Region = "us-east-1"
-
-	client := sns.NewFromConfig(cfg)
 
-	input := &sns.SubscribeInput{
-		Endpoint:              &phone,
-		Protocol:              aws.String("sms"),
-		ReturnSubscriptionArn: true,
-		TopicArn:              aws.String(topicARN),
-	}
+	return m.SendTextTopic(msg, topicARN)
+}
 
-	result, err := client.Subscribe(context.TODO(), input)
+func SubscribeUser(phone string, topicARN string) (string, error) {
+	m, err := GetMessenger()
 	if err != nil {
 		return "", err
 	}
 
-	return *result.SubscriptionArn, nil
+	return m.SubscribeUser(phone, topicARN)
 }
 
 func RemoveSubscriber(subscriptionArn string) error {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	m, err := GetMessenger()
 	if err != nil {
 		return err
 	}
-	cfg.Region = "us-east-1"
-
-	client := sns.NewFromConfig(cfg)
 
-	input := &sns.UnsubscribeInput{
-		SubscriptionArn: &subscriptionArn,
-	}
-
-	_, err = client.Unsubscribe(context.TODO(), input)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return m.RemoveSubscriber(subscriptionArn)
 }
 
 func ConfirmSubscribeUser(phone string, topicARN string) error {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		return err
-	}
-	cfg.Region = "us-east-1"
-
-	client := sns.NewFromConfig(cfg)
-
-	input := &sns.SubscribeInput{
-		Endpoint:              &phone,
-		Protocol:              aws.String("sms"),
-		ReturnSubscriptionArn: true,
-		TopicArn:              aws.String(topicARN),
-	}
-
-	result, err := client.Subscribe(context.TODO(), input)
+	sa, err := SubscribeUser(phone, topicARN)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Subscription ARN: " + *result.SubscriptionArn)
+	log.Println("Subscription ARN: " + sa)
 
 	return nil
 }
 
 func CreateTopic(topic string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	m, err := GetMessenger()
 	if err != nil {
 		return "", err
 	}
-	cfg.Region = "us-east-1"
-
-	client := sns.NewFromConfig(cfg)
 
-	input := &sns.CreateTopicInput{
-		Name:       &topic,
-		Attributes: map[string]string{"DisplayName": topic},
-	}
-
-	result, err := client.CreateTopic(context.TODO(), input)
-	if err != nil {
-		return "", err
-	}
-
-	return *result.TopicArn, nil
+	return m.CreateTopic(topic)
 }
 
 func DeleteTopic(topicARN string) error {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	m, err := GetMessenger()
 	if err != nil {
 		return err
 	}
-	cfg.Region = "us-east-1"
-
-	client := sns.NewFromConfig(cfg)
 
-	input := &sns.DeleteTopicInput{
-		TopicArn: &topicARN,
-	}
+	return m.DeleteTopic(topicARN)
+}
 
-	_, err = client.DeleteTopic(context.TODO(), input)
-	if err != nil {
-		return err
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
 	}
-
-	return nil
+	return fallback
 }
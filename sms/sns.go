@@ -0,0 +1,154 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX - License - Identifier: Apache - 2.0
+package sms
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// SNSPublishAPI defines the interface for the Publish function.
+// We use this interface to test the function using a mocked service.
+type SNSPublishAPI interface {
+	Publish(ctx context.Context,
+		params *sns.PublishInput,
+		optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// PublishMessage publishes a message to an Amazon Simple Notification Service (Amazon SNS) topic
+// Inputs:
+//
+//	c is the context of the method call, which includes the Region
+//	api is the interface that defines the method call
+//	input defines the input arguments to the service call.
+//
+// Output:
+//
+//	If success, a PublishOutput object containing the result of the service call and nil
+//	Otherwise, nil and an error from the call to Publish
+func PublishMessage(c context.Context, api SNSPublishAPI, input *sns.PublishInput) (*sns.PublishOutput, error) {
+	return api.Publish(c, input)
+}
+
+// SNSMessenger sends texts through Amazon SNS.
+type SNSMessenger struct {
+	client *sns.Client
+}
+
+// NewSNSMessenger loads the default AWS config once for region.
+func NewSNSMessenger(region string) (*SNSMessenger, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	cfg.Region = region
+
+	return &SNSMessenger{client: sns.NewFromConfig(cfg)}, nil
+}
+
+func (m *SNSMessenger) SendTextPhone(msg string, phone string) (string, error) {
+	input := &sns.PublishInput{
+		Message:     &msg,
+		PhoneNumber: &phone,
+	}
+
+	result, err := PublishMessage(context.TODO(), m.client, input)
+	if err != nil {
+		return "", err
+	}
+
+	return *result.MessageId, nil
+}
+
+func (m *SNSMessenger) SendTextTopic(msg string, topicARN string) (string, error) {
+	input := &sns.PublishInput{
+		Message:  &msg,
+		TopicArn: &topicARN,
+	}
+
+	result, err := PublishMessage(context.TODO(), m.client, input)
+	if err != nil {
+		return "", err
+	}
+
+	return *result.MessageId, nil
+}
+
+func (m *SNSMessenger) SubscribeUser(phone string, topicARN string) (string, error) {
+	input := &sns.SubscribeInput{
+		Endpoint:              &phone,
+		Protocol:              aws.String("sms"),
+		ReturnSubscriptionArn: true,
+		TopicArn:              aws.String(topicARN),
+	}
+
+	result, err := m.client.Subscribe(context.TODO(), input)
+	if err != nil {
+		return "", err
+	}
+
+	return *result.SubscriptionArn, nil
+}
+
+func (m *SNSMessenger) RemoveSubscriber(subscriptionArn string) error {
+	input := &sns.UnsubscribeInput{
+		SubscriptionArn: &subscriptionArn,
+	}
+
+	_, err := m.client.Unsubscribe(context.TODO(), input)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *SNSMessenger) CreateTopic(topic string) (string, error) {
+	input := &sns.CreateTopicInput{
+		Name:       &topic,
+		Attributes: map[string]string{"DisplayName": topic},
+	}
+
+	result, err := m.client.CreateTopic(context.TODO(), input)
+	if err != nil {
+		return "", err
+	}
+
+	return *result.TopicArn, nil
+}
+
+func (m *SNSMessenger) DeleteTopic(topicARN string) error {
+	input := &sns.DeleteTopicInput{
+		TopicArn: &topicARN,
+	}
+
+	_, err := m.client.DeleteTopic(context.TODO(), input)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// permanentCodes are SNS error codes that won't go away by sending again.
+var permanentCodes = map[string]bool{
+	"InvalidParameter":      true,
+	"InvalidParameterValue": true,
+	"AuthorizationError":    true,
+	"NotFound":              true,
+	"OptedOut":              true,
+}
+
+// Permanent reports whether a failed send should not be retried.
+func Permanent(err error) bool {
+	var ae interface{ ErrorCode() string }
+	if errors.As(err, &ae) {
+		return permanentCodes[ae.ErrorCode()]
+	}
+
+	return false
+}
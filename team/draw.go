@@ -0,0 +1,129 @@
+package team
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"mariners/db"
+	"math/big"
+	"sort"
+)
+
+var ErrScoresEntered = errors.New("scores have already been entered for this game")
+
+// Draw splits the players in pids into teams of size, filling the short teams
+// with ghosts.  The order is random, but when seeds are given (lower is
+// better, such as a scoring average) players are handed out in a snake draft
+// so the teams come out even.  Players without a seed are drafted last.
+func Draw(pids []int64, size int, seeds map[int64]float64) ([]TeamMembers, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("team size %d: teams need at least one player", size)
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no players to draw")
+	}
+
+	ps := make([]int64, len(pids))
+	copy(ps, pids)
+	for i := len(ps) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		j := int(n.Int64())
+		ps[i], ps[j] = ps[j], ps[i]
+	}
+
+	if len(seeds) > 0 {
+		sort.SliceStable(ps, func(i, j int) bool {
+			si, iok := seeds[ps[i]]
+			sj, jok := seeds[ps[j]]
+			if iok != jok {
+				return iok
+			}
+			return si < sj
+		})
+	}
+
+	count := (len(ps) + size - 1) / size
+	ts := make([]TeamMembers, count)
+	for i, pid := range ps {
+		round := i / count
+		t := i % count
+		if round%2 == 1 {
+			t = count - 1 - t
+		}
+		ts[t] = append(ts[t], TeamMember{PlayerID: pid})
+	}
+
+	for t := range ts {
+		for len(ts[t]) < size {
+			ts[t] = append(ts[t], TeamMember{Ghost: true})
+		}
+	}
+
+	return ts, nil
+}
+
+// SaveDraw replaces the teams for game gid with the drawn teams, keeping the
+// old teams if any of the new ones can't be saved.  Teams can't be redrawn
+// once scores have been entered against them.
+func SaveDraw(ctx context.Context, gid int64, draw []TeamMembers) (Teams, error) {
+	ts := make(Teams, 0)
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err := db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		err := deleteTeams(ctx, gid)
+		if err != nil {
+			return err
+		}
+
+		for _, ms := range draw {
+			t := Team{}
+			err = getStore().AddTeam(ctx, gid, &t)
+			if err != nil {
+				return err
+			}
+			for _, m := range ms {
+				m.TeamID = t.ID
+				err = getStore().AddTeamMember(ctx, &m)
+				if err != nil {
+					return err
+				}
+			}
+			ts = append(ts, t)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return make(Teams, 0), err
+	}
+
+	return ts, nil
+}
+
+// DeleteTeams removes every team, and its members, from game gid, unless
+// scores have been entered against them.
+func DeleteTeams(ctx context.Context, gid int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return db.InTx(ctx, db.Con, func(ctx context.Context) error {
+		return deleteTeams(ctx, gid)
+	})
+}
+
+func deleteTeams(ctx context.Context, gid int64) error {
+	count, err := getStore().CountScores(ctx, gid)
+	if err != nil {
+		return err
+	}
+	if count != 0 {
+		return ErrScoresEntered
+	}
+
+	return getStore().DeleteTeams(ctx, gid)
+}
@@ -0,0 +1,152 @@
+package team
+
+import (
+	"context"
+	"database/sql"
+	"mariners/db"
+)
+
+// TeamStore loads and saves the teams drawn for each game and who is on them.
+type TeamStore interface {
+	AddTeam(ctx context.Context, gid int64, t *Team) error
+	GetTeam(ctx context.Context, id int64) (Team, error)
+	GetTeamsByGameID(ctx context.Context, gid int64) (Teams, error)
+	GetTeamByPlayer(ctx context.Context, gid int64, pid int64) (Team, error)
+	DeleteTeams(ctx context.Context, gid int64) error
+	AddTeamMember(ctx context.Context, m *TeamMember) error
+	GetTeamMembers(ctx context.Context, tid int64) (TeamMembers, error)
+	// CountScores returns how many scores have been entered against the
+	// teams for game gid.
+	CountScores(ctx context.Context, gid int64) (int64, error)
+}
+
+// SQLTeamStore is a TeamStore backed by the team and team_members tables.
+type SQLTeamStore struct {
+	DB *sql.DB
+}
+
+func NewSQLTeamStore(con *sql.DB) *SQLTeamStore {
+	return &SQLTeamStore{DB: con}
+}
+
+var store TeamStore
+
+// SetStore replaces the TeamStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s TeamStore) {
+	store = s
+}
+
+func getStore() TeamStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLTeamStore(db.Con)
+}
+
+func (s *SQLTeamStore) AddTeam(ctx context.Context, gid int64, t *Team) error {
+	query := "INSERT INTO team (idteam, idgame) VALUES (NULL, ?)"
+
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, gid)
+	if err != nil {
+		return err
+	}
+
+	t.ID, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	t.GameID = gid
+
+	return nil
+}
+
+func (s *SQLTeamStore) GetTeam(ctx context.Context, id int64) (Team, error) {
+	t := Team{}
+
+	query := "SELECT idteam, idgame FROM team WHERE idteam=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, id).Scan(&t.ID, &t.GameID)
+
+	return t, err
+}
+
+func (s *SQLTeamStore) GetTeamsByGameID(ctx context.Context, gid int64) (Teams, error) {
+	ts := make(Teams, 0)
+
+	query := "SELECT idteam, idgame FROM team WHERE idgame=?"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, gid)
+	if err != nil {
+		return ts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t Team
+		if err := rows.Scan(&t.ID, &t.GameID); err != nil {
+			return ts, err
+		}
+		ts = append(ts, t)
+	}
+
+	return ts, rows.Err()
+}
+
+func (s *SQLTeamStore) GetTeamByPlayer(ctx context.Context, gid int64, pid int64) (Team, error) {
+	t := Team{}
+
+	query := "SELECT t.idteam, t.idgame FROM team t JOIN team_members m ON m.idteam = t.idteam WHERE t.idgame=? AND m.idplayer=? AND m.ghost=0"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, gid, pid).Scan(&t.ID, &t.GameID)
+
+	return t, err
+}
+
+func (s *SQLTeamStore) DeleteTeams(ctx context.Context, gid int64) error {
+	query := "DELETE FROM team_members WHERE idteam IN (SELECT idteam FROM team WHERE idgame=?)"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, gid)
+	if err != nil {
+		return err
+	}
+
+	query = "DELETE FROM team WHERE idgame=?"
+	_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, gid)
+
+	return err
+}
+
+func (s *SQLTeamStore) AddTeamMember(ctx context.Context, m *TeamMember) error {
+	query := "INSERT INTO team_members (idteam, idplayer, ghost, ninth_dropped) VALUES (?, ?, ?, ?)"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, m.TeamID, m.PlayerID, m.Ghost, m.NinthDropped)
+
+	return err
+}
+
+func (s *SQLTeamStore) GetTeamMembers(ctx context.Context, tid int64) (TeamMembers, error) {
+	ms := make(TeamMembers, 0)
+
+	query := "SELECT idteam, idplayer, ghost, ninth_dropped FROM team_members WHERE idteam=?"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, tid)
+	if err != nil {
+		return ms, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m TeamMember
+		if err := rows.Scan(&m.TeamID, &m.PlayerID, &m.Ghost, &m.NinthDropped); err != nil {
+			return ms, err
+		}
+		ms = append(ms, m)
+	}
+
+	return ms, rows.Err()
+}
+
+func (s *SQLTeamStore) CountScores(ctx context.Context, gid int64) (int64, error) {
+	var count int64
+
+	query := "SELECT COUNT(*) FROM score INNER JOIN team ON score.idteam=team.idteam WHERE team.idgame=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, gid).Scan(&count)
+
+	return count, err
+}
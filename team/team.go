@@ -2,9 +2,7 @@ package team
 
 import (
 	"context"
-	"fmt"
 	"mariners/db"
-	"time"
 )
 
 type Team struct {
@@ -18,56 +16,59 @@ type TeamMember struct {
 	NinthDropped bool  `json:"ninth_dropped"`
 }
 
-func AddTeam(gid int64, t *Team) error {
-	err := writeTeam(gid, t)
-	if err != nil {
-		return err
-	}
+type Teams []Team
 
-	return nil
-}
+type TeamMembers []TeamMember
 
-func GetTeam(id int64, t *Team) error {
-	err := getTeam(id, t)
-	if err != nil {
-		return err
-	}
+func AddTeam(ctx context.Context, gid int64, t *Team) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
 
-	return nil
+	return getStore().AddTeam(ctx, gid, t)
 }
 
-func getTeam(id int64, t *Team) error {
-	query := "SELECT idteam, idgame FROM team WHERE idteam=?"
-
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+func GetTeam(ctx context.Context, id int64, t *Team) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query, id).Scan(&t.ID, &t.GameID)
+	nt, err := getStore().GetTeam(ctx, id)
 	if err != nil {
 		return err
 	}
+	*t = nt
 
 	return nil
 }
 
-func writeTeam(gid int64, t *Team) error {
-	query := fmt.Sprintf("INSERT INTO team (idteam, idgame) VALUES (NULL, %d);\n", gid)
+func GetTeamsByGameID(ctx context.Context, gid int64) (Teams, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetTeamsByGameID(ctx, gid)
+}
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
+func GetTeamMembers(ctx context.Context, tid int64) (TeamMembers, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
 	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
-	if err != nil {
-		return err
-	}
 
-	t.ID, err = res.LastInsertId()
-	if err != nil {
-		return err
-	}
+	return getStore().GetTeamMembers(ctx, tid)
+}
 
-	_, err = res.RowsAffected()
+// GetTeamByPlayer loads the team player pid was drawn onto for game gid.
+func GetTeamByPlayer(ctx context.Context, gid int64, pid int64, t *Team) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	nt, err := getStore().GetTeamByPlayer(ctx, gid, pid)
 	if err != nil {
 		return err
 	}
+	*t = nt
 
 	return nil
 }
+
+func AddTeamMember(ctx context.Context, m *TeamMember) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().AddTeamMember(ctx, m)
+}
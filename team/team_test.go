@@ -0,0 +1,203 @@
+package team
+
+import (
+	"context"
+	"errors"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"testing"
+)
+
+func openDB(t *testing.T) {
+	t.Helper()
+
+	dbtest.Open(t)
+	SetStore(nil)
+}
+
+func TestDraw(t *testing.T) {
+	pids := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	ts, err := Draw(pids, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 3 {
+		t.Fatalf("%d teams, want 3", len(ts))
+	}
+	seen := make(map[int64]bool)
+	ghosts := 0
+	for _, ms := range ts {
+		if len(ms) != 4 {
+			t.Errorf("team of %d, want 4", len(ms))
+		}
+		for _, m := range ms {
+			if m.Ghost {
+				ghosts++
+				continue
+			}
+			if seen[m.PlayerID] {
+				t.Errorf("player %d drawn twice", m.PlayerID)
+			}
+			seen[m.PlayerID] = true
+		}
+	}
+	if len(seen) != len(pids) || ghosts != 2 {
+		t.Errorf("drew %d players and %d ghosts, want %d and 2", len(seen), ghosts, len(pids))
+	}
+
+	if _, err = Draw(pids, 0, nil); err == nil {
+		t.Error("drew teams of 0")
+	}
+	if _, err = Draw(nil, 4, nil); err == nil {
+		t.Error("drew teams from nobody")
+	}
+}
+
+func TestDrawSeeded(t *testing.T) {
+	seeds := map[int64]float64{1: 36, 2: 38, 3: 40, 4: 42}
+
+	ts, err := Draw([]int64{4, 3, 2, 1}, 2, seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A snake draft puts the best and worst together.
+	for _, ms := range ts {
+		sum := seeds[ms[0].PlayerID] + seeds[ms[1].PlayerID]
+		if sum != 78 {
+			t.Errorf("team %v has seeds adding to %v, want 78", ms, sum)
+		}
+	}
+}
+
+func TestSaveDraw(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	draw := []TeamMembers{
+		{{PlayerID: 1}, {PlayerID: 2, NinthDropped: true}},
+		{{PlayerID: 3}, {Ghost: true}},
+	}
+	ts, err := SaveDraw(ctx, 7, draw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetTeamsByGameID(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("%d teams saved, want 2", len(got))
+	}
+	for i, tm := range got {
+		if tm.ID != ts[i].ID || tm.GameID != 7 {
+			t.Errorf("team %d is %+v, want %+v in game 7", i, tm, ts[i])
+		}
+		ms, err := GetTeamMembers(ctx, tm.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ms) != len(draw[i]) {
+			t.Fatalf("team %d has %d members, want %d", i, len(ms), len(draw[i]))
+		}
+		for j, m := range ms {
+			want := draw[i][j]
+			want.TeamID = tm.ID
+			if m != want {
+				t.Errorf("member %d of team %d is %+v, want %+v", j, i, m, want)
+			}
+		}
+	}
+
+	var tm Team
+	err = GetTeamByPlayer(ctx, 7, 3, &tm)
+	if err != nil || tm.ID != ts[1].ID {
+		t.Errorf("player 3 is on team %d, %v, want %d", tm.ID, err, ts[1].ID)
+	}
+
+	// Redrawing replaces the teams.
+	_, err = SaveDraw(ctx, 7, draw[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = GetTeamsByGameID(ctx, 7)
+	if err != nil || len(got) != 1 {
+		t.Errorf("after redrawing there are %d teams, %v, want 1", len(got), err)
+	}
+}
+
+func TestDeleteTeamsWithScores(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	ts, err := SaveDraw(ctx, 7, []TeamMembers{{{PlayerID: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Con.Exec("INSERT INTO score (idplayer, idteam, first, second, third, fourth, fifth, sixth, seventh, eighth, ninth) VALUES (1, ?, 4, 4, 4, 4, 4, 4, 4, 4, 4)", ts[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = DeleteTeams(ctx, 7)
+	if !errors.Is(err, ErrScoresEntered) {
+		t.Errorf("deleting scored teams = %v, want ErrScoresEntered", err)
+	}
+	_, err = SaveDraw(ctx, 7, []TeamMembers{{{PlayerID: 2}}})
+	if !errors.Is(err, ErrScoresEntered) {
+		t.Errorf("redrawing scored teams = %v, want ErrScoresEntered", err)
+	}
+}
+
+// failingStore fails to add team members after ok of them.
+type failingStore struct {
+	TeamStore
+	ok int
+}
+
+func (s *failingStore) AddTeamMember(ctx context.Context, m *TeamMember) error {
+	if s.ok == 0 {
+		return errors.New("disk full")
+	}
+	s.ok--
+
+	return s.TeamStore.AddTeamMember(ctx, m)
+}
+
+func TestSaveDrawFailureKeepsOldTeams(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	old, err := SaveDraw(ctx, 7, []TeamMembers{{{PlayerID: 1}, {PlayerID: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetStore(&failingStore{TeamStore: NewSQLTeamStore(db.Con), ok: 3})
+	_, err = SaveDraw(ctx, 7, []TeamMembers{
+		{{PlayerID: 3}, {PlayerID: 4}},
+		{{PlayerID: 5}, {PlayerID: 6}},
+	})
+
+	SetStore(nil)
+	if err == nil {
+		t.Fatal("saving the draw didn't fail")
+	}
+
+	got, err := GetTeamsByGameID(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != old[0].ID {
+		t.Fatalf("after a failed save the teams are %+v, want %+v", got, old)
+	}
+	ms, err := GetTeamMembers(ctx, got[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || ms[0].PlayerID != 1 || ms[1].PlayerID != 2 {
+		t.Errorf("after a failed save the members are %+v, want players 1 and 2", ms)
+	}
+}
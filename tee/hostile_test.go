@@ -0,0 +1,57 @@
+package tee
+
+import (
+	"context"
+	"mariners/db/dbtest"
+	"testing"
+)
+
+func TestTeeHostileStrings(t *testing.T) {
+	ctx := context.Background()
+	dbtest.Open(t)
+	SetStore(nil)
+
+	for i, h := range dbtest.Hostile {
+		te := Tee{Name: h}
+		err := te.AddTee(ctx)
+		if err != nil {
+			t.Fatalf("adding %q: %s", h, err)
+		}
+		checkTee(t, "added", te.ID, h)
+
+		u := dbtest.Hostile[(i+1)%len(dbtest.Hostile)]
+		te.Name = u
+		err = te.UpdateTee(ctx)
+		if err != nil {
+			t.Fatalf("renaming to %q: %s", u, err)
+		}
+		checkTee(t, "renamed", te.ID, u)
+
+		err = te.DeleteTee(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkTee(t *testing.T, how string, id int64, want string) {
+	t.Helper()
+
+	ctx := context.Background()
+	got := Tee{}
+	err := got.GetTeeByID(ctx, id)
+	if err != nil || got.Name != want {
+		t.Errorf("%s tee read back as %q, %v, want %q", how, got.Name, err, want)
+	}
+
+	byName := Tee{}
+	err = byName.GetTeeByName(ctx, want)
+	if err != nil || byName.ID != id {
+		t.Errorf("%s: looking up %q found %d, %v, want %d", how, want, byName.ID, err, id)
+	}
+
+	ts, err := GetTees(ctx)
+	if err != nil || len(ts) != 1 || ts[0].Name != want {
+		t.Errorf("%s tees listed as %+v, %v, want just %q", how, ts, err, want)
+	}
+}
@@ -2,9 +2,9 @@ package tee
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
+	"errors"
 	"mariners/db"
-	"time"
 )
 
 type Tee struct {
@@ -14,12 +14,50 @@ type Tee struct {
 
 type Tees []Tee
 
-func (t *Tee) AddTee() error {
-	query := fmt.Sprintf("INSERT INTO ninthtee VALUES (null, \"%s\");", t.Name)
+// ErrTeeInUse is returned when deleting a tee a game has been played from.
+var ErrTeeInUse = errors.New("tee has been used for a game")
+
+// TeeStore loads and saves ninth tees.
+type TeeStore interface {
+	AddTee(ctx context.Context, t *Tee) error
+	GetTee(ctx context.Context, id int64) (Tee, error)
+	GetTeeByName(ctx context.Context, name string) (Tee, error)
+	GetTees(ctx context.Context) (Tees, error)
+	UpdateTee(ctx context.Context, t *Tee) error
+	// DeleteTee returns ErrTeeInUse if a game has been played from the
+	// tee.
+	DeleteTee(ctx context.Context, id int64) error
+}
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	res, err := db.Con.ExecContext(ctx, query)
+// SQLTeeStore is a TeeStore backed by the ninthtee table.
+type SQLTeeStore struct {
+	DB *sql.DB
+}
+
+func NewSQLTeeStore(con *sql.DB) *SQLTeeStore {
+	return &SQLTeeStore{DB: con}
+}
+
+var store TeeStore
+
+// SetStore replaces the TeeStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s TeeStore) {
+	store = s
+}
+
+func getStore() TeeStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLTeeStore(db.Con)
+}
+
+func (s *SQLTeeStore) AddTee(ctx context.Context, t *Tee) error {
+	query := "INSERT INTO ninthtee (idninthtee, name) VALUES (NULL, ?)"
+
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, t.Name)
 	if err != nil {
 		return err
 	}
@@ -32,42 +70,33 @@ func (t *Tee) AddTee() error {
 	return nil
 }
 
-func (t *Tee) GetTeeByID(id int64) error {
-	query := fmt.Sprintf("SELECT idninthtee, name FROM ninthtee WHERE idninthtee=%d", id)
+func (s *SQLTeeStore) GetTee(ctx context.Context, id int64) (Tee, error) {
+	t := Tee{}
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(&t.ID, &t.Name)
-	if err != nil {
-		return err
-	}
+	query := "SELECT idninthtee, name FROM ninthtee WHERE idninthtee=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, id).Scan(&t.ID, &t.Name)
 
-	return nil
+	return t, err
 }
 
-func (t *Tee) GetTeeByName(name string) error {
-	query := fmt.Sprintf("SELECT idninthtee, name FROM ninthtee WHERE name=\"%s\"", name)
+func (s *SQLTeeStore) GetTeeByName(ctx context.Context, name string) (Tee, error) {
+	t := Tee{}
 
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(&t.ID, &t.Name)
-	if err != nil {
-		return err
-	}
+	query := "SELECT idninthtee, name FROM ninthtee WHERE name=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, name).Scan(&t.ID, &t.Name)
 
-	return nil
+	return t, err
 }
 
-func GetTees() (Tees, error) {
+func (s *SQLTeeStore) GetTees(ctx context.Context) (Tees, error) {
 	ts := make(Tees, 0)
 
 	query := "SELECT idninthtee, name FROM ninthtee"
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query)
 	if err != nil {
 		return ts, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var t Tee
@@ -77,5 +106,86 @@ func GetTees() (Tees, error) {
 		ts = append(ts, t)
 	}
 
-	return ts, nil
+	return ts, rows.Err()
+}
+
+func (s *SQLTeeStore) UpdateTee(ctx context.Context, t *Tee) error {
+	query := "UPDATE ninthtee SET name=? WHERE idninthtee=?"
+	_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, t.Name, t.ID)
+
+	return err
+}
+
+func (s *SQLTeeStore) DeleteTee(ctx context.Context, id int64) error {
+	var count int64
+
+	query := "SELECT COUNT(*) FROM game WHERE idninthtee=?"
+	err := db.Q(ctx, s.DB).QueryRowContext(ctx, query, id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != 0 {
+		return ErrTeeInUse
+	}
+
+	query = "DELETE FROM ninthtee WHERE idninthtee=?"
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return db.OneRow(res)
+}
+
+func (t *Tee) AddTee(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().AddTee(ctx, t)
+}
+
+func (t *Tee) GetTeeByID(ctx context.Context, id int64) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	nt, err := getStore().GetTee(ctx, id)
+	if err != nil {
+		return err
+	}
+	*t = nt
+
+	return nil
+}
+
+func (t *Tee) GetTeeByName(ctx context.Context, name string) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	nt, err := getStore().GetTeeByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	*t = nt
+
+	return nil
+}
+
+func GetTees(ctx context.Context) (Tees, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetTees(ctx)
+}
+
+func (t *Tee) UpdateTee(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().UpdateTee(ctx, t)
+}
+
+// DeleteTee removes the tee, unless a game has been played from it.
+func (t *Tee) DeleteTee(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().DeleteTee(ctx, t.ID)
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"mariners/db/dbtest"
+	"mariners/player"
+	"mariners/role"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// seededRoles returns a player holding each role the migrations add, with
+// the permissions it grants.
+func seededRoles(t *testing.T) map[string]player.Player {
+	t.Helper()
+
+	ctx := context.Background()
+	dbtest.Open(t)
+	role.SetStore(nil)
+
+	m, err := role.GetMatrix(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := role.GetRoles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps := make(map[string]player.Player)
+	for id, name := range rs {
+		ps[name] = player.Player{ID: 100 + id, PreferredName: name, Roles: role.Roles{id: name}, Permissions: m[id]}
+	}
+
+	return ps
+}
+
+// permitted reports whether h let user through to the handler it wraps.
+func permitted(h pageHandler, r *http.Request, user player.Player) bool {
+	w := httptest.NewRecorder()
+	h(w, r, "test", user)
+
+	return w.Code == http.StatusTeapot
+}
+
+func teapot(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	w.WriteHeader(http.StatusTeapot)
+}
+
+func TestPermit(t *testing.T) {
+	ps := seededRoles(t)
+	want := map[string][]string{
+		"Administrator":  {role.PlayersWrite, role.RolesManage, role.EventsManage, role.MessagesSend, role.GamesManage, role.SiteAdmin},
+		"User":           nil,
+		"Game Manager":   {role.GamesManage},
+		"Communications": {role.MessagesSend},
+		"Tournament":     nil,
+	}
+
+	for name, perms := range want {
+		user, ok := ps[name]
+		if !ok {
+			t.Fatalf("no %s role", name)
+		}
+		for _, p := range role.Permissions {
+			allowed := false
+			for _, wp := range perms {
+				allowed = allowed || wp == p.Name
+			}
+			r := httptest.NewRequest("GET", "/cache", nil)
+			if got := permitted(permit(p.Name, nil, teapot), r, user); got != allowed {
+				t.Errorf("%s through %s: %t, want %t", name, p.Name, got, allowed)
+			}
+		}
+	}
+}
+
+func TestPermitOwn(t *testing.T) {
+	ps := seededRoles(t)
+	user, gm := ps["User"], ps["Game Manager"]
+
+	card := func(pid int64) *http.Request {
+		r := httptest.NewRequest("DELETE", "/form/delscore/1/"+strconv.FormatInt(pid, 10), nil)
+		return mux.SetURLVars(r, map[string]string{"id": "1", "pid": strconv.FormatInt(pid, 10)})
+	}
+	score := func(pid int64) *http.Request {
+		form := url.Values{"player": {strconv.FormatInt(pid, 10)}}
+		r := httptest.NewRequest("POST", "/form/postscore/1", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	tests := []struct {
+		name string
+		own  ownFunc
+		r    *http.Request
+		user player.Player
+		want bool
+	}{
+		{"own card", ownCard, card(user.ID), user, true},
+		{"another's card", ownCard, card(gm.ID), user, false},
+		{"another's card as Game Manager", ownCard, card(user.ID), gm, true},
+		{"own score", ownScore, score(user.ID), user, true},
+		{"another's score", ownScore, score(gm.ID), user, false},
+		{"another's score as Game Manager", ownScore, score(user.ID), gm, true},
+		{"no owner", nil, card(user.ID), user, false},
+	}
+	for _, tt := range tests {
+		if got := permitted(permit(role.GamesManage, tt.own, teapot), tt.r, tt.user); got != tt.want {
+			t.Errorf("%s: %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRoles(t *testing.T) {
+	ps := seededRoles(t)
+	id := func(name string) int64 {
+		for k := range ps[name].Roles {
+			return k
+		}
+		t.Fatalf("no %s role", name)
+		return 0
+	}
+	user := role.Roles{id("User"): "User"}
+	withGM := role.Roles{id("User"): "User", id("Game Manager"): "Game Manager"}
+
+	roster := player.Player{ID: 1, Permissions: []string{role.PlayersWrite}}
+	tests := []struct {
+		name     string
+		by       player.Player
+		old, new role.Roles
+		ok       bool
+	}{
+		{"no change without permission", ps["User"], withGM, withGM, true},
+		{"adding a role without permission", ps["User"], role.Roles{}, user, false},
+		{"adding a role that grants nothing", roster, role.Roles{}, user, true},
+		{"adding a role that grants more", roster, user, withGM, false},
+		{"taking a role that grants more", roster, withGM, user, false},
+		{"administrator adding any role", ps["Administrator"], user, withGM, true},
+		{"administrator taking any role", ps["Administrator"], withGM, user, true},
+		{"game manager handing out their own role", ps["Game Manager"], user, withGM, false},
+	}
+	for _, tt := range tests {
+		err := checkRoles(context.Background(), tt.by, tt.old, tt.new)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
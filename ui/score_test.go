@@ -0,0 +1,35 @@
+package main
+
+import (
+	"mariners/db/dbtest"
+	"mariners/player"
+	"mariners/scoring"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDelScoreHandler(t *testing.T) {
+	dbtest.Open(t)
+	scoring.SetStore(nil)
+
+	tests := []struct {
+		name string
+		vars map[string]string
+		want int
+	}{
+		{"no such score", map[string]string{"id": "1", "pid": "1"}, http.StatusNotFound},
+		{"a bad game", map[string]string{"id": "x", "pid": "1"}, http.StatusBadRequest},
+		{"a bad player", map[string]string{"id": "1", "pid": "x"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := mux.SetURLVars(httptest.NewRequest("DELETE", "/delscore/"+tt.vars["id"]+"/"+tt.vars["pid"], nil), tt.vars)
+		w := httptest.NewRecorder()
+		delScoreHandler(w, r, "test", player.Player{})
+		if w.Code != tt.want {
+			t.Errorf("%s: %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
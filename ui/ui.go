@@ -1,29 +1,38 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"mariners/db"
 	"mariners/game"
+	"mariners/inbound"
 	"mariners/mpevent"
+	"mariners/otp"
+	"mariners/outbox"
 	"mariners/player"
+	"mariners/queue"
 	"mariners/role"
+	"mariners/schedule"
 	"mariners/scoring"
 	"mariners/sms"
-	"math/rand"
+	"mariners/team"
+	"mariners/weather"
 	"net"
 	"net/http"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/nyaruka/phonenumbers"
 )
 
 type Page struct {
@@ -32,10 +41,27 @@ type Page struct {
 	Roles       role.Roles
 	Events      mpevent.Events
 	Scores      scoring.MPAverages
+	WindStats   scoring.WeatherStats
 	User        player.Player
 	FocusPlayer player.Player
 	FocusEvent  mpevent.Event
 	Game        game.Game
+	Teams       team.Teams
+	Scorecards  scoring.Scores
+	TeamResults scoring.TeamResults
+	Mystery     scoring.MysteryResult
+	Draw        []drawnTeam
+	CheckedIn   player.Players
+	Blasts      queue.Blasts
+	Matrix      role.Matrix
+	Permissions []role.Permission
+	Schedule    []schedule.Status
+}
+
+// drawnTeam is a team from the day's draw with its members' names.
+type drawnTeam struct {
+	ID      int64
+	Members []string
 }
 
 type MemberPage struct {
@@ -43,20 +69,38 @@ type MemberPage struct {
 	User  player.Player
 }
 
-func (p *Page) MessageTime() bool {
-	loc, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		return false
+// Local formats t in the league's timezone for a page, or says never for the
+// zero time.
+func (p *Page) Local(t time.Time) string {
+	if t.IsZero() {
+		return "never"
 	}
-	t := time.Now().In(loc)
-	if t.Hour() <= 8 && t.Hour() >= 20 {
-		return false
+
+	return db.Local(t).Format("Mon Jan 2 3:04 PM")
+}
+
+// Postpone is when the game would tee off a week later, in the form
+// datetime-local inputs use, for the game page's postpone form.
+func (p *Page) Postpone() string {
+	t := p.Game.TeeTime
+	if t.IsZero() {
+		t = p.Game.Date.Add(game.DefaultTeeTime)
 	}
-	return true
+
+	return db.Local(t).AddDate(0, 0, 7).Format("2006-01-02T15:04")
+}
+
+// TeamSize is the size of a drawn team, and the largest the draw form
+// allows.
+func (p *Page) TeamSize() int {
+	return gameRules.TeamSize
 }
 
 var pagedata Page
 
+// gameRules are the team scoring rules, set from the environment at startup.
+var gameRules = scoring.DefaultRules
+
 // Players
 func playerHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
 	p := Page{}
@@ -91,7 +135,7 @@ func playereditHandler(w http.ResponseWriter, r *http.Request, title string, use
 	p.Title = title
 	p.Roles = pagedata.Roles
 	p.User = user
-	p.FocusPlayer.GetPlayerByID(id)
+	p.FocusPlayer.GetPlayerByID(r.Context(), id)
 	p.Players = pagedata.Players
 
 	renderTemplate(w, "playeredit", &p)
@@ -110,7 +154,7 @@ func playerviewHandler(w http.ResponseWriter, r *http.Request, title string, use
 	p.Title = title
 	p.Roles = pagedata.Roles
 	p.User = user
-	p.FocusPlayer.GetPlayerByID(id)
+	p.FocusPlayer.GetPlayerByID(r.Context(), id)
 	p.Players = pagedata.Players
 
 	renderTemplate(w, "playerview", &p)
@@ -158,7 +202,7 @@ func putPlayerHandler(w http.ResponseWriter, r *http.Request, title string, user
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		fr, err := role.GetRoleByID(int64(rid))
+		fr, err := role.GetRoleByID(r.Context(), int64(rid))
 		if err != nil {
 			log.Error().Msgf("putPlayerHandler: %s\n", err)
 			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
@@ -171,14 +215,52 @@ func putPlayerHandler(w http.ResponseWriter, r *http.Request, title string, user
 		}
 	}
 
-	err = p.UpdatePlayer()
+	// Players editing their own profile don't see the roles, so keep theirs.
+	old := player.Player{}
+	err = old.GetPlayerByID(r.Context(), p.ID)
+	if err != nil {
+		log.Error().Msgf("putPlayerHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !user.Can(role.PlayersWrite) {
+		p.Roles = old.Roles
+	}
+	err = checkRoles(r.Context(), user, old.Roles, p.Roles)
+	if err != nil {
+		log.Error().Msgf("putPlayerHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	err = p.UpdatePlayer(r.Context())
 	if err != nil {
 		log.Error().Msgf("putPlayerHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	if r.FormValue("channel") != "" && p.ID == user.ID {
+		p.Preferences, err = preferencesFromForm(r)
+		if err != nil {
+			log.Error().Msgf("putPlayerHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = p.UpdatePreferences(r.Context())
+		if errors.Is(err, player.ErrInvalidPreferences) {
+			log.Error().Msgf("putPlayerHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Error().Msgf("putPlayerHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("putPlayerHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -189,6 +271,36 @@ func putPlayerHandler(w http.ResponseWriter, r *http.Request, title string, user
 	r.Body.Close()
 }
 
+// preferencesFromForm reads notification preferences from the profile form.
+func preferencesFromForm(r *http.Request) (player.Preferences, error) {
+	pr := player.Preferences{Channel: r.FormValue("channel")}
+
+	for _, c := range r.Form["notify"] {
+		switch c {
+		case player.CategoryLeague:
+			pr.League = true
+		case player.CategoryTournament:
+			pr.Tournament = true
+		case player.CategoryEvents:
+			pr.Events = true
+		case player.CategoryGame:
+			pr.Game = true
+		}
+	}
+
+	var err error
+	pr.QuietStart, err = strconv.Atoi(r.FormValue("quiet-start"))
+	if err != nil {
+		return pr, err
+	}
+	pr.QuietEnd, err = strconv.Atoi(r.FormValue("quiet-end"))
+	if err != nil {
+		return pr, err
+	}
+
+	return pr, nil
+}
+
 func postPlayerHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
 	p := player.Player{}
 
@@ -213,7 +325,7 @@ func postPlayerHandler(w http.ResponseWriter, r *http.Request, title string, use
 			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
 			return
 		}
-		fr, err := role.GetRoleByID(int64(rid))
+		fr, err := role.GetRoleByID(r.Context(), int64(rid))
 		if err != nil {
 			log.Error().Msgf("addplayerHandler: %s\n", err)
 			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
@@ -221,15 +333,21 @@ func postPlayerHandler(w http.ResponseWriter, r *http.Request, title string, use
 		}
 		p.Roles[int64(rid)] = fr[int64(rid)]
 	}
+	err = checkRoles(r.Context(), user, nil, p.Roles)
+	if err != nil {
+		log.Error().Msgf("addplayerHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusForbidden)
+		return
+	}
 
-	err = player.AddPlayer(&p)
+	err = player.AddPlayer(r.Context(), &p)
 	if err != nil {
 		log.Error().Msgf("addplayerHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("addplayerHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -251,14 +369,14 @@ func delPlayerHandler(w http.ResponseWriter, r *http.Request, title string, user
 
 	p := player.Player{}
 	p.ID = int64(id)
-	err = p.DeletePlayer()
+	err = p.DeletePlayer(r.Context())
 	if err != nil {
 		log.Error().Msgf("deleteplayerHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("deleteplayerHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -269,20 +387,93 @@ func delPlayerHandler(w http.ResponseWriter, r *http.Request, title string, user
 	r.Body.Close()
 }
 
-// Messages
-func postMessageHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
-	a, err := checkPerms(user, "Communications")
+// Permissions
+func permissionsHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	m, err := role.GetMatrix(r.Context())
 	if err != nil {
-		log.Error().Msgf("sendmessageHandler: %s\n", err)
-		errorHandlerStatus(w, r, err.Error(), http.StatusForbidden)
+		log.Error().Msgf("permissionsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if !a {
-		log.Error().Msgf("sendmessageHandler: %s\n", err)
-		errorHandlerStatus(w, r, err.Error(), http.StatusForbidden)
+
+	p := Page{}
+	p.Title = title
+	p.Roles = pagedata.Roles
+	p.User = user
+	p.Matrix = m
+	p.Permissions = role.Permissions
+
+	renderTemplate(w, "permissions", &p)
+}
+
+// putPermissionsHandler saves the whole role by permission matrix.  Users
+// can only grant permissions they have, and Administrator always keeps
+// roles:manage so someone can put things back.
+func putPermissionsHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	err := r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		log.Error().Msgf("putPermissionsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rs, err := role.GetRoles(r.Context())
+	if err != nil {
+		log.Error().Msgf("putPermissionsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	m, err := role.GetMatrix(r.Context())
+	if err != nil {
+		log.Error().Msgf("putPermissionsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	want := make(role.Matrix)
+	for id, name := range rs {
+		want[id] = r.Form[fmt.Sprintf("perm-%d", id)]
+		for _, perm := range want[id] {
+			if !m.Grants(id, perm) && !user.Can(perm) {
+				log.Error().Msgf("putPermissionsHandler: %s can't grant %s\n", user.PreferredName, perm)
+				errorHandlerStatus(w, r, fmt.Sprintf("you can't grant %s without having it", perm), http.StatusForbidden)
+				return
+			}
+		}
+		if name == "Administrator" && !want.Grants(id, role.RolesManage) {
+			log.Error().Msgf("putPermissionsHandler: Administrator can't lose %s\n", role.RolesManage)
+			errorHandlerStatus(w, r, fmt.Sprintf("Administrator can't lose %s", role.RolesManage), http.StatusBadRequest)
+			return
+		}
+	}
+
+	for id, perms := range want {
+		err = role.SetPermissions(r.Context(), id, perms)
+		if errors.Is(err, role.ErrUnknownPermission) {
+			log.Error().Msgf("putPermissionsHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Error().Msgf("putPermissionsHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = cacheData(r.Context())
+	if err != nil {
+		log.Error().Msgf("putPermissionsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+// Messages
+func postMessageHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
 	strid := mux.Vars(r)["id"]
 	id, err := strconv.Atoi(strid)
 	if err != nil {
@@ -292,7 +483,7 @@ func postMessageHandler(w http.ResponseWriter, r *http.Request, title string, us
 	}
 
 	p := player.Player{}
-	err = p.GetPlayerByID(int64(id))
+	err = p.GetPlayerByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("sendmessageHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -301,36 +492,25 @@ func postMessageHandler(w http.ResponseWriter, r *http.Request, title string, us
 	msg := fmt.Sprintf("Message from %s: ", p.PreferredName)
 	msg += r.FormValue("message")
 
+	ps := make(player.Players, 0)
 	for _, p := range pagedata.Players {
 		if p.HasRole("User") {
-			num, err := phonenumbers.Parse(p.Phone, "US")
-			if err != nil {
-				log.Error().Msgf("sendmessageHandler: %s\n", err)
-				continue
-			}
-			phone := phonenumbers.Format(num, phonenumbers.E164)
-			sms.SendTextPhone(msg, phone)
-			time.Sleep(time.Second)
+			ps = append(ps, p)
 		}
 	}
 
-	w.WriteHeader(http.StatusOK)
-	r.Body.Close()
-}
-
-func postTournamentMessageHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
-	a, err := checkPerms(user, "Communications")
+	_, err = queue.Enqueue(r.Context(), p.ID, player.CategoryLeague, "League", msg, ps)
 	if err != nil {
 		log.Error().Msgf("sendmessageHandler: %s\n", err)
-		errorHandlerStatus(w, r, err.Error(), http.StatusForbidden)
-		return
-	}
-	if !a {
-		log.Error().Msgf("sendmessageHandler: %s\n", err)
-		errorHandlerStatus(w, r, err.Error(), http.StatusForbidden)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+func postTournamentMessageHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
 	strid := mux.Vars(r)["id"]
 	id, err := strconv.Atoi(strid)
 	if err != nil {
@@ -340,7 +520,7 @@ func postTournamentMessageHandler(w http.ResponseWriter, r *http.Request, title
 	}
 
 	p := player.Player{}
-	err = p.GetPlayerByID(int64(id))
+	err = p.GetPlayerByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("sendmessageHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -349,19 +529,20 @@ func postTournamentMessageHandler(w http.ResponseWriter, r *http.Request, title
 	msg := fmt.Sprintf("Message from %s: ", p.PreferredName)
 	msg += r.FormValue("message")
 
+	ps := make(player.Players, 0)
 	for _, p := range pagedata.Players {
 		if p.HasRole("User") && p.HasRole("Tournament") {
-			num, err := phonenumbers.Parse(p.Phone, "US")
-			if err != nil {
-				log.Error().Msgf("sendmessageHandler: %s\n", err)
-				continue
-			}
-			phone := phonenumbers.Format(num, phonenumbers.E164)
-			sms.SendTextPhone(msg, phone)
-			time.Sleep(time.Second)
+			ps = append(ps, p)
 		}
 	}
 
+	_, err = queue.Enqueue(r.Context(), p.ID, player.CategoryTournament, "Tournament", msg, ps)
+	if err != nil {
+		log.Error().Msgf("sendmessageHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	r.Body.Close()
 }
@@ -376,6 +557,34 @@ func messageHandler(w http.ResponseWriter, r *http.Request, title string, user p
 	renderTemplate(w, "message", &p)
 }
 
+// blastsHandler shows how the most recent blasts are getting on.
+func blastsHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	p := Page{}
+
+	bs, err := queue.GetBlasts(r.Context(), 10)
+	if err != nil {
+		log.Error().Msgf("blastsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range bs {
+		for j := range bs[i].Messages {
+			for _, pl := range pagedata.Players {
+				if pl.ID == bs[i].Messages[j].Player.ID {
+					bs[i].Messages[j].Player = pl
+				}
+			}
+		}
+	}
+
+	p.Title = title
+	p.User = user
+	p.Blasts = bs
+
+	renderTemplate(w, "blasts", &p)
+}
+
 func messageinfoHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
 	p := Page{}
 
@@ -428,12 +637,12 @@ func eventeditHandler(w http.ResponseWriter, r *http.Request, title string, user
 		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
-	p.FocusEvent.GetEventByID(id)
+	p.FocusEvent.GetEventByID(r.Context(), id)
 
 	p.Title = title
 	p.Roles = pagedata.Roles
 	p.User = user
-	p.FocusPlayer.GetPlayerByID(id)
+	p.FocusPlayer.GetPlayerByID(r.Context(), id)
 	p.Players = pagedata.Players
 	p.Events = pagedata.Events
 
@@ -450,7 +659,7 @@ func eventviewHandler(w http.ResponseWriter, r *http.Request, title string, user
 		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
-	p.FocusEvent.GetEventByID(id)
+	p.FocusEvent.GetEventByID(r.Context(), id)
 
 	p.Title = title
 	p.Roles = pagedata.Roles
@@ -473,22 +682,13 @@ func postEventHandler(w http.ResponseWriter, r *http.Request, title string, user
 
 	e.Name = r.FormValue("name")
 	fd := r.FormValue("date")
-	if fd == "" {
-		e.Date = "0001-01-01T00:00"
-	} else {
-		loc, err := time.LoadLocation("America/Los_Angeles")
-		if err != nil {
-			log.Error().Msgf("addeventHandler: %s\n", err)
-			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
-			return
-		}
-		t, err := time.ParseInLocation("2006-01-02T15:04", fd, loc)
+	if fd != "" {
+		e.Date, err = db.ParseLocal("2006-01-02T15:04", fd)
 		if err != nil {
 			log.Error().Msgf("addeventHandler: %s\n", err)
 			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
 			return
 		}
-		e.Date = t.Format("2006-01-01T15:04")
 	}
 	e.Description = r.FormValue("desc")
 	strid := r.FormValue("owner")
@@ -516,16 +716,22 @@ func postEventHandler(w http.ResponseWriter, r *http.Request, title string, user
 		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
-	e.Owner.GetPlayerByID(int64(id))
+	// Anyone can add an event, but only for themselves.
+	if int64(id) != user.ID && !user.Can(role.EventsManage) {
+		log.Error().Msgf("addeventHandler: %s can't add an event for player %d\n", user.PreferredName, id)
+		errorHandlerStatus(w, r, fmt.Sprintf("you need the %s permission to add an event for someone else", role.EventsManage), http.StatusForbidden)
+		return
+	}
+	e.Owner.GetPlayerByID(r.Context(), int64(id))
 
-	err = e.CreateEvent()
+	err = e.CreateEvent(r.Context())
 	if err != nil {
 		log.Error().Msgf("addeventHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("addeventHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -544,7 +750,8 @@ func putEventHandler(w http.ResponseWriter, r *http.Request, title string, user
 		return
 	}
 
-	strid := r.FormValue("id")
+	// The event is the one in the path, which permit checked the user owns.
+	strid := mux.Vars(r)["id"]
 	id, err := strconv.ParseInt(strid, 10, 64)
 	if err != nil {
 		log.Error().Msgf("eventupdateHandler: %s\n", err)
@@ -553,20 +760,24 @@ func putEventHandler(w http.ResponseWriter, r *http.Request, title string, user
 	}
 
 	e := mpevent.Event{}
-	err = e.GetEventByID(int64(id))
+	err = e.GetEventByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("eventupdateHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	//2022-02-19T11:00 AM
 	e.Name = r.FormValue("name")
 	fd := r.FormValue("date")
 	if fd == "" {
-		e.Date = "0001-01-01T00:00"
+		e.Date = time.Time{}
 	} else {
-		e.Date = fd
+		e.Date, err = db.ParseLocal("2006-01-02T15:04", fd)
+		if err != nil {
+			log.Error().Msgf("eventupdateHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 	e.Description = r.FormValue("description")
 	stroid := r.FormValue("owner")
@@ -576,7 +787,7 @@ func putEventHandler(w http.ResponseWriter, r *http.Request, title string, user
 		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
-	e.Owner.GetPlayerByID(int64(oid))
+	e.Owner.GetPlayerByID(r.Context(), int64(oid))
 	if _, ok := r.Form["paidevent"]; ok {
 		e.PaidEvent = true
 	} else {
@@ -595,14 +806,14 @@ func putEventHandler(w http.ResponseWriter, r *http.Request, title string, user
 		e.InviteOnly = false
 	}
 
-	err = e.UpdateEvent()
+	err = e.UpdateEvent(r.Context())
 	if err != nil {
 		log.Error().Msgf("eventupdateHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("eventupdateHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -623,20 +834,20 @@ func delEventHandler(w http.ResponseWriter, r *http.Request, title string, user
 	}
 
 	e := mpevent.Event{}
-	err = e.GetEventByID(int64(id))
+	err = e.GetEventByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("deleventHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = e.DeleteEvent()
+	err = e.DeleteEvent(r.Context())
 	if err != nil {
 		log.Error().Msgf("deleventHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("deleventHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -666,21 +877,21 @@ func postEventMessageHandler(w http.ResponseWriter, r *http.Request, title strin
 	msg := r.FormValue("message")
 
 	e := mpevent.Event{}
-	err = e.GetEventByID(int64(id))
+	err = e.GetEventByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("eventmessageHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = e.SendEventMessage(msg, user.ID)
+	err = e.SendEventMessage(r.Context(), msg, user.ID)
 	if err != nil {
 		log.Error().Msgf("eventmessageHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("eventmessageHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -715,20 +926,20 @@ func postMemberHandler(w http.ResponseWriter, r *http.Request, title string, use
 	}
 
 	e := mpevent.Event{}
-	err = e.GetEventByID(int64(id))
+	err = e.GetEventByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("addmemberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = e.AddMember(int64(mid), false)
+	err = e.AddMember(r.Context(), int64(mid), false)
 	if err != nil {
 		log.Error().Msgf("addmemberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("addmemberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -756,20 +967,20 @@ func putMemberJoinHandler(w http.ResponseWriter, r *http.Request, title string,
 	}
 
 	e := mpevent.Event{}
-	err = e.GetEventByID(int64(id))
+	err = e.GetEventByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("eventjoinHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = e.AddMember(int64(mid), false)
+	err = e.AddMember(r.Context(), int64(mid), false)
 	if err != nil {
 		log.Error().Msgf("eventjoinHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("eventjoinHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -797,20 +1008,20 @@ func delMemberHandler(w http.ResponseWriter, r *http.Request, title string, user
 	}
 
 	e := mpevent.Event{}
-	err = e.GetEventByID(int64(id))
+	err = e.GetEventByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("removememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = e.DeleteMember(int64(pid))
+	err = e.DeleteMember(r.Context(), int64(pid))
 	if err != nil {
 		log.Error().Msgf("removememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("removememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -838,20 +1049,20 @@ func putMemberPayHandler(w http.ResponseWriter, r *http.Request, title string, u
 	}
 
 	e := mpevent.Event{}
-	err = e.GetEventByID(int64(id))
+	err = e.GetEventByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("updatememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = e.UpdateMember(int64(pid), true)
+	err = e.UpdateMember(r.Context(), int64(pid), true)
 	if err != nil {
 		log.Error().Msgf("updatememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("updatememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -879,20 +1090,20 @@ func putMemberUnpayHandler(w http.ResponseWriter, r *http.Request, title string,
 	}
 
 	e := mpevent.Event{}
-	err = e.GetEventByID(int64(id))
+	err = e.GetEventByID(r.Context(), int64(id))
 	if err != nil {
 		log.Error().Msgf("updatememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = e.UpdateMember(int64(pid), false)
+	err = e.UpdateMember(r.Context(), int64(pid), false)
 	if err != nil {
 		log.Error().Msgf("updatememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = cacheData()
+	err = cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("updatememberHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
@@ -920,19 +1131,25 @@ func gameinfoHandler(w http.ResponseWriter, r *http.Request, title string, user
 func scoresHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
 	p := Page{}
 
-	ss, err := scoring.GetAverages()
+	ss, err := scoring.GetAverages(r.Context())
 	if err != nil {
 		log.Error().Msgf("scoresHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	ws, err := scoring.GetWeatherStats(r.Context(), gameRules)
+	if err != nil {
+		log.Error().Msgf("scoresHandler: %s\n", err)
+	}
+
 	p.Title = title
 	p.User = user
 	p.Roles = pagedata.Roles
 	p.Players = pagedata.Players
 	p.Events = pagedata.Events
 	p.Scores = ss
+	p.WindStats = ws
 
 	renderTemplate(w, "scores", &p)
 }
@@ -947,119 +1164,792 @@ func scoresinfoHandler(w http.ResponseWriter, r *http.Request, title string, use
 	renderTemplate(w, "scoresinfo", &p)
 }
 
-// Game
-
-func gameHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
-	p := Page{}
-	p = pagedata
-
-	p.User = user
-	p.Title = title
-
-	renderTemplate(w, "game", &p)
-}
-
-func gamechangeHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
-	p := Page{}
-	p = pagedata
-
-	p.User = user
-	p.Title = title
-
-	renderTemplate(w, "game", &p)
-}
+func gamescoresHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	strid := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(strid, 10, 64)
+	if err != nil {
+		log.Error().Msgf("gamescoresHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-func gamecheckinHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
 	p := Page{}
-	p = pagedata
+	err = p.Game.GetGameByID(r.Context(), id)
+	if err != nil {
+		log.Error().Msgf("gamescoresHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusNotFound)
+		return
+	}
+	p.Teams, err = team.GetTeamsByGameID(r.Context(), id)
+	if err != nil {
+		log.Error().Msgf("gamescoresHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.Scorecards, err = scoring.GetScoresByGameID(r.Context(), id)
+	if err != nil {
+		log.Error().Msgf("gamescoresHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	p.User = user
 	p.Title = title
-
-	renderTemplate(w, "game", &p)
-}
-
-// Main
-func indexHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
-	p := Page{}
-
-	if title == "" {
-		p.Title = "home"
-	} else {
-		p.Title = title
-	}
 	p.User = user
 	p.Roles = pagedata.Roles
 	p.Players = pagedata.Players
-	p.Events = pagedata.Events
 
-	renderTemplate(w, "index", &p)
+	renderTemplate(w, "gamescores", &p)
 }
 
-func homeHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
-	p := Page{}
-
-	p.Title = title
-	p.Roles = pagedata.Roles
-	p.User = user
-
-	renderTemplate(w, "home", &p)
-}
+// scoreFromForm builds a score card from the player, team and hole fields of
+// a score entry form.
+func scoreFromForm(r *http.Request) (scoring.Score, error) {
+	s := scoring.Score{}
 
-// Auth
-func authHandler(w http.ResponseWriter, r *http.Request) {
-	p := Page{}
+	pid, err := strconv.ParseInt(r.FormValue("player"), 10, 64)
+	if err != nil {
+		return s, err
+	}
+	tid, err := strconv.ParseInt(r.FormValue("team"), 10, 64)
+	if err != nil {
+		return s, err
+	}
+	s.Player.ID = pid
+	s.TeamID.ID = tid
 
-	p.Players = pagedata.Players
+	for i := range s.Scores {
+		h, err := strconv.Atoi(r.FormValue(fmt.Sprintf("hole%d", i+1)))
+		if err != nil {
+			return s, fmt.Errorf("hole %d: %s", i+1, err)
+		}
+		s.Scores[i] = h
+	}
 
-	renderTemplate(w, "auth", &p)
+	return s, nil
 }
 
-func sendcodeHandler(w http.ResponseWriter, r *http.Request) {
-	strid := r.FormValue("player")
-
-	if strid == "Select Your Name" {
-		http.Redirect(w, r, "/auth", http.StatusFound)
-	}
-	id, err := strconv.Atoi(strid)
+func postScoreHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	strid := mux.Vars(r)["id"]
+	gid, err := strconv.ParseInt(strid, 10, 64)
 	if err != nil {
-		log.Printf("sendcodeHandler: %s\n", err)
-		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		log.Error().Msgf("postScoreHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		log.Error().Msgf("postScoreHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s, err := scoreFromForm(r)
+	if err != nil {
+		log.Error().Msgf("postScoreHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A second card for the same player replaces the first.
+	err = scoring.AddScore(r.Context(), gid, &s)
+	if errors.Is(err, scoring.ErrDuplicateScore) {
+		err = s.UpdateScore(r.Context(), gid)
+	}
+	if err != nil {
+		log.Error().Msgf("postScoreHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+func delScoreHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	strid := mux.Vars(r)["id"]
+	strpid := mux.Vars(r)["pid"]
+	gid, err := strconv.ParseInt(strid, 10, 64)
+	if err != nil {
+		log.Error().Msgf("delScoreHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pid, err := strconv.ParseInt(strpid, 10, 64)
+	if err != nil {
+		log.Error().Msgf("delScoreHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = scoring.DeleteScore(r.Context(), gid, pid)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Error().Msgf("delScoreHandler: no score for player %d in game %d\n", pid, gid)
+		errorHandlerStatus(w, r, "score not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error().Msgf("delScoreHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+// Game
+
+func gameHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	p := Page{}
+	p = pagedata
+
+	p.User = user
+	p.Title = title
+
+	trs, err := scoring.GetTeamResults(r.Context(), p.Game.ID, gameRules)
+	if err != nil {
+		log.Error().Msgf("gameHandler: %s\n", err)
+	}
+	p.TeamResults = trs
+
+	p.Draw, err = getDraw(r.Context(), p.Game.ID)
+	if err != nil {
+		log.Error().Msgf("gameHandler: %s\n", err)
+	}
+
+	if p.Game.Mystery.Hole != 0 {
+		p.Mystery, err = scoring.GetMysteryResult(r.Context(), p.Game.ID, p.Game.Mystery.Hole)
+		if err != nil {
+			log.Error().Msgf("gameHandler: %s\n", err)
+		}
+	}
+
+	renderTemplate(w, "game", &p)
+}
+
+// getDraw loads the teams drawn for game gid.
+func getDraw(ctx context.Context, gid int64) ([]drawnTeam, error) {
+	ds := make([]drawnTeam, 0)
+
+	ts, err := team.GetTeamsByGameID(ctx, gid)
+	if err != nil {
+		return ds, err
+	}
+
+	for _, t := range ts {
+		ms, err := team.GetTeamMembers(ctx, t.ID)
+		if err != nil {
+			return ds, err
+		}
+		d := drawnTeam{ID: t.ID}
+		for _, m := range ms {
+			if m.Ghost {
+				d.Members = append(d.Members, "Ghost")
+				continue
+			}
+			p := player.Player{}
+			err = p.GetPlayerByID(ctx, m.PlayerID)
+			if err != nil {
+				return ds, err
+			}
+			d.Members = append(d.Members, p.PreferredName)
+		}
+		ds = append(ds, d)
+	}
+
+	return ds, nil
+}
+
+// textCheckins queues msg from player sid to every player checked in to a
+// game.
+func textCheckins(ctx context.Context, sid int64, label string, msg string, cs game.Checkins) error {
+	ps := make(player.Players, 0)
+	for _, ci := range cs {
+		p := player.Player{}
+		err := p.GetPlayerByID(ctx, ci.PlayerID)
+		if err != nil {
+			return err
+		}
+		ps = append(ps, p)
+	}
+
+	_, err := queue.Enqueue(ctx, sid, player.CategoryGame, label, msg, ps)
+
+	return err
+}
+
+func postDrawHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	strid := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(strid, 10, 64)
+	if err != nil {
+		log.Error().Msgf("postDrawHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		log.Error().Msgf("postDrawHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	size := gameRules.TeamSize
+	if v := r.FormValue("size"); v != "" {
+		size, err = strconv.Atoi(v)
+		if err != nil {
+			log.Error().Msgf("postDrawHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// Scoring fills short teams with ghosts, but can't score a team bigger
+	// than the rules allow.
+	if size > gameRules.TeamSize {
+		err = fmt.Errorf("teams of %d: teams are at most %d", size, gameRules.TeamSize)
+		log.Error().Msgf("postDrawHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g := game.Game{}
+	err = g.GetGameByID(r.Context(), id)
+	if err != nil {
+		log.Error().Msgf("postDrawHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if g.TeamsLocked {
+		log.Error().Msgf("postDrawHandler: %s\n", game.ErrTeamsLocked)
+		errorHandlerStatus(w, r, game.ErrTeamsLocked.Error(), http.StatusConflict)
+		return
+	}
+
+	err = g.GetCheckins(r.Context())
+	if err != nil {
+		log.Error().Msgf("postDrawHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pids := make([]int64, 0, len(g.Checkins))
+	for _, ci := range g.Checkins {
+		pids = append(pids, ci.PlayerID)
+	}
+
+	var seeds map[int64]float64
+	if _, ok := r.Form["seeded"]; ok {
+		as, err := scoring.GetAverages(r.Context())
+		if err != nil {
+			log.Error().Msgf("postDrawHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		seeds = make(map[int64]float64)
+		for _, a := range as {
+			seeds[a.Player.ID] = a.Last20
+		}
+	}
+
+	draw, err := team.Draw(pids, size, seeds)
+	if err != nil {
+		log.Error().Msgf("postDrawHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = team.SaveDraw(r.Context(), g.ID, draw)
+	if err != nil {
+		log.Error().Msgf("postDrawHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+func putLockTeamsHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	strid := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(strid, 10, 64)
+	if err != nil {
+		log.Error().Msgf("putLockTeamsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g := game.Game{}
+	err = g.GetGameByID(r.Context(), id)
+	if err != nil {
+		log.Error().Msgf("putLockTeamsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ds, err := getDraw(r.Context(), g.ID)
+	if err != nil {
+		log.Error().Msgf("putLockTeamsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(ds) == 0 {
+		log.Error().Msgf("putLockTeamsHandler: no teams drawn for game %d\n", g.ID)
+		errorHandlerStatus(w, r, "no teams have been drawn", http.StatusConflict)
+		return
+	}
+
+	err = g.LockTeams(r.Context())
+	if err != nil {
+		log.Error().Msgf("putLockTeamsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+
+	err = g.GetCheckins(r.Context())
+	if err != nil {
+		log.Error().Msgf("putLockTeamsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	msg := fmt.Sprintf("Teams for %s:", g.Day())
+	for i, d := range ds {
+		msg += fmt.Sprintf("\n%d: %s", i+1, strings.Join(d.Members, ", "))
+	}
+	err = textCheckins(r.Context(), user.ID, "Teams", msg, g.Checkins)
+	if err != nil {
+		log.Error().Msgf("putLockTeamsHandler: %s\n", err)
+	}
+
+	err = cacheData(r.Context())
+	if err != nil {
+		log.Error().Msgf("putLockTeamsHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+func postMysteryHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	strid := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(strid, 10, 64)
+	if err != nil {
+		log.Error().Msgf("postMysteryHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g := game.Game{}
+	err = g.GetGameByID(r.Context(), id)
+	if err != nil {
+		log.Error().Msgf("postMysteryHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = g.DrawMystery(r.Context())
+	if err != nil {
+		log.Error().Msgf("postMysteryHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+
+	err = g.GetCheckins(r.Context())
+	if err != nil {
+		log.Error().Msgf("postMysteryHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	msg := fmt.Sprintf("The mystery hole for %s is hole %d!", g.Day(), g.Mystery.Hole)
+	err = textCheckins(r.Context(), user.ID, "Mystery Hole", msg, g.Checkins)
+	if err != nil {
+		log.Error().Msgf("postMysteryHandler: %s\n", err)
+	}
+
+	err = cacheData(r.Context())
+	if err != nil {
+		log.Error().Msgf("postMysteryHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+// postCancelGameHandler calls the game off, moving it to the day in the
+// form's date if there is one, and texts the players it affects.
+func postCancelGameHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	strid := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(strid, 10, 64)
+	if err != nil {
+		log.Error().Msgf("postCancelGameHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		log.Error().Msgf("postCancelGameHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var to time.Time
+	if fd := r.FormValue("date"); fd != "" {
+		to, err = db.ParseLocal("2006-01-02T15:04", fd)
+		if err != nil {
+			log.Error().Msgf("postCancelGameHandler: %s\n", err)
+			errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	g := game.Game{}
+	err = g.GetGameByID(r.Context(), id)
+	if err != nil {
+		log.Error().Msgf("postCancelGameHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = g.Cancel(r.Context(), user.ID, to)
+	switch {
+	case errors.Is(err, game.ErrGameCancelled):
+		log.Error().Msgf("postCancelGameHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, game.ErrRescheduleDay):
+		log.Error().Msgf("postCancelGameHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Error().Msgf("postCancelGameHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = cacheData(r.Context())
+	if err != nil {
+		log.Error().Msgf("postCancelGameHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+func gamechangeHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	p := Page{}
+	p = pagedata
+
+	p.User = user
+	p.Title = title
+
+	renderTemplate(w, "game", &p)
+}
+
+// checkinHandler shows today's check-ins, loaded for each request rather than
+// kept with the cached game, so check-ins never touch the shared page data.
+func checkinHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	p := Page{}
+	p.Game = pagedata.Game
+
+	err := p.Game.GetCheckins(r.Context())
+	if err != nil {
+		log.Error().Msgf("checkinHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, pl := range pagedata.Players {
+		if p.Game.HasCheckin(pl.ID) {
+			p.CheckedIn = append(p.CheckedIn, pl)
+		}
+	}
+
+	p.Title = title
+	p.User = user
+	p.Players = pagedata.Players
+
+	renderTemplate(w, "checkin", &p)
+}
+
+// checkinTarget returns the game and player for a check-in form: the user,
+// or the player in the path, which permit only lets a Game Manager name.
+func checkinTarget(r *http.Request, user player.Player) (game.Game, player.Player, int, error) {
+	g := game.Game{}
+	p := player.Player{}
+
+	gid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return g, p, http.StatusBadRequest, err
+	}
+	err = g.GetGameByID(r.Context(), gid)
+	if err != nil {
+		return g, p, http.StatusNotFound, err
+	}
+
+	pid := user.ID
+	if strpid, ok := mux.Vars(r)["pid"]; ok {
+		pid, err = strconv.ParseInt(strpid, 10, 64)
+		if err != nil {
+			return g, p, http.StatusBadRequest, err
+		}
+	}
+	err = p.GetPlayerByID(r.Context(), pid)
+	if err != nil {
+		return g, p, http.StatusNotFound, err
+	}
+
+	return g, p, http.StatusOK, nil
+}
+
+func checkinErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, game.ErrCheckedIn), errors.Is(err, game.ErrNotCheckedIn),
+		errors.Is(err, game.ErrGameCancelled):
+		return http.StatusConflict
+	case errors.Is(err, game.ErrCheckinClosed):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func postCheckinHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	g, p, status, err := checkinTarget(r, user)
+	if err != nil {
+		log.Error().Msgf("postCheckinHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), status)
+		return
+	}
+
+	err = g.AddCheckin(r.Context(), p, user.Can(role.GamesManage))
+	if err != nil {
+		log.Error().Msgf("postCheckinHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), checkinErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+func delCheckinHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	g, p, status, err := checkinTarget(r, user)
+	if err != nil {
+		log.Error().Msgf("delCheckinHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), status)
+		return
+	}
+
+	err = g.RemoveCheckin(r.Context(), p, user.Can(role.GamesManage))
+	if err != nil {
+		log.Error().Msgf("delCheckinHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), checkinErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	r.Body.Close()
+}
+
+// inboundHandler takes texts sent to the club's number from SNS or another
+// SMS provider's webhook, runs the command and texts back the reply.  The
+// webhook has to be configured with a token query parameter matching
+// MPINBOUNDTOKEN, and nothing is accepted until it's set.  SNS requests are
+// also checked against MPINBOUNDTOPIC and their signature.
+func inboundHandler(w http.ResponseWriter, r *http.Request) {
+	token := getEnv("MPINBOUNDTOKEN", "")
+	if token == "" {
+		log.Error().Msgf("inboundHandler: MPINBOUNDTOKEN isn't set, refusing %s\n", r.RemoteAddr)
+		http.Error(w, "inbound texts aren't configured", http.StatusServiceUnavailable)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		log.Error().Msgf("inboundHandler: bad token from %s\n", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	m, err := inbound.FromRequest(r)
+	if errors.Is(err, inbound.ErrSubscriptionConfirmed) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if errors.Is(err, inbound.ErrBadSignature) {
+		log.Error().Msgf("inboundHandler: %s from %s\n", err, r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Error().Msgf("inboundHandler: %s\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reply := inbound.Handle(r.Context(), m)
+
+	_, err = sms.SendTextPhone(reply, m.From)
+	if err != nil {
+		log.Error().Msgf("inboundHandler: %s\n", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Main
+func indexHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	p := Page{}
+
+	if title == "" {
+		p.Title = "home"
+	} else {
+		p.Title = title
+	}
+	p.User = user
+	p.Roles = pagedata.Roles
+	p.Players = pagedata.Players
+	p.Events = pagedata.Events
+
+	renderTemplate(w, "index", &p)
+}
+
+func homeHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	p := Page{}
+
+	p.Title = title
+	p.Roles = pagedata.Roles
+	p.User = user
+
+	renderTemplate(w, "home", &p)
+}
+
+// Auth
+func authHandler(w http.ResponseWriter, r *http.Request) {
+	p := Page{}
+
+	p.Players = pagedata.Players
+
+	renderTemplate(w, "auth", &p)
+}
+
+// sendLimit and verifyLimit cap how often one address can ask for login
+// codes and try them, on top of otp's own limits.
+var (
+	sendLimit   = otp.NewLimiter(otp.MaxPerIP, otp.Window)
+	verifyLimit = otp.NewLimiter(4*otp.MaxAttempts, otp.Window)
+)
+
+// clientIP is the address a request came from.  Behind a proxy, set
+// MPTRUSTPROXY=true to use the address it puts first in X-Forwarded-For.
+func clientIP(r *http.Request) string {
+	if getEnv("MPTRUSTPROXY", "") == "true" {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// loginError shows the login page tmpl again with msg.
+func loginError(w http.ResponseWriter, tmpl string, msg string, status int) {
+	p := Page{}
+
+	p.Title = msg
+	p.Players = pagedata.Players
+
+	w.WriteHeader(status)
+	renderTemplate(w, tmpl, &p)
+}
+
+// sendcodeHandler texts the player a one-time code, and remembers who asked
+// in the login cookie so the code only works for them.
+func sendcodeHandler(w http.ResponseWriter, r *http.Request) {
+	ip := clientIP(r)
+	if !sendLimit.Allow(ip) {
+		log.Error().Msgf("sendcodeHandler: too many codes asked for from %s\n", ip)
+		loginError(w, "auth", otp.ErrTooManyRequests.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	strid := r.FormValue("player")
+
+	if strid == "Select Your Name" {
+		http.Redirect(w, r, "/auth", http.StatusFound)
+		return
+	}
+	id, err := strconv.ParseInt(strid, 10, 64)
+	if err != nil {
+		log.Error().Msgf("sendcodeHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	p := player.Player{}
-	err = p.GetPlayerByID(int64(id))
+	err = p.GetPlayerByID(r.Context(), id)
 	if err != nil {
-		log.Printf("sendcodeHandler: %s\n", err)
+		log.Error().Msgf("sendcodeHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	num, err := phonenumbers.Parse(p.Phone, "US")
+	phone, err := player.NormalizePhone(p.Phone)
 	if err != nil {
-		log.Printf("sendcodeHandler: %s\n", err)
+		log.Error().Msgf("sendcodeHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	phone := phonenumbers.Format(num, phonenumbers.E164)
 
-	var code string
-	msg := "Your MPLINKSTERS Login Code is: "
-	for i := 0; i < 5; i++ {
-		s1 := rand.NewSource(time.Now().UnixNano())
-		r1 := rand.New(s1)
-		code += strconv.Itoa(r1.Intn(9))
+	code, nonce, err := otp.Request(r.Context(), p.ID, ip)
+	if errors.Is(err, otp.ErrTooManyRequests) {
+		log.Error().Msgf("sendcodeHandler: %s for %s from %s\n", err, p.PreferredName, ip)
+		loginError(w, "auth", err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		log.Error().Msgf("sendcodeHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	p.WriteToken(code)
+	msg := fmt.Sprintf("Your MPLINKSTERS Login Code is: %s.  It works for %d minutes.", code, int(otp.TTL.Minutes()))
+	_, err = sms.SendTextPhone(msg, phone)
+	if err != nil {
+		log.Error().Msgf("sendcodeHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	sms.SendTextPhone(msg+code, phone)
+	cookie := &http.Cookie{
+		Name:     "login",
+		Value:    nonce,
+		Expires:  time.Now().Add(otp.TTL),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, cookie)
 
 	http.Redirect(w, r, "/verify", http.StatusFound)
 }
 
 func verifyHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie("login"); err != nil {
+		http.Redirect(w, r, "/auth", http.StatusFound)
+		return
+	}
+
 	p := Page{}
 
 	p.Players = pagedata.Players
@@ -1067,27 +1957,64 @@ func verifyHandler(w http.ResponseWriter, r *http.Request) {
 	renderTemplate(w, "verify", &p)
 }
 
+// maketokenHandler checks the code against the one asked for by the browser
+// whose nonce is in the login cookie, and starts a session for its player if
+// it's right.
 func maketokenHandler(w http.ResponseWriter, r *http.Request) {
-	p := player.Player{}
-	err := p.GetPlayerByToken(r.FormValue("code"))
+	ip := clientIP(r)
+	if !verifyLimit.Allow(ip) {
+		log.Error().Msgf("maketokenHandler: too many codes tried from %s\n", ip)
+		loginError(w, "verify", "too many codes tried, wait a while and try again", http.StatusTooManyRequests)
+		return
+	}
+
+	login, err := r.Cookie("login")
 	if err != nil {
-		log.Printf("maketokenHandler: %s\n", err)
 		http.Redirect(w, r, "/auth", http.StatusFound)
+		return
+	}
+
+	pid, err := otp.Verify(r.Context(), login.Value, strings.TrimSpace(r.FormValue("code")))
+	switch {
+	case errors.Is(err, otp.ErrInvalidCode):
+		log.Error().Msgf("maketokenHandler: %s from %s\n", err, ip)
+		loginError(w, "verify", err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, otp.ErrTooManyAttempts):
+		log.Error().Msgf("maketokenHandler: %s from %s\n", err, ip)
+		http.SetCookie(w, &http.Cookie{Name: "login", MaxAge: -1})
+		loginError(w, "auth", err.Error(), http.StatusTooManyRequests)
+		return
+	case err != nil:
+		log.Error().Msgf("maketokenHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p := player.Player{}
+	err = p.GetPlayerByID(r.Context(), pid)
+	if err != nil {
+		log.Error().Msgf("maketokenHandler: %s\n", err)
+		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	token := uuid.New().String()
 
-	err = p.WriteToken(token)
+	err = p.WriteToken(r.Context(), token)
 	if err != nil {
-		log.Printf("maketokenHandler: %s\n", err)
+		log.Error().Msgf("maketokenHandler: %s\n", err)
 		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	http.SetCookie(w, &http.Cookie{Name: "login", MaxAge: -1})
 	cookie := &http.Cookie{
-		Name:    "token",
-		Value:   token,
-		Expires: time.Now().AddDate(0, 1, 0),
+		Name:     "token",
+		Value:    token,
+		Expires:  time.Now().AddDate(0, 1, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 	}
 	http.SetCookie(w, cookie)
 
@@ -1102,17 +2029,16 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only the session in the request's own cookie is ended, so a link
+	// can't log anyone else out.
 	p := player.Player{}
-	err = p.GetPlayerByID(id)
-	if err != nil {
-		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = p.RemoveToken()
-	if err != nil {
-		errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
-		return
+	token, err := r.Cookie("token")
+	if err == nil && p.GetPlayerByToken(r.Context(), token.Value) == nil && p.ID == id {
+		err = p.RemoveToken(r.Context())
+		if err != nil {
+			errorHandlerStatus(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	cookie := &http.Cookie{
@@ -1140,6 +2066,7 @@ func renderTemplate(w http.ResponseWriter, tmpl string, p *Page) {
 		"tmpl/memberdel.html",
 		"tmpl/memberjoin.html",
 		"tmpl/eventdel.html",
+		"tmpl/scoredel.html",
 		"tmpl/"+tmpl+".html")
 	if err != nil {
 		log.Error().Msgf("renderTemplate: %s\n", err)
@@ -1152,17 +2079,140 @@ func renderTemplate(w http.ResponseWriter, tmpl string, p *Page) {
 	}
 }
 
-func checkPerms(p player.Player, n string) (bool, error) {
-	for _, r := range p.Roles {
-		if r == n {
-			return true, nil
+// pageHandler is a handler for a signed in user, as makeHandler calls it.
+type pageHandler func(http.ResponseWriter, *http.Request, string, player.Player)
+
+// ownFunc reports whether a request is on the user's own things, which they
+// can change without a role granting the permission.
+type ownFunc func(r *http.Request, user player.Player) bool
+
+// permit wraps fn so it only runs for users whose roles grant perm, or, if
+// own is set, for requests own says are on their own things.  The pages hide
+// what a user can't do, but this is what stops them doing it.
+func permit(perm string, own ownFunc, fn pageHandler) pageHandler {
+	return func(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+		if !user.Can(perm) && (own == nil || !own(r, user)) {
+			log.Error().Msgf("permit: %s needs %s, which %s doesn't have\n", r.URL.Path, perm, user.PreferredName)
+			errorHandlerStatus(w, r, fmt.Sprintf("you need the %s permission to do that", perm), http.StatusForbidden)
+			return
+		}
+
+		fn(w, r, title, user)
+	}
+}
+
+// pathPlayer reads the player ID in path variable name.
+func pathPlayer(r *http.Request, name string) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+}
+
+// pathEvent loads the event in path variable id.
+func pathEvent(r *http.Request) (mpevent.Event, error) {
+	e := mpevent.Event{}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return e, err
+	}
+	err = e.GetEventByID(r.Context(), id)
+
+	return e, err
+}
+
+// ownPlayer is the user's own player page.
+func ownPlayer(r *http.Request, user player.Player) bool {
+	id, err := pathPlayer(r, "id")
+
+	return err == nil && id == user.ID
+}
+
+// ownProfile is the profile form for the user.
+func ownProfile(r *http.Request, user player.Player) bool {
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	return err == nil && id == user.ID
+}
+
+// ownCard is the user's own check-in or card for a game.
+func ownCard(r *http.Request, user player.Player) bool {
+	pid, err := pathPlayer(r, "pid")
+
+	return err == nil && pid == user.ID
+}
+
+// ownScore is the score form for the user's own card.
+func ownScore(r *http.Request, user player.Player) bool {
+	pid, err := strconv.ParseInt(r.FormValue("player"), 10, 64)
+
+	return err == nil && pid == user.ID
+}
+
+// ownEvent is an event the user owns.
+func ownEvent(r *http.Request, user player.Player) bool {
+	e, err := pathEvent(r)
+
+	return err == nil && e.Owner.ID == user.ID
+}
+
+// leaving is the user taking themselves off an event, or its owner taking
+// anyone off.
+func leaving(r *http.Request, user player.Player) bool {
+	return ownCard(r, user) || ownEvent(r, user)
+}
+
+// joining is the user joining an event that isn't invite only, or its owner
+// adding anyone.
+func joining(r *http.Request, user player.Player) bool {
+	e, err := pathEvent(r)
+	if err != nil {
+		return false
+	}
+
+	return e.Owner.ID == user.ID || (ownCard(r, user) && !e.InviteOnly)
+}
+
+// eventMember is a member or the owner of an event, who can text the rest.
+func eventMember(r *http.Request, user player.Player) bool {
+	e, err := pathEvent(r)
+
+	return err == nil && (e.Owner.ID == user.ID || e.HasMember(user))
+}
+
+// checkRoles refuses to change a player's roles from old to roles unless
+// user can manage players and has every permission each changed role
+// grants, so no one can hand out more than they have themselves.
+func checkRoles(ctx context.Context, user player.Player, old, roles role.Roles) error {
+	m, err := role.GetMatrix(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed := make(role.Roles)
+	for id, name := range old {
+		if _, ok := roles[id]; !ok {
+			changed[id] = name
+		}
+	}
+	for id, name := range roles {
+		if _, ok := old[id]; !ok {
+			changed[id] = name
+		}
+	}
+
+	for id, name := range changed {
+		if !user.Can(role.PlayersWrite) {
+			return fmt.Errorf("changing roles needs the %s permission", role.PlayersWrite)
+		}
+		for _, perm := range m[id] {
+			if !user.Can(perm) {
+				return fmt.Errorf("%s grants %s, which you don't have", name, perm)
+			}
 		}
 	}
 
-	return false, nil
+	return nil
 }
 
-var validPath = regexp.MustCompile("^/(ui|players|playeredit|playerview|updateplayer|addplayer|deleteplayer|events|editevent|addevent|delevent|addmember|addmemberedit|removemember|updatemember|games|auth|sendcode|verify|maketoken|message|sendmessage|addalluser|scores|scoresinfo|checkin|checkins)?")
+var validPath = regexp.MustCompile("^/(ui|players|playeredit|playerview|updateplayer|addplayer|deleteplayer|events|editevent|addevent|delevent|addmember|addmemberedit|removemember|updatemember|games|auth|sendcode|verify|maketoken|message|sendmessage|addalluser|scores|scoresinfo|gamescores|checkin|checkins)?")
 
 func makeHandler(fn func(http.ResponseWriter, *http.Request, string, player.Player)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -1180,7 +2230,7 @@ func makeHandler(fn func(http.ResponseWriter, *http.Request, string, player.Play
 		}
 
 		user := player.Player{}
-		err = user.GetPlayerByToken(token.Value)
+		err = user.GetPlayerByToken(r.Context(), token.Value)
 
 		if err != nil {
 			http.Redirect(w, r, "/auth", http.StatusFound)
@@ -1211,8 +2261,171 @@ func redirectToHTTPS() {
 	log.Fatal().Msgf("%s", httpSrv.ListenAndServe())
 }
 
-func cacheHandler(w http.ResponseWriter, r *http.Request) {
-	err := cacheData()
+// loadWorker reads the message queue settings from MPSMSRATE, MPSMSBATCH,
+// MPSMSRETRIES and MPSMSBACKOFF, keeping the defaults for any that are unset.
+func loadWorker() (queue.Worker, error) {
+	wk := queue.DefaultWorker
+
+	if v := getEnv("MPSMSRATE", ""); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return wk, fmt.Errorf("MPSMSRATE: %s", err)
+		}
+		wk.Rate = d
+	}
+	if v := getEnv("MPSMSBATCH", ""); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return wk, fmt.Errorf("MPSMSBATCH: %s is not a positive number", v)
+		}
+		wk.Batch = n
+	}
+	if v := getEnv("MPSMSRETRIES", ""); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return wk, fmt.Errorf("MPSMSRETRIES: %s is not a positive number", v)
+		}
+		wk.MaxAttempts = n
+	}
+	if v := getEnv("MPSMSBACKOFF", ""); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return wk, fmt.Errorf("MPSMSBACKOFF: %s", err)
+		}
+		wk.Backoff = d
+	}
+
+	return wk, nil
+}
+
+// scheduler runs the background jobs, once main has started it.
+var scheduler *schedule.Scheduler
+
+// loadSchedule sets up the background jobs.  Today's forecast is refreshed
+// on the cron rules in MPWEATHERSCHEDULE, separated by semicolons, and again
+// MPWEATHERLEAD before the tee time, unless that's 0.  The weather observed
+// during today's game is recorded on the rules in MPOBSERVEDSCHEDULE, once
+// play has closed.
+func loadSchedule() (*schedule.Scheduler, error) {
+	rules, err := parseSchedule("MPWEATHERSCHEDULE", "0 12 * * 0,6; 0 13 * * 1-5")
+	if err != nil {
+		return nil, err
+	}
+	observed, err := parseSchedule("MPOBSERVEDSCHEDULE", "0 18 * * *")
+	if err != nil {
+		return nil, err
+	}
+
+	lead, err := time.ParseDuration(getEnv("MPWEATHERLEAD", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("MPWEATHERLEAD: %s", err)
+	}
+
+	jobs := []schedule.Job{{
+		Name:  "weather",
+		Rules: rules,
+		Run: func() error {
+			return game.RefreshTodaysWeather(context.Background(), weather.ReasonScheduled)
+		},
+	}, {
+		Name:  "observed weather",
+		Rules: observed,
+		Run: func() error {
+			return game.RecordTodaysWeather(context.Background())
+		},
+	}}
+	if lead > 0 {
+		jobs = append(jobs, schedule.Job{
+			Name: "weather before tee time",
+			When: func(t time.Time) bool {
+				tee, err := game.GetTeeTime(context.Background(), t)
+				if err != nil {
+					if !errors.Is(err, sql.ErrNoRows) {
+						log.Error().Msgf("schedule: %s\n", err)
+					}
+					return false
+				}
+				return tee.Add(-lead).Truncate(time.Minute).Equal(t)
+			},
+			Run: func() error {
+				return game.RefreshTodaysWeather(context.Background(), weather.ReasonTeeTime)
+			},
+		})
+	}
+
+	return schedule.New(jobs...), nil
+}
+
+// parseSchedule reads the cron rules, separated by semicolons, in environment
+// variable key, or fallback if it's unset.
+func parseSchedule(key, fallback string) ([]schedule.Rule, error) {
+	var rules []schedule.Rule
+	for _, spec := range strings.Split(getEnv(key, fallback), ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		rule, err := schedule.Parse(spec, db.Location())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", key, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// loadRules reads the scoring rules from MPTEAMSIZE, MPBESTBALLS,
+// MPGHOSTSCORE and MPPAR, keeping the defaults for any that are unset.
+func loadRules() error {
+	if v := getEnv("MPTEAMSIZE", ""); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MPTEAMSIZE: %s", err)
+		}
+		gameRules.TeamSize = n
+	}
+	if v := getEnv("MPBESTBALLS", ""); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MPBESTBALLS: %s", err)
+		}
+		gameRules.BestBalls = n
+	}
+	if v := getEnv("MPGHOSTSCORE", ""); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MPGHOSTSCORE: %s", err)
+		}
+		for i := range gameRules.GhostScore {
+			gameRules.GhostScore[i] = n
+		}
+	}
+	if v := getEnv("MPPAR", ""); v != "" {
+		par, err := scoring.ParseHoles(v)
+		if err != nil {
+			return fmt.Errorf("MPPAR: %s", err)
+		}
+		gameRules.Par = par
+	}
+
+	return nil
+}
+
+// healthHandler says the site is up, for the load balancer, and how the
+// scheduled jobs are getting on.  It doesn't touch the database, so the site
+// stays healthy while the database is slow.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	p := Page{}
+
+	if scheduler != nil {
+		p.Schedule = scheduler.Statuses()
+	}
+
+	renderTemplate(w, "health", &p)
+}
+
+func cacheHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	err := cacheData(r.Context())
 	if err != nil {
 		log.Error().Msgf("cacheHandler: %s", err)
 		return
@@ -1221,8 +2434,8 @@ func cacheHandler(w http.ResponseWriter, r *http.Request) {
 	r.Body.Close()
 }
 
-func addAllUserHandler(w http.ResponseWriter, r *http.Request) {
-	err := player.AddRoleAll(1)
+func addAllUserHandler(w http.ResponseWriter, r *http.Request, title string, user player.Player) {
+	err := player.AddRoleAll(r.Context(), 1)
 	if err != nil {
 		log.Error().Msgf("addAllUserHandler: %s", err)
 		return
@@ -1230,12 +2443,12 @@ func addAllUserHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
-func cacheData() error {
+func cacheData(ctx context.Context) error {
 	log.Info().Msg("Refreshing data cache...")
 
 	log.Info().Msg("Players...")
 
-	ps, err := player.GetPlayers()
+	ps, err := player.GetPlayers(ctx)
 	if err != nil {
 		return err
 	}
@@ -1243,7 +2456,7 @@ func cacheData() error {
 
 	log.Info().Msg("Roles...")
 
-	rs, err := role.GetRoles()
+	rs, err := role.GetRoles(ctx)
 	if err != nil {
 		return err
 	}
@@ -1251,7 +2464,7 @@ func cacheData() error {
 
 	log.Info().Msg("Events...")
 
-	es, err := mpevent.GetEvents()
+	es, err := mpevent.GetEvents(ctx)
 	if err != nil {
 		return err
 	}
@@ -1260,16 +2473,16 @@ func cacheData() error {
 	log.Info().Msg("Game...")
 
 	n := time.Now()
-	g, err := game.GetGameByDate(n)
+	g, err := game.GetGameByDate(ctx, n)
 	if err != nil {
 		log.Info().Err(err)
 		if err == sql.ErrNoRows {
 			log.Info().Msg("Creating game...")
-			err = g.Tee.GetTeeByName("White")
+			err = g.Tee.GetTeeByName(ctx, "White")
 			if err != nil {
 				return err
 			}
-			err = g.AddGame()
+			err = g.AddGame(ctx)
 			if err != nil {
 				return err
 			}
@@ -1289,6 +2502,8 @@ func errorHandlerStatus(w http.ResponseWriter, r *http.Request, e string, status
 
 	p.Title = e
 
+	w.WriteHeader(status)
+
 	renderTemplate(w, "error", &p)
 }
 
@@ -1306,8 +2521,60 @@ func main() {
 	}
 	defer db.Con.Close()
 
+	if getEnv("MPMIGRATE", "true") == "true" {
+		err = db.Migrate(db.Con, db.Dialect, -1)
+		if err != nil {
+			log.Fatal().Msgf("Could not migrate database: %s", err)
+		}
+	}
+
 	listenport := getEnv("listenport", "8000")
 
+	err = loadRules()
+	if err != nil {
+		log.Fatal().Msgf("Could not load game rules: %s", err)
+	}
+
+	err = sms.Configure()
+	if err != nil {
+		log.Fatal().Msgf("Could not configure messaging: %s", err)
+	}
+
+	// Only adding a game needs a forecast, so the site still runs without.
+	err = weather.Configure()
+	if err != nil {
+		log.Error().Msgf("Could not configure the weather provider: %s", err)
+	}
+
+	err = game.Configure()
+	if err != nil {
+		log.Fatal().Msgf("Could not configure games: %s", err)
+	}
+
+	err = inbound.Configure()
+	if err != nil {
+		log.Fatal().Msgf("Could not configure inbound texts: %s", err)
+	}
+
+	wk, err := loadWorker()
+	if err != nil {
+		log.Fatal().Msgf("Could not configure the message queue: %s", err)
+	}
+	go func() {
+		log.Error().Msgf("Message queue stopped: %s", wk.Run(context.Background()))
+	}()
+	go func() {
+		log.Error().Msgf("Outbox stopped: %s", outbox.Run(context.Background(), time.Minute))
+	}()
+
+	scheduler, err = loadSchedule()
+	if err != nil {
+		log.Fatal().Msgf("Could not configure the schedule: %s", err)
+	}
+	go func() {
+		log.Error().Msgf("Scheduler stopped: %s", scheduler.Run(context.Background()))
+	}()
+
 	r := mux.NewRouter()
 
 	r.HandleFunc("/", makeHandler(indexHandler))
@@ -1318,46 +2585,62 @@ func main() {
 	sr.HandleFunc("/home", makeHandler(homeHandler))
 
 	sr.HandleFunc("/players", makeHandler(playerHandler))
-	sr.HandleFunc("/playeradd", makeHandler(playeraddHandler))
-	sr.HandleFunc("/playeredit/{id}", makeHandler(playereditHandler))
+	sr.HandleFunc("/playeradd", makeHandler(permit(role.PlayersWrite, nil, playeraddHandler)))
+	sr.HandleFunc("/playeredit/{id}", makeHandler(permit(role.PlayersWrite, ownPlayer, playereditHandler)))
 	sr.HandleFunc("/playerview/{id}", makeHandler(playerviewHandler))
 	sr.HandleFunc("/playerinfo", makeHandler(playerinfoHandler))
 
-	fr.HandleFunc("/postplayer", makeHandler(postPlayerHandler)).Methods("POST")
-	fr.HandleFunc("/putplayer", makeHandler(putPlayerHandler)).Methods("PUT")
-	fr.HandleFunc("/delplayer/{id}", makeHandler(delPlayerHandler)).Methods("DELETE")
+	fr.HandleFunc("/postplayer", makeHandler(permit(role.PlayersWrite, nil, postPlayerHandler))).Methods("POST")
+	fr.HandleFunc("/putplayer", makeHandler(permit(role.PlayersWrite, ownProfile, putPlayerHandler))).Methods("PUT")
+	fr.HandleFunc("/delplayer/{id}", makeHandler(permit(role.PlayersWrite, nil, delPlayerHandler))).Methods("DELETE")
+
+	sr.HandleFunc("/permissions", makeHandler(permit(role.RolesManage, nil, permissionsHandler)))
+	fr.HandleFunc("/putpermissions", makeHandler(permit(role.RolesManage, nil, putPermissionsHandler))).Methods("PUT")
 
 	sr.HandleFunc("/message", makeHandler(messageHandler))
 	sr.HandleFunc("/messageinfo", makeHandler(messageinfoHandler))
+	sr.HandleFunc("/blasts", makeHandler(blastsHandler))
 
-	fr.HandleFunc("/postmessage/{id}", makeHandler(postMessageHandler)).Methods("POST")
-	fr.HandleFunc("/posttournymessage/{id}", makeHandler(postTournamentMessageHandler)).Methods("POST")
+	fr.HandleFunc("/postmessage/{id}", makeHandler(permit(role.MessagesSend, nil, postMessageHandler))).Methods("POST")
+	fr.HandleFunc("/posttournymessage/{id}", makeHandler(permit(role.MessagesSend, nil, postTournamentMessageHandler))).Methods("POST")
 
 	sr.HandleFunc("/events", makeHandler(eventHandler))
 	sr.HandleFunc("/eventadd", makeHandler(eventaddHandler))
-	sr.HandleFunc("/eventedit/{id}", makeHandler(eventeditHandler))
+	sr.HandleFunc("/eventedit/{id}", makeHandler(permit(role.EventsManage, ownEvent, eventeditHandler)))
 	sr.HandleFunc("/eventview/{id}", makeHandler(eventviewHandler))
 	sr.HandleFunc("/eventinfo", makeHandler(eventinfoHandler))
 
 	fr.HandleFunc("/postevent", makeHandler(postEventHandler)).Methods("POST")
-	fr.HandleFunc("/putevent/{id}", makeHandler(putEventHandler)).Methods("PUT")
-	fr.HandleFunc("/delevent/{id}", makeHandler(delEventHandler)).Methods("DELETE")
+	fr.HandleFunc("/putevent/{id}", makeHandler(permit(role.EventsManage, ownEvent, putEventHandler))).Methods("PUT")
+	fr.HandleFunc("/delevent/{id}", makeHandler(permit(role.EventsManage, ownEvent, delEventHandler))).Methods("DELETE")
 
-	fr.HandleFunc("/postmember/{id}", makeHandler(postMemberHandler)).Methods("POST")
-	fr.HandleFunc("/postmemberjoin/{id}/{pid}", makeHandler(putMemberJoinHandler)).Methods("POST")
-	fr.HandleFunc("/putmemberpay/{id}/{pid}", makeHandler(putMemberPayHandler)).Methods("PUT")
-	fr.HandleFunc("/putmemberunpay/{id}/{pid}", makeHandler(putMemberUnpayHandler)).Methods("PUT")
-	fr.HandleFunc("/delmember/{id}/{pid}", makeHandler(delMemberHandler)).Methods("DELETE")
+	fr.HandleFunc("/postmember/{id}", makeHandler(permit(role.EventsManage, ownEvent, postMemberHandler))).Methods("POST")
+	fr.HandleFunc("/postmemberjoin/{id}/{pid}", makeHandler(permit(role.EventsManage, joining, putMemberJoinHandler))).Methods("POST")
+	fr.HandleFunc("/putmemberpay/{id}/{pid}", makeHandler(permit(role.EventsManage, ownEvent, putMemberPayHandler))).Methods("PUT")
+	fr.HandleFunc("/putmemberunpay/{id}/{pid}", makeHandler(permit(role.EventsManage, ownEvent, putMemberUnpayHandler))).Methods("PUT")
+	fr.HandleFunc("/delmember/{id}/{pid}", makeHandler(permit(role.EventsManage, leaving, delMemberHandler))).Methods("DELETE")
 
 	sr.HandleFunc("/game", makeHandler(gameHandler))
-	sr.HandleFunc("/gamechange", makeHandler(gamechangeHandler))
-	fr.HandleFunc("/gameCheckin", makeHandler(gamecheckinHandler))
+	sr.HandleFunc("/gamechange", makeHandler(permit(role.GamesManage, nil, gamechangeHandler)))
+	sr.HandleFunc("/checkin", makeHandler(checkinHandler))
+	fr.HandleFunc("/postcheckin/{id}", makeHandler(postCheckinHandler)).Methods("POST")
+	fr.HandleFunc("/postcheckin/{id}/{pid}", makeHandler(permit(role.GamesManage, ownCard, postCheckinHandler))).Methods("POST")
+	fr.HandleFunc("/delcheckin/{id}", makeHandler(delCheckinHandler)).Methods("DELETE")
+	fr.HandleFunc("/delcheckin/{id}/{pid}", makeHandler(permit(role.GamesManage, ownCard, delCheckinHandler))).Methods("DELETE")
+	fr.HandleFunc("/postmystery/{id}", makeHandler(permit(role.GamesManage, nil, postMysteryHandler))).Methods("POST")
+	fr.HandleFunc("/postcancelgame/{id}", makeHandler(permit(role.GamesManage, nil, postCancelGameHandler))).Methods("POST")
+	fr.HandleFunc("/postdraw/{id}", makeHandler(permit(role.GamesManage, nil, postDrawHandler))).Methods("POST")
+	fr.HandleFunc("/putlockteams/{id}", makeHandler(permit(role.GamesManage, nil, putLockTeamsHandler))).Methods("PUT")
 	sr.HandleFunc("/gameinfo", makeHandler(gameinfoHandler))
 
-	fr.HandleFunc("/posteventmessage/{id}", makeHandler(postEventMessageHandler)).Methods("POST")
+	fr.HandleFunc("/posteventmessage/{id}", makeHandler(permit(role.EventsManage, eventMember, postEventMessageHandler))).Methods("POST")
 
 	sr.HandleFunc("/scores", makeHandler(scoresHandler))
 	sr.HandleFunc("/scoresinfo", makeHandler(scoresinfoHandler))
+	sr.HandleFunc("/gamescores/{id}", makeHandler(gamescoresHandler))
+
+	fr.HandleFunc("/postscore/{id}", makeHandler(permit(role.GamesManage, ownScore, postScoreHandler))).Methods("POST")
+	fr.HandleFunc("/delscore/{id}/{pid}", makeHandler(permit(role.GamesManage, ownCard, delScoreHandler))).Methods("DELETE")
 
 	r.HandleFunc("/auth", authHandler)
 	r.HandleFunc("/sendcode", sendcodeHandler)
@@ -1365,17 +2648,21 @@ func main() {
 	r.HandleFunc("/maketoken", maketokenHandler)
 	r.HandleFunc("/logout/{id}", logoutHandler)
 
+	r.HandleFunc("/inbound", inboundHandler).Methods("POST")
+
 	r.HandleFunc("/error", errorHandler)
-	r.HandleFunc("/cache", cacheHandler)
-	r.HandleFunc("/addalluser", addAllUserHandler)
+	r.HandleFunc("/cache", makeHandler(permit(role.SiteAdmin, nil, cacheHandler)))
+	r.HandleFunc("/addalluser", makeHandler(permit(role.SiteAdmin, nil, addAllUserHandler)))
 
+	// The load balancer checks this, so it stays where the static page was.
+	r.HandleFunc("/static/html/health.html", healthHandler)
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 
 	http.Handle("/", r)
 
 	go redirectToHTTPS()
 
-	err = cacheData()
+	err = cacheData(context.Background())
 	if err != nil {
 		log.Panic().Err(err)
 	}
@@ -0,0 +1,199 @@
+package weather
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+)
+
+// accuWeatherHour is one hour of an AccuWeather hourly forecast, in imperial
+// units.
+type accuWeatherHour struct {
+	EpochDateTime int64  `json:"EpochDateTime"`
+	WeatherIcon   int    `json:"WeatherIcon"`
+	IconPhrase    string `json:"IconPhrase"`
+	Temperature   struct {
+		Value float64 `json:"Value"`
+	} `json:"Temperature"`
+	RealFeelTemperature struct {
+		Value float64 `json:"Value"`
+	} `json:"RealFeelTemperature"`
+	Wind struct {
+		Speed struct {
+			Value float64 `json:"Value"`
+		} `json:"Speed"`
+		Direction struct {
+			English string `json:"English"`
+		} `json:"Direction"`
+	} `json:"Wind"`
+	WindGust struct {
+		Speed struct {
+			Value float64 `json:"Value"`
+		} `json:"Speed"`
+	} `json:"WindGust"`
+	RelativeHumidity         int `json:"RelativeHumidity"`
+	CloudCover               int `json:"CloudCover"`
+	PrecipitationProbability int `json:"PrecipitationProbability"`
+	TotalLiquid              struct {
+		Value float64 `json:"Value"`
+	} `json:"TotalLiquid"`
+	Link string `json:"Link"`
+}
+
+func (h accuWeatherHour) weather() Weather {
+	return Weather{
+		Date:          time.Unix(h.EpochDateTime, 0).UTC(),
+		Temperature:   int64(math.Round(h.Temperature.Value)),
+		FeelsLike:     int64(math.Round(h.RealFeelTemperature.Value)),
+		Precipitation: h.TotalLiquid.Value,
+		ChanceOfRain:  int64(h.PrecipitationProbability),
+		Wind:          h.Wind.Speed.Value,
+		WindGust:      h.WindGust.Speed.Value,
+		WindDirection: h.Wind.Direction.English,
+		Humidity:      int64(h.RelativeHumidity),
+		CloudCover:    int64(h.CloudCover),
+		WeatherText:   h.IconPhrase,
+		WeatherIcon:   fmt.Sprintf("https://developer.accuweather.com/sites/default/files/%02d-s.png", h.WeatherIcon),
+		WeatherLink:   h.Link,
+	}
+}
+
+// accuWeatherObservation is one of AccuWeather's current conditions, with
+// the imperial half of each measurement.
+type accuWeatherObservation struct {
+	EpochTime   int64  `json:"EpochTime"`
+	WeatherText string `json:"WeatherText"`
+	WeatherIcon int    `json:"WeatherIcon"`
+	Temperature struct {
+		Imperial accuWeatherValue `json:"Imperial"`
+	} `json:"Temperature"`
+	RealFeelTemperature struct {
+		Imperial accuWeatherValue `json:"Imperial"`
+	} `json:"RealFeelTemperature"`
+	Wind struct {
+		Direction struct {
+			English string `json:"English"`
+		} `json:"Direction"`
+		Speed struct {
+			Imperial accuWeatherValue `json:"Imperial"`
+		} `json:"Speed"`
+	} `json:"Wind"`
+	WindGust struct {
+		Speed struct {
+			Imperial accuWeatherValue `json:"Imperial"`
+		} `json:"Speed"`
+	} `json:"WindGust"`
+	RelativeHumidity int `json:"RelativeHumidity"`
+	CloudCover       int `json:"CloudCover"`
+	Precip1hr        struct {
+		Imperial accuWeatherValue `json:"Imperial"`
+	} `json:"Precip1hr"`
+	Link string `json:"Link"`
+}
+
+type accuWeatherValue struct {
+	Value float64 `json:"Value"`
+}
+
+func (o accuWeatherObservation) weather() Weather {
+	return Weather{
+		Date:          time.Unix(o.EpochTime, 0).UTC(),
+		Temperature:   int64(math.Round(o.Temperature.Imperial.Value)),
+		FeelsLike:     int64(math.Round(o.RealFeelTemperature.Imperial.Value)),
+		Precipitation: o.Precip1hr.Imperial.Value,
+		Wind:          o.Wind.Speed.Imperial.Value,
+		WindGust:      o.WindGust.Speed.Imperial.Value,
+		WindDirection: o.Wind.Direction.English,
+		Humidity:      int64(o.RelativeHumidity),
+		CloudCover:    int64(o.CloudCover),
+		WeatherText:   o.WeatherText,
+		WeatherIcon:   fmt.Sprintf("https://developer.accuweather.com/sites/default/files/%02d-s.png", o.WeatherIcon),
+		WeatherLink:   o.Link,
+	}
+}
+
+// AccuWeatherProvider gets forecasts from AccuWeather.  Its hourly forecast
+// only reaches 12 hours ahead, so it has to be asked on the morning of a
+// game, and its history only goes back 24 hours, so it has to be asked that
+// evening.
+type AccuWeatherProvider struct {
+	Key string
+	// Location is the course's AccuWeather location key.
+	Location string
+	// URL is where the hourly forecasts are, and HistoryURL the current
+	// conditions, there to be pointed elsewhere.
+	URL        string
+	HistoryURL string
+}
+
+func NewAccuWeatherProvider(key, location string) *AccuWeatherProvider {
+	return &AccuWeatherProvider{
+		Key:        key,
+		Location:   location,
+		URL:        "https://dataservice.accuweather.com/forecasts/v1/hourly/12hour/",
+		HistoryURL: "https://dataservice.accuweather.com/currentconditions/v1/",
+	}
+}
+
+func (p *AccuWeatherProvider) Forecast(day time.Time, hours []int) (WeatherHours, error) {
+	q := url.Values{}
+	q.Set("apikey", p.Key)
+	q.Set("details", "true")
+
+	var aw []accuWeatherHour
+	err := getJSON(p.URL+url.PathEscape(p.Location)+"?"+q.Encode(), nil, &aw)
+	if err != nil {
+		return nil, err
+	}
+
+	byTime := make(map[time.Time]accuWeatherHour)
+	for _, h := range aw {
+		byTime[time.Unix(h.EpochDateTime, 0).UTC()] = h
+	}
+
+	w := make(WeatherHours, 0, len(hours))
+	for _, h := range hours {
+		t := hourOf(day, h)
+		ah, ok := byTime[t]
+		if !ok {
+			return nil, fmt.Errorf("accuweather: %w at %s", ErrNoForecast, t.Format(time.RFC3339))
+		}
+		w = append(w, ah.weather())
+	}
+
+	return w, nil
+}
+
+// History fetches the last 24 hours of conditions and picks the one nearest
+// each hour.
+func (p *AccuWeatherProvider) History(day time.Time, hours []int) (WeatherHours, error) {
+	q := url.Values{}
+	q.Set("apikey", p.Key)
+	q.Set("details", "true")
+
+	var obs []accuWeatherObservation
+	err := getJSON(p.HistoryURL+url.PathEscape(p.Location)+"/historical/24?"+q.Encode(), nil, &obs)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := make([]time.Time, len(obs))
+	for i, o := range obs {
+		ts[i] = time.Unix(o.EpochTime, 0).UTC()
+	}
+
+	w := make(WeatherHours, 0, len(hours))
+	for _, h := range hours {
+		t := hourOf(day, h)
+		i, ok := nearest(ts, t)
+		if !ok {
+			return nil, fmt.Errorf("accuweather: %w at %s", ErrNoObservation, t.Format(time.RFC3339))
+		}
+		wt := obs[i].weather()
+		wt.Date = t
+		w = append(w, wt)
+	}
+
+	return w, nil
+}
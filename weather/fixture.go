@@ -0,0 +1,100 @@
+package weather
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mariners/db"
+	"math"
+	"os"
+	"time"
+)
+
+// fixtureFiles are the weatherapi.com responses a FixtureProvider serves.
+var fixtureFiles = []string{"weather_hour.json", "weather.json"}
+
+// fixtures are the captured responses built into the binary, so the fixture
+// provider works wherever it's run from.
+//
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// FixtureProvider serves forecasts captured from weatherapi.com, so games can
+// be added offline and in tests.  Each hour asked for gets whichever captured
+// hour, or current conditions, is closest to it in the day, moved to the day
+// and hour asked for.
+type FixtureProvider struct {
+	// Files holds the captured responses.
+	Files fs.FS
+}
+
+// NewFixtureProvider serves the responses captured in dir, or the ones built
+// in if dir is empty.
+func NewFixtureProvider(dir string) *FixtureProvider {
+	if dir == "" {
+		sub, _ := fs.Sub(fixtures, "fixtures")
+		return &FixtureProvider{Files: sub}
+	}
+
+	return &FixtureProvider{Files: os.DirFS(dir)}
+}
+
+func (p *FixtureProvider) Forecast(day time.Time, hours []int) (WeatherHours, error) {
+	captured := make([]weatherapiHour, 0)
+	for _, name := range fixtureFiles {
+		b, err := fs.ReadFile(p.Files, name)
+		if err != nil {
+			return nil, err
+		}
+
+		var wa weatherapiForecast
+		err = json.Unmarshal(b, &wa)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if wa.Current.LastUpdatedEpoch != 0 {
+			captured = append(captured, wa.Current)
+		}
+		for _, fd := range wa.Forecast.Forecastday {
+			captured = append(captured, fd.Hour...)
+		}
+	}
+	if len(captured) == 0 {
+		return nil, fmt.Errorf("fixture: %w", ErrNoForecast)
+	}
+
+	w := make(WeatherHours, 0, len(hours))
+	for _, h := range hours {
+		best := captured[0]
+		for _, c := range captured[1:] {
+			if hoursApart(c, h) < hoursApart(best, h) {
+				best = c
+			}
+		}
+
+		wt, err := best.weather()
+		if err != nil {
+			return nil, err
+		}
+		wt.Date = hourOf(day, h)
+		w = append(w, wt)
+	}
+
+	return w, nil
+}
+
+// hoursApart is how far c is from hour h of its day, in the league's
+// timezone.
+func hoursApart(c weatherapiHour, h int) float64 {
+	t := db.Local(c.time())
+
+	return math.Abs(float64(t.Hour()) + float64(t.Minute())/60 - float64(h))
+}
+
+// History serves the captured hours the same way Forecast does, there being
+// no captured observations.
+func (p *FixtureProvider) History(day time.Time, hours []int) (WeatherHours, error) {
+	return p.Forecast(day, hours)
+}
@@ -0,0 +1,58 @@
+package weather
+
+import (
+	"mariners/db"
+	"testing"
+	"time"
+)
+
+func TestFixtureProvider(t *testing.T) {
+	tee, err := db.ParseLocal("2006-01-02 15:04", "2024-06-01 11:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hours := []int{11, 12, 13}
+
+	for name, p := range map[string]*FixtureProvider{
+		"built in": NewFixtureProvider(""),
+		"from dir": NewFixtureProvider("fixtures"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			SetProvider(p)
+			defer SetProvider(nil)
+
+			w, err := Fetch(tee, 3*time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(w) != len(hours) {
+				t.Fatalf("%d hours, want %d", len(w), len(hours))
+			}
+			for i, wt := range w {
+				lt := db.Local(wt.Date)
+				if lt.Hour() != hours[i] || lt.Day() != 1 || lt.Month() != time.June {
+					t.Errorf("hour %d is for %s, want %d:00 on June 1", i, lt, hours[i])
+				}
+				if wt.WeatherText == "" || wt.Temperature == 0 {
+					t.Errorf("hour %d came back empty: %+v", i, wt)
+				}
+			}
+
+			o, err := FetchObserved(tee, 3*time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(o) != len(hours) {
+				t.Errorf("%d observed hours, want %d", len(o), len(hours))
+			}
+		})
+	}
+}
+
+func TestFixtureProviderMissingDir(t *testing.T) {
+	p := NewFixtureProvider("no-such-dir")
+	_, err := p.Forecast(time.Now(), []int{12})
+	if err == nil {
+		t.Error("forecast from a missing directory succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package weather
+
+import (
+	"context"
+	"mariners/db"
+	"mariners/db/dbtest"
+	"testing"
+	"time"
+)
+
+func TestWeatherHostileStrings(t *testing.T) {
+	con := dbtest.Open(t)
+	s := NewSQLWeatherStore(con)
+	ctx := context.Background()
+	date := time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC)
+
+	for i, h := range dbtest.Hostile {
+		res, err := con.Exec("INSERT INTO game (idgame, game_date, tee_time, idninthtee, ismatch) VALUES (NULL, ?, ?, 0, 0)", db.FormatTime(date), db.FormatTime(date))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gid, _ := res.LastInsertId()
+		w := WeatherHours{{Date: date, WindDirection: h, WeatherText: h, WeatherIcon: h, WeatherLink: h}}
+		err = s.SetGameWeather(ctx, gid, w, h)
+		if err != nil {
+			t.Fatalf("forecasting %q: %s", h, err)
+		}
+		got, err := s.GetGameWeather(ctx, gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkWeather(t, "forecast", got, h)
+
+		rs, err := s.GetRevisions(ctx, gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rs) != 1 || rs[0].Reason != h {
+			t.Fatalf("revisions read back as %+v, want one for %q", rs, h)
+		}
+		checkWeather(t, "revised", rs[0].Hours, h)
+
+		u := dbtest.Hostile[(i+1)%len(dbtest.Hostile)]
+		w[0].WindDirection, w[0].WeatherText, w[0].WeatherIcon, w[0].WeatherLink = u, u, u, u
+		err = s.SetGameWeather(ctx, gid, w, u)
+		if err != nil {
+			t.Fatalf("updating to %q: %s", u, err)
+		}
+		got, err = s.GetGameWeather(ctx, gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkWeather(t, "updated", got, u)
+
+		err = s.SetGameObserved(ctx, gid, WeatherHours{{Date: date, WindDirection: h, WeatherText: h, WeatherIcon: h, WeatherLink: h}})
+		if err != nil {
+			t.Fatalf("observing %q: %s", h, err)
+		}
+		got, err = s.GetGameObserved(ctx, gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkWeather(t, "observed", got, h)
+	}
+}
+
+func checkWeather(t *testing.T, how string, w WeatherHours, want string) {
+	t.Helper()
+
+	if len(w) != 1 {
+		t.Fatalf("%s %d hours, want 1", how, len(w))
+	}
+	got := w[0]
+	if got.WindDirection != want || got.WeatherText != want || got.WeatherIcon != want || got.WeatherLink != want {
+		t.Errorf("%s hour read back as %q / %q / %q / %q, want %q", how, got.WindDirection, got.WeatherText, got.WeatherIcon, got.WeatherLink, want)
+	}
+}
@@ -0,0 +1,254 @@
+package weather
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nwsPoint is the part of the NWS points response that says where a place's
+// forecasts are, and which stations observe it.
+type nwsPoint struct {
+	Properties struct {
+		ForecastHourly      string `json:"forecastHourly"`
+		ObservationStations string `json:"observationStations"`
+	} `json:"properties"`
+}
+
+// nwsStations lists stations, nearest first, each ID being the station's
+// address.
+type nwsStations struct {
+	Features []struct {
+		ID string `json:"id"`
+	} `json:"features"`
+}
+
+// nwsObservations is what a station observed, newest first.
+type nwsObservations struct {
+	Features []struct {
+		Properties nwsObservation `json:"properties"`
+	} `json:"features"`
+}
+
+// nwsObservation is one observation, in metric units.  Anything the station
+// didn't measure is null, and so zero.
+type nwsObservation struct {
+	Timestamp             time.Time `json:"timestamp"`
+	TextDescription       string    `json:"textDescription"`
+	Icon                  string    `json:"icon"`
+	Temperature           nwsValue  `json:"temperature"`
+	WindSpeed             nwsValue  `json:"windSpeed"`
+	WindGust              nwsValue  `json:"windGust"`
+	WindDirection         nwsValue  `json:"windDirection"`
+	RelativeHumidity      nwsValue  `json:"relativeHumidity"`
+	PrecipitationLastHour nwsValue  `json:"precipitationLastHour"`
+}
+
+type nwsValue struct {
+	Value float64 `json:"value"`
+}
+
+// compass are the points of the compass, clockwise from north.
+var compass = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// weather converts o from metric, with the temperature having to do for how
+// it feels.
+func (o nwsObservation) weather(link string) Weather {
+	t := o.Temperature.Value*9/5 + 32
+	dir := int(math.Round(o.WindDirection.Value/22.5)) % len(compass)
+
+	return Weather{
+		Date:          o.Timestamp.UTC(),
+		Temperature:   int64(math.Round(t)),
+		FeelsLike:     int64(math.Round(t)),
+		Precipitation: math.Round(o.PrecipitationLastHour.Value/25.4*100) / 100,
+		Wind:          math.Round(o.WindSpeed.Value / 1.609344),
+		WindGust:      math.Round(o.WindGust.Value / 1.609344),
+		WindDirection: compass[dir],
+		Humidity:      int64(math.Round(o.RelativeHumidity.Value)),
+		WeatherText:   o.TextDescription,
+		WeatherIcon:   o.Icon,
+		WeatherLink:   link,
+	}
+}
+
+// nwsForecast is an NWS hourly forecast.
+type nwsForecast struct {
+	Properties struct {
+		Periods []nwsPeriod `json:"periods"`
+	} `json:"properties"`
+}
+
+type nwsPeriod struct {
+	StartTime        time.Time `json:"startTime"`
+	Temperature      float64   `json:"temperature"`
+	TemperatureUnit  string    `json:"temperatureUnit"`
+	WindSpeed        string    `json:"windSpeed"`
+	WindDirection    string    `json:"windDirection"`
+	Icon             string    `json:"icon"`
+	ShortForecast    string    `json:"shortForecast"`
+	RelativeHumidity struct {
+		Value float64 `json:"value"`
+	} `json:"relativeHumidity"`
+	ProbabilityOfPrecipitation struct {
+		Value float64 `json:"value"`
+	} `json:"probabilityOfPrecipitation"`
+}
+
+// weather converts p.  NWS hourly forecasts give the chance of rain but not
+// how much or the gusts, so those are left at zero, and the temperature has
+// to do for how it feels.
+func (p nwsPeriod) weather(link string) Weather {
+	t := p.Temperature
+	if p.TemperatureUnit == "C" {
+		t = t*9/5 + 32
+	}
+
+	return Weather{
+		Date:          p.StartTime.UTC(),
+		Temperature:   int64(math.Round(t)),
+		FeelsLike:     int64(math.Round(t)),
+		ChanceOfRain:  int64(math.Round(p.ProbabilityOfPrecipitation.Value)),
+		Wind:          nwsSpeed(p.WindSpeed),
+		WindDirection: p.WindDirection,
+		Humidity:      int64(p.RelativeHumidity.Value),
+		WeatherText:   p.ShortForecast,
+		WeatherIcon:   p.Icon,
+		WeatherLink:   link,
+	}
+}
+
+// nwsSpeed reads a wind speed like "10 mph" or "5 to 10 mph", taking the
+// higher end of a range.
+func nwsSpeed(s string) float64 {
+	var mph float64
+	for _, f := range strings.Fields(s) {
+		if v, err := strconv.ParseFloat(f, 64); err == nil {
+			mph = v
+		}
+	}
+
+	return mph
+}
+
+// NWSProvider gets forecasts from the US National Weather Service, which
+// needs no key.
+type NWSProvider struct {
+	// Location is the course as latitude,longitude.
+	Location string
+	// URL is the API's address, there to be pointed elsewhere.
+	URL string
+}
+
+func NewNWSProvider(location string) *NWSProvider {
+	return &NWSProvider{
+		Location: location,
+		URL:      "https://api.weather.gov",
+	}
+}
+
+// nwsHeader is sent with every request, since the NWS turns away requests
+// that don't say who they're from.
+var nwsHeader = http.Header{
+	"User-Agent": {"mariners golf league (https://mplinksters.club)"},
+	"Accept":     {"application/geo+json"},
+}
+
+// link is the NWS forecast page for the course.
+func (p *NWSProvider) link() string {
+	return "https://forecast.weather.gov/MapClick.php?textField1=" + strings.Replace(p.Location, ",", "&textField2=", 1)
+}
+
+// Forecast looks up where the course's forecasts are, then fetches its hourly
+// forecast and picks out hours.
+func (p *NWSProvider) Forecast(day time.Time, hours []int) (WeatherHours, error) {
+	var pt nwsPoint
+	err := getJSON(p.URL+"/points/"+p.Location, nwsHeader, &pt)
+	if err != nil {
+		return nil, err
+	}
+	if pt.Properties.ForecastHourly == "" {
+		return nil, fmt.Errorf("nws: no hourly forecast for %s", p.Location)
+	}
+
+	var f nwsForecast
+	err = getJSON(pt.Properties.ForecastHourly, nwsHeader, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	link := p.link()
+	byTime := make(map[time.Time]nwsPeriod)
+	for _, pd := range f.Properties.Periods {
+		byTime[pd.StartTime.UTC()] = pd
+	}
+
+	w := make(WeatherHours, 0, len(hours))
+	for _, h := range hours {
+		t := hourOf(day, h)
+		pd, ok := byTime[t]
+		if !ok {
+			return nil, fmt.Errorf("nws: %w at %s", ErrNoForecast, t.Format(time.RFC3339))
+		}
+		w = append(w, pd.weather(link))
+	}
+
+	return w, nil
+}
+
+// History looks up the station nearest the course, then fetches what it
+// observed over the hours and picks the observation nearest each.
+func (p *NWSProvider) History(day time.Time, hours []int) (WeatherHours, error) {
+	if len(hours) == 0 {
+		return WeatherHours{}, nil
+	}
+
+	var pt nwsPoint
+	err := getJSON(p.URL+"/points/"+p.Location, nwsHeader, &pt)
+	if err != nil {
+		return nil, err
+	}
+	var ss nwsStations
+	if pt.Properties.ObservationStations != "" {
+		err = getJSON(pt.Properties.ObservationStations, nwsHeader, &ss)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(ss.Features) == 0 {
+		return nil, fmt.Errorf("nws: no observation stations for %s", p.Location)
+	}
+
+	q := url.Values{}
+	q.Set("start", hourOf(day, hours[0]).Add(-30*time.Minute).Format(time.RFC3339))
+	q.Set("end", hourOf(day, hours[len(hours)-1]).Add(30*time.Minute).Format(time.RFC3339))
+	var obs nwsObservations
+	err = getJSON(ss.Features[0].ID+"/observations?"+q.Encode(), nwsHeader, &obs)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := make([]time.Time, len(obs.Features))
+	for i, f := range obs.Features {
+		ts[i] = f.Properties.Timestamp.UTC()
+	}
+
+	link := p.link()
+	w := make(WeatherHours, 0, len(hours))
+	for _, h := range hours {
+		t := hourOf(day, h)
+		i, ok := nearest(ts, t)
+		if !ok {
+			return nil, fmt.Errorf("nws: %w at %s", ErrNoObservation, t.Format(time.RFC3339))
+		}
+		wt := obs.Features[i].Properties.weather(link)
+		wt.Date = t
+		w = append(w, wt)
+	}
+
+	return w, nil
+}
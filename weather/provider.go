@@ -0,0 +1,171 @@
+package weather
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mariners/db"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Provider fetches hourly forecasts for the course, and what the weather
+// turned out to be.
+type Provider interface {
+	// Forecast returns the forecast for each of hours, in the league's
+	// timezone, on the league's day that day falls on.
+	Forecast(day time.Time, hours []int) (WeatherHours, error)
+	// History returns the weather observed in each of hours, in the
+	// league's timezone, on the league's day that day falls on.
+	History(day time.Time, hours []int) (WeatherHours, error)
+}
+
+// ErrNoForecast is returned when a provider has nothing for an hour asked
+// for, usually because it's too far ahead.
+var ErrNoForecast = errors.New("no forecast for that hour")
+
+// ErrNoObservation is returned when a provider has no observation for an
+// hour asked for, because it hasn't happened yet or is too far back.
+var ErrNoObservation = errors.New("no observation for that hour")
+
+// The course, for the providers that look it up by where it is.
+const (
+	defaultLocation    = "37.57,-122.28"
+	defaultAccuWeather = "332128"
+)
+
+var (
+	mu      sync.Mutex
+	current Provider
+)
+
+// Configure picks the Provider from the environment.  MPWEATHER is
+// "weatherapi" (the default), "accuweather", "nws", or "fixture" to serve the
+// forecasts captured in MPWEATHERFIXTURES, or the ones built in if that's
+// unset, without going online.  MPWEATHERKEY is the weatherapi.com or
+// AccuWeather API key, and MPWEATHERLOCATION where the course is:
+// latitude,longitude for weatherapi and NWS, or an AccuWeather location key.
+func Configure() error {
+	var p Provider
+
+	switch getEnv("MPWEATHER", "weatherapi") {
+	case "weatherapi":
+		key := getEnv("MPWEATHERKEY", "")
+		if key == "" {
+			return fmt.Errorf("MPWEATHERKEY is needed for weatherapi")
+		}
+		p = NewWeatherAPIProvider(key, getEnv("MPWEATHERLOCATION", defaultLocation))
+	case "accuweather":
+		key := getEnv("MPWEATHERKEY", "")
+		if key == "" {
+			return fmt.Errorf("MPWEATHERKEY is needed for accuweather")
+		}
+		p = NewAccuWeatherProvider(key, getEnv("MPWEATHERLOCATION", defaultAccuWeather))
+	case "nws":
+		p = NewNWSProvider(getEnv("MPWEATHERLOCATION", defaultLocation))
+	case "fixture":
+		p = NewFixtureProvider(getEnv("MPWEATHERFIXTURES", ""))
+	default:
+		return fmt.Errorf("unknown MPWEATHER provider %s", os.Getenv("MPWEATHER"))
+	}
+
+	SetProvider(p)
+
+	return nil
+}
+
+// SetProvider replaces the Provider used by the package functions.
+func SetProvider(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = p
+}
+
+// GetProvider returns the Provider in use, configuring one from the
+// environment the first time it's needed.
+func GetProvider() (Provider, error) {
+	mu.Lock()
+	p := current
+	mu.Unlock()
+
+	if p != nil {
+		return p, nil
+	}
+
+	err := Configure()
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return current, nil
+}
+
+// client is what the providers fetch with, so a slow provider can't hold up
+// adding a game for long.
+var client = &http.Client{Timeout: 15 * time.Second}
+
+// getJSON fetches url and decodes its JSON into v.  Anything but a 200 is an
+// error, with the start of the body to say why.
+func getJSON(url string, header http.Header, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, body)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// hourOf returns hour h, in the league's timezone, on the league's day that
+// day falls on.
+func hourOf(day time.Time, h int) time.Time {
+	ls := db.Local(day)
+
+	return time.Date(ls.Year(), ls.Month(), ls.Day(), h, 0, 0, 0, ls.Location()).UTC()
+}
+
+// nearest returns the index of the time in ts closest to t, as long as it's
+// within half an hour, since observations aren't taken on the hour.
+func nearest(ts []time.Time, t time.Time) (int, bool) {
+	best := -1
+	var bestd time.Duration
+	for i, o := range ts {
+		d := o.Sub(t)
+		if d < 0 {
+			d = -d
+		}
+		if d <= 30*time.Minute && (best < 0 || d < bestd) {
+			best, bestd = i, d
+		}
+	}
+
+	return best, best >= 0
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,336 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"mariners/db"
+	"time"
+)
+
+// WeatherStore loads and saves hourly forecasts.
+type WeatherStore interface {
+	AddWeather(ctx context.Context, w *Weather) error
+	GetWeather(ctx context.Context, id int64) (Weather, error)
+	// GetWeatherBetween returns the forecasts from s up to, but not
+	// including, f, earliest first.  Observed weather is left out.
+	GetWeatherBetween(ctx context.Context, s, f time.Time) (WeatherHours, error)
+	// GetGameWeather returns game gid's forecast, earliest first.
+	GetGameWeather(ctx context.Context, gid int64) (WeatherHours, error)
+	// SetGameWeather makes w game gid's forecast, and keeps it as a
+	// Revision fetched for reason.  Hours the game already has are updated
+	// in place, and hours it has that aren't in w are deleted.
+	SetGameWeather(ctx context.Context, gid int64, w WeatherHours, reason string) error
+	// GetGameObserved returns the weather observed during game gid,
+	// earliest first.
+	GetGameObserved(ctx context.Context, gid int64) (WeatherHours, error)
+	// SetGameObserved makes w the weather observed during game gid.
+	SetGameObserved(ctx context.Context, gid int64, w WeatherHours) error
+	// GetRevisions returns game gid's forecast revisions, newest first.
+	GetRevisions(ctx context.Context, gid int64) (Revisions, error)
+}
+
+// SQLWeatherStore is a WeatherStore backed by the weather table.
+type SQLWeatherStore struct {
+	DB *sql.DB
+}
+
+func NewSQLWeatherStore(con *sql.DB) *SQLWeatherStore {
+	return &SQLWeatherStore{DB: con}
+}
+
+var store WeatherStore
+
+// SetStore replaces the WeatherStore used by the package functions, which
+// otherwise use db.Con.
+func SetStore(s WeatherStore) {
+	store = s
+}
+
+func getStore() WeatherStore {
+	if store != nil {
+		return store
+	}
+
+	return NewSQLWeatherStore(db.Con)
+}
+
+const weatherColumns = "idweather, " +
+	"idgame, " +
+	"observed, " +
+	"weather_date, " +
+	"temperature, " +
+	"feels_like, " +
+	"precipitation, " +
+	"chance_of_rain, " +
+	"wind, " +
+	"wind_gust, " +
+	"wind_direction, " +
+	"humidity, " +
+	"cloudcover, " +
+	"weather_text, " +
+	"weather_icon, " +
+	"weather_link "
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWeather(row scanner, w *Weather) error {
+	var gid sql.NullInt64
+	err := row.Scan(
+		&w.ID,
+		&gid,
+		&w.Observed,
+		db.ScanTime(&w.Date),
+		&w.Temperature,
+		&w.FeelsLike,
+		&w.Precipitation,
+		&w.ChanceOfRain,
+		&w.Wind,
+		&w.WindGust,
+		&w.WindDirection,
+		&w.Humidity,
+		&w.CloudCover,
+		&w.WeatherText,
+		&w.WeatherIcon,
+		&w.WeatherLink)
+	w.GameID = gid.Int64
+
+	return err
+}
+
+// gameID is what idgame is written as: NULL for a forecast that isn't a
+// game's.
+func gameID(gid int64) interface{} {
+	if gid == 0 {
+		return nil
+	}
+
+	return gid
+}
+
+func (s *SQLWeatherStore) AddWeather(ctx context.Context, w *Weather) error {
+	query := "INSERT INTO weather (idweather, idgame, observed, weather_date, temperature, feels_like, precipitation, chance_of_rain, wind, wind_gust, wind_direction, humidity, cloudcover, weather_text, weather_icon, weather_link) " +
+		"VALUES (NULL, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	res, err := db.Q(ctx, s.DB).ExecContext(ctx, query,
+		gameID(w.GameID),
+		w.Observed,
+		db.FormatTime(w.Date),
+		w.Temperature,
+		w.FeelsLike,
+		w.Precipitation,
+		w.ChanceOfRain,
+		w.Wind,
+		w.WindGust,
+		w.WindDirection,
+		w.Humidity,
+		w.CloudCover,
+		w.WeatherText,
+		w.WeatherIcon,
+		w.WeatherLink)
+	if err != nil {
+		return err
+	}
+
+	w.ID, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLWeatherStore) GetWeather(ctx context.Context, id int64) (Weather, error) {
+	w := Weather{}
+
+	query := "SELECT " + weatherColumns + "FROM weather WHERE idweather=?"
+	err := scanWeather(db.Q(ctx, s.DB).QueryRowContext(ctx, query, id), &w)
+
+	return w, err
+}
+
+func (s *SQLWeatherStore) GetWeatherBetween(ctx context.Context, st, f time.Time) (WeatherHours, error) {
+	ws := make(WeatherHours, 0)
+
+	query := "SELECT " + weatherColumns + "FROM weather WHERE weather_date >= ? AND weather_date < ? AND observed=? ORDER BY weather_date"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, db.FormatTime(st), db.FormatTime(f), false)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w Weather
+		err = scanWeather(rows, &w)
+		if err != nil {
+			return nil, err
+		}
+
+		ws = append(ws, w)
+	}
+
+	return ws, rows.Err()
+}
+
+func (s *SQLWeatherStore) GetGameWeather(ctx context.Context, gid int64) (WeatherHours, error) {
+	return s.gameHours(ctx, gid, false)
+}
+
+func (s *SQLWeatherStore) GetGameObserved(ctx context.Context, gid int64) (WeatherHours, error) {
+	return s.gameHours(ctx, gid, true)
+}
+
+// gameHours returns game gid's observed weather or its forecast, earliest
+// first.
+func (s *SQLWeatherStore) gameHours(ctx context.Context, gid int64, observed bool) (WeatherHours, error) {
+	ws := make(WeatherHours, 0)
+
+	query := "SELECT " + weatherColumns + "FROM weather WHERE idgame=? AND observed=? ORDER BY weather_date"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, gid, observed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w Weather
+		err = scanWeather(rows, &w)
+		if err != nil {
+			return nil, err
+		}
+
+		ws = append(ws, w)
+	}
+
+	return ws, rows.Err()
+}
+
+func (s *SQLWeatherStore) SetGameWeather(ctx context.Context, gid int64, w WeatherHours, reason string) error {
+	return db.InTx(ctx, s.DB, func(ctx context.Context) error {
+		old, err := s.GetGameWeather(ctx, gid)
+		if err != nil {
+			return err
+		}
+		ids := make(map[time.Time]int64)
+		for _, o := range old {
+			ids[o.Date] = o.ID
+		}
+
+		query := "UPDATE weather SET temperature=?, feels_like=?, precipitation=?, chance_of_rain=?, wind=?, wind_gust=?, wind_direction=?, humidity=?, cloudcover=?, weather_text=?, weather_icon=?, weather_link=? WHERE idweather=?"
+		for i := range w {
+			w[i].GameID = gid
+			id, ok := ids[w[i].Date.UTC()]
+			if !ok {
+				err = s.AddWeather(ctx, &w[i])
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			w[i].ID = id
+			delete(ids, w[i].Date.UTC())
+			_, err = db.Q(ctx, s.DB).ExecContext(ctx, query,
+				w[i].Temperature,
+				w[i].FeelsLike,
+				w[i].Precipitation,
+				w[i].ChanceOfRain,
+				w[i].Wind,
+				w[i].WindGust,
+				w[i].WindDirection,
+				w[i].Humidity,
+				w[i].CloudCover,
+				w[i].WeatherText,
+				w[i].WeatherIcon,
+				w[i].WeatherLink,
+				id)
+			if err != nil {
+				return err
+			}
+		}
+
+		query = "DELETE FROM weather WHERE idweather=?"
+		for _, id := range ids {
+			_, err = db.Q(ctx, s.DB).ExecContext(ctx, query, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		forecast, err := json.Marshal(w)
+		if err != nil {
+			return err
+		}
+		query = "INSERT INTO weather_revision (idrevision, idgame, revision_date, reason, forecast) VALUES (NULL, ?, ?, ?, ?)"
+		_, err = db.Q(ctx, s.DB).ExecContext(ctx, query,
+			gid,
+			db.FormatTime(time.Now()),
+			reason,
+			string(forecast))
+
+		return err
+	})
+}
+
+func (s *SQLWeatherStore) SetGameObserved(ctx context.Context, gid int64, w WeatherHours) error {
+	return db.InTx(ctx, s.DB, func(ctx context.Context) error {
+		query := "DELETE FROM weather WHERE idgame=? AND observed=?"
+		_, err := db.Q(ctx, s.DB).ExecContext(ctx, query, gid, true)
+		if err != nil {
+			return err
+		}
+
+		for i := range w {
+			w[i].GameID = gid
+			w[i].Observed = true
+			err = s.AddWeather(ctx, &w[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+const revisionColumns = "idrevision, idgame, revision_date, reason, forecast "
+
+func scanRevision(row scanner, r *Revision) error {
+	var forecast string
+	err := row.Scan(
+		&r.ID,
+		&r.GameID,
+		db.ScanTime(&r.Date),
+		&r.Reason,
+		&forecast)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(forecast), &r.Hours)
+}
+
+func (s *SQLWeatherStore) GetRevisions(ctx context.Context, gid int64) (Revisions, error) {
+	rs := make(Revisions, 0)
+
+	query := "SELECT " + revisionColumns + "FROM weather_revision WHERE idgame=? ORDER BY revision_date DESC, idrevision DESC"
+	rows, err := db.Q(ctx, s.DB).QueryContext(ctx, query, gid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Revision
+		err = scanRevision(rows, &r)
+		if err != nil {
+			return nil, err
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs, rows.Err()
+}
@@ -1,18 +1,16 @@
 package weather
 
-// weather is a daemon that will download weather data from accuweather once daily at:
+// weather fetches each game's forecast from a Provider and keeps it, along
+// with every revision of it, and what the weather turned out to be.  The ui
+// refreshes today's game's forecast on a schedule, by default once daily at:
 //	12 PM on Sunday and Saturday
 //	1 PM on Monday - Friday
+// and again shortly before the tee time.
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"mariners/db"
-	"math"
-	"net/http"
-	"net/url"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -20,770 +18,202 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// accuWeather is a []struct that can store the unmarshalled results of the accuweather current conditions API
-/* type accuWeather []struct {
-	LocalObservationDateTime string      `json:"LocalObservationDateTime"`
-	EpochTime                int         `json:"EpochTime"`
-	WeatherText              string      `json:"WeatherText"`
-	WeatherIcon              int         `json:"WeatherIcon"`
-	HasPrecipitation         bool        `json:"HasPrecipitation"`
-	PrecipitationType        interface{} `json:"PrecipitationType"`
-	IsDayTime                bool        `json:"IsDayTime"`
-	Temperature              struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"Temperature"`
-	RealFeelTemperature struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-			Phrase   string  `json:"Phrase"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-			Phrase   string  `json:"Phrase"`
-		} `json:"Imperial"`
-	} `json:"RealFeelTemperature"`
-	RealFeelTemperatureShade struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-			Phrase   string  `json:"Phrase"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-			Phrase   string  `json:"Phrase"`
-		} `json:"Imperial"`
-	} `json:"RealFeelTemperatureShade"`
-	RelativeHumidity       int `json:"RelativeHumidity"`
-	IndoorRelativeHumidity int `json:"IndoorRelativeHumidity"`
-	DewPoint               struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"DewPoint"`
-	Wind struct {
-		Direction struct {
-			Degrees   int    `json:"Degrees"`
-			Localized string `json:"Localized"`
-			English   string `json:"English"`
-		} `json:"Direction"`
-		Speed struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Speed"`
-	} `json:"Wind"`
-	WindGust struct {
-		Speed struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Speed"`
-	} `json:"WindGust"`
-	UVIndex     int    `json:"UVIndex"`
-	UVIndexText string `json:"UVIndexText"`
-	Visibility  struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"Visibility"`
-	ObstructionsToVisibility string `json:"ObstructionsToVisibility"`
-	CloudCover               int    `json:"CloudCover"`
-	Ceiling                  struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"Ceiling"`
-	Pressure struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"Pressure"`
-	PressureTendency struct {
-		LocalizedText string `json:"LocalizedText"`
-		Code          string `json:"Code"`
-	} `json:"PressureTendency"`
-	Past24HourTemperatureDeparture struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"Past24HourTemperatureDeparture"`
-	ApparentTemperature struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"ApparentTemperature"`
-	WindChillTemperature struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"WindChillTemperature"`
-	WetBulbTemperature struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"WetBulbTemperature"`
-	Precip1Hr struct {
-		Metric struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Metric"`
-		Imperial struct {
-			Value    float64 `json:"Value"`
-			Unit     string  `json:"Unit"`
-			UnitType int     `json:"UnitType"`
-		} `json:"Imperial"`
-	} `json:"Precip1hr"`
-	PrecipitationSummary struct {
-		Precipitation struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Precipitation"`
-		PastHour struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"PastHour"`
-		Past3Hours struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Past3Hours"`
-		Past6Hours struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Past6Hours"`
-		Past9Hours struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Past9Hours"`
-		Past12Hours struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Past12Hours"`
-		Past18Hours struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Past18Hours"`
-		Past24Hours struct {
-			Metric struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Metric"`
-			Imperial struct {
-				Value    float64 `json:"Value"`
-				Unit     string  `json:"Unit"`
-				UnitType int     `json:"UnitType"`
-			} `json:"Imperial"`
-		} `json:"Past24Hours"`
-	} `json:"PrecipitationSummary"`
-	TemperatureSummary struct {
-		Past6HourRange struct {
-			Minimum struct {
-				Metric struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Metric"`
-				Imperial struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Imperial"`
-			} `json:"Minimum"`
-			Maximum struct {
-				Metric struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Metric"`
-				Imperial struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Imperial"`
-			} `json:"Maximum"`
-		} `json:"Past6HourRange"`
-		Past12HourRange struct {
-			Minimum struct {
-				Metric struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Metric"`
-				Imperial struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Imperial"`
-			} `json:"Minimum"`
-			Maximum struct {
-				Metric struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Metric"`
-				Imperial struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Imperial"`
-			} `json:"Maximum"`
-		} `json:"Past12HourRange"`
-		Past24HourRange struct {
-			Minimum struct {
-				Metric struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Metric"`
-				Imperial struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Imperial"`
-			} `json:"Minimum"`
-			Maximum struct {
-				Metric struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Metric"`
-				Imperial struct {
-					Value    float64 `json:"Value"`
-					Unit     string  `json:"Unit"`
-					UnitType int     `json:"UnitType"`
-				} `json:"Imperial"`
-			} `json:"Maximum"`
-		} `json:"Past24HourRange"`
-	} `json:"TemperatureSummary"`
-	MobileLink string `json:"MobileLink"`
-	Link       string `json:"Link"`
-}*/
-
-/* type weatherapi struct {
-	Location struct {
-		Name           string  `json:"name"`
-		Region         string  `json:"region"`
-		Country        string  `json:"country"`
-		Lat            float64 `json:"lat"`
-		Lon            float64 `json:"lon"`
-		TzID           string  `json:"tz_id"`
-		LocaltimeEpoch int     `json:"localtime_epoch"`
-		Localtime      string  `json:"localtime"`
-	} `json:"location"`
-	Current struct {
-		LastUpdatedEpoch int     `json:"last_updated_epoch"`
-		LastUpdated      string  `json:"last_updated"`
-		TempC            float64 `json:"temp_c"`
-		TempF            float64 `json:"temp_f"`
-		IsDay            int     `json:"is_day"`
-		Condition        struct {
-			Text string `json:"text"`
-			Icon string `json:"icon"`
-			Code int    `json:"code"`
-		} `json:"condition"`
-		WindMph    float64 `json:"wind_mph"`
-		WindKph    float64 `json:"wind_kph"`
-		WindDegree int     `json:"wind_degree"`
-		WindDir    string  `json:"wind_dir"`
-		PressureMb float64 `json:"pressure_mb"`
-		PressureIn float64 `json:"pressure_in"`
-		PrecipMm   float64 `json:"precip_mm"`
-		PrecipIn   float64 `json:"precip_in"`
-		Humidity   int     `json:"humidity"`
-		Cloud      int     `json:"cloud"`
-		FeelslikeC float64 `json:"feelslike_c"`
-		FeelslikeF float64 `json:"feelslike_f"`
-		VisKm      float64 `json:"vis_km"`
-		VisMiles   float64 `json:"vis_miles"`
-		Uv         float64 `json:"uv"`
-		GustMph    float64 `json:"gust_mph"`
-		GustKph    float64 `json:"gust_kph"`
-	} `json:"current"`
-} */
-
-type weatherapihour struct {
-	Location struct {
-		Name           string  `json:"name"`
-		Region         string  `json:"region"`
-		Country        string  `json:"country"`
-		Lat            float64 `json:"lat"`
-		Lon            float64 `json:"lon"`
-		TzID           string  `json:"tz_id"`
-		LocaltimeEpoch int     `json:"localtime_epoch"`
-		Localtime      string  `json:"localtime"`
-	} `json:"location"`
-	Current struct {
-		LastUpdatedEpoch int     `json:"last_updated_epoch"`
-		LastUpdated      string  `json:"last_updated"`
-		TempC            float64 `json:"temp_c"`
-		TempF            float64 `json:"temp_f"`
-		IsDay            int     `json:"is_day"`
-		Condition        struct {
-			Text string `json:"text"`
-			Icon string `json:"icon"`
-			Code int    `json:"code"`
-		} `json:"condition"`
-		WindMph    float64 `json:"wind_mph"`
-		WindKph    float64 `json:"wind_kph"`
-		WindDegree int     `json:"wind_degree"`
-		WindDir    string  `json:"wind_dir"`
-		PressureMb float64 `json:"pressure_mb"`
-		PressureIn float64 `json:"pressure_in"`
-		PrecipMm   float64 `json:"precip_mm"`
-		PrecipIn   float64 `json:"precip_in"`
-		Humidity   float64 `json:"humidity"`
-		Cloud      int     `json:"cloud"`
-		FeelslikeC float64 `json:"feelslike_c"`
-		FeelslikeF float64 `json:"feelslike_f"`
-		VisKm      float64 `json:"vis_km"`
-		VisMiles   float64 `json:"vis_miles"`
-		Uv         float64 `json:"uv"`
-		GustMph    float64 `json:"gust_mph"`
-		GustKph    float64 `json:"gust_kph"`
-	} `json:"current"`
-	Forecast struct {
-		Forecastday []struct {
-			Date      string `json:"date"`
-			DateEpoch int    `json:"date_epoch"`
-			Day       struct {
-				MaxtempC          float64 `json:"maxtemp_c"`
-				MaxtempF          float64 `json:"maxtemp_f"`
-				MintempC          float64 `json:"mintemp_c"`
-				MintempF          float64 `json:"mintemp_f"`
-				AvgtempC          float64 `json:"avgtemp_c"`
-				AvgtempF          float64 `json:"avgtemp_f"`
-				MaxwindMph        float64 `json:"maxwind_mph"`
-				MaxwindKph        float64 `json:"maxwind_kph"`
-				TotalprecipMm     float64 `json:"totalprecip_mm"`
-				TotalprecipIn     float64 `json:"totalprecip_in"`
-				TotalsnowCm       float64 `json:"totalsnow_cm"`
-				AvgvisKm          float64 `json:"avgvis_km"`
-				AvgvisMiles       float64 `json:"avgvis_miles"`
-				Avghumidity       float64 `json:"avghumidity"`
-				DailyWillItRain   int     `json:"daily_will_it_rain"`
-				DailyChanceOfRain int     `json:"daily_chance_of_rain"`
-				DailyWillItSnow   int     `json:"daily_will_it_snow"`
-				DailyChanceOfSnow int     `json:"daily_chance_of_snow"`
-				Condition         struct {
-					Text string `json:"text"`
-					Icon string `json:"icon"`
-					Code int    `json:"code"`
-				} `json:"condition"`
-				Uv float64 `json:"uv"`
-			} `json:"day"`
-			Astro struct {
-				Sunrise          string `json:"sunrise"`
-				Sunset           string `json:"sunset"`
-				Moonrise         string `json:"moonrise"`
-				Moonset          string `json:"moonset"`
-				MoonPhase        string `json:"moon_phase"`
-				MoonIllumination string `json:"moon_illumination"`
-				IsMoonUp         int    `json:"is_moon_up"`
-				IsSunUp          int    `json:"is_sun_up"`
-			} `json:"astro"`
-			Hour []struct {
-				TimeEpoch int     `json:"time_epoch"`
-				Time      string  `json:"time"`
-				TempC     float64 `json:"temp_c"`
-				TempF     float64 `json:"temp_f"`
-				IsDay     int     `json:"is_day"`
-				Condition struct {
-					Text string `json:"text"`
-					Icon string `json:"icon"`
-					Code int    `json:"code"`
-				} `json:"condition"`
-				WindMph      float64 `json:"wind_mph"`
-				WindKph      float64 `json:"wind_kph"`
-				WindDegree   float64 `json:"wind_degree"`
-				WindDir      string  `json:"wind_dir"`
-				PressureMb   float64 `json:"pressure_mb"`
-				PressureIn   float64 `json:"pressure_in"`
-				PrecipMm     float64 `json:"precip_mm"`
-				PrecipIn     float64 `json:"precip_in"`
-				Humidity     float64 `json:"humidity"`
-				Cloud        int     `json:"cloud"`
-				FeelslikeC   float64 `json:"feelslike_c"`
-				FeelslikeF   float64 `json:"feelslike_f"`
-				WindchillC   float64 `json:"windchill_c"`
-				WindchillF   float64 `json:"windchill_f"`
-				HeatindexC   float64 `json:"heatindex_c"`
-				HeatindexF   float64 `json:"heatindex_f"`
-				DewpointC    float64 `json:"dewpoint_c"`
-				DewpointF    float64 `json:"dewpoint_f"`
-				WillItRain   int     `json:"will_it_rain"`
-				ChanceOfRain int     `json:"chance_of_rain"`
-				WillItSnow   int     `json:"will_it_snow"`
-				ChanceOfSnow int     `json:"chance_of_snow"`
-				VisKm        float64 `json:"vis_km"`
-				VisMiles     float64 `json:"vis_miles"`
-				GustMph      float64 `json:"gust_mph"`
-				GustKph      float64 `json:"gust_kph"`
-				Uv           float64 `json:"uv"`
-			} `json:"hour"`
-		} `json:"forecastday"`
-	} `json:"forecast"`
-}
-
+// Weather is an hour of forecast, or of the weather observed once it's
+// passed.  GameID is the game it's for, if any.
 type Weather struct {
-	ID            int64   `json:"id"`
-	Date          string  `json:"date"`
-	Temperature   int64   `json:"temperature"`
-	FeelsLike     int64   `json:"feels_like"`
-	Precipitation float64 `json:"precipitation"`
-	Wind          float64 `json:"wind"`
-	WindGust      float64 `json:"wind_gust"`
-	WindDirection string  `json:"wind_direction"`
-	Humidity      int64   `json:"humidity"`
-	CloudCover    int64   `json:"cloud_cover"`
-	WeatherText   string  `json:"weather_text"`
-	WeatherIcon   string  `json:"weather_icon"`
-	WeatherLink   string  `json:"weather_link"`
+	ID            int64     `json:"id"`
+	GameID        int64     `json:"game_id"`
+	Observed      bool      `json:"observed"`
+	Date          time.Time `json:"date"`
+	Temperature   int64     `json:"temperature"`
+	FeelsLike     int64     `json:"feels_like"`
+	Precipitation float64   `json:"precipitation"`
+	ChanceOfRain  int64     `json:"chance_of_rain"`
+	Wind          float64   `json:"wind"`
+	WindGust      float64   `json:"wind_gust"`
+	WindDirection string    `json:"wind_direction"`
+	Humidity      int64     `json:"humidity"`
+	CloudCover    int64     `json:"cloud_cover"`
+	WeatherText   string    `json:"weather_text"`
+	WeatherIcon   string    `json:"weather_icon"`
+	WeatherLink   string    `json:"weather_link"`
 }
 
 type WeatherHours []Weather
 
-func AddWeather() (WeatherHours, error) {
-	w := make(WeatherHours, 0)
-	hours := [4]int64{12, 13, 14, 15}
+// Limits are how bad a forecast can get before play is at risk: the chance of
+// rain in percent, rain in inches an hour, and wind and gusts in mph.  A zero
+// limit isn't checked.
+type Limits struct {
+	ChanceOfRain  int64
+	Precipitation float64
+	Wind          float64
+	WindGust      float64
+}
 
-	for i, h := range hours {
-		var wh Weather
-		var wa weatherapihour
-		err := wa.getWeatherAPI(h)
-		if err != nil {
-			return nil, err
+// Risks returns what, if anything, in the forecast goes over l, each with the
+// worst of it.
+func (w WeatherHours) Risks(l Limits) []string {
+	var chance int64
+	var precip, wind, gust float64
+	for _, h := range w {
+		if h.ChanceOfRain > chance {
+			chance = h.ChanceOfRain
 		}
-
-		wh.Date = wa.Forecast.Forecastday[0].Hour[0].Time
-		wh.Temperature = int64(math.Round(wa.Forecast.Forecastday[0].Hour[0].TempF))
-		wh.FeelsLike = int64(math.Round(wa.Forecast.Forecastday[0].Hour[0].FeelslikeF))
-		wh.Precipitation = float64(wa.Forecast.Forecastday[0].Hour[0].PrecipIn)
-		wh.Wind = wa.Forecast.Forecastday[0].Hour[0].WindMph
-		wh.WindGust = wa.Forecast.Forecastday[0].Hour[0].GustMph
-		wh.WindDirection = wa.Forecast.Forecastday[0].Hour[0].WindDir
-		wh.Humidity = int64(wa.Forecast.Forecastday[0].Hour[0].Humidity)
-		wh.CloudCover = int64(wa.Forecast.Forecastday[0].Hour[0].Cloud)
-		wh.WeatherText = wa.Forecast.Forecastday[0].Hour[0].Condition.Text
-		u, err := url.Parse("https:" + wa.Forecast.Forecastday[0].Hour[0].Condition.Icon)
-		if err != nil {
-			return nil, err
+		if h.Precipitation > precip {
+			precip = h.Precipitation
 		}
-		wh.WeatherIcon = "static/img" + u.Path
-		wh.WeatherLink = "https://weather.com/"
-
-		w = append(w, wh)
-
-		query := fmt.Sprintf("INSERT INTO weather VALUES (NULL, \"%s\", %d, %d, %.2f, %.1f, %.1f, \"%s\", %d, %d, \"%s\", \"%s\", \"%s\")",
-			w[i].Date,
-			w[i].Temperature,
-			w[i].FeelsLike,
-			w[i].Precipitation,
-			w[i].Wind,
-			w[i].WindGust,
-			w[i].WindDirection,
-			w[i].Humidity,
-			w[i].CloudCover,
-			w[i].WeatherText,
-			w[i].WeatherIcon,
-			w[i].WeatherLink)
-
-		ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelfunc()
-		res, err := db.Con.ExecContext(ctx, query)
-		if err != nil {
-			return nil, err
+		if h.Wind > wind {
+			wind = h.Wind
 		}
-
-		w[i].ID, err = res.LastInsertId()
-		if err != nil {
-			return nil, err
+		if h.WindGust > gust {
+			gust = h.WindGust
 		}
+	}
 
-		log.Info().Msgf("weather ID %d inserted", w[i].ID)
+	rs := make([]string, 0)
+	if l.ChanceOfRain != 0 && chance >= l.ChanceOfRain {
+		rs = append(rs, fmt.Sprintf("%d%% chance of rain", chance))
+	}
+	if l.Precipitation != 0 && precip >= l.Precipitation {
+		rs = append(rs, fmt.Sprintf("%.2f in of rain an hour", precip))
+	}
+	if l.Wind != 0 && wind >= l.Wind {
+		rs = append(rs, fmt.Sprintf("winds of %.0f mph", wind))
+	}
+	if l.WindGust != 0 && gust >= l.WindGust {
+		rs = append(rs, fmt.Sprintf("gusts of %.0f mph", gust))
 	}
 
-	return w, nil
+	return rs
 }
 
-func (w *Weather) GetWeatherByID() error {
-	query := fmt.Sprintf(
-		"SELECT "+
-			"idweather, "+
-			"weather_date, "+
-			"temperature, "+
-			"feels_like, "+
-			"precipitation, "+
-			"wind, "+
-			"wind_gust, "+
-			"wind_direction, "+
-			"humidity, "+
-			"cloudcover, "+
-			"weather_text, "+
-			"weather_icon "+
-			"FROM weather WHERE idweather=%d",
-		w.ID)
-
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	err := db.Con.QueryRowContext(ctx, query).Scan(
-		&w.ID,
-		&w.Date,
-		&w.Temperature,
-		&w.FeelsLike,
-		&w.Precipitation,
-		&w.Wind,
-		&w.WindGust,
-		&w.WindDirection,
-		&w.Humidity,
-		&w.CloudCover,
-		&w.WeatherText,
-		&w.WeatherIcon)
-	if err != nil {
-		return err
+// Reasons a game's forecast is fetched.
+const (
+	ReasonAdded     = "added"
+	ReasonScheduled = "scheduled"
+	ReasonTeeTime   = "tee time"
+	ReasonRequested = "requested"
+)
+
+// Revision is one fetch of a game's forecast, as it came back.
+type Revision struct {
+	ID     int64        `json:"id"`
+	GameID int64        `json:"game_id"`
+	Date   time.Time    `json:"date"`
+	Reason string       `json:"reason"`
+	Hours  WeatherHours `json:"hours"`
+}
+
+type Revisions []Revision
+
+// Window returns the hours, in the league's timezone, that play starting at
+// tee and lasting d is on the course for.
+func Window(tee time.Time, d time.Duration) []int {
+	hours := make([]int, 0)
+	end := tee.Add(d)
+	for h := db.Local(tee).Hour(); hourOf(tee, h).Before(end); h++ {
+		hours = append(hours, h)
 	}
 
-	return nil
+	return hours
 }
 
-func GetWeatherByDate(d string) (WeatherHours, error) {
-	ws := make(WeatherHours, 0)
-	t, err := time.Parse("2006-01-02", d)
+// Fetch asks the Provider, once, for the forecast for play starting at tee
+// and lasting d.
+func Fetch(tee time.Time, d time.Duration) (WeatherHours, error) {
+	p, err := GetProvider()
 	if err != nil {
 		return nil, err
 	}
 
-	s := fmt.Sprintf("%d-%02d-%02d 00:00", t.Year(), t.Month(), t.Day())
-	f := fmt.Sprintf("%d-%02d-%02d 23:59", t.Year(), t.Month(), t.Day())
-	query := fmt.Sprintf(
-		"SELECT "+
-			"idweather, "+
-			"weather_date, "+
-			"temperature, "+
-			"feels_like, "+
-			"precipitation, "+
-			"wind, "+
-			"wind_gust, "+
-			"wind_direction, "+
-			"humidity, "+
-			"cloudcover, "+
-			"weather_text, "+
-			"weather_icon "+
-			"FROM weather WHERE "+
-			"UNIXEPOCH(weather_date) BETWEEN UNIXEPOCH('%s') AND UNIXEPOCH('%s')",
-		s, f)
-
-	fmt.Println(query)
-
-	ctx, cancelfunc := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelfunc()
-	rows, err := db.Con.QueryContext(ctx, query)
+	return p.Forecast(tee, Window(tee, d))
+}
+
+// FetchObserved asks the Provider, once, for the weather observed during play
+// that started at tee and lasted d.
+func FetchObserved(tee time.Time, d time.Duration) (WeatherHours, error) {
+	p, err := GetProvider()
 	if err != nil {
 		return nil, err
 	}
 
-	for rows.Next() {
-		var w Weather
-		err = db.Con.QueryRowContext(ctx, query).Scan(
-			&w.ID,
-			&w.Date,
-			&w.Temperature,
-			&w.FeelsLike,
-			&w.Precipitation,
-			&w.Wind,
-			&w.WindGust,
-			&w.WindDirection,
-			&w.Humidity,
-			&w.CloudCover)
-		if err != nil {
-			return nil, err
-		}
+	return p.History(tee, Window(tee, d))
+}
 
-		ws = append(ws, w)
-	}
+// GetGameWeather loads game gid's forecast.
+func GetGameWeather(ctx context.Context, gid int64) (WeatherHours, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
 
-	return ws, nil
+	return getStore().GetGameWeather(ctx, gid)
 }
 
-/* func (aw *accuWeather) getAccuWeather() error {
-	resp, err := http.Get("http://dataservice.accuweather.com/currentconditions/v1/332128?apikey=put8mfXawbPRMEXpDunTjZrKWJCw4AeE&details=true")
+// SetGameWeather saves w as game gid's forecast, updating the hours it
+// already has rather than adding them again, and keeps it as a revision
+// fetched for reason.
+func SetGameWeather(ctx context.Context, gid int64, w WeatherHours, reason string) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err := getStore().SetGameWeather(ctx, gid, w, reason)
 	if err != nil {
 		return err
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
+	log.Info().Msgf("game %d has %d hours of weather (%s)", gid, len(w), reason)
 
-	fmt.Printf("%s\n", body)
+	return nil
+}
+
+// GetGameObserved loads the weather observed during game gid.
+func GetGameObserved(ctx context.Context, gid int64) (WeatherHours, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
 
-	err = json.Unmarshal([]byte(body), aw)
+	return getStore().GetGameObserved(ctx, gid)
+}
+
+// SetGameObserved saves w as the weather observed during game gid, replacing
+// any it had.
+func SetGameObserved(ctx context.Context, gid int64, w WeatherHours) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	err := getStore().SetGameObserved(ctx, gid, w)
 	if err != nil {
 		return err
 	}
 
+	log.Info().Msgf("game %d has %d hours of observed weather", gid, len(w))
+
 	return nil
-} */
+}
 
-func (wa *weatherapihour) getWeatherAPI(h int64) error {
-	url := fmt.Sprintf("http://api.weatherapi.com/v1/forecast.json?days=1&key=a59ece49937045878a8175453230605&q=37.57,-122.28&hour=%d", h)
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
+// GetRevisions loads every fetch of game gid's forecast, newest first.
+func GetRevisions(ctx context.Context, gid int64) (Revisions, error) {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
+	return getStore().GetRevisions(ctx, gid)
+}
 
-	err = json.Unmarshal([]byte(body), wa)
+func (w *Weather) GetWeatherByID(ctx context.Context) error {
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+	nw, err := getStore().GetWeather(ctx, w.ID)
 	if err != nil {
 		return err
 	}
+	*w = nw
 
 	return nil
 }
+
+// GetWeatherByDate loads the forecasts for the league's day that t falls on.
+func GetWeatherByDate(ctx context.Context, t time.Time) (WeatherHours, error) {
+	s, f := db.Day(t)
+
+	ctx, cancelfunc := db.WithTimeout(ctx)
+	defer cancelfunc()
+
+	return getStore().GetWeatherBetween(ctx, s, f)
+}
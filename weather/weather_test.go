@@ -0,0 +1,58 @@
+package weather
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRisks(t *testing.T) {
+	l := Limits{ChanceOfRain: 60, Precipitation: 0.1, Wind: 20, WindGust: 30}
+	calm := Weather{ChanceOfRain: 10, Precipitation: 0, Wind: 5, WindGust: 8}
+
+	tests := []struct {
+		name  string
+		hours WeatherHours
+		l     Limits
+		want  []string
+	}{
+		{"no forecast", nil, l, []string{}},
+		{"an empty forecast", WeatherHours{}, l, []string{}},
+		{"a calm forecast", WeatherHours{calm, calm}, l, []string{}},
+		{"chance of rain", WeatherHours{calm, {ChanceOfRain: 60}}, l, []string{"60% chance of rain"}},
+		{"just under the chance of rain", WeatherHours{{ChanceOfRain: 59}}, l, []string{}},
+		{"rain", WeatherHours{{Precipitation: 0.14}, calm}, l, []string{"0.14 in of rain an hour"}},
+		{"just under the rain", WeatherHours{{Precipitation: 0.09}}, l, []string{}},
+		{"wind", WeatherHours{calm, {Wind: 22.4}}, l, []string{"winds of 22 mph"}},
+		{"just under the wind", WeatherHours{{Wind: 19.9}}, l, []string{}},
+		{"gusts", WeatherHours{{WindGust: 30}}, l, []string{"gusts of 30 mph"}},
+		{"just under the gusts", WeatherHours{{WindGust: 29.9}}, l, []string{}},
+		{"the worst hour", WeatherHours{{Wind: 21}, {Wind: 35}, {Wind: 25}}, l, []string{"winds of 35 mph"}},
+		{
+			"everything, worst of each from different hours",
+			WeatherHours{{ChanceOfRain: 90, Wind: 25}, {Precipitation: 0.3, WindGust: 40}},
+			l,
+			[]string{"90% chance of rain", "0.30 in of rain an hour", "winds of 25 mph", "gusts of 40 mph"},
+		},
+		{
+			"limits of zero aren't checked",
+			WeatherHours{{ChanceOfRain: 100, Precipitation: 1, Wind: 50, WindGust: 70}},
+			Limits{},
+			[]string{},
+		},
+		{
+			"only the limits set are checked",
+			WeatherHours{{ChanceOfRain: 100, Precipitation: 1, Wind: 50, WindGust: 70}},
+			Limits{WindGust: 30},
+			[]string{"gusts of 70 mph"},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.hours.Risks(tt.l)
+		if got == nil {
+			t.Errorf("%s: Risks is nil, want a list", tt.name)
+		}
+		if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+			t.Errorf("%s: Risks = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
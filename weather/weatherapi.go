@@ -0,0 +1,160 @@
+package weather
+
+import (
+	"fmt"
+	"mariners/db"
+	"math"
+	"net/url"
+	"time"
+)
+
+// weatherapiForecast is the part of a weatherapi.com forecast.json response
+// we use.  history.json responses come in the same shape.
+type weatherapiForecast struct {
+	Current  weatherapiHour `json:"current"`
+	Forecast struct {
+		Forecastday []struct {
+			Date string           `json:"date"`
+			Hour []weatherapiHour `json:"hour"`
+		} `json:"forecastday"`
+	} `json:"forecast"`
+}
+
+// weatherapiHour is one hour of a weatherapi.com forecast.  The current
+// conditions come in the same shape, stamped with LastUpdatedEpoch instead of
+// TimeEpoch, and without a chance of rain.
+type weatherapiHour struct {
+	TimeEpoch        int64   `json:"time_epoch"`
+	LastUpdatedEpoch int64   `json:"last_updated_epoch"`
+	TempF            float64 `json:"temp_f"`
+	Condition        struct {
+		Text string `json:"text"`
+		Icon string `json:"icon"`
+		Code int    `json:"code"`
+	} `json:"condition"`
+	WindMph      float64 `json:"wind_mph"`
+	WindDir      string  `json:"wind_dir"`
+	PrecipIn     float64 `json:"precip_in"`
+	ChanceOfRain int64   `json:"chance_of_rain"`
+	Humidity     float64 `json:"humidity"`
+	Cloud        int     `json:"cloud"`
+	FeelslikeF   float64 `json:"feelslike_f"`
+	GustMph      float64 `json:"gust_mph"`
+}
+
+// time is when the hour starts, or when current conditions were taken.
+func (h weatherapiHour) time() time.Time {
+	if h.TimeEpoch == 0 {
+		return time.Unix(h.LastUpdatedEpoch, 0).UTC()
+	}
+
+	return time.Unix(h.TimeEpoch, 0).UTC()
+}
+
+func (h weatherapiHour) weather() (Weather, error) {
+	w := Weather{
+		Date:          h.time(),
+		Temperature:   int64(math.Round(h.TempF)),
+		FeelsLike:     int64(math.Round(h.FeelslikeF)),
+		Precipitation: h.PrecipIn,
+		ChanceOfRain:  h.ChanceOfRain,
+		Wind:          h.WindMph,
+		WindGust:      h.GustMph,
+		WindDirection: h.WindDir,
+		Humidity:      int64(h.Humidity),
+		CloudCover:    int64(h.Cloud),
+		WeatherText:   h.Condition.Text,
+		WeatherLink:   "https://weather.com/",
+	}
+
+	// The icons are served from static/img, under the same path as on
+	// weatherapi.com's CDN.
+	u, err := url.Parse("https:" + h.Condition.Icon)
+	if err != nil {
+		return w, err
+	}
+	w.WeatherIcon = "static/img" + u.Path
+
+	return w, nil
+}
+
+// hours picks hours, in the league's timezone, on the league's day that day
+// falls on out of the response, with missing for an hour it doesn't have.
+func (wa weatherapiForecast) hours(day time.Time, hours []int, missing error) (WeatherHours, error) {
+	byTime := make(map[time.Time]weatherapiHour)
+	for _, fd := range wa.Forecast.Forecastday {
+		for _, h := range fd.Hour {
+			byTime[h.time()] = h
+		}
+	}
+
+	w := make(WeatherHours, 0, len(hours))
+	for _, h := range hours {
+		t := hourOf(day, h)
+		wh, ok := byTime[t]
+		if !ok {
+			return nil, fmt.Errorf("weatherapi: %w at %s", missing, t.Format(time.RFC3339))
+		}
+		wt, err := wh.weather()
+		if err != nil {
+			return nil, err
+		}
+		w = append(w, wt)
+	}
+
+	return w, nil
+}
+
+// WeatherAPIProvider gets forecasts from weatherapi.com.
+type WeatherAPIProvider struct {
+	Key string
+	// Location is the course as latitude,longitude.
+	Location string
+	// URL is forecast.json's address, and HistoryURL history.json's,
+	// there to be pointed elsewhere.
+	URL        string
+	HistoryURL string
+}
+
+func NewWeatherAPIProvider(key, location string) *WeatherAPIProvider {
+	return &WeatherAPIProvider{
+		Key:        key,
+		Location:   location,
+		URL:        "https://api.weatherapi.com/v1/forecast.json",
+		HistoryURL: "https://api.weatherapi.com/v1/history.json",
+	}
+}
+
+// Forecast fetches the whole day at once and picks out hours.
+func (p *WeatherAPIProvider) Forecast(day time.Time, hours []int) (WeatherHours, error) {
+	q := url.Values{}
+	q.Set("key", p.Key)
+	q.Set("q", p.Location)
+	q.Set("days", "1")
+	q.Set("dt", db.Local(day).Format("2006-01-02"))
+
+	var wa weatherapiForecast
+	err := getJSON(p.URL+"?"+q.Encode(), nil, &wa)
+	if err != nil {
+		return nil, err
+	}
+
+	return wa.hours(day, hours, ErrNoForecast)
+}
+
+// History fetches the whole day at once, as it was, and picks out hours.  How
+// far back it can go depends on the key's plan.
+func (p *WeatherAPIProvider) History(day time.Time, hours []int) (WeatherHours, error) {
+	q := url.Values{}
+	q.Set("key", p.Key)
+	q.Set("q", p.Location)
+	q.Set("dt", db.Local(day).Format("2006-01-02"))
+
+	var wa weatherapiForecast
+	err := getJSON(p.HistoryURL+"?"+q.Encode(), nil, &wa)
+	if err != nil {
+		return nil, err
+	}
+
+	return wa.hours(day, hours, ErrNoObservation)
+}